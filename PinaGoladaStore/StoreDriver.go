@@ -13,6 +13,7 @@ import (
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
 	"github.com/PES-Innovation-Lab/willow-go/types"
 	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
 )
 
 func InitStorage(nameSpaceId types.NamespaceId) *store.Store[string, string, uint, []byte, string] {
@@ -22,19 +23,41 @@ func InitStorage(nameSpaceId types.NamespaceId) *store.Store[string, string, uin
 		log.Fatal(err)
 	}
 
-	payloadRefKVstore := kv_driver.KvDriver[uint]{Db: payloadRefDb}
-	PayloadReferenceCounter := payloadDriver.PayloadReferenceCounter[uint]{
-		Store: payloadRefKVstore,
+	entryDb, err := pebble.Open(fmt.Sprintf("willow/%s/entries", string(nameSpaceId)), &pebble.Options{})
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	entryDb, err := pebble.Open(fmt.Sprintf("willow/%s/entries", string(nameSpaceId)), &pebble.Options{})
+	return makeStorage(nameSpaceId, payloadRefDb, entryDb, fmt.Sprintf("willow/%s/payload", string(nameSpaceId)))
+}
+
+// Keeps the entries of a store in memory, so stores can be thrown away after a test. Payloads are still kept in payloadDir.
+func InitMemoryStorage(nameSpaceId types.NamespaceId, payloadDir string) *store.Store[string, string, uint, []byte, string] {
+
+	payloadRefDb, err := pebble.Open("payloadrefcounter", &pebble.Options{FS: vfs.NewMem()})
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	entryDb, err := pebble.Open("entries", &pebble.Options{FS: vfs.NewMem()})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return makeStorage(nameSpaceId, payloadRefDb, entryDb, payloadDir)
+}
+
+func makeStorage(nameSpaceId types.NamespaceId, payloadRefDb, entryDb *pebble.DB, payloadDir string) *store.Store[string, string, uint, []byte, string] {
+
+	payloadRefKVstore := kv_driver.KvDriver[uint]{Db: payloadRefDb}
+	PayloadReferenceCounter := payloadDriver.PayloadReferenceCounter[uint]{
+		Store: payloadRefKVstore,
+	}
+
 	entryKvStore := kv_driver.KvDriver[uint]{Db: entryDb}
 
 	PayloadLock := &sync.Mutex{}
-	TestPayloadDriver := payloadDriver.MakePayloadDriver(fmt.Sprintf("willow/%s/payload", string(nameSpaceId)), TestPayloadScheme, PayloadLock)
+	TestPayloadDriver := payloadDriver.MakePayloadDriver(payloadDir, TestPayloadScheme, PayloadLock)
 
 	entryDriver := entrydriver.EntryDriver[string, string, uint]{
 		PayloadReferenceCounter: PayloadReferenceCounter,
@@ -61,8 +84,9 @@ func InitStorage(nameSpaceId types.NamespaceId) *store.Store[string, string, uin
 		EntryDriver:        entryDriver,
 		PayloadDriver:      TestPayloadDriver,
 		NameSpaceId:        nameSpaceId,
-		IngestionMutexLock: sync.Mutex{},
+		IngestionMutexLock: &sync.Mutex{},
 		PrefixDriver:       TestPrefixDriver,
+		Listeners:          store.NewIngestionListeners[string](),
 	}
 }
 
@@ -78,10 +102,20 @@ func InitKDTree(WillowStore *store.Store[string, string, uint, []byte, string])
 			log.Fatal(err)
 		}
 		keys = append(keys, kdnode.Key{
-			Subspace:    sub,
-			Timestamp:   time,
-			Path:        path,
-			Fingerprint: string(decodedValue.AuthDigest),
+			Subspace:  sub,
+			Timestamp: time,
+			Path:      path,
+			Fingerprint: string(WillowStore.Schemes.FingerprintScheme.FingerPrintSingleton(datamodeltypes.LengthyEntry{
+				Entry: types.Entry{
+					Namespace_id:   WillowStore.NameSpaceId,
+					Subspace_id:    sub,
+					Path:           path,
+					Timestamp:      time,
+					Payload_digest: decodedValue.PayloadDigest,
+					Payload_length: decodedValue.PayloadLength,
+				},
+				Available: WillowStore.HeldPayload(decodedValue.PayloadDigest),
+			})),
 		})
 	}
 	WillowStore.EntryDriver.Storage = WillowStore.EntryDriver.MakeStorage([]byte(WillowStore.NameSpaceId), keys)
@@ -7,26 +7,17 @@ import (
 	"strings"
 
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/pai"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
 	"github.com/PES-Innovation-Lab/willow-go/utils"
 )
 
 // Test schemes defined!
-var NameSpaceEncoding utils.EncodingScheme[types.NamespaceId] = utils.EncodingScheme[types.NamespaceId]{
-	Encode: func(id types.NamespaceId) []byte {
-		return []byte(id)
-	},
-	Decode: func(bytes []byte) (types.NamespaceId, error) {
-		return types.NamespaceId(bytes), nil
-	},
-	EncodedLength: func(id types.NamespaceId) uint64 {
-		return uint64(len(id))
-	},
-	// DecodeStream: func(value *utils.GrowingBytes) (types.NamespaceId, error) {
-	// 	bytes := value.NextAbsolute(1)
-	// 	return types.NamespaceId(bytes), nil
-	// },
-}
+// Namespace and subspace ids are sent over the wire, so they need to be self delimiting
+var NameSpaceEncoding utils.EncodingScheme[types.NamespaceId] = utils.LengthPrefixedEncoding[types.NamespaceId]()
+
 var TestNameSpaceScheme datamodeltypes.NamespaceScheme = datamodeltypes.NamespaceScheme{
 	EncodingScheme: NameSpaceEncoding,
 	IsEqual: func(a types.NamespaceId, b types.NamespaceId) bool {
@@ -35,21 +26,7 @@ var TestNameSpaceScheme datamodeltypes.NamespaceScheme = datamodeltypes.Namespac
 	DefaultNamespaceId: types.NamespaceId(""),
 }
 
-var SubspaceEncoding utils.EncodingScheme[types.SubspaceId] = utils.EncodingScheme[types.SubspaceId]{
-	Encode: func(id types.SubspaceId) []byte {
-		return []byte(id)
-	},
-	Decode: func(bytes []byte) (types.SubspaceId, error) {
-		return types.SubspaceId(bytes), nil
-	},
-	EncodedLength: func(id types.SubspaceId) uint64 {
-		return uint64(len(id))
-	},
-	// DecodeStream: func(value *utils.GrowingBytes) (types.SubspaceId, error) {
-	// 	bytes := value.NextAbsolute(1)
-	// 	return types.SubspaceId(bytes), nil
-	// },
-}
+var SubspaceEncoding utils.EncodingScheme[types.SubspaceId] = utils.LengthPrefixedEncoding[types.SubspaceId]()
 
 var TestSubspaceScheme datamodeltypes.SubspaceScheme = datamodeltypes.SubspaceScheme{
 	EncodingScheme:      SubspaceEncoding,
@@ -57,14 +34,7 @@ var TestSubspaceScheme datamodeltypes.SubspaceScheme = datamodeltypes.SubspaceSc
 	Order:               utils.OrderSubspace,
 	MinimalSubspaceId:   types.SubspaceId(""),
 }
-var TestFingerprintScheme datamodeltypes.FingerprintScheme[string, string] = datamodeltypes.FingerprintScheme[string, string]{
-	FingerPrintFinalise: func(fingerprint string) string {
-		return fingerprint
-	},
-	IsEqual: func(a string, b string) bool {
-		return strings.Compare(a, b) == 0
-	},
-}
+var TestFingerprintScheme datamodeltypes.FingerprintScheme[string, string] = store.DefaultFingerprintScheme
 
 var TestAuthorisationScheme datamodeltypes.AuthorisationScheme[[]byte, string] = datamodeltypes.AuthorisationScheme[[]byte, string]{
 	Authorise: func(entry types.Entry, opts []byte) (string, error) {
@@ -94,6 +64,109 @@ var TestAuthorisationScheme datamodeltypes.AuthorisationScheme[[]byte, string] =
 	},
 }
 
+// The authorisation tokens are subspace ids, which are entirely static
+var TestAuthorisationTokenScheme wgpstypes.AuthorisationTokenScheme[string, string, string] = wgpstypes.AuthorisationTokenScheme[string, string, string]{
+	RecomposeAuthToken: func(staticToken string, dynamicToken string) string {
+		return staticToken + dynamicToken
+	},
+	DecomposeAuthToken: func(authToken string) (string, string) {
+		return authToken, ""
+	},
+	Encodings: struct {
+		StaticToken  utils.EncodingScheme[string]
+		DynamicToken utils.EncodingScheme[string]
+	}{
+		StaticToken:  utils.LengthPrefixedEncoding[string](),
+		DynamicToken: utils.LengthPrefixedEncoding[string](),
+	},
+}
+
+/*
+The test read and subspace capabilities are the ids of the namespaces they grant reading entirely, and the receiver
+of a capability is the subspace of the same name. Signatures are hashes over the receiver, which anyone can compute,
+so these schemes are only fit for testing.
+*/
+var TestSignatureScheme types.SignatureScheme[types.SubspaceId, string, string] = types.SignatureScheme[types.SubspaceId, string, string]{
+	Sign: func(publicKey types.SubspaceId, secretKey string, bytestring []byte) string {
+		hash := sha256.Sum256(append([]byte(secretKey), bytestring...))
+		return hex.EncodeToString(hash[:])
+	},
+	Verify: func(publicKey types.SubspaceId, signature string, bytestring []byte) bool {
+		hash := sha256.Sum256(append([]byte(publicKey), bytestring...))
+		return signature == hex.EncodeToString(hash[:])
+	},
+}
+
+var testCapabilityEncoding utils.EncodingScheme[string] = utils.LengthPrefixedEncoding[string]()
+
+var TestAccessControlScheme wgpstypes.AccessControlScheme[string, string, types.SubspaceId, string, uint] = wgpstypes.AccessControlScheme[string, string, types.SubspaceId, string, uint]{
+	GetReceiver: func(cap string) types.SubspaceId {
+		return types.SubspaceId(cap)
+	},
+	GetSecretKey: func(receiver types.SubspaceId) string {
+		return string(receiver)
+	},
+	GetGrantedArea: func(cap string) types.Area {
+		return utils.FullArea()
+	},
+	GetGrantedNamespace: func(cap string) types.NamespaceId {
+		return types.NamespaceId(cap)
+	},
+	Signatures: TestSignatureScheme,
+	IsValidCap: func(cap string) bool {
+		return cap != ""
+	},
+	Encodings: struct {
+		ReadCap       wgpstypes.ReadCapEncodingScheme[string, uint]
+		SyncSignature utils.EncodingScheme[string]
+	}{
+		ReadCap: wgpstypes.ReadCapEncodingScheme[string, uint]{
+			PrivyEncodingScheme: utils.PrivyEncodingScheme[string, wgpstypes.ReadCapPrivy, uint]{
+				Encode: func(cap string, privy wgpstypes.ReadCapPrivy) []byte {
+					return testCapabilityEncoding.Encode(cap)
+				},
+				Decode: func(encoded []byte, privy wgpstypes.ReadCapPrivy) (string, error) {
+					return testCapabilityEncoding.Decode(encoded)
+				},
+				EncodedLength: func(cap string, privy wgpstypes.ReadCapPrivy) uint {
+					return uint(testCapabilityEncoding.EncodedLength(cap))
+				},
+				DecodeStream: func(bytes *utils.GrowingBytes) (string, error) {
+					cap, ok := <-testCapabilityEncoding.DecodeStream(bytes)
+					if !ok {
+						return "", fmt.Errorf("not enough bytes to decode a read capability")
+					}
+					return cap, nil
+				},
+			},
+		},
+		SyncSignature: utils.LengthPrefixedEncoding[string](),
+	},
+}
+
+var TestSubspaceCapScheme wgpstypes.SubspaceCapScheme[types.SubspaceId, string, string, string, uint] = wgpstypes.SubspaceCapScheme[types.SubspaceId, string, string, string, uint]{
+	GetSecretKey: func(receiver types.SubspaceId) string {
+		return string(receiver)
+	},
+	GetNamespace: func(cap string) types.NamespaceId {
+		return types.NamespaceId(cap)
+	},
+	GetReceiver: func(cap string) types.SubspaceId {
+		return types.SubspaceId(cap)
+	},
+	IsValidCap: func(cap string) bool {
+		return cap != ""
+	},
+	Signatures: TestSignatureScheme,
+	Encodings: struct {
+		SubspaceCapability    utils.EncodingScheme[string]
+		SyncSubspaceSignature utils.EncodingScheme[string]
+	}{
+		SubspaceCapability:    utils.LengthPrefixedEncoding[string](),
+		SyncSubspaceSignature: utils.LengthPrefixedEncoding[string](),
+	},
+}
+
 var TestPathParams types.PathParams[uint] = types.PathParams[uint]{
 	MaxComponentCount:  50,
 	MaxComponentLength: 50,
@@ -109,6 +182,29 @@ var TestPayloadScheme datamodeltypes.PayloadScheme = datamodeltypes.PayloadSchem
 			}
 			return decoded
 		},
+		Decode: func(encoded []byte) (types.PayloadDigest, error) {
+			if len(encoded) < sha256.Size {
+				return "", fmt.Errorf("not enough bytes to decode a payload digest")
+			}
+			return types.PayloadDigest(hex.EncodeToString(encoded[:sha256.Size])), nil
+		},
+		EncodedLength: func(value types.PayloadDigest) uint64 {
+			return sha256.Size
+		},
+		DecodeStream: func(value *utils.GrowingBytes) chan types.PayloadDigest {
+			ch := make(chan types.PayloadDigest, 1)
+			go func() {
+				defer close(ch)
+				bytes := value.NextAbsolute(sha256.Size)
+				if len(bytes) < sha256.Size {
+					return
+				}
+				digest := types.PayloadDigest(hex.EncodeToString(bytes[:sha256.Size]))
+				value.Prune(sha256.Size)
+				ch <- digest
+			}()
+			return ch
+		},
 	},
 	FromBytes: func(bytes []byte) chan types.PayloadDigest {
 		ch := make(chan types.PayloadDigest, 1)
@@ -120,10 +216,22 @@ var TestPayloadScheme datamodeltypes.PayloadScheme = datamodeltypes.PayloadSchem
 		return ch
 	},
 }
+
+// Private area intersection over the test read capabilities, each of which is the fragment of its whole namespace
+var TestPaiScheme wgpstypes.PaiScheme[string, pai.X25519Group, pai.X25519Scalar, uint] = pai.NewX25519PaiScheme(pai.X25519PaiSchemeOpts[string, uint]{
+	NamespaceScheme: TestNameSpaceScheme,
+	SubspaceScheme:  TestSubspaceScheme,
+	PathParams:      TestPathParams,
+	GetFragmentKit: func(cap string) wgpstypes.FragmentKit {
+		return wgpstypes.FragmentKitComplete{GrantedNamespace: types.NamespaceId(cap)}
+	},
+})
+
 var StoreSchemes datamodeltypes.StoreSchemes[string, string, uint, []byte, string] = datamodeltypes.StoreSchemes[string, string, uint, []byte, string]{
 	PathParams:          TestPathParams,
 	NamespaceScheme:     TestNameSpaceScheme,
 	AuthorisationScheme: TestAuthorisationScheme,
 	SubspaceScheme:      TestSubspaceScheme,
 	PayloadScheme:       TestPayloadScheme,
+	FingerprintScheme:   TestFingerprintScheme,
 }
@@ -14,6 +14,8 @@ import (
 
 type KDTreeStorage[PreFingerPrint, FingerPrint string, K constraints.Unsigned] struct {
 	KDTree *kdtree.KDTree[kdnode.Key]
+	// Same keys as the KD tree, with the fingerprints and sizes of subtrees cached for summarising ranges
+	Summaries *kdnode.SummaryTree
 
 	Opts struct {
 		Namespace         types.NamespaceId
@@ -68,15 +70,19 @@ func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) Get(Subspace types.Subsp
 	}
 }
 
-func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) Insert(Subspace types.SubspaceId, Path types.Path, Timestamp uint64) error {
+func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) Insert(Subspace types.SubspaceId, Path types.Path, Timestamp uint64, Fingerprint PreFingerPrint) error {
 	newVal := kdnode.Key{
-		Subspace:  Subspace,
-		Path:      Path,
-		Timestamp: Timestamp,
+		Subspace:    Subspace,
+		Path:        Path,
+		Timestamp:   Timestamp,
+		Fingerprint: string(Fingerprint),
 	}
 	if !k.KDTree.Add(newVal) {
 		return errors.New("error inserting the node into the KD tree")
 	}
+	if !k.Summaries.Insert(newVal) {
+		return errors.New("error inserting the node into the summary tree")
+	}
 	return nil
 }
 
@@ -92,7 +98,10 @@ func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) Remove(entry types.Posit
 		Path:      entry.Path,
 	}
 
-	return k.KDTree.Delete(NodeToDelete)
+	if !k.KDTree.Delete(NodeToDelete) {
+		return false
+	}
+	return k.Summaries.Remove(NodeToDelete)
 }
 
 // TODO :- Not Fullproof, check triplestorage.ts implementation for further additions
@@ -109,75 +118,138 @@ func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) GetInterestRange(areaOfI
 	return newRange
 }
 
+/*
+Summarises the entries in a range, every node stores the fingerprint singleton of its entry
+(computed on insertion), and the summary tree caches these combined for every subtree.
+The returned fingerprint is not finalised yet.
+*/
 func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) Summarise(Range types.Range3d) struct {
-	FingerPrint string
+	FingerPrint PreFingerPrint
 	Size        uint64
 } {
-	var size uint64
-	var fingerPrint string
-	valuesInRange := k.Query(Range)
-	for _, val := range valuesInRange {
-		size += 1
-		fingerPrint = xorStrings(fingerPrint, val.Fingerprint)
-	}
+	fingerPrint, size := k.Summaries.Summarise(Range)
 	return struct {
-		FingerPrint string
+		FingerPrint PreFingerPrint
 		Size        uint64
 	}{
-		FingerPrint: fingerPrint,
+		FingerPrint: PreFingerPrint(fingerPrint),
 		Size:        size,
 	}
 }
 
-/* Used for splitting a 3dRange*/
-func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) SplitRange(Range types.Range3d, size int) (types.Range3d, types.Range3d) {
-	entries := k.Query(Range)
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Timestamp < entries[j].Timestamp
-	})
-	//find median
-	mid := len(entries) / 2
-	median := entries[mid].Timestamp
-
-	//split the range
-	leftRange := types.Range3d{
-		SubspaceRange: Range.SubspaceRange,
-		PathRange:     Range.PathRange,
-		TimeRange: types.Range[uint64]{
-			Start:   Range.TimeRange.Start,
-			End:     median,
-			OpenEnd: false,
-		},
-	}
-	rightRange := types.Range3d{
-		SubspaceRange: Range.SubspaceRange,
-		PathRange:     Range.PathRange,
-		TimeRange: types.Range[uint64]{
-			Start:   median,
-			End:     Range.TimeRange.End,
-			OpenEnd: Range.TimeRange.OpenEnd,
-		},
-	}
-
-	return leftRange, rightRange
+/*
+Creates the summary tree for the given keys using the combine function of the fingerprint scheme.
+*/
+func NewSummaryTree[PreFingerPrint, FingerPrint string](fingerprintScheme FingerprintScheme[PreFingerPrint, FingerPrint], keys []kdnode.Key) *kdnode.SummaryTree {
+	return kdnode.NewSummaryTree(func(a, b string) string {
+		return string(fingerprintScheme.FingerPrintCombine(PreFingerPrint(a), PreFingerPrint(b)))
+	}, string(fingerprintScheme.Neutral), keys)
 }
 
-func xorStrings(a, b string) string {
-	// ensure both strings have the same length
-	// they should always be the same length 🙄
-	// if len(a) > len(b) {
-	// 	b += string(make([]byte, len(a)-len(b)))
-	// } else if len(b) > len(a) {
-	// 	a += string(make([]byte, len(b)-len(a)))
-	// }
-
-	if len(a) != len(b) {
-		log.Fatal("Hashes of payloads are of different length 😨, fingerprinting.go, line 63")
+/*
+Splits a range into at most split subranges along a single dimension, covering the original range exactly.
+size is the number of entries in the range (as returned by Summarise), there is no point in producing more
+parts than there are entries. The dimension whose split points give the most even partition of the entries
+is chosen, so a burst of writes with (nearly) the same timestamp gets split along subspace or path instead.
+Ranges which cannot be split (fewer than two entries, or nothing to tell them apart) are returned as they are.
+*/
+func (k *KDTreeStorage[PreFingerPrint, FingerPrint, K]) SplitRange(Range types.Range3d, size int, split int) []types.Range3d {
+	if split > size {
+		split = size
+	}
+	if split < 2 {
+		return []types.Range3d{Range}
 	}
 
-	result := make([]byte, len(a))
-	for i := range a {
-		result[i] = a[i] ^ b[i]
+	entries := k.Query(Range)
+	if len(entries) < 2 {
+		return []types.Range3d{Range}
+	}
+
+	bestDim, bestLargest := -1, len(entries)
+	var bestBoundaries []kdnode.Key
+	for dim := 0; dim < 3; dim++ {
+		sorted := make([]kdnode.Key, len(entries))
+		copy(sorted, entries)
+		sort.Slice(sorted, func(i, j int) bool {
+			return orderKeyDim(sorted[i], sorted[j], dim) < 0
+		})
+
+		// Split points are at the quantiles, skipping those which would produce an empty part
+		var boundaries []kdnode.Key
+		previous := sorted[0]
+		for i := 1; i < split; i++ {
+			candidate := sorted[i*len(sorted)/split]
+			if orderKeyDim(candidate, previous, dim) > 0 {
+				boundaries = append(boundaries, candidate)
+				previous = candidate
+			}
+		}
+		if len(boundaries) == 0 {
+			continue
+		}
+
+		largest, start := 0, 0
+		for _, boundary := range boundaries {
+			end := sort.Search(len(sorted), func(j int) bool {
+				return orderKeyDim(sorted[j], boundary, dim) >= 0
+			})
+			largest = max(largest, end-start)
+			start = end
+		}
+		largest = max(largest, len(sorted)-start)
+
+		if largest < bestLargest {
+			bestDim, bestLargest, bestBoundaries = dim, largest, boundaries
+		}
+	}
+
+	if bestDim == -1 {
+		return []types.Range3d{Range}
+	}
+
+	parts := make([]types.Range3d, 0, len(bestBoundaries)+1)
+	for i := 0; i <= len(bestBoundaries); i++ {
+		part := Range
+		switch bestDim {
+		case 0:
+			if i > 0 {
+				part.TimeRange.Start = bestBoundaries[i-1].Timestamp
+			}
+			if i < len(bestBoundaries) {
+				part.TimeRange.End = bestBoundaries[i].Timestamp
+				part.TimeRange.OpenEnd = false
+			}
+		case 1:
+			if i > 0 {
+				part.SubspaceRange.Start = bestBoundaries[i-1].Subspace
+			}
+			if i < len(bestBoundaries) {
+				part.SubspaceRange.End = bestBoundaries[i].Subspace
+				part.SubspaceRange.OpenEnd = false
+			}
+		case 2:
+			if i > 0 {
+				part.PathRange.Start = bestBoundaries[i-1].Path
+			}
+			if i < len(bestBoundaries) {
+				part.PathRange.End = bestBoundaries[i].Path
+				part.PathRange.OpenEnd = false
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// Compares two keys along a single dimension, dimensions are numbered the same way as in the KD tree
+func orderKeyDim(a, b kdnode.Key, dim int) types.Rel {
+	switch dim {
+	case 0:
+		return utils.OrderTimestamp(a.Timestamp, b.Timestamp)
+	case 1:
+		return utils.OrderSubspace(a.Subspace, b.Subspace)
+	default:
+		return utils.OrderPath(a.Path, b.Path)
 	}
-	return string(result)
 }
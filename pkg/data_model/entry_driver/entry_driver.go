@@ -35,7 +35,8 @@ Thos function will be used when we want to instantiate a new KD tree at the star
 */
 func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) MakeStorage(nameSpaceId types.NamespaceId, dbValues []kdnode.Key) datamodeltypes.KDTreeStorage[PreFingerPrint, FingerPrint, K] {
 	storage := datamodeltypes.KDTreeStorage[PreFingerPrint, FingerPrint, K]{
-		KDTree: kdtree.NewKDTreeWithValues[kdnode.Key](3, dbValues),
+		KDTree:    kdtree.NewKDTreeWithValues[kdnode.Key](3, dbValues),
+		Summaries: datamodeltypes.NewSummaryTree(e.Opts.FingerprintScheme, dbValues),
 		Opts: struct {
 			Namespace         types.NamespaceId
 			SubspaceScheme    datamodeltypes.SubspaceScheme
@@ -91,7 +92,12 @@ func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Get(Subspace types.Subspac
 	}, nil
 }
 
-func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Insert(extendedEntry datamodeltypes.ExtendedEntry) error {
+/*
+Inserts an entry into the KV store and the KD tree, available is the number of payload bytes
+of the entry we hold locally. The fingerprint singleton of the entry is computed here so that
+summarising a range only has to combine the stored singletons.
+*/
+func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Insert(extendedEntry datamodeltypes.ExtendedEntry, available uint64) error {
 	encodedKey, err := kv_driver.EncodeKey(types.Position3d{Time: extendedEntry.Entry.Timestamp, Subspace: extendedEntry.Entry.Subspace_id, Path: extendedEntry.Entry.Path}, e.Opts.PathParams)
 	if err != nil {
 		return err
@@ -109,13 +115,29 @@ func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Insert(extendedEntry datam
 	if err != nil {
 		return err
 	}
-	err = e.Storage.Insert(extendedEntry.Entry.Subspace_id, extendedEntry.Entry.Path, extendedEntry.Entry.Timestamp)
+	fingerprint := e.Opts.FingerprintScheme.FingerPrintSingleton(datamodeltypes.LengthyEntry{
+		Entry:     extendedEntry.Entry,
+		Available: available,
+	})
+	err = e.Storage.Insert(extendedEntry.Entry.Subspace_id, extendedEntry.Entry.Path, extendedEntry.Entry.Timestamp, fingerprint)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Replaces the fingerprint of an entry in the KD tree once a different amount of its payload is available
+func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) UpdateAvailable(entry types.Entry, available uint64) error {
+	if !(e.Storage.Remove(types.Position3d{Time: entry.Timestamp, Subspace: entry.Subspace_id, Path: entry.Path})) {
+		return errors.New("entry does not exist in the KD Tree")
+	}
+	fingerprint := e.Opts.FingerprintScheme.FingerPrintSingleton(datamodeltypes.LengthyEntry{
+		Entry:     entry,
+		Available: available,
+	})
+	return e.Storage.Insert(entry.Subspace_id, entry.Path, entry.Timestamp, fingerprint)
+}
+
 func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Delete(entry types.Entry) error {
 	encodedKey, err := kv_driver.EncodeKey(types.Position3d{Time: entry.Timestamp, Subspace: entry.Subspace_id, Path: entry.Path}, e.Opts.PathParams)
 	if err != nil {
@@ -134,7 +156,7 @@ func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Delete(entry types.Entry)
 
 func (e *EntryDriver[PreFingerPrint, FingerPrint, K]) Query(range3d types.Range3d) ([]datamodeltypes.ExtendedEntry, error) {
 	entryNodes := e.Storage.Query(range3d)
-	Entries := make([]datamodeltypes.ExtendedEntry, len(entryNodes))
+	Entries := make([]datamodeltypes.ExtendedEntry, 0, len(entryNodes))
 	for _, node := range entryNodes {
 		encodedKey, err := kv_driver.EncodeKey(types.Position3d{Time: node.Timestamp, Subspace: node.Subspace, Path: node.Path}, e.Opts.PathParams)
 		if err != nil {
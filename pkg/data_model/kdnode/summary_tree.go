@@ -0,0 +1,344 @@
+package kdnode
+
+import (
+	"sort"
+
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+	kdtree "github.com/rishitc/go-kd-tree"
+)
+
+/*
+SummaryTree is a KD tree over the same keys as the willow KD tree, augmented with the combined
+fingerprint and the number of entries of every subtree, along with the bounding box of the subtree.
+
+When a subtree lies completely inside the range being summarised its cached fingerprint is used as is,
+and when it lies completely outside of the range it is skipped, so a summary only has to descend into
+the subtrees which straddle the boundary of the range. Ranges produced by splitting along the tree
+(which is what reconciliation does) therefore only cost a logarithmic number of combinations.
+
+Deleted keys are kept as tombstones until they make up half of the tree, unbalanced subtrees are rebuilt
+scapegoat style. The combine function has to be associative and commutative (a lifting monoid), since
+the order in which subtrees are combined depends on the shape of the tree.
+*/
+type SummaryTree struct {
+	root    *summaryNode
+	combine func(a, b string) string
+	neutral string
+	live    int
+	dead    int
+}
+
+type summaryNode struct {
+	key     Key
+	deleted bool
+	dim     int
+	left    *summaryNode
+	right   *summaryNode
+
+	// Number of nodes in the subtree, tombstones included
+	total int
+
+	// Aggregates over the live keys of the subtree
+	count       uint64
+	fingerprint string
+	minTime     uint64
+	maxTime     uint64
+	minSubspace types.SubspaceId
+	maxSubspace types.SubspaceId
+	minPath     types.Path
+	maxPath     types.Path
+}
+
+const (
+	// A subtree is rebuilt when one of its children holds more than this fraction of its nodes
+	summaryTreeBalance = 0.75
+	// Subtrees smaller than this are never considered unbalanced
+	summaryTreeMinRebuild = 8
+	dimensions            = 3
+)
+
+func NewSummaryTree(combine func(a, b string) string, neutral string, keys []Key) *SummaryTree {
+	tree := &SummaryTree{
+		combine: combine,
+		neutral: neutral,
+	}
+	liveKeys := make([]Key, len(keys))
+	copy(liveKeys, keys)
+	tree.root = tree.build(liveKeys, 0)
+	tree.live = len(liveKeys)
+	return tree
+}
+
+// Number of (live) keys in the tree
+func (t *SummaryTree) Size() int {
+	return t.live
+}
+
+// Inserts a key, returns false if a key at the same position already exists
+func (t *SummaryTree) Insert(key Key) bool {
+	path := []*summaryNode{}
+	link := &t.root
+	dim := 0
+	for *link != nil {
+		node := *link
+		path = append(path, node)
+		switch key.Order(node.key, node.dim) {
+		case kdtree.Lesser:
+			link = &node.left
+		case kdtree.Greater:
+			link = &node.right
+		default:
+			if !node.deleted {
+				return false
+			}
+			// Revive the tombstone with the new fingerprint
+			node.key = key
+			node.deleted = false
+			t.dead--
+			t.live++
+			t.refreshPath(path)
+			return true
+		}
+		dim = (node.dim + 1) % dimensions
+	}
+
+	*link = t.leaf(key, dim)
+	t.live++
+	for _, node := range path {
+		node.total++
+	}
+	t.refreshPath(path)
+
+	// Look for the highest unbalanced node on the path, and rebuild its subtree
+	for i, node := range path {
+		if node.total < summaryTreeMinRebuild {
+			break
+		}
+		if float64(subtreeTotal(node.left)) > summaryTreeBalance*float64(node.total) ||
+			float64(subtreeTotal(node.right)) > summaryTreeBalance*float64(node.total) {
+			t.rebuildAt(path[:i], node)
+			break
+		}
+	}
+	return true
+}
+
+// Removes the key at the same position as the given key, returns false if there is no such key
+func (t *SummaryTree) Remove(key Key) bool {
+	path := []*summaryNode{}
+	node := t.root
+	for node != nil {
+		path = append(path, node)
+		switch key.Order(node.key, node.dim) {
+		case kdtree.Lesser:
+			node = node.left
+		case kdtree.Greater:
+			node = node.right
+		default:
+			if node.deleted {
+				return false
+			}
+			node.deleted = true
+			t.live--
+			t.dead++
+			if t.dead > t.live {
+				t.root = t.build(t.collect(t.root, nil), 0)
+				t.dead = 0
+			} else {
+				t.refreshPath(path)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Combines the fingerprints of all the keys inside the given range, and counts them
+func (t *SummaryTree) Summarise(queryRange types.Range3d) (string, uint64) {
+	fingerprint, count := t.neutral, uint64(0)
+	var visit func(node *summaryNode)
+	visit = func(node *summaryNode) {
+		if node == nil || node.count == 0 {
+			return
+		}
+		if boundsOutsideRange(node, queryRange) {
+			return
+		}
+		if boundsInsideRange(node, queryRange) {
+			fingerprint = t.combine(fingerprint, node.fingerprint)
+			count += node.count
+			return
+		}
+		if !node.deleted && keyInRange(node.key, queryRange) {
+			fingerprint = t.combine(fingerprint, node.key.Fingerprint)
+			count++
+		}
+		visit(node.left)
+		visit(node.right)
+	}
+	visit(t.root)
+	return fingerprint, count
+}
+
+func (t *SummaryTree) leaf(key Key, dim int) *summaryNode {
+	node := &summaryNode{key: key, dim: dim, total: 1}
+	t.refresh(node)
+	return node
+}
+
+// Builds a balanced subtree out of the given keys, splitting on the median of the dimension of each level
+func (t *SummaryTree) build(keys []Key, dim int) *summaryNode {
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Order(keys[j], dim) == kdtree.Lesser
+	})
+	mid := len(keys) / 2
+	node := &summaryNode{
+		key:   keys[mid],
+		dim:   dim,
+		left:  t.build(keys[:mid], (dim+1)%dimensions),
+		right: t.build(keys[mid+1:], (dim+1)%dimensions),
+	}
+	node.total = 1 + subtreeTotal(node.left) + subtreeTotal(node.right)
+	t.refresh(node)
+	return node
+}
+
+// Rebuilds the subtree rooted at node, dropping its tombstones. path holds the ancestors of node.
+func (t *SummaryTree) rebuildAt(path []*summaryNode, node *summaryNode) {
+	keys := t.collect(node, nil)
+	removed := node.total - len(keys)
+	rebuilt := t.build(keys, node.dim)
+
+	if len(path) == 0 {
+		t.root = rebuilt
+	} else {
+		parent := path[len(path)-1]
+		if parent.left == node {
+			parent.left = rebuilt
+		} else {
+			parent.right = rebuilt
+		}
+	}
+	t.dead -= removed
+	for _, ancestor := range path {
+		ancestor.total -= removed
+	}
+}
+
+// Appends the live keys of a subtree to keys
+func (t *SummaryTree) collect(node *summaryNode, keys []Key) []Key {
+	if node == nil {
+		return keys
+	}
+	keys = t.collect(node.left, keys)
+	if !node.deleted {
+		keys = append(keys, node.key)
+	}
+	return t.collect(node.right, keys)
+}
+
+// Recomputes the aggregates of the nodes on a path, starting from the bottom
+func (t *SummaryTree) refreshPath(path []*summaryNode) {
+	for i := len(path) - 1; i >= 0; i-- {
+		t.refresh(path[i])
+	}
+}
+
+// Recomputes the aggregates of a node from its own key and the aggregates of its children
+func (t *SummaryTree) refresh(node *summaryNode) {
+	node.count = 0
+	node.fingerprint = t.neutral
+
+	include := func(fingerprint string, count uint64, minTime, maxTime uint64, minSubspace, maxSubspace types.SubspaceId, minPath, maxPath types.Path) {
+		if count == 0 {
+			return
+		}
+		if node.count == 0 {
+			node.minTime, node.maxTime = minTime, maxTime
+			node.minSubspace, node.maxSubspace = minSubspace, maxSubspace
+			node.minPath, node.maxPath = minPath, maxPath
+		} else {
+			if minTime < node.minTime {
+				node.minTime = minTime
+			}
+			if maxTime > node.maxTime {
+				node.maxTime = maxTime
+			}
+			if utils.OrderSubspace(minSubspace, node.minSubspace) < 0 {
+				node.minSubspace = minSubspace
+			}
+			if utils.OrderSubspace(maxSubspace, node.maxSubspace) > 0 {
+				node.maxSubspace = maxSubspace
+			}
+			if utils.OrderPath(minPath, node.minPath) < 0 {
+				node.minPath = minPath
+			}
+			if utils.OrderPath(maxPath, node.maxPath) > 0 {
+				node.maxPath = maxPath
+			}
+		}
+		node.fingerprint = t.combine(node.fingerprint, fingerprint)
+		node.count += count
+	}
+
+	for _, child := range []*summaryNode{node.left, node.right} {
+		if child != nil {
+			include(child.fingerprint, child.count, child.minTime, child.maxTime, child.minSubspace, child.maxSubspace, child.minPath, child.maxPath)
+		}
+	}
+	if !node.deleted {
+		key := node.key
+		include(key.Fingerprint, 1, key.Timestamp, key.Timestamp, key.Subspace, key.Subspace, key.Path, key.Path)
+	}
+}
+
+func subtreeTotal(node *summaryNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.total
+}
+
+func keyInRange(key Key, queryRange types.Range3d) bool {
+	return utils.IsIncluded3d(utils.OrderSubspace, queryRange, types.Position3d{
+		Subspace: key.Subspace,
+		Path:     key.Path,
+		Time:     key.Timestamp,
+	})
+}
+
+// Whether every live key of the subtree is inside the range
+func boundsInsideRange(node *summaryNode, queryRange types.Range3d) bool {
+	if node.minTime < queryRange.TimeRange.Start || (!queryRange.TimeRange.OpenEnd && node.maxTime >= queryRange.TimeRange.End) {
+		return false
+	}
+	if utils.OrderSubspace(node.minSubspace, queryRange.SubspaceRange.Start) < 0 ||
+		(!queryRange.SubspaceRange.OpenEnd && utils.OrderSubspace(node.maxSubspace, queryRange.SubspaceRange.End) >= 0) {
+		return false
+	}
+	if utils.OrderPath(node.minPath, queryRange.PathRange.Start) < 0 ||
+		(!queryRange.PathRange.OpenEnd && utils.OrderPath(node.maxPath, queryRange.PathRange.End) >= 0) {
+		return false
+	}
+	return true
+}
+
+// Whether no live key of the subtree can be inside the range
+func boundsOutsideRange(node *summaryNode, queryRange types.Range3d) bool {
+	if node.maxTime < queryRange.TimeRange.Start || (!queryRange.TimeRange.OpenEnd && node.minTime >= queryRange.TimeRange.End) {
+		return true
+	}
+	if utils.OrderSubspace(node.maxSubspace, queryRange.SubspaceRange.Start) < 0 ||
+		(!queryRange.SubspaceRange.OpenEnd && utils.OrderSubspace(node.minSubspace, queryRange.SubspaceRange.End) >= 0) {
+		return true
+	}
+	if utils.OrderPath(node.maxPath, queryRange.PathRange.Start) < 0 ||
+		(!queryRange.PathRange.OpenEnd && utils.OrderPath(node.minPath, queryRange.PathRange.End) >= 0) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package kdnode
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+// Bytewise addition, associative and commutative like a real fingerprint scheme
+func addFingerprints(a, b string) string {
+	result := make([]byte, 4)
+	for i := range result {
+		result[i] = a[i] + b[i]
+	}
+	return string(result)
+}
+
+func randomKey(rng *rand.Rand) Key {
+	return Key{
+		Timestamp:   uint64(rng.Intn(50)),
+		Subspace:    types.SubspaceId{byte('a' + rng.Intn(5))},
+		Path:        types.Path{[]byte{byte('a' + rng.Intn(5))}, []byte{byte('a' + rng.Intn(5))}},
+		Fingerprint: fmt.Sprintf("%04d", rng.Intn(10000)),
+	}
+}
+
+func randomRange(rng *rand.Rand) types.Range3d {
+	startTime := uint64(rng.Intn(50))
+	startSubspace := byte('a' + rng.Intn(5))
+	startPath := byte('a' + rng.Intn(5))
+	return types.Range3d{
+		TimeRange: types.Range[uint64]{
+			Start:   startTime,
+			End:     startTime + uint64(rng.Intn(30)),
+			OpenEnd: rng.Intn(4) == 0,
+		},
+		SubspaceRange: types.Range[types.SubspaceId]{
+			Start:   types.SubspaceId{startSubspace},
+			End:     types.SubspaceId{startSubspace + byte(rng.Intn(4))},
+			OpenEnd: rng.Intn(4) == 0,
+		},
+		PathRange: types.Range[types.Path]{
+			Start:   types.Path{[]byte{startPath}},
+			End:     types.Path{[]byte{startPath + byte(rng.Intn(4))}},
+			OpenEnd: rng.Intn(4) == 0,
+		},
+	}
+}
+
+func TestSummaryTreeMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	neutral := string(make([]byte, 4))
+	tree := NewSummaryTree(addFingerprints, neutral, nil)
+	present := map[string]Key{}
+
+	for i := 0; i < 3000; i++ {
+		key := randomKey(rng)
+		id := key.String()
+		if _, ok := present[id]; ok && rng.Intn(2) == 0 {
+			if !tree.Remove(key) {
+				t.Fatalf("failed to remove %v", id)
+			}
+			delete(present, id)
+		} else if !ok {
+			if !tree.Insert(key) {
+				t.Fatalf("failed to insert %v", id)
+			}
+			present[id] = key
+		} else if tree.Insert(key) {
+			t.Fatalf("inserted %v twice", id)
+		}
+
+		if tree.Size() != len(present) {
+			t.Fatalf("expected size %d, got %d", len(present), tree.Size())
+		}
+
+		if i%10 != 0 {
+			continue
+		}
+		queryRange := randomRange(rng)
+		expected, expectedCount := neutral, uint64(0)
+		for _, key := range present {
+			if utils.IsIncluded3d(utils.OrderSubspace, queryRange, types.Position3d{Subspace: key.Subspace, Path: key.Path, Time: key.Timestamp}) {
+				expected = addFingerprints(expected, key.Fingerprint)
+				expectedCount++
+			}
+		}
+		fingerprint, count := tree.Summarise(queryRange)
+		if count != expectedCount || fingerprint != expected {
+			t.Fatalf("summary of %v: expected %d entries (%q), got %d (%q)", queryRange, expectedCount, expected, count, fingerprint)
+		}
+	}
+}
+
+func TestSummaryTreeStaysBalanced(t *testing.T) {
+	tree := NewSummaryTree(addFingerprints, string(make([]byte, 4)), nil)
+	// Monotonically increasing timestamps, the common case for a single writer
+	for i := 0; i < 4096; i++ {
+		tree.Insert(Key{
+			Timestamp:   uint64(i),
+			Subspace:    types.SubspaceId("a"),
+			Path:        types.Path{[]byte("p")},
+			Fingerprint: "0001",
+		})
+	}
+
+	var height func(node *summaryNode) int
+	height = func(node *summaryNode) int {
+		if node == nil {
+			return 0
+		}
+		return 1 + max(height(node.left), height(node.right))
+	}
+	if h := height(tree.root); h > 40 {
+		t.Errorf("tree of 4096 keys has height %d", h)
+	}
+}
@@ -72,8 +72,11 @@ func (k *KvDriver[T]) Get(key []byte) ([]byte, error) {
 	if err != nil {
 		return []byte{}, err
 	}
+	// The value is only valid until the closer is closed
+	result := make([]byte, len(value))
+	copy(result, value)
 	closer.Close()
-	return value, nil
+	return result, nil
 }
 
 func (k *KvDriver[T]) Set(key, value []byte) error {
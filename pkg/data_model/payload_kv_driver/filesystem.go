@@ -84,6 +84,20 @@ func (pd *PayloadDriver) Get(PayloadHash types.PayloadDigest) (datamodeltypes.Pa
 	return pd.GetPayload(filepath), nil
 }
 
+// Retrieves what was received so far of an incomplete payload corresponding to the given hash.
+func (pd *PayloadDriver) GetPartial(PayloadHash types.PayloadDigest) (datamodeltypes.Payload, error) {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	filepath := filepath.Join(pd.path, "partial", pd.GetKey(PayloadHash))
+	_, err := os.Lstat(filepath)
+	if err != nil {
+		return datamodeltypes.Payload{}, err
+	}
+
+	return pd.GetPayload(filepath), nil
+}
+
 // Deletes the payload corresponding to the given hash.
 func (pd *PayloadDriver) Erase(PayloadHash types.PayloadDigest) (bool, error) {
 	pd.mu.Lock()
@@ -174,20 +188,20 @@ func (pd *PayloadDriver) Receive(payload []byte, offset int64, expectedLength ui
 	stagingFilePath := filepath.Join(pd.path, "staging", tempKey)
 
 	// If offset is greater than 0, copy the existing partial file to staging
+	partialFilePath := filepath.Join(pd.path, "partial", pd.GetKey(expectedDigest))
 	if offset > 0 {
-		partialFilePath := filepath.Join(pd.path, "partial", pd.GetKey(expectedDigest))
 		err := copyFile(partialFilePath, stagingFilePath)
 		if err != nil {
-			panic("Unable to copy file: " + err.Error())
+			return "", 0, nil, nil, fmt.Errorf("no partial payload to resume from: %w", err)
 		}
 	}
 
-	// Open the file in the appropriate mode
+	// Open the file in the appropriate mode, keeping the partial payload a received payload continues
 	var file *os.File
 	if offset == 0 {
 		file, err = os.OpenFile(stagingFilePath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0777)
 	} else {
-		file, err = os.OpenFile(stagingFilePath, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0777)
+		file, err = os.OpenFile(stagingFilePath, os.O_CREATE|os.O_RDWR, 0777)
 	}
 	if err != nil {
 		panic("Unable to open file: " + err.Error())
@@ -239,6 +253,8 @@ func (pd *PayloadDriver) Receive(payload []byte, offset int64, expectedLength ui
 		if isCompletePayload {
 			committedFilePath = filepath.Join(pd.path, pd.GetKey(expectedDigest))
 			err = os.Rename(stagingFilePath, committedFilePath)
+			// The partial payload the complete one was resumed from is of no use anymore
+			os.Remove(partialFilePath)
 		} else {
 			pd.EnsureDir("partial")
 			committedFilePath = filepath.Join(pd.path, "partial", pd.GetKey(expectedDigest))
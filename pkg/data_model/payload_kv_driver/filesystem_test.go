@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
@@ -443,3 +444,47 @@ func TestReceive(t *testing.T) {
 	}
 
 }
+
+func TestReceiveResumesAPartialPayload(t *testing.T) {
+	pd := PayloadDriver{
+		path:          t.TempDir(),
+		PayloadScheme: mockPayloadScheme,
+		mu:            &sync.Mutex{},
+	}
+	firstPart, secondPart := []byte("The first half of a payload,"), []byte(" and the half which was resumed.")
+	complete := append(append([]byte{}, firstPart...), secondPart...)
+	expectedDigest := <-mockPayloadScheme.FromBytes(complete)
+
+	_, receivedLength, commit, _, err := pd.Receive(firstPart, 0, uint64(len(complete)), expectedDigest)
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	commit(receivedLength == uint64(len(complete)))
+	partial, err := pd.GetPartial(expectedDigest)
+	if err != nil {
+		t.Fatalf("Failed to get partial payload: %v", err)
+	}
+	if !bytes.Equal(partial.Bytes(), firstPart) {
+		t.Errorf("Partial payload content = %s; want %s", partial.Bytes(), firstPart)
+	}
+
+	receivedDigest, receivedLength, commit, _, err := pd.Receive(secondPart, int64(len(firstPart)), uint64(len(complete)), expectedDigest)
+	if err != nil {
+		t.Fatalf("Receive failed for the resumed payload: %v", err)
+	}
+	if receivedDigest != expectedDigest || receivedLength != uint64(len(complete)) {
+		t.Fatalf("Resumed payload has digest %s and length %d; want %s and %d", receivedDigest, receivedLength, expectedDigest, len(complete))
+	}
+	commit(true)
+
+	storedPayload, err := pd.Get(expectedDigest)
+	if err != nil {
+		t.Fatalf("Failed to get resumed payload: %v", err)
+	}
+	if !bytes.Equal(storedPayload.Bytes(), complete) {
+		t.Errorf("Resumed payload content = %s; want %s", storedPayload.Bytes(), complete)
+	}
+	if _, err := pd.GetPartial(expectedDigest); err == nil {
+		t.Error("Partial payload still exists once the payload is complete")
+	}
+}
@@ -1,122 +1,113 @@
 package store
 
-// type FPNodes struct {
-// 	Range       types.Range3d
-// 	Fingerprint string
-// 	Covers      uint64 // what the fuck is this supposed to do??
-// }
-
-// /* Used for splitting a 3dRange*/
-// func SplitRange(Range types.Range3d, size int) {
-
-// }
-
-// func Summarise(Range types.Range3d) struct {
-// 	FingerPrint string
-// 	size        uint64
-// } {
-// 	var size uint64
-// 	valuesInRange :=
-
-// }
-
-// import (
-// 	"log"
-// 	"math"
-
-// 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/kdnode"
-// )
-
-// // "fmt"
-
-// // "github.com/PES-Innovation-Lab/willow-go/types"
-// // "github.com/PES-Innovation-Lab/willow-go/utils"
-
-// // absolutelty beautiful code
-// // TO DO : add routines??
-
-// type Node struct {
-// 	Index int
-// 	Hash  string
-// }
-
-// func BuildFingerprints(entries []kdnode.Key) []Node {
-// 	// check for empty entries
-// 	if len(entries) == 0 {
-// 		log.Fatal(`Empty entry list, line 21, fingerprinting.go`)
-// 	}
-
-// 	temp := math.Pow(2, math.Ceil(math.Log2(float64(len(entries)))+1))
-// 	fptree := make([]string, int(temp))
-// 	mid := len(entries) / 2
-
-// 	fptree[0] = xorStrings(
-// 		buildHelper(entries[0:mid], fptree, 1),
-// 		buildHelper(entries[mid:], fptree, 2),
-// 	)
-// 	fptree = ShortenArray(fptree)
-// 	nodeTree := make([]Node, int(temp))
-// 	for i, fp := range fptree {
-// 		newNode := Node{
-// 			Index: i,
-// 			Hash:  fp,
-// 		}
-// 		nodeTree[i] = newNode
-// 	}
-
-// 	return nodeTree
-// }
-
-// func buildHelper(entries []kdnode.Key, fps []string, index int) string {
-// 	if len(entries) == 1 {
-// 		fps[index] = entries[0].Fingerprint
-// 		return entries[0].Fingerprint
-// 	}
-
-// 	mid := len(entries) / 2
-// 	fingerprint := xorStrings(
-// 		buildHelper(entries[0:mid], fps, 2*index+1),
-// 		buildHelper(entries[mid:], fps, 2*index+2),
-// 	)
-// 	fps[index] = fingerprint
-// 	return fingerprint
-// }
-
-// // ShortenArray shortens the array to include elements up to the last non-empty string.
-// func ShortenArray(arr []string) []string {
-// 	lastIndex := -1
-// 	for i := len(arr) - 1; i >= 0; i-- {
-// 		if arr[i] != "" {
-// 			lastIndex = i
-// 			break
-// 		}
-// 	}
-
-// 	// if all elements are empty, return an empty slice
-// 	if lastIndex == -1 {
-// 		return []string{}
-// 	}
-
-// 	// return the slice up to the last non-empty string
-// 	return arr[:lastIndex+1]
-// }
-
-// func xorStrings(a, b string) string {
-// 	// ensure both strings have the same length
-// 	// they should always be the same length 🙄
-// 	// if len(a) > len(b) {
-// 	// 	b += string(make([]byte, len(a)-len(b)))
-// 	// } else if len(b) > len(a) {
-// 	// 	a += string(make([]byte, len(b)-len(a)))
-// 	// }
-
-// 	if len(a) != len(b) {
-// 		log.Fatal("Hashes of payloads are of different length 😨, fingerprinting.go, line 63")
-// 	}
-
-// 	result := make([]byte, len(a))
-// 	for i := range a {
-// 		result[i] = a[i] ^ b[i]
-// 	}
-// 	return string(result)
-// }
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+/*
+The default fingerprint scheme of willow-go.
+
+Every entry is hashed (SHA-512) to a scalar modulo the order of the prime order
+subgroup of edwards25519 (the same group ristretto255 uses), and fingerprints are
+combined by adding those scalars modulo the group order. Addition in a group is
+associative and commutative with zero as its neutral element, so this is a proper
+lifting monoid: the fingerprint of a set of entries does not depend on how the set
+was split up or in which order it was folded, which is exactly what range based set
+reconciliation needs.
+
+The singleton hashes the entry along with how much of its payload is available, so
+two peers holding the same entries only agree on the fingerprint once they hold the
+same parts of the payloads as well.
+*/
+
+// Length in bytes of a fingerprint produced by the default fingerprint scheme.
+const DefaultFingerprintLength = 32
+
+// Order of the prime order subgroup of edwards25519, 2^252 + 27742317777372353535851937790883648493.
+var fingerprintGroupOrder, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+var defaultNeutralFingerprint = string(make([]byte, DefaultFingerprintLength))
+
+// Encodes an entry unambiguously, every variable length field is prefixed by its length.
+func fingerprintEntryBytes(entry types.Entry) []byte {
+	var encoded []byte
+	appendBytes := func(bytes []byte) {
+		encoded = binary.BigEndian.AppendUint64(encoded, uint64(len(bytes)))
+		encoded = append(encoded, bytes...)
+	}
+
+	appendBytes(entry.Namespace_id)
+	appendBytes(entry.Subspace_id)
+	encoded = binary.BigEndian.AppendUint64(encoded, uint64(len(entry.Path)))
+	for _, component := range entry.Path {
+		appendBytes(component)
+	}
+	encoded = binary.BigEndian.AppendUint64(encoded, entry.Timestamp)
+	encoded = binary.BigEndian.AppendUint64(encoded, entry.Payload_length)
+	appendBytes([]byte(entry.Payload_digest))
+
+	return encoded
+}
+
+// Converts a group element to its fixed width big endian representation.
+func fingerprintFromScalar(scalar *big.Int) string {
+	return string(scalar.FillBytes(make([]byte, DefaultFingerprintLength)))
+}
+
+func DefaultFingerprintSingleton(entry datamodeltypes.LengthyEntry) string {
+	hash := sha512.Sum512(binary.BigEndian.AppendUint64(fingerprintEntryBytes(entry.Entry), entry.Available))
+	scalar := new(big.Int).SetBytes(hash[:])
+	return fingerprintFromScalar(scalar.Mod(scalar, fingerprintGroupOrder))
+}
+
+func DefaultFingerprintCombine(a, b string) string {
+	sum := new(big.Int).Add(new(big.Int).SetBytes([]byte(a)), new(big.Int).SetBytes([]byte(b)))
+	return fingerprintFromScalar(sum.Mod(sum, fingerprintGroupOrder))
+}
+
+var DefaultFingerprintEncoding utils.EncodingScheme[string] = utils.EncodingScheme[string]{
+	Encode: func(fingerprint string) []byte {
+		return []byte(fingerprint)
+	},
+	Decode: func(encoded []byte) (string, error) {
+		if len(encoded) < DefaultFingerprintLength {
+			return "", errors.New("not enough bytes to decode a fingerprint")
+		}
+		return string(encoded[:DefaultFingerprintLength]), nil
+	},
+	EncodedLength: func(fingerprint string) uint64 {
+		return DefaultFingerprintLength
+	},
+	DecodeStream: func(value *utils.GrowingBytes) chan string {
+		ch := make(chan string, 1)
+		go func() {
+			bytes := value.NextAbsolute(DefaultFingerprintLength)
+			fingerprint := string(bytes[:DefaultFingerprintLength])
+			value.Prune(DefaultFingerprintLength)
+			ch <- fingerprint
+			close(ch)
+		}()
+		return ch
+	},
+}
+
+var DefaultFingerprintScheme datamodeltypes.FingerprintScheme[string, string] = datamodeltypes.FingerprintScheme[string, string]{
+	FingerPrintSingleton: DefaultFingerprintSingleton,
+	FingerPrintCombine:   DefaultFingerprintCombine,
+	FingerPrintFinalise: func(fingerprint string) string {
+		return fingerprint
+	},
+	Neutral:          defaultNeutralFingerprint,
+	NeutralFinalised: defaultNeutralFingerprint,
+	IsEqual: func(a, b string) bool {
+		return a == b
+	},
+	Encoding: DefaultFingerprintEncoding,
+}
@@ -1,72 +1,143 @@
 package store
 
-// import (
-// 	"testing"
+import (
+	"fmt"
+	"testing"
 
-// 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/kdnode"
-// )
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/kdnode"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+	kdtree "github.com/rishitc/go-kd-tree"
+)
 
-// func TestBuildFingerprints(t *testing.T) {
-// 	tests := []struct {
-// 		name     string
-// 		entries  []kdnode.Key
-// 		expected []string
-// 	}{
-// 		{
-// 			name: "Even number of entries",
-// 			entries: []kdnode.Key{
-// 				{Fingerprint: "a"},
-// 				{Fingerprint: "b"},
-// 				{Fingerprint: "c"},
-// 				{Fingerprint: "d"},
-// 			},
-// 			expected: []string{"abcd", "ab", "cd", "a", "b", "c", "d"},
-// 		},
-// 		{
-// 			name: "Odd number of entries",
-// 			entries: []kdnode.Key{
-// 				{Fingerprint: "a"},
-// 				{Fingerprint: "b"},
-// 				{Fingerprint: "c"},
-// 			},
-// 			expected: []string{"abc", "a", "bc", "", "", "b", "c"},
-// 		},
-// 		{
-// 			name: "5 entries",
-// 			entries: []kdnode.Key{
-// 				{Fingerprint: "a"},
-// 				{Fingerprint: "b"},
-// 				{Fingerprint: "c"},
-// 				{Fingerprint: "d"},
-// 				{Fingerprint: "e"},
-// 			},
-// 			expected: []string{"abcde", "ab", "cde", "a", "b", "c", "de", "", "", "", "", "", "", "d", "e"},
-// 		},
-// 		{
-// 			name: "Single entry",
-// 			entries: []kdnode.Key{
-// 				{Fingerprint: "a"},
-// 			},
-// 			expected: []string{"a"},
-// 		},
-// 		{
-// 			name:     "Empty entries",
-// 			entries:  []kdnode.Key{},
-// 			expected: []string{""},
-// 		},
-// 	}
+func fingerprintTestEntries(count int) []datamodeltypes.LengthyEntry {
+	entries := make([]datamodeltypes.LengthyEntry, 0, count)
+	for i := 0; i < count; i++ {
+		entries = append(entries, datamodeltypes.LengthyEntry{
+			Entry: types.Entry{
+				Namespace_id:   types.NamespaceId("Test"),
+				Subspace_id:    types.SubspaceId(fmt.Sprintf("subspace%d", i%3)),
+				Path:           types.Path{[]byte("path"), []byte(fmt.Sprintf("%d", i))},
+				Timestamp:      uint64(100 + i),
+				Payload_digest: types.PayloadDigest(fmt.Sprintf("digest%d", i)),
+				Payload_length: uint64(i),
+			},
+			Available: uint64(i),
+		})
+	}
+	return entries
+}
 
-// 	for _, tt := range tests {
-// 		t.Run(tt.name, func(t *testing.T) {
-// 			result := BuildFingerprints(tt.entries)
-// 			// if len(result) != len(tt.expected) {
-// 			// 	t.Errorf("Expected length %d, got %d, %s", len(tt.expected), len(result), result)
-// 			// }
-// 			for i := range tt.expected {
-// 				if result[i] != tt.expected[i] {
-// 					t.Errorf("Result: %s\nExpected %s at index %d, got %s", result, tt.expected[i], i, result[i])
-// 				}
-// 			}
-// 		})
-// 	}
-// }
+func TestDefaultFingerprintSchemeIsMonoid(t *testing.T) {
+	scheme := DefaultFingerprintScheme
+	entries := fingerprintTestEntries(3)
+	a := scheme.FingerPrintSingleton(entries[0])
+	b := scheme.FingerPrintSingleton(entries[1])
+	c := scheme.FingerPrintSingleton(entries[2])
+
+	if len(a) != DefaultFingerprintLength {
+		t.Fatalf("expected fingerprint of length %d, got %d", DefaultFingerprintLength, len(a))
+	}
+	if a == b {
+		t.Fatalf("different entries produced the same fingerprint")
+	}
+	if scheme.FingerPrintCombine(a, scheme.Neutral) != a || scheme.FingerPrintCombine(scheme.Neutral, a) != a {
+		t.Errorf("neutral element is not neutral")
+	}
+	if scheme.FingerPrintCombine(scheme.FingerPrintCombine(a, b), c) != scheme.FingerPrintCombine(a, scheme.FingerPrintCombine(b, c)) {
+		t.Errorf("combine is not associative")
+	}
+	if scheme.FingerPrintCombine(a, b) != scheme.FingerPrintCombine(b, a) {
+		t.Errorf("combine is not commutative")
+	}
+
+	// Differing amounts of available payload change the fingerprint
+	partial := entries[1]
+	partial.Available = 0
+	if scheme.FingerPrintSingleton(partial) == b {
+		t.Errorf("fingerprint does not depend on the available payload")
+	}
+
+	decoded, err := scheme.Encoding.Decode(scheme.Encoding.Encode(a))
+	if err != nil || decoded != a {
+		t.Errorf("fingerprint does not survive encoding, got %v %v", decoded, err)
+	}
+}
+
+func TestSummariseUsesFingerprintScheme(t *testing.T) {
+	storage := datamodeltypes.KDTreeStorage[string, string, uint8]{
+		KDTree:    kdtree.NewKDTreeWithValues[kdnode.Key](3, nil),
+		Summaries: datamodeltypes.NewSummaryTree(DefaultFingerprintScheme, nil),
+	}
+	storage.Opts.PathParams = TestPathParams
+	storage.Opts.FingerprintScheme = DefaultFingerprintScheme
+
+	everything := utils.DefaultRange3d(types.SubspaceId(""))
+	if summary := storage.Summarise(everything); summary.Size != 0 || summary.FingerPrint != DefaultFingerprintScheme.Neutral {
+		t.Fatalf("empty storage should summarise to the neutral fingerprint, got %v", summary)
+	}
+
+	expected := DefaultFingerprintScheme.Neutral
+	for _, entry := range fingerprintTestEntries(10) {
+		fingerprint := DefaultFingerprintScheme.FingerPrintSingleton(entry)
+		if err := storage.Insert(entry.Entry.Subspace_id, entry.Entry.Path, entry.Entry.Timestamp, fingerprint); err != nil {
+			t.Fatal(err)
+		}
+		expected = DefaultFingerprintScheme.FingerPrintCombine(expected, fingerprint)
+	}
+
+	summary := storage.Summarise(everything)
+	if summary.Size != 10 {
+		t.Errorf("expected 10 entries, got %d", summary.Size)
+	}
+	if summary.FingerPrint != expected {
+		t.Errorf("summary does not match the combined singletons")
+	}
+}
+
+func TestSplitRange(t *testing.T) {
+	storage := datamodeltypes.KDTreeStorage[string, string, uint8]{
+		KDTree:    kdtree.NewKDTreeWithValues[kdnode.Key](3, nil),
+		Summaries: datamodeltypes.NewSummaryTree(DefaultFingerprintScheme, nil),
+	}
+	storage.Opts.PathParams = TestPathParams
+	storage.Opts.FingerprintScheme = DefaultFingerprintScheme
+	everything := utils.DefaultRange3d(types.SubspaceId(""))
+
+	if parts := storage.SplitRange(everything, 0, 4); len(parts) != 1 {
+		t.Fatalf("empty range should not be split, got %d parts", len(parts))
+	}
+
+	// A burst of writes, all with the same timestamp
+	for i := 0; i < 40; i++ {
+		entry := types.Entry{
+			Namespace_id: types.NamespaceId("Test"),
+			Subspace_id:  types.SubspaceId(fmt.Sprintf("subspace%d", i%2)),
+			Path:         types.Path{[]byte(fmt.Sprintf("%02d", i))},
+			Timestamp:    1000,
+		}
+		fingerprint := DefaultFingerprintScheme.FingerPrintSingleton(datamodeltypes.LengthyEntry{Entry: entry})
+		if err := storage.Insert(entry.Subspace_id, entry.Path, entry.Timestamp, fingerprint); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, split := range []int{2, 3, 4, 7} {
+		parts := storage.SplitRange(everything, 40, split)
+		if len(parts) != split {
+			t.Fatalf("expected %d parts, got %d", split, len(parts))
+		}
+		var total uint64
+		for _, part := range parts {
+			size := storage.Summarise(part).Size
+			if size == 0 || size > uint64(40/split+1) {
+				t.Errorf("uneven split into %d parts, part has %d entries", split, size)
+			}
+			total += size
+		}
+		if total != 40 {
+			t.Errorf("parts should cover all 40 entries, covered %d", total)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+/*
+IngestionListeners are told about every entry ingested into a store, along with the authorisation token it was
+ingested with, and about every payload completed in it. They are held by pointer, so every copy of a store made after
+they were set up shares them.
+*/
+type IngestionListeners[AuthorisationToken string] struct {
+	mu               sync.Mutex
+	listeners        map[uint64]func(entry types.Entry, authorisation AuthorisationToken)
+	payloadListeners map[uint64]func(digest types.PayloadDigest)
+	next             uint64
+}
+
+func NewIngestionListeners[AuthorisationToken string]() *IngestionListeners[AuthorisationToken] {
+	return &IngestionListeners[AuthorisationToken]{
+		listeners:        make(map[uint64]func(entry types.Entry, authorisation AuthorisationToken)),
+		payloadListeners: make(map[uint64]func(digest types.PayloadDigest)),
+	}
+}
+
+// Adds a listener, which is removed again by the returned function
+func (l *IngestionListeners[AuthorisationToken]) Add(listener func(entry types.Entry, authorisation AuthorisationToken)) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := l.next
+	l.next++
+	l.listeners[id] = listener
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.listeners, id)
+	}
+}
+
+// Tells every listener about an ingested entry, nothing listens to a nil set of listeners
+func (l *IngestionListeners[AuthorisationToken]) Notify(entry types.Entry, authorisation AuthorisationToken) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	listeners := make([]func(entry types.Entry, authorisation AuthorisationToken), 0, len(l.listeners))
+	for _, listener := range l.listeners {
+		listeners = append(listeners, listener)
+	}
+	l.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(entry, authorisation)
+	}
+}
+
+// Adds a listener to the payloads completed, which is removed again by the returned function
+func (l *IngestionListeners[AuthorisationToken]) AddPayload(listener func(digest types.PayloadDigest)) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := l.next
+	l.next++
+	l.payloadListeners[id] = listener
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.payloadListeners, id)
+	}
+}
+
+// Tells every listener about a completed payload, nothing listens to a nil set of listeners
+func (l *IngestionListeners[AuthorisationToken]) NotifyPayload(digest types.PayloadDigest) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	listeners := make([]func(digest types.PayloadDigest), 0, len(l.payloadListeners))
+	for _, listener := range l.payloadListeners {
+		listeners = append(listeners, listener)
+	}
+	l.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(digest)
+	}
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+func TestIngestionListeners(t *testing.T) {
+	listeners := NewIngestionListeners[string]()
+	var first, second []string
+	removeFirst := listeners.Add(func(entry types.Entry, authorisation string) {
+		first = append(first, authorisation)
+	})
+	listeners.Add(func(entry types.Entry, authorisation string) {
+		second = append(second, authorisation)
+	})
+
+	listeners.Notify(types.Entry{}, "alfie")
+	removeFirst()
+	listeners.Notify(types.Entry{}, "betty")
+
+	if len(first) != 1 || first[0] != "alfie" {
+		t.Errorf("expected the removed listener to only hear of alfie, heard of %v", first)
+	}
+	if len(second) != 2 {
+		t.Errorf("expected the remaining listener to hear of both entries, heard of %v", second)
+	}
+
+	// Listeners to payloads only hear of payloads
+	var completed []types.PayloadDigest
+	listeners.AddPayload(func(digest types.PayloadDigest) {
+		completed = append(completed, digest)
+	})
+	listeners.NotifyPayload("digest")
+	if len(completed) != 1 || completed[0] != "digest" || len(second) != 2 {
+		t.Errorf("expected the payload listener alone to hear of the payload, heard of %v", completed)
+	}
+
+	// Stores without listeners ingest entries all the same
+	var none *IngestionListeners[string]
+	none.Notify(types.Entry{}, "alfie")
+	none.NotifyPayload("digest")
+	var withoutListeners Store[string, string, uint8, []byte, string]
+	if _, err := withoutListeners.OnIngestEntry(func(entry types.Entry, authorisation string) {}); !errors.Is(err, ErrNoListeners) {
+		t.Errorf("expected listening to a store without listeners to fail, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,13 +21,28 @@ import (
 	"golang.org/x/exp/constraints"
 )
 
+// Wrapped by the errors of entries which are not ingested because the store holds a newer entry which overwrites them
+var ErrNewerEntryExists = errors.New("newer entry already exists in store")
+
+/*
+Returned when listening to a store without listeners. They are set up along with the store by its constructor, as
+listeners set up on a copy of the store later would not be the ones of the other copies.
+*/
+var ErrNoListeners = errors.New("the store has no ingestion listeners")
+
+// Wrapped by the errors of entries which may never be ingested into the store, being of another namespace or unauthorised
+var ErrInvalidEntry = errors.New("invalid entry")
+
 type Store[PreFingerPrint, FingerPrint string, K constraints.Unsigned, AuthorisationOpts []byte, AuthorisationToken string] struct {
-	Schemes            datamodeltypes.StoreSchemes[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]
-	EntryDriver        entrydriver.EntryDriver[PreFingerPrint, FingerPrint, K]
-	PayloadDriver      payloadDriver.PayloadDriver
-	NameSpaceId        types.NamespaceId
-	IngestionMutexLock sync.Mutex
+	Schemes       datamodeltypes.StoreSchemes[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]
+	EntryDriver   entrydriver.EntryDriver[PreFingerPrint, FingerPrint, K]
+	PayloadDriver payloadDriver.PayloadDriver
+	NameSpaceId   types.NamespaceId
+	// Held by pointer like the listeners, so copies of a store do not ingest entries at the same time. Set up by the constructor of the store
+	IngestionMutexLock *sync.Mutex
 	PrefixDriver       kv_driver.PrefixDriver[K]
+	// Told about every entry ingested, set up with NewIngestionListeners by the constructor of the store
+	Listeners *IngestionListeners[AuthorisationToken]
 }
 
 func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) Set(
@@ -53,7 +69,8 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 	}
 	prunedEntries, err := s.IngestEntry(entry, authToken)
 	if err != nil {
-		return nil, errors.New(err.Error())
+		// Returned as is, so that ErrNewerEntryExists can be told apart
+		return nil, err
 	}
 	count, err := s.EntryDriver.PayloadReferenceCounter.Count(digest)
 	if err != nil {
@@ -65,22 +82,57 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 	return prunedEntries, nil
 }
 
+// Ingests an entry and tells the listeners of the store about it, once it was ingested and the store is unlocked again
 func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) IngestEntry(
 	entry types.Entry,
 	authorisation AuthorisationToken,
+) ([]types.Entry, error) {
+	prunedEntries, err := s.ingestEntry(entry, authorisation)
+	if err != nil {
+		return nil, err
+	}
+	s.Listeners.Notify(entry, authorisation)
+	return prunedEntries, nil
+}
+
+/*
+Calls a listener with every entry ingested into the store from now on, until the returned function is called. The
+listener is called on the goroutine which ingested the entry, so it should hand the entry off rather than block.
+*/
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) OnIngestEntry(
+	listener func(entry types.Entry, authorisation AuthorisationToken),
+) (func(), error) {
+	if s.Listeners == nil {
+		return nil, ErrNoListeners
+	}
+	return s.Listeners.Add(listener), nil
+}
+
+// Calls a listener with the digest of every payload completed in the store from now on, until the returned function is called
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) OnIngestPayload(
+	listener func(digest types.PayloadDigest),
+) (func(), error) {
+	if s.Listeners == nil {
+		return nil, ErrNoListeners
+	}
+	return s.Listeners.AddPayload(listener), nil
+}
+
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) ingestEntry(
+	entry types.Entry,
+	authorisation AuthorisationToken,
 ) ([]types.Entry, error) {
 	s.IngestionMutexLock.Lock() // Locked so that no parallel entry insertions can happen
+	defer s.IngestionMutexLock.Unlock()
 
 	// Check if the namespace id of the entry and the current namespace match!
 	if !(s.Schemes.NamespaceScheme.IsEqual(s.NameSpaceId, entry.Namespace_id)) {
-		s.IngestionMutexLock.Unlock()
-		return nil, errors.New("failed to ingest entry\nnamespace does not match store namespace")
+		return nil, fmt.Errorf("failed to ingest entry\n%w: namespace does not match store namespace", ErrInvalidEntry)
 	}
 
 	// Check if the authorisation token is valid
 	if !(s.Schemes.AuthorisationScheme.IsAuthoriseWrite(entry, authorisation)) {
-		s.IngestionMutexLock.Unlock()
-		return nil, errors.New("failed to ingest entry\nauthorisation failed")
+		return nil, fmt.Errorf("failed to ingest entry\n%w: authorisation failed", ErrInvalidEntry)
 	}
 
 	// Get all the prefixes of the entry path to be inserted, iterate through them
@@ -89,8 +141,7 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 	prefixes := s.PrefixDriver.DriverPrefixesOf(entry.Subspace_id, entry.Path, s.Schemes.PathParams, s.EntryDriver.Storage.KDTree)
 	for _, prefix := range prefixes {
 		if prefix.Timestamp >= entry.Timestamp {
-			s.IngestionMutexLock.Unlock()
-			return nil, errors.New("failed to ingest entry\nnewer prefix already exists in store")
+			return nil, fmt.Errorf("failed to ingest entry\n%w at a prefix of its path", ErrNewerEntryExists)
 		}
 	}
 
@@ -107,16 +158,13 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 		if utils.OrderPath(otherEntry.Entry.Path, entry.Path) == 0 {
 			if otherEntry.Entry.Timestamp >= entry.Timestamp {
 				// Check timestamps for newer entry
-				s.IngestionMutexLock.Unlock()
-				return nil, errors.New("failed to ingest entry\nnewer entry already exists in store")
+				return nil, fmt.Errorf("failed to ingest entry\n%w", ErrNewerEntryExists)
 			} else if entry.Timestamp == otherEntry.Entry.Timestamp && otherEntry.Entry.Payload_digest >= entry.Payload_digest {
 				// Check payload digests for newer entry
-				s.IngestionMutexLock.Unlock()
-				return nil, errors.New("failed to ingest entry\nnewer entry already exists in store")
+				return nil, fmt.Errorf("failed to ingest entry\n%w", ErrNewerEntryExists)
 			} else if entry.Timestamp == otherEntry.Entry.Timestamp && otherEntry.Entry.Payload_digest == entry.Payload_digest && otherEntry.Entry.Payload_length >= entry.Payload_length {
 				// Check payload lengths for newer entry
-				s.IngestionMutexLock.Unlock()
-				return nil, errors.New("failed to ingest entry\nnewer entry already exists in store")
+				return nil, fmt.Errorf("failed to ingest entry\n%w", ErrNewerEntryExists)
 			}
 			// If the three conditions does not satisgy, it means the entry to be inserted is newer
 			// and the other entry should be removed
@@ -168,9 +216,6 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 		return nil, errors.New(err.Error())
 	}
 
-	// Unlock the mutex lock
-	s.IngestionMutexLock.Unlock()
-
 	// Return the pruned entries and the entry which was inserted with no errors
 	return prunedEntries, nil
 }
@@ -189,7 +234,7 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 	encodedToken := s.Schemes.AuthorisationScheme.TokenEncoding.Encode(entry.AuthToken)
 	authDigest, _, _ := s.PayloadDriver.Set(encodedToken)
 
-	// Insert the entry into the storage
+	// Insert the entry into the storage, along with how much of its payload we already have
 	err := s.EntryDriver.Insert(datamodeltypes.ExtendedEntry{
 		Entry: types.Entry{
 			Timestamp:      entry.Timestamp,
@@ -200,7 +245,7 @@ func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationT
 			Namespace_id:   s.NameSpaceId,
 		},
 		AuthDigest: authDigest,
-	})
+	}, s.heldPayload(entry.PayloadDigest))
 	if err != nil {
 		return nil, errors.New(err.Error())
 	}
@@ -278,79 +323,208 @@ const (
 	Success Status = 1
 )
 
+/*
+Ingests (a part of) the payload of an existing entry, payload holds the bytes starting at offset.
+If allowPartial is false the payload has to be complete after this call. The listeners of the store are told once the
+payload is complete, after the store is unlocked again.
+*/
 func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) IngestPayload(
 	entryDetails types.Position3d,
 	payload []byte,
 	allowPartial bool,
 	offset int64,
 ) (Status, error) {
+	status, completed, err := s.ingestPayload(entryDetails, payload, allowPartial, offset)
+	if completed != "" {
+		s.Listeners.NotifyPayload(completed)
+	}
+	return status, err
+}
+
+// IngestPayload, which also returns the digest of the payload once this completed it
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) ingestPayload(
+	entryDetails types.Position3d,
+	payload []byte,
+	allowPartial bool,
+	offset int64,
+) (Status, types.PayloadDigest, error) {
+	// Locked so that the entry is not pruned or replaced while its payload is ingested
+	s.IngestionMutexLock.Lock()
+	defer s.IngestionMutexLock.Unlock()
+
 	encodedKey, err := kv_driver.EncodeKey(types.Position3d{
 		Time:     entryDetails.Time,
 		Subspace: entryDetails.Subspace,
 		Path:     entryDetails.Path,
 	}, s.Schemes.PathParams)
 	if err != nil {
-		return 0, errors.New(err.Error())
+		return Failure, "", errors.New(err.Error())
 	}
 	getEntry, err := s.EntryDriver.Opts.KVDriver.Get(encodedKey)
 	if err != nil {
-		return 0, errors.New(err.Error())
-	}
-	if getEntry != nil {
-		return Failure, errors.New("entry does not exist")
+		return Failure, "", errors.New("entry does not exist")
 	}
 
-	// Samar needs to make a DecodeValue Function that returns payloadDigest
-	// Len and Digest as mentioned by the entry
 	decodedValue := kv_driver.DecodeValues(getEntry)
 
-	existingPayload, err := s.PayloadDriver.Get(decodedValue.PayloadDigest)
-	if err != nil {
-		return 0, errors.New("unable to Get")
+	// Nothing to do if we already have the complete payload
+	if s.availablePayload(decodedValue.PayloadDigest) == decodedValue.PayloadLength {
+		return No_Op, "", nil
 	}
 
-	if !reflect.DeepEqual(existingPayload, datamodeltypes.Payload{}) {
-		return No_Op, errors.New("file already exists")
-	}
 	// Result after fully ingesting the paylaod
 	resDigest, resLen, resCommit, resReject, err := s.PayloadDriver.Receive(payload, offset, decodedValue.PayloadLength, decodedValue.PayloadDigest)
 	if err != nil {
-		return 0, errors.New("unable to receive")
+		return Failure, "", errors.New("unable to receive")
 	}
-	if resLen > decodedValue.PayloadLength || (!allowPartial && decodedValue.PayloadLength != resLen) || (resLen == decodedValue.PayloadLength && s.Schemes.PayloadScheme.Order(resDigest, decodedValue.PayloadDigest) != 0) {
+	if resLen > decodedValue.PayloadLength || (!allowPartial && decodedValue.PayloadLength != resLen) || (resLen == decodedValue.PayloadLength && resDigest != decodedValue.PayloadDigest) {
 		resReject()
-		return Failure, errors.New("data mismatch")
+		return Failure, "", errors.New("data mismatch")
 	}
 
 	resCommit(resLen == decodedValue.PayloadLength)
 
-	if resLen == decodedValue.PayloadLength && (s.Schemes.PayloadScheme.Order(resDigest, decodedValue.PayloadDigest) == 0) {
-		complete, err := s.PayloadDriver.Get(decodedValue.PayloadDigest)
+	// The fingerprints of the entries cover how much of their payload is held, which every write changes
+	entries := []types.Entry{{
+		Namespace_id:   s.NameSpaceId,
+		Subspace_id:    entryDetails.Subspace,
+		Path:           entryDetails.Path,
+		Timestamp:      entryDetails.Time,
+		Payload_length: decodedValue.PayloadLength,
+		Payload_digest: decodedValue.PayloadDigest,
+	}}
+	if count, _ := s.EntryDriver.PayloadReferenceCounter.Count(decodedValue.PayloadDigest); count > 1 {
+		entries, err = s.entriesWithPayload(decodedValue.PayloadDigest)
 		if err != nil {
-			return 0, errors.New(err.Error())
-		}
-
-		if reflect.DeepEqual(complete, datamodeltypes.Payload{}) {
-			return 0, fmt.Errorf("could not get payload for a payload that was just ingested")
+			return Failure, "", err
 		}
-		authToken, err := s.PayloadDriver.Get(decodedValue.AuthDigest)
+	}
+	for _, entry := range entries {
+		err = s.EntryDriver.UpdateAvailable(entry, resLen)
 		if err != nil {
-			return 0, fmt.Errorf("could not get payload for a payload that was just ingested: %s", err.Error())
+			return Failure, "", err
 		}
-		if reflect.DeepEqual(authToken, datamodeltypes.Payload{}) {
-			return 0, fmt.Errorf("could not get payload for a payload that was just ingested")
+	}
+
+	if resLen == decodedValue.PayloadLength {
+		return Success, decodedValue.PayloadDigest, nil
+	}
+	return Success, "", nil
+}
+
+// Returns every entry referring to the payload with the given digest, there is no index of them so all are searched
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) entriesWithPayload(digest types.PayloadDigest) ([]types.Entry, error) {
+	extendedEntries, err := s.EntryDriver.Query(utils.DefaultRange3d(s.Schemes.SubspaceScheme.MinimalSubspaceId))
+	if err != nil {
+		return nil, err
+	}
+	var entries []types.Entry
+	for _, extendedEntry := range extendedEntries {
+		if extendedEntry.Entry.Payload_digest == digest {
+			entries = append(entries, extendedEntry.Entry)
 		}
+	}
+	return entries, nil
+}
+
+/*
+Runs read while no entry is being ingested. Copies of a store share the storage of its entries, which is not safe to
+read while another copy ingests into it, such as the sessions of a messenger syncing with several peers at once.
+*/
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) ReadEntries(read func()) {
+	s.IngestionMutexLock.Lock()
+	defer s.IngestionMutexLock.Unlock()
+	read()
+}
+
+// Returns the number of bytes of the payload with the given digest which are stored locally
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) AvailablePayload(digest types.PayloadDigest) uint64 {
+	s.IngestionMutexLock.Lock()
+	defer s.IngestionMutexLock.Unlock()
+	return s.availablePayload(digest)
+}
 
+// AvailablePayload for the ones already holding IngestionMutexLock
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) availablePayload(digest types.PayloadDigest) uint64 {
+	payload, err := s.PayloadDriver.Get(digest)
+	if err != nil {
+		return 0
+	}
+	length, err := payload.Length()
+	if err != nil {
+		return 0
 	}
-	// s.Storage.UpdateAvailablePayload(entryDetails.Subspace, entryDetails.Path)
-	return Success, nil
+	return length
 }
 
-// Returns range of the passed areaOfInterest
+/*
+Returns the number of bytes of the payload with the given digest which are held locally, whether it is complete or
+not. The fingerprints of the entries with this payload cover it.
+*/
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) HeldPayload(digest types.PayloadDigest) uint64 {
+	s.IngestionMutexLock.Lock()
+	defer s.IngestionMutexLock.Unlock()
+	return s.heldPayload(digest)
+}
+
+// HeldPayload for the ones already holding IngestionMutexLock
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) heldPayload(digest types.PayloadDigest) uint64 {
+	if available := s.availablePayload(digest); available > 0 {
+		return available
+	}
+	return s.PartialPayload(digest)
+}
+
+// Returns the number of bytes received so far of an incomplete payload with the given digest, from which it may be resumed
+func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) PartialPayload(digest types.PayloadDigest) uint64 {
+	payload, err := s.PayloadDriver.GetPartial(digest)
+	if err != nil {
+		return 0
+	}
+	length, err := payload.Length()
+	if err != nil {
+		return 0
+	}
+	return length
+}
+
+/*
+Returns range of the passed areaOfInterest. The range of an area of interest with a MaxCount or MaxSize starts at the
+time of the oldest of the newest entries within these limits, entries older than it are left out. Entries with the
+same timestamp as that oldest entry remain included, and a range no entry fits into is empty.
+*/
 func (s *Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]) AreaOfInterestToRange(
 	areaOfInterest types.AreaOfInterest,
 ) (types.Range3d, error) {
-	return s.EntryDriver.Storage.GetInterestRange(areaOfInterest), nil
+	areaRange := s.EntryDriver.Storage.GetInterestRange(areaOfInterest)
+	if areaOfInterest.MaxCount == 0 && areaOfInterest.MaxSize == 0 {
+		return areaRange, nil
+	}
+
+	entries, err := s.EntryDriver.Query(areaRange)
+	if err != nil {
+		return areaRange, err
+	}
+	// Newest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Entry.Timestamp > entries[j].Entry.Timestamp
+	})
+	var count, size uint64
+	for i, entry := range entries {
+		count++
+		size += entry.Entry.Payload_length
+		if (areaOfInterest.MaxCount == 0 || count <= areaOfInterest.MaxCount) && (areaOfInterest.MaxSize == 0 || size <= areaOfInterest.MaxSize) {
+			continue
+		}
+		limited := areaRange
+		if i == 0 {
+			limited.TimeRange = types.Range[uint64]{Start: areaRange.TimeRange.Start, End: areaRange.TimeRange.Start}
+		} else {
+			limited.TimeRange.Start = entries[i-1].Entry.Timestamp
+		}
+		return limited, nil
+	}
+	return areaRange, nil
 }
 
 // Function which returns the payload if we pass in the entry details
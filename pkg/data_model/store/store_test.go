@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -62,8 +63,9 @@ func InitStorage(nameSpaceId types.NamespaceId) *Store[string, string, uint8, []
 		EntryDriver:        entryDriver,
 		PayloadDriver:      TestPayloadDriver,
 		NameSpaceId:        nameSpaceId,
-		IngestionMutexLock: sync.Mutex{},
+		IngestionMutexLock: &sync.Mutex{},
 		PrefixDriver:       TestPrefixDriver,
+		Listeners:          NewIngestionListeners[string](),
 	}
 }
 
@@ -121,10 +123,20 @@ func TestSet(t *testing.T) {
 			log.Fatal(err)
 		}
 		keys = append(keys, kdnode.Key{
-			Subspace:    sub,
-			Timestamp:   time,
-			Path:        path,
-			Fingerprint: string(decodedValue.AuthDigest),
+			Subspace:  sub,
+			Timestamp: time,
+			Path:      path,
+			Fingerprint: string(TestStore.Schemes.FingerprintScheme.FingerPrintSingleton(datamodeltypes.LengthyEntry{
+				Entry: types.Entry{
+					Namespace_id:   TestStore.NameSpaceId,
+					Subspace_id:    sub,
+					Path:           path,
+					Timestamp:      time,
+					Payload_digest: decodedValue.PayloadDigest,
+					Payload_length: decodedValue.PayloadLength,
+				},
+				Available: TestStore.AvailablePayload(decodedValue.PayloadDigest),
+			})),
 		})
 	}
 	TestStore.EntryDriver.Storage = TestStore.EntryDriver.MakeStorage([]byte("Test"), keys)
@@ -175,3 +187,28 @@ func TestSet(t *testing.T) {
 		fmt.Println("============================")
 	}
 }
+
+func TestSetRefusesEntriesOverwrittenByNewerOnes(t *testing.T) {
+	if TestStore.EntryDriver.Storage.KDTree == nil {
+		TestStore.EntryDriver.Storage = TestStore.EntryDriver.MakeStorage([]byte("Test"), nil)
+	}
+	now := uint64(time.Now().UnixMicro())
+	set := func(path types.Path, timestamp uint64) error {
+		_, err := TestStore.Set(datamodeltypes.EntryInput{
+			Subspace:  []byte("Samarth"),
+			Payload:   []byte("overwritten"),
+			Timestamp: timestamp,
+			Path:      path,
+		}, []byte("Samarth"))
+		return err
+	}
+	if err := set(types.Path{[]byte("newer")}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := set(types.Path{[]byte("newer")}, now-1); !errors.Is(err, ErrNewerEntryExists) {
+		t.Errorf("expected an older entry at the same path to be refused, got %v", err)
+	}
+	if err := set(types.Path{[]byte("newer"), []byte("child")}, now-1); !errors.Is(err, ErrNewerEntryExists) {
+		t.Errorf("expected an older entry below the path of a newer one to be refused, got %v", err)
+	}
+}
@@ -12,26 +12,9 @@ import (
 )
 
 // Test schemes defined!
-var NameSpaceEncoding utils.EncodingScheme[types.NamespaceId] = utils.EncodingScheme[types.NamespaceId]{
-	Encode: func(id types.NamespaceId) []byte {
-		return []byte(id)
-	},
-	Decode: func(bytes []byte) (types.NamespaceId, error) {
-		return types.NamespaceId(bytes), nil
-	},
-	EncodedLength: func(id types.NamespaceId) uint64 {
-		return uint64(len(id))
-	},
-	DecodeStream: func(value *utils.GrowingBytes) chan types.NamespaceId {
-		ch := make(chan types.NamespaceId)
-		go func() {
-			bytes := value.NextAbsolute(1)
-			ch <- types.NamespaceId(bytes)
-			close(ch)
-		}()
-		return ch
-	},
-}
+// Namespace and subspace ids are sent over the wire, so they need to be self delimiting
+var NameSpaceEncoding utils.EncodingScheme[types.NamespaceId] = utils.LengthPrefixedEncoding[types.NamespaceId]()
+
 var TestNameSpaceScheme datamodeltypes.NamespaceScheme = datamodeltypes.NamespaceScheme{
 	EncodingScheme: NameSpaceEncoding,
 	IsEqual: func(a types.NamespaceId, b types.NamespaceId) bool {
@@ -40,26 +23,7 @@ var TestNameSpaceScheme datamodeltypes.NamespaceScheme = datamodeltypes.Namespac
 	DefaultNamespaceId: types.NamespaceId(""),
 }
 
-var SubspaceEncoding utils.EncodingScheme[types.SubspaceId] = utils.EncodingScheme[types.SubspaceId]{
-	Encode: func(id types.SubspaceId) []byte {
-		return []byte(id)
-	},
-	Decode: func(bytes []byte) (types.SubspaceId, error) {
-		return types.SubspaceId(bytes), nil
-	},
-	EncodedLength: func(id types.SubspaceId) uint64 {
-		return uint64(len(id))
-	},
-	DecodeStream: func(value *utils.GrowingBytes) chan types.SubspaceId {
-		ch := make(chan types.SubspaceId)
-		go func() {
-			bytes := value.NextAbsolute(1)
-			ch <- types.SubspaceId(bytes)
-			close(ch)
-		}()
-		return ch
-	},
-}
+var SubspaceEncoding utils.EncodingScheme[types.SubspaceId] = utils.LengthPrefixedEncoding[types.SubspaceId]()
 
 var TestSubspaceScheme datamodeltypes.SubspaceScheme = datamodeltypes.SubspaceScheme{
 	EncodingScheme:      SubspaceEncoding,
@@ -67,14 +31,7 @@ var TestSubspaceScheme datamodeltypes.SubspaceScheme = datamodeltypes.SubspaceSc
 	Order:               utils.OrderSubspace,
 	MinimalSubspaceId:   types.SubspaceId(""),
 }
-var TestFingerprintScheme datamodeltypes.FingerprintScheme[string, string] = datamodeltypes.FingerprintScheme[string, string]{
-	FingerPrintFinalise: func(fingerprint string) string {
-		return fingerprint
-	},
-	IsEqual: func(a string, b string) bool {
-		return strings.Compare(a, b) == 0
-	},
-}
+var TestFingerprintScheme datamodeltypes.FingerprintScheme[string, string] = DefaultFingerprintScheme
 
 var TestAuthorisationScheme datamodeltypes.AuthorisationScheme[[]byte, string] = datamodeltypes.AuthorisationScheme[[]byte, string]{
 	Authorise: func(entry types.Entry, opts []byte) (string, error) {
@@ -124,6 +81,29 @@ var TestPayloadScheme datamodeltypes.PayloadScheme = datamodeltypes.PayloadSchem
 			}
 			return decoded
 		},
+		Decode: func(encoded []byte) (types.PayloadDigest, error) {
+			if len(encoded) < sha256.Size {
+				return "", fmt.Errorf("not enough bytes to decode a payload digest")
+			}
+			return types.PayloadDigest(hex.EncodeToString(encoded[:sha256.Size])), nil
+		},
+		EncodedLength: func(value types.PayloadDigest) uint64 {
+			return sha256.Size
+		},
+		DecodeStream: func(value *utils.GrowingBytes) chan types.PayloadDigest {
+			ch := make(chan types.PayloadDigest, 1)
+			go func() {
+				defer close(ch)
+				bytes := value.NextAbsolute(sha256.Size)
+				if len(bytes) < sha256.Size {
+					return
+				}
+				digest := types.PayloadDigest(hex.EncodeToString(bytes[:sha256.Size]))
+				value.Prune(sha256.Size)
+				ch <- digest
+			}()
+			return ch
+		},
 	},
 	FromBytes: func(bytes []byte) chan types.PayloadDigest {
 		ch := make(chan types.PayloadDigest, 1)
@@ -141,4 +121,5 @@ var StoreSchemes datamodeltypes.StoreSchemes[string, string, uint8, []byte, stri
 	AuthorisationScheme: TestAuthorisationScheme,
 	SubspaceScheme:      TestSubspaceScheme,
 	PayloadScheme:       TestPayloadScheme,
+	FingerprintScheme:   TestFingerprintScheme,
 }
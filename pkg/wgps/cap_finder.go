@@ -12,35 +12,24 @@ import (
 )
 
 type Options[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey any, K constraints.Unsigned] struct {
-	HandleStoreOurs handlestore.HandleStore[ReadCapability]
-	Schemes         struct {
+	// The store the capabilities are bound in, either ours or the one of the other peer
+	Handles *handlestore.HandleStore[ReadCapability]
+	Schemes struct {
 		Namespace     datamodeltypes.NamespaceScheme
 		Subspace      datamodeltypes.SubspaceScheme
 		AccessControl wgpstypes.AccessControlScheme[SyncSignature, ReadCapability, Receiver, ReceiverSecretKey, K]
 	}
 }
 
+/*
+CapFinder finds a bound read capability whose granted area includes an entry. Capabilities are indexed by their
+granted namespace once they are added, and a capability whose handle was freed since is never found.
+*/
 type CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey any, K constraints.Unsigned] struct {
 	NamespaceMap map[string]map[uint64]struct{}
 	Opts         Options[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
 }
 
-func isEmpty[T any](value T) bool {
-	switch v := any(value).(type) {
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
-		return v == 0
-	case float32, float64:
-		return v == 0.0
-	case string:
-		return v == ""
-	default:
-		// This case should not happen if T is truly constraints.Ordered,
-		// but it's here for completeness. Handling complex types or
-		// defining "empty" for them would require more context.
-		return false
-	}
-}
-
 // NewCapFinder creates a new instance of CapFinder with initialized NamespaceMap.
 func NewCapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey any, K constraints.Unsigned](opts Options[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]) *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K] {
 	return &CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]{
@@ -54,19 +43,15 @@ func (c *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K
 	return base64.StdEncoding.EncodeToString(encoded), nil
 }
 
+// Adds the capability bound to a handle, which does nothing if the handle is not bound
 func (c *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]) AddCap(handle uint64) {
-	cap, _ := c.Opts.HandleStoreOurs.Get(handle)
-	empty := isEmpty(cap)
-	if empty {
-		//WillowError (TODO)
+	cap, found := c.Opts.Handles.Get(handle)
+	if !found {
+		return
 	}
 
 	namespace := c.Opts.Schemes.AccessControl.GetGrantedNamespace(cap)
 	key, _ := c.GetNamespaceKey(namespace)
-	res := c.NamespaceMap[key]
-	if _, ok := res[handle]; !ok {
-		res[handle] = struct{}{}
-	}
 	// Check if the key exists in NamespaceMap
 	if _, exists := c.NamespaceMap[key]; !exists {
 		// If the key doesn't exist, initialize a new set and add the handle
@@ -76,19 +61,21 @@ func (c *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K
 	c.NamespaceMap[key][handle] = struct{}{}
 }
 
-func (c *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]) FindCapHandle(entry types.Entry) uint64 {
+// The handle of a capability which grants reading the entry, if any was added
+func (c *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]) FindCapHandle(entry types.Entry) (uint64, bool) {
 	key, _ := c.GetNamespaceKey(entry.Namespace_id)
 	set := c.NamespaceMap[key]
 	if set == nil {
-		return 0
+		return 0, false
 	}
 	entryPos := utils.EntryPosition(entry)
 
 	for handle := range set {
-		cap, _ := c.Opts.HandleStoreOurs.Get(handle)
-		empty := isEmpty(cap)
-		if empty {
-			//WillowError (TODO)
+		cap, found := c.Opts.Handles.Get(handle)
+		if !found {
+			// The handle was freed since
+			delete(set, handle)
+			continue
 		}
 
 		grantedArea := c.Opts.Schemes.AccessControl.GetGrantedArea(cap)
@@ -96,8 +83,8 @@ func (c *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K
 		isInArea := utils.IsIncludedArea(c.Opts.Schemes.Subspace.Order, grantedArea, entryPos)
 
 		if isInArea {
-			return handle
+			return handle, true
 		}
 	}
-	return 0
+	return 0, false
 }
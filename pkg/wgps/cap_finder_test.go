@@ -0,0 +1,60 @@
+package wgps
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+// The test capabilities are "namespace/subspace", granting reading a single subspace of a namespace
+func newTestCapFinder() (*CapFinder[string, string, types.SubspaceId, string, uint8], *handlestore.HandleStore[string]) {
+	handles := &handlestore.HandleStore[string]{Map: handlestore.NewMap[string]()}
+	opts := Options[string, string, types.SubspaceId, string, uint8]{Handles: handles}
+	opts.Schemes.Namespace = store.TestNameSpaceScheme
+	opts.Schemes.Subspace = store.TestSubspaceScheme
+	opts.Schemes.AccessControl.GetGrantedNamespace = func(cap string) types.NamespaceId {
+		namespace, _, _ := strings.Cut(cap, "/")
+		return types.NamespaceId(namespace)
+	}
+	opts.Schemes.AccessControl.GetGrantedArea = func(cap string) types.Area {
+		_, subspace, _ := strings.Cut(cap, "/")
+		return utils.SubspaceArea(types.SubspaceId(subspace))
+	}
+	return NewCapFinder(opts), handles
+}
+
+func testEntry(namespace, subspace string) types.Entry {
+	return types.Entry{
+		Namespace_id: types.NamespaceId(namespace),
+		Subspace_id:  types.SubspaceId(subspace),
+		Path:         types.Path{[]byte("entry")},
+	}
+}
+
+func TestCapFinderFindsCapabilitiesIncludingEntries(t *testing.T) {
+	finder, handles := newTestCapFinder()
+	finder.AddCap(handles.Bind("family/alfie"))
+	betty := handles.Bind("family/betty")
+	finder.AddCap(betty)
+	finder.AddCap(handles.Bind("work/alfie"))
+
+	handle, found := finder.FindCapHandle(testEntry("family", "betty"))
+	if !found || handle != betty {
+		t.Errorf("found handle %d for an entry of betty, expected %d", handle, betty)
+	}
+	if _, found := finder.FindCapHandle(testEntry("work", "betty")); found {
+		t.Errorf("found a capability for a subspace no capability grants")
+	}
+	if _, found := finder.FindCapHandle(testEntry("hobbies", "alfie")); found {
+		t.Errorf("found a capability for a namespace no capability grants")
+	}
+
+	handles.Free(betty)
+	if _, found := finder.FindCapHandle(testEntry("family", "betty")); found {
+		t.Errorf("found a capability whose handle was freed")
+	}
+}
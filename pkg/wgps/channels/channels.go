@@ -6,7 +6,7 @@ import (
 
 var MsgLogicalChannels = map[wgpstypes.MsgKind]wgpstypes.Channel{
 	wgpstypes.PaiBindFragment:                wgpstypes.IntersectionChannel,
-	wgpstypes.PaiReplyFragment:               wgpstypes.IntersectionChannel,
+	wgpstypes.PaiReplyFragment:               0,
 	wgpstypes.SetupBindReadCapability:        wgpstypes.CapabilityChannel,
 	wgpstypes.SetupBindAreaOfInterest:        wgpstypes.AreaOfInterestChannel,
 	wgpstypes.SetupBindStaticToken:           wgpstypes.StaticTokenChannel,
@@ -28,5 +28,6 @@ var MsgLogicalChannels = map[wgpstypes.MsgKind]wgpstypes.Channel{
 	wgpstypes.ControlIssueGuarantee:          0,
 	wgpstypes.PaiRequestSubspaceCapability:   0,
 	wgpstypes.PaiReplySubspaceCapability:     0,
-	wgpstypes.DataSetMetadata:                0,
+	// Sent along the reconciliation it affects, so the other peer knows about it before it replies to the reconciliation
+	wgpstypes.DataSetMetadata: wgpstypes.ReconciliationChannel,
 }
@@ -0,0 +1,139 @@
+package wgps
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+// The defaults of the commitment scheme, which hashes nonces with SHA-256
+const (
+	DEFAULT_CHALLENGE_LENGTH       = 16
+	DEFAULT_CHALLENGE_HASH_LENGTH  = sha256.Size
+	DEFAULT_MAX_PAYLOAD_SIZE_POWER = 64
+)
+
+func defaultChallengeHash(bytes []byte) []byte {
+	hash := sha256.Sum256(bytes)
+	return hash[:]
+}
+
+/*
+Commitment runs the commitment scheme which opens a session. Each peer sends the hash of a random nonce first, and
+reveals the nonce only once it received the hash of the other peer, so that neither can choose its nonce based on
+the other one. Both challenges are derived from both nonces: alfie's is their bitwise xor and betty's its bitwise
+complement.
+
+Every capability a peer binds carries a signature over its own challenge, which is fresh for every session, so
+signatures can not be replayed in other sessions.
+*/
+type Commitment struct {
+	Role          wgpstypes.SyncRole
+	Nonce         []byte
+	ChallengeHash func(bytes []byte) []byte
+
+	// Received in the opening of the other peer
+	TheirMaxPayloadSizePower uint8
+	TheirCommitment          []byte
+
+	// Derived once the other peer revealed its nonce
+	OurChallenge   []byte
+	TheirChallenge []byte
+
+	mu       sync.Mutex
+	revealed chan struct{}
+}
+
+func NewCommitment(role wgpstypes.SyncRole, challengeLength int, challengeHash func(bytes []byte) []byte) (*Commitment, error) {
+	nonce := make([]byte, challengeLength)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a nonce: %w", err)
+	}
+	return &Commitment{
+		Role:          role,
+		Nonce:         nonce,
+		ChallengeHash: challengeHash,
+		revealed:      make(chan struct{}),
+	}, nil
+}
+
+// The hash of our nonce, which we commit to in our opening
+func (c *Commitment) Ours() []byte {
+	return c.ChallengeHash(c.Nonce)
+}
+
+// Records the opening of the other peer, and returns the message revealing our nonce
+func (c *Commitment) ReceiveOpening(maxPayloadSizePower uint8, commitment []byte) wgpstypes.MsgCommitmentReveal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.TheirMaxPayloadSizePower = maxPayloadSizePower
+	c.TheirCommitment = commitment
+	return wgpstypes.MsgCommitmentReveal{
+		Kind: wgpstypes.CommitmentReveal,
+		Data: wgpstypes.MsgCommitmentRevealData{Nonce: c.Nonce},
+	}
+}
+
+// Checks the nonce the other peer revealed against its commitment, and derives both challenges from it
+func (c *Commitment) Reveal(theirNonce []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.OurChallenge != nil {
+		return fmt.Errorf("the other peer revealed its nonce twice")
+	}
+	if c.TheirCommitment == nil {
+		return fmt.Errorf("the other peer revealed its nonce before committing to it")
+	}
+	if len(theirNonce) != len(c.Nonce) || !bytes.Equal(c.ChallengeHash(theirNonce), c.TheirCommitment) {
+		return fmt.Errorf("the nonce of the other peer does not match its commitment")
+	}
+
+	alfie := make([]byte, len(c.Nonce))
+	betty := make([]byte, len(c.Nonce))
+	for i := range c.Nonce {
+		alfie[i] = c.Nonce[i] ^ theirNonce[i]
+		betty[i] = ^alfie[i]
+	}
+	c.OurChallenge, c.TheirChallenge = betty, alfie
+	if wgpstypes.IsAlfie(c.Role) {
+		c.OurChallenge, c.TheirChallenge = alfie, betty
+	}
+	close(c.revealed)
+	return nil
+}
+
+// Waits until the other peer revealed its nonce, and returns our challenge and theirs
+func (c *Commitment) Challenges() ([]byte, []byte) {
+	<-c.revealed
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.OurChallenge, c.TheirChallenge
+}
+
+// Signs our challenge, as every capability we bind has to be
+func SignChallenge[PublicKey, SecretKey, Signature any](
+	c *Commitment,
+	scheme types.SignatureScheme[PublicKey, SecretKey, Signature],
+	publicKey PublicKey,
+	secretKey SecretKey,
+) Signature {
+	ours, _ := c.Challenges()
+	return scheme.Sign(publicKey, secretKey, ours)
+}
+
+// Whether a signature the other peer sent along with a capability covers its challenge
+func VerifyChallenge[PublicKey, SecretKey, Signature any](
+	c *Commitment,
+	scheme types.SignatureScheme[PublicKey, SecretKey, Signature],
+	publicKey PublicKey,
+	signature Signature,
+) bool {
+	_, theirs := c.Challenges()
+	return scheme.Verify(publicKey, signature, theirs)
+}
@@ -0,0 +1,88 @@
+package wgps
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+func openSession(t *testing.T) (*Commitment, *Commitment) {
+	alfie, err := NewCommitment(wgpstypes.SyncRoleAlfie, DEFAULT_CHALLENGE_LENGTH, defaultChallengeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty, err := NewCommitment(wgpstypes.SyncRoleBetty, DEFAULT_CHALLENGE_LENGTH, defaultChallengeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alfieReveal := alfie.ReceiveOpening(64, betty.Ours())
+	bettyReveal := betty.ReceiveOpening(32, alfie.Ours())
+	if err := alfie.Reveal(bettyReveal.Data.Nonce); err != nil {
+		t.Fatalf("alfie rejected the nonce of betty: %v", err)
+	}
+	if err := betty.Reveal(alfieReveal.Data.Nonce); err != nil {
+		t.Fatalf("betty rejected the nonce of alfie: %v", err)
+	}
+	return alfie, betty
+}
+
+func TestCommitmentDerivesComplementaryChallenges(t *testing.T) {
+	alfie, betty := openSession(t)
+
+	alfieOurs, alfieTheirs := alfie.Challenges()
+	bettyOurs, bettyTheirs := betty.Challenges()
+	if !bytes.Equal(alfieOurs, bettyTheirs) || !bytes.Equal(bettyOurs, alfieTheirs) {
+		t.Fatalf("the peers derived different challenges")
+	}
+	for i := range alfieOurs {
+		if alfieOurs[i] != ^bettyOurs[i] {
+			t.Fatalf("the challenge of betty is not the complement of the one of alfie")
+		}
+	}
+	if alfie.TheirMaxPayloadSizePower != 64 || betty.TheirMaxPayloadSizePower != 32 {
+		t.Errorf("did not record the maximum payload sizes of the other peers")
+	}
+}
+
+func TestCommitmentRejectsMismatchingNonce(t *testing.T) {
+	alfie, err := NewCommitment(wgpstypes.SyncRoleAlfie, DEFAULT_CHALLENGE_LENGTH, defaultChallengeHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alfie.Reveal(make([]byte, DEFAULT_CHALLENGE_LENGTH)); err == nil {
+		t.Errorf("accepted a nonce before a commitment to it")
+	}
+
+	alfie.ReceiveOpening(64, defaultChallengeHash([]byte("committed to this")))
+	if err := alfie.Reveal([]byte("but revealed that")); err == nil {
+		t.Errorf("accepted a nonce which does not match the commitment")
+	}
+}
+
+func TestChallengeSignaturesDoNotCarryOverSessions(t *testing.T) {
+	// Signing appends the secret key, which is also the public key
+	scheme := types.SignatureScheme[string, string, string]{
+		Sign: func(publicKey string, secretKey string, bytestring []byte) string {
+			return secretKey + string(bytestring)
+		},
+		Verify: func(publicKey string, signature string, bytestring []byte) bool {
+			return signature == publicKey+string(bytestring)
+		},
+	}
+
+	alfie, betty := openSession(t)
+	signature := SignChallenge(alfie, scheme, "alfie", "alfie")
+	if !VerifyChallenge(betty, scheme, "alfie", signature) {
+		t.Fatalf("betty rejected the signature of alfie over its challenge")
+	}
+	if VerifyChallenge(alfie, scheme, "alfie", signature) {
+		t.Errorf("accepted a signature over our own challenge")
+	}
+
+	_, otherBetty := openSession(t)
+	if VerifyChallenge(otherBetty, scheme, "alfie", signature) {
+		t.Errorf("accepted a signature from another session")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
@@ -36,33 +37,41 @@ type PayloadRequest struct {
 	Entry  types.Entry
 }
 
+// The number of payload bytes sent in a single message, unless set otherwise
+const PAYLOAD_CHUNK_SIZE = 4096
+
 type DataSenderOpts[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken, DynamicToken string, AuthorisationOpts []byte] struct {
-	HandlesPayloadRequestsTheirs handlestore.HandleStore[PayloadRequest]
-	GetStore                     wgpstypes.GetStoreFn[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]
-	TransformPayload             func(chunk []byte) []byte
+	// The payload requests the other peer bound, which are answered from the store
+	HandlesPayloadRequestsTheirs *handlestore.HandleStore[PayloadRequest]
+	Store                        *store.Store[Prefingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken]
+	TransformPayload             func(chunk []byte) []byte // Leaves payloads as they are when nil
+	PayloadChunkSize             int                       // Defaults to PAYLOAD_CHUNK_SIZE
 }
 
+/*
+DataSender queues entries and payloads to send on the data channel. Every entry is followed by its payload from the
+given offset, and every payload request of the other peer is answered with the requested payload from its offset.
+*/
 type DataSender[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken, DynamicToken string, AuthorisationOpts []byte] struct {
 	Opts          DataSenderOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]
-	InternalQueue []interface{} // Either DataSendEntry or DataBindPayloadRequest
+	InternalQueue []interface{} // Either DataSendEntryPack or DataBindPayloadRequestPack
 }
 
-func NewDataSender[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken, DynamicToken string, AuthorisationOpts []byte](opts DataSenderOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts] {
-	return DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]{
-		Opts:          opts,
-		InternalQueue: make([]interface{}, 1),
+func NewDataSender[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken, DynamicToken string, AuthorisationOpts []byte](opts DataSenderOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts] {
+	if opts.TransformPayload == nil {
+		opts.TransformPayload = func(chunk []byte) []byte { return chunk }
+	}
+	if opts.PayloadChunkSize == 0 {
+		opts.PayloadChunkSize = PAYLOAD_CHUNK_SIZE
+	}
+	return &DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]{
+		Opts: opts,
 	}
 }
 
 func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) QueueEntry(entry types.Entry, staticTokenHandle uint64, dynamicToken DynamicToken, offset uint64) error {
-	Store := q.Opts.GetStore(entry.Namespace_id)
-	Payload, err := Store.PayloadDriver.Get(entry.Payload_digest)
-
-	if err != nil {
-		//throw an error
-		return fmt.Errorf("error getting payload: %v", err)
-	}
-
+	// An entry whose payload we do not hold is sent without any bytes
+	Payload, _ := q.Opts.Store.PayloadDriver.Get(entry.Payload_digest)
 	q.InternalQueue = append(q.InternalQueue, (DataSendEntryPack[DynamicToken]{
 		Entry:             entry,
 		Offset:            offset,
@@ -73,17 +82,14 @@ func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicT
 	return nil
 }
 
+// Queues the reply to a payload request the other peer bound to a handle
 func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) QueuePayloadRequest(handle uint64) error {
-	payloadRequest, found := q.Opts.HandlesPayloadRequestsTheirs.Get(handle) //This is actually supposed to be getEventually, need to see if writing it this way affects the code in any way
+	payloadRequest, found := q.Opts.HandlesPayloadRequestsTheirs.Get(handle)
 	if !found {
 		return fmt.Errorf("handle not found")
 	}
-	store := q.Opts.GetStore(payloadRequest.Entry.Namespace_id)
-	payload, err := store.PayloadDriver.Get(payloadRequest.Entry.Payload_digest)
-	if err != nil {
-		//throw an error
-		return fmt.Errorf("error getting payload: %v", err)
-	}
+	// A payload we do not hold is answered without any bytes
+	payload, _ := q.Opts.Store.PayloadDriver.Get(payloadRequest.Entry.Payload_digest)
 	q.InternalQueue = append(q.InternalQueue, (DataBindPayloadRequestPack{
 		Handle:  handle,
 		Offset:  payloadRequest.Offset,
@@ -92,11 +98,13 @@ func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicT
 	return nil
 }
 
-func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) Messages() ([]interface{}, error) {
+// Takes the messages of everything queued so far, leaving the queue empty
+func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) Messages() ([]wgpstypes.SyncMessage, error) {
+	queue := q.InternalQueue
+	q.InternalQueue = nil
 
-	var messages []interface{}
-
-	for _, msg := range q.InternalQueue {
+	var messages []wgpstypes.SyncMessage
+	for _, msg := range queue {
 		switch msg := msg.(type) {
 		case DataSendEntryPack[DynamicToken]:
 			messages = append(messages, wgpstypes.MsgDataSendEntry[DynamicToken]{
@@ -108,22 +116,11 @@ func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicT
 					StaticTokenHandle: msg.StaticTokenHandle,
 				},
 			})
-			payloadIterator, err := msg.Payload.BytesWithOffset(int(msg.Offset))
+			payload, err := q.payloadMessages(msg.Payload, msg.Offset)
 			if err != nil {
 				return messages, err
-
-			}
-			for _, chunk := range payloadIterator {
-				transformed := q.Opts.TransformPayload([]byte{chunk})
-				messages = append(messages, wgpstypes.MsgDataSendPayload{
-					Kind: wgpstypes.DataSendPayload,
-					Data: wgpstypes.MsgDataSendPayloadData{
-						Amount: uint64(len(transformed)),
-						Bytes:  transformed,
-					},
-				})
-
 			}
+			messages = append(messages, payload...)
 
 		case DataBindPayloadRequestPack:
 			messages = append(messages, wgpstypes.MsgDataReplyPayload{
@@ -132,27 +129,40 @@ func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicT
 					Handle: msg.Handle,
 				},
 			})
-
-			payloadIterator, err := msg.Payload.BytesWithOffset(int(msg.Offset))
+			payload, err := q.payloadMessages(msg.Payload, msg.Offset)
 			if err != nil {
 				return messages, err
-
-			}
-			for _, chunk := range payloadIterator {
-				transformed := q.Opts.TransformPayload([]byte{chunk})
-				messages = append(messages, wgpstypes.MsgDataSendPayload{
-					Kind: wgpstypes.DataSendPayload,
-					Data: wgpstypes.MsgDataSendPayloadData{
-						Amount: uint64(len(transformed)),
-						Bytes:  transformed,
-					},
-				})
-
 			}
-
+			messages = append(messages, payload...)
 		}
-
 	}
 
 	return messages, nil
 }
+
+// A payload from an offset, split into chunks
+func (q *DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]) payloadMessages(payload datamodeltypes.Payload, offset uint64) ([]wgpstypes.SyncMessage, error) {
+	if payload.Length == nil {
+		return nil, nil
+	}
+	if length, err := payload.Length(); err != nil || length <= offset {
+		return nil, nil
+	}
+	bytes, err := payload.BytesWithOffset(int(offset))
+	if err != nil {
+		return nil, err
+	}
+	var messages []wgpstypes.SyncMessage
+	for start := 0; start < len(bytes); start += q.Opts.PayloadChunkSize {
+		end := min(start+q.Opts.PayloadChunkSize, len(bytes))
+		transformed := q.Opts.TransformPayload(bytes[start:end])
+		messages = append(messages, wgpstypes.MsgDataSendPayload{
+			Kind: wgpstypes.DataSendPayload,
+			Data: wgpstypes.MsgDataSendPayloadData{
+				Amount: uint64(len(transformed)),
+				Bytes:  transformed,
+			},
+		})
+	}
+	return messages, nil
+}
@@ -1,157 +1,205 @@
 package data
 
 import (
-	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/syncutils"
+	"fmt"
 
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
 	"golang.org/x/exp/constraints"
 )
 
-// Define interfaces and structs to represent the union types.
+// The progress of receiving the payload of a request, reported on the channel of the request
+type PayloadProgress struct {
+	Received uint64 // Bytes of the payload received so far, counting from its start rather than from the offset
+	Length   uint64 // Length of the whole payload
+	Done     bool   // Whether the request was answered, no progress is reported afterwards
+	Err      error  // Why the payload could not be stored, only set once the request is done
+}
 
-// Define an interface that can be implemented by both []byte and CANCELLATION.
-//type FIFOItem interface{}
+type PayloadIngesterOpts[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken string, AuthorisationOpts []byte] struct {
+	// The payload requests we bound, whose replies are ingested
+	HandlesPayloadRequestsOurs *handlestore.HandleStore[PayloadRequest]
+	Store                      *store.Store[Prefingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken]
+	ProcessReceivedPayload     func(chunk []byte, entryLength uint64) []byte // Leaves payloads as they are when nil
+}
 
-// Modify the FIFO struct to use FIFOItem.
-//type FIFO struct {
-//	Items []FIFOItem // Now can hold both []byte and CANCELLATION
-//}
+/*
+PayloadIngester receives the replies to our payload requests, and the payloads of the entries the other peer sends
+us, on the data channel. A reply starts with the handle of the request and an entry starts with the entry, followed by
+the payload from the offset either was sent from, which is stored once it is complete or once the next reply or entry
+starts. Answered requests are freed, and the messages telling the other peer so are queued.
+*/
+type PayloadIngester[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken string, AuthorisationOpts []byte] struct {
+	Opts PayloadIngesterOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]
+	// The progress channels of the requests which were not answered yet, by handle
+	Progress      map[uint64]chan PayloadProgress
+	InternalQueue []wgpstypes.SyncMessage
+
+	// The payload currently being received, if any
+	current *ingestion
+}
 
-type CurrentIngestion struct {
-	Kind           string // Active or Cancelled
-	FifoQueue      chan Event
-	ReceivedLength uint64       // bigint in TypeScript is closest to int64 in Go
-	Entry          *types.Entry // Assuming Entry is defined elsewhere
+type ingestion struct {
+	Handle    uint64
+	Requested bool // Whether we requested the payload, or it follows an entry
+	Request   PayloadRequest
+	Payload   []byte
+}
 
+func NewPayloadIngester[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken string, AuthorisationOpts []byte](
+	opts PayloadIngesterOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts],
+) *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts] {
+	if opts.ProcessReceivedPayload == nil {
+		opts.ProcessReceivedPayload = func(chunk []byte, entryLength uint64) []byte { return chunk }
+	}
+	return &PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]{
+		Opts:     opts,
+		Progress: make(map[uint64]chan PayloadProgress),
+	}
 }
 
-type Event struct {
-	Entry  *types.Entry
-	Data   []byte
-	Cancel bool
+// Returns the channel the progress of a payload request we bound to a handle is reported on
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) Expect(handle uint64) <-chan PayloadProgress {
+	// Buffered for every chunk of a payload of the default chunk size, so a slow reader does not hold up the session
+	progress := make(chan PayloadProgress, 64)
+	p.Progress[handle] = progress
+	return progress
 }
 
-type PayloadIngesterOpts[PreFingerPrint, FingerPrint string, K constraints.Unsigned, AuthorisationToken string, AuthorisationOpts []byte] struct {
-	GetStore               wgpstypes.GetStoreFn[PreFingerPrint, FingerPrint, K, AuthorisationToken, AuthorisationOpts]
-	ProcessReceivedPayload func(bytes []byte, entryLength uint64) []byte
+// Starts receiving the reply to the payload request bound to a handle, the previous reply is complete
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) Target(handle uint64) error {
+	p.Terminate()
+	request, found := p.Opts.HandlesPayloadRequestsOurs.Get(handle)
+	if !found {
+		return fmt.Errorf("received a reply to payload request %v which we did not bind", handle)
+	}
+	p.current = &ingestion{Handle: handle, Requested: true, Request: request}
+	return nil
 }
 
-// PayloadIngester struct modified to include the currentIngestion field.
-// This class can handle both the payload sending procedures for payloads sent via reconciliation AND data channels. It would probably be better to split them up.
-type PayloadIngester[Prefingerprint, Fingerprint string, AuthorisationToken string, AuthorisationOpts []byte] struct {
-	CurrentIngestion       CurrentIngestion
-	Events                 chan Event
-	ProcessReceivedPayload func(bytes []byte, entryLength uint64) []byte
-	// Add a pointer to Entry, which can be nil
-	EntryToRequestPayloadFor *types.Entry
-	//getStore                 GetStoreFn[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts, NamespaceId, SubspaceId, PayloadDigest]
-	//processReceivedPayload   func(bytes []byte, entryLength uint64) []byte
+// Starts receiving the payload of an entry the other peer sent us from an offset, the previous payload is complete
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) TargetEntry(entry types.Entry, offset uint64) {
+	p.Terminate()
+	if offset < entry.Payload_length {
+		p.current = &ingestion{Request: PayloadRequest{Entry: entry, Offset: offset}}
+	}
 }
 
-// NewPayloadIngester creates a new PayloadIngester with the initial state.
-func NewPayloadIngester[Prefingerprint, Fingerprint string, K constraints.Unsigned, AuthorisationToken string, AuthorisationOpts []byte](
-	opts PayloadIngesterOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts],
-) PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts] {
-
-	var newPayloadIngester PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts]
-	newPayloadIngester.ProcessReceivedPayload = opts.ProcessReceivedPayload
-	newPayloadIngester.Events = make(chan Event, 32)
-	newPayloadIngester.CurrentIngestion = CurrentIngestion{
-		Kind: "Uninitialised",
-	}
-
-	go syncutils.AsyncReceive[Event](newPayloadIngester.Events, func(value Event) error {
-
-		if value.Cancel {
-			if newPayloadIngester.CurrentIngestion.Kind == "Active" {
-				newPayloadIngester.CurrentIngestion.FifoQueue <- Event{
-					Cancel: true,
-				}
-				newPayloadIngester.CurrentIngestion.Kind = "Cancelled"
-			} else if value.Entry != nil {
-				if newPayloadIngester.CurrentIngestion.Kind == "Active" {
-					newPayloadIngester.CurrentIngestion.FifoQueue <- Event{
-						Cancel: true,
-					}
-					newPayloadIngester.CurrentIngestion = CurrentIngestion{
-						Kind:  "Pending",
-						Entry: value.Entry,
-					}
-
-				}
-			} else {
-				if newPayloadIngester.CurrentIngestion.Kind == "Active" {
-					transformed := newPayloadIngester.ProcessReceivedPayload(value.Data, newPayloadIngester.CurrentIngestion.Entry.Payload_length)
-					newPayloadIngester.CurrentIngestion.ReceivedLength += uint64(len(transformed))
-					newPayloadIngester.CurrentIngestion.FifoQueue <- Event{
-						Entry:  newPayloadIngester.CurrentIngestion.Entry,
-						Data:   transformed,
-						Cancel: false,
-					}
-
-				} else if newPayloadIngester.CurrentIngestion.Kind == "Pending" {
-
-					store := opts.GetStore(newPayloadIngester.CurrentIngestion.Entry.Namespace_id)
-					fifoQueue := make(chan Event, 32)
-					transformed := newPayloadIngester.ProcessReceivedPayload(value.Data, newPayloadIngester.CurrentIngestion.Entry.Payload_length)
-					fifoQueue <- Event{
-						Entry:  newPayloadIngester.CurrentIngestion.Entry,
-						Data:   transformed,
-						Cancel: false,
-					}
-					store.IngestPayload(
-						types.Position3d{
-							Subspace: newPayloadIngester.CurrentIngestion.Entry.Subspace_id,
-							Path:     newPayloadIngester.CurrentIngestion.Entry.Path,
-							Time:     newPayloadIngester.CurrentIngestion.Entry.Timestamp,
-						}, transformed, false, 0,
-					)
-					entry := newPayloadIngester.CurrentIngestion.Entry
-					newPayloadIngester.CurrentIngestion = CurrentIngestion{
-						Kind:           "Active",
-						FifoQueue:      fifoQueue,
-						ReceivedLength: uint64(len(transformed)),
-						Entry:          entry,
-					}
-				}
-
-			}
-		}
+// Receives the next chunk of the payload currently being received, payload bytes nobody asked for are dropped
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) Push(chunk []byte) {
+	if p.current == nil {
+		return
+	}
+	current := p.current
+	current.Payload = append(current.Payload, p.Opts.ProcessReceivedPayload(chunk, current.Request.Entry.Payload_length)...)
 
-		return nil
-	}, nil)
+	received := current.Request.Offset + uint64(len(current.Payload))
+	if received >= current.Request.Entry.Payload_length {
+		p.Terminate()
+		return
+	}
+	p.report(current.Handle, PayloadProgress{Received: received, Length: current.Request.Entry.Payload_length})
+}
+
+/*
+Stores what was received of the payload currently being received, and frees its request if we requested it. A peer which does not hold
+the complete payload sends what it has, so an incomplete payload is stored as well.
+*/
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) Terminate() {
+	if p.current == nil {
+		return
+	}
+	current := p.current
+	p.current = nil
 
-	return newPayloadIngester
+	progress := p.store(current)
+	if !current.Requested {
+		return
+	}
+	p.report(current.Handle, progress)
 
+	if p.Opts.HandlesPayloadRequestsOurs.Free(current.Handle) == nil {
+		p.InternalQueue = append(p.InternalQueue, wgpstypes.MsgControlFree{
+			Kind: wgpstypes.ControlFree,
+			Data: wgpstypes.MsgControlFreeData{Handle: current.Handle, Mine: true, HandleType: wgpstypes.PayloadRequestHandle},
+		})
+	}
 }
 
-func (p *PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts]) Target(entry types.Entry, requestifImmediatelyTerminated bool) {
-	p.Events <- Event{
-		Entry:  &entry,
-		Cancel: false,
+/*
+Stores what was received of the payload currently being received once the session it was received in ended, and ends
+every request which was not answered with the given error. Returns the payloads left incomplete, which another session
+may resume from what is stored of them.
+*/
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) Abort(err error) []PayloadRequest {
+	var interrupted []PayloadRequest
+	if current := p.current; current != nil {
+		p.current = nil
+		progress := p.store(current)
+		if progress.Err == nil {
+			progress.Err = err
+		}
+		interrupted = append(interrupted, current.Request)
+		if current.Requested {
+			p.report(current.Handle, progress)
+		}
 	}
-	if requestifImmediatelyTerminated {
-		p.EntryToRequestPayloadFor = &entry //
+	for handle := range p.Progress {
+		request, found := p.Opts.HandlesPayloadRequestsOurs.Get(handle)
+		if found {
+			interrupted = append(interrupted, request)
+		}
+		p.report(handle, PayloadProgress{Received: request.Offset, Length: request.Entry.Payload_length, Done: true, Err: err})
 	}
+	return interrupted
 }
 
-func (p *PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts]) Push(bytes []byte, end bool) {
-	p.Events <- Event{
-		Data: bytes,
+// Stores what was received of a payload, and returns the progress of receiving it once it is done
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) store(current *ingestion) PayloadProgress {
+	entry := current.Request.Entry
+	progress := PayloadProgress{
+		Received: current.Request.Offset + uint64(len(current.Payload)),
+		Length:   entry.Payload_length,
+		Done:     true,
 	}
-	if end {
-		p.Events <- Event{
-			Cancel: true,
-		}
+	if len(current.Payload) > 0 {
+		_, progress.Err = p.Opts.Store.IngestPayload(types.Position3d{
+			Subspace: entry.Subspace_id,
+			Path:     entry.Path,
+			Time:     entry.Timestamp,
+		}, current.Payload, true, int64(current.Request.Offset))
 	}
-	p.EntryToRequestPayloadFor = nil
+	return progress
+}
+
+// Takes the messages queued so far, leaving the queue empty
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) Messages() []wgpstypes.SyncMessage {
+	queue := p.InternalQueue
+	p.InternalQueue = nil
+	return queue
 }
 
-// Returns the entry to request a payload for or null
-func (p *PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts]) Terminate() *types.Entry {
-	p.Events <- Event{
-		Cancel: true,
+// Reports progress without ever blocking, a reader which falls behind only misses intermediate progress
+func (p *PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]) report(handle uint64, progress PayloadProgress) {
+	channel, found := p.Progress[handle]
+	if !found {
+		return
+	}
+	if progress.Done {
+		delete(p.Progress, handle)
+		// Make room for the final progress, which must not be missed
+		select {
+		case <-channel:
+		default:
+		}
+		channel <- progress
+		close(channel)
+		return
+	}
+	select {
+	case channel <- progress:
+	default:
 	}
-	return p.EntryToRequestPayloadFor
 }
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+type testStore = store.Store[string, string, uint, []byte, string]
+
+// Stores keep their entries in memory and their payloads in a directory of the test
+func newTestStore(t *testing.T) *testStore {
+	s := pinagoladastore.InitMemoryStorage(types.NamespaceId("Test"), t.TempDir())
+	pinagoladastore.InitKDTree(s)
+	return s
+}
+
+func TestPayloadIngesterReceivesRequestedPayloads(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	for _, path := range []string{"video", "gone"} {
+		_, err := storeAlfie.Set(datamodeltypes.EntryInput{
+			Subspace:  types.SubspaceId("alfie"),
+			Path:      types.Path{[]byte(path)},
+			Payload:   []byte("the payload of the " + path),
+			Timestamp: 1000,
+		}, []byte("alfie"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := storeAlfie.EntryDriver.Query(utils.DefaultRange3d(types.SubspaceId("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]types.Entry{}
+	for _, entry := range entries {
+		// Betty only holds the entries, and requests their payloads
+		if _, err := storeBetty.IngestEntry(entry.Entry, "alfie"); err != nil {
+			t.Fatal(err)
+		}
+		byPath[string(entry.Entry.Path[0])] = entry.Entry
+	}
+	// Alfie lost one of the payloads since
+	if _, err := storeAlfie.PayloadDriver.Erase(byPath["gone"].Payload_digest); err != nil {
+		t.Fatal(err)
+	}
+
+	requestsAlfie := handlestore.HandleStore[PayloadRequest]{Map: handlestore.NewMap[PayloadRequest]()}
+	requestsBetty := handlestore.HandleStore[PayloadRequest]{Map: handlestore.NewMap[PayloadRequest]()}
+	sender := NewDataSender(DataSenderOpts[string, string, uint, string, string, []byte]{
+		HandlesPayloadRequestsTheirs: &requestsAlfie,
+		Store:                        storeAlfie,
+		PayloadChunkSize:             4,
+	})
+	ingester := NewPayloadIngester(PayloadIngesterOpts[string, string, uint, string, []byte]{
+		HandlesPayloadRequestsOurs: &requestsBetty,
+		Store:                      storeBetty,
+	})
+
+	// Betty binds her requests, alfie binds them in turn and answers them
+	request := func(path string) (uint64, <-chan PayloadProgress) {
+		handle := requestsBetty.Bind(PayloadRequest{Entry: byPath[path]})
+		progress := ingester.Expect(handle)
+		if err := sender.QueuePayloadRequest(requestsAlfie.Bind(PayloadRequest{Entry: byPath[path]})); err != nil {
+			t.Fatal(err)
+		}
+		return handle, progress
+	}
+	video, videoProgress := request("video")
+	gone, goneProgress := request("gone")
+
+	messages, err := sender.Messages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range messages {
+		switch msg := msg.(type) {
+		case wgpstypes.MsgDataReplyPayload:
+			if err := ingester.Target(msg.Data.Handle); err != nil {
+				t.Fatal(err)
+			}
+		case wgpstypes.MsgDataSendPayload:
+			ingester.Push(msg.Data.Bytes)
+		default:
+			t.Fatalf("unexpected %T in reply to payload requests", msg)
+		}
+	}
+
+	var last PayloadProgress
+	reports := 0
+	for progress := range videoProgress {
+		reports++
+		if progress.Received < last.Received {
+			t.Errorf("progress went back from %d to %d", last.Received, progress.Received)
+		}
+		last = progress
+	}
+	if !last.Done || last.Err != nil || last.Received != byPath["video"].Payload_length || reports < 2 {
+		t.Errorf("expected progress up to the complete video, last reported %+v after %d reports", last, reports)
+	}
+	if available := storeBetty.AvailablePayload(byPath["video"].Payload_digest); available != byPath["video"].Payload_length {
+		t.Errorf("betty holds %d bytes of the video, expected %d", available, byPath["video"].Payload_length)
+	}
+
+	// The reply to the lost payload is only complete once another reply starts or the ingester terminates it
+	ingester.Terminate()
+	last = <-goneProgress
+	if !last.Done || last.Received != 0 {
+		t.Errorf("expected the request of the lost payload to be answered without bytes, reported %+v", last)
+	}
+
+	freed := map[uint64]bool{}
+	for _, msg := range ingester.Messages() {
+		freed[msg.(wgpstypes.MsgControlFree).Data.Handle] = true
+	}
+	if !freed[video] || !freed[gone] || requestsBetty.CanUse(video) || requestsBetty.CanUse(gone) {
+		t.Errorf("expected both answered requests to be freed, freed %v", freed)
+	}
+}
+
+func TestPayloadIngesterResumesWhatAnEndedSessionReceived(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	for _, path := range []string{"video", "later"} {
+		_, err := storeAlfie.Set(datamodeltypes.EntryInput{
+			Subspace:  types.SubspaceId("alfie"),
+			Path:      types.Path{[]byte(path)},
+			Payload:   []byte("the payload of the " + path + ", which takes a while"),
+			Timestamp: 1000,
+		}, []byte("alfie"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := storeAlfie.EntryDriver.Query(utils.DefaultRange3d(types.SubspaceId("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]types.Entry{}
+	for _, entry := range entries {
+		if _, err := storeBetty.IngestEntry(entry.Entry, "alfie"); err != nil {
+			t.Fatal(err)
+		}
+		byPath[string(entry.Entry.Path[0])] = entry.Entry
+	}
+	video := byPath["video"]
+
+	// Betty receives part of the video in a session, and requests another payload which is never answered
+	requestsAlfie := handlestore.HandleStore[PayloadRequest]{Map: handlestore.NewMap[PayloadRequest]()}
+	requestsBetty := handlestore.HandleStore[PayloadRequest]{Map: handlestore.NewMap[PayloadRequest]()}
+	ingester := NewPayloadIngester(PayloadIngesterOpts[string, string, uint, string, []byte]{
+		HandlesPayloadRequestsOurs: &requestsBetty,
+		Store:                      storeBetty,
+	})
+	videoProgress := ingester.Expect(requestsBetty.Bind(PayloadRequest{Entry: video}))
+	laterProgress := ingester.Expect(requestsBetty.Bind(PayloadRequest{Entry: byPath["later"]}))
+	if err := ingester.Target(0); err != nil {
+		t.Fatal(err)
+	}
+	ingester.Push([]byte("the payload"))
+
+	lost := fmt.Errorf("the session ended")
+	interrupted := ingester.Abort(lost)
+	if len(interrupted) != 2 {
+		t.Fatalf("expected both requests to be interrupted, got %v", interrupted)
+	}
+	for progress := range videoProgress {
+		if progress.Done && (progress.Err != lost || progress.Received != 11) {
+			t.Errorf("expected the video to end with the session after 11 bytes, reported %+v", progress)
+		}
+	}
+	if last := <-laterProgress; !last.Done || last.Err != lost {
+		t.Errorf("expected the unanswered request to end with the session, reported %+v", last)
+	}
+	if partial := storeBetty.PartialPayload(video.Payload_digest); partial != 11 {
+		t.Fatalf("expected betty to hold 11 bytes of the video, she holds %d", partial)
+	}
+
+	// The next session resumes the video where the last one ended
+	requestsAlfie = handlestore.HandleStore[PayloadRequest]{Map: handlestore.NewMap[PayloadRequest]()}
+	requestsBetty = handlestore.HandleStore[PayloadRequest]{Map: handlestore.NewMap[PayloadRequest]()}
+	sender := NewDataSender(DataSenderOpts[string, string, uint, string, string, []byte]{
+		HandlesPayloadRequestsTheirs: &requestsAlfie,
+		Store:                        storeAlfie,
+		PayloadChunkSize:             4,
+	})
+	ingester = NewPayloadIngester(PayloadIngesterOpts[string, string, uint, string, []byte]{
+		HandlesPayloadRequestsOurs: &requestsBetty,
+		Store:                      storeBetty,
+	})
+	resumed := PayloadRequest{Entry: video, Offset: storeBetty.PartialPayload(video.Payload_digest)}
+	videoProgress = ingester.Expect(requestsBetty.Bind(resumed))
+	if err := sender.QueuePayloadRequest(requestsAlfie.Bind(resumed)); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := sender.Messages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sentBytes := 0
+	for _, msg := range messages {
+		switch msg := msg.(type) {
+		case wgpstypes.MsgDataReplyPayload:
+			if err := ingester.Target(msg.Data.Handle); err != nil {
+				t.Fatal(err)
+			}
+		case wgpstypes.MsgDataSendPayload:
+			sentBytes += len(msg.Data.Bytes)
+			ingester.Push(msg.Data.Bytes)
+		}
+	}
+	var last PayloadProgress
+	for progress := range videoProgress {
+		last = progress
+	}
+	if !last.Done || last.Err != nil || last.Received != video.Payload_length {
+		t.Errorf("expected the resumed video to be complete, last reported %+v", last)
+	}
+	if sentBytes != int(video.Payload_length)-11 {
+		t.Errorf("expected only the rest of the video to be sent, %d bytes were", sentBytes)
+	}
+	payload, err := storeBetty.PayloadDriver.Get(video.Payload_digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload.Bytes()) != "the payload of the video, which takes a while" {
+		t.Errorf("expected betty to hold the video, she holds %q", payload.Bytes())
+	}
+}
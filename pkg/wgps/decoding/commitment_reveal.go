@@ -1,20 +1,46 @@
 package decoding
 
 import (
+	"fmt"
+
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/utils"
 )
 
-func DecodeCommitmentReveal(bytes *utils.GrowingBytes, challengeLength int) wgpstypes.MsgCommitmentReveal {
+func DecodeCommitmentReveal(bytes *utils.GrowingBytes, challengeLength int) (wgpstypes.MsgCommitmentReveal, error) {
 	CommitmentBytes := bytes.NextAbsolute(1 + challengeLength)
+	if len(CommitmentBytes) < 1+challengeLength {
+		return wgpstypes.MsgCommitmentReveal{}, fmt.Errorf("the stream ended in the middle of a commitment reveal")
+	}
+	nonce := append([]byte{}, CommitmentBytes[1:1+challengeLength]...)
 
 	bytes.Prune(1 + challengeLength)
 
 	return wgpstypes.MsgCommitmentReveal{
 		Kind: wgpstypes.CommitmentReveal,
 		Data: wgpstypes.MsgCommitmentRevealData{
-			Nonce: CommitmentBytes[1 : 1+challengeLength],
+			Nonce: nonce,
 		},
+	}, nil
+
+}
+
+/*
+DecodeOpening decodes the bytes each peer sends on the control channel before any message, returning the power of
+two of its maximum payload size and the hash of the nonce it committed to.
+*/
+func DecodeOpening(bytes *utils.GrowingBytes, challengeHashLength int) (uint8, []byte, error) {
+	openingBytes := bytes.NextAbsolute(1 + challengeHashLength)
+	if len(openingBytes) < 1+challengeHashLength {
+		return 0, nil, fmt.Errorf("the stream ended before the commitment of the other peer")
 	}
+	maxPayloadSizePower := openingBytes[0]
+	commitment := append([]byte{}, openingBytes[1:1+challengeHashLength]...)
+
+	bytes.Prune(1 + challengeHashLength)
 
+	if maxPayloadSizePower > 64 {
+		return 0, nil, fmt.Errorf("the maximum payload size power %d of the other peer exceeds 64", maxPayloadSizePower)
+	}
+	return maxPayloadSizePower, commitment, nil
 }
@@ -67,7 +67,7 @@ func DecodeControlIssueGuarantee(bytes *utils.GrowingBytes) wgpstypes.MsgControl
 	bytes.Prune(2 + CompactWidth)
 
 	return wgpstypes.MsgControlIssueGuarantee{
-		Kind: wgpstypes.ControlAbsolve,
+		Kind: wgpstypes.ControlIssueGuarantee,
 		Data: wgpstypes.ControlIssueGuaranteeData{
 			Channel: Channel,
 			Amount:  uint64(Amount),
@@ -165,7 +165,7 @@ func DecodeControlFree(bytes *utils.GrowingBytes) wgpstypes.MsgControlFree {
 		Data: wgpstypes.MsgControlFreeData{
 			HandleType: HandleType,
 			Handle:     uint64(Handle),
-			Mine:       true,
+			Mine:       (width2[1] & 0x10) == 0x10,
 		},
 	}
 
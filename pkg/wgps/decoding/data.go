@@ -1,8 +1,6 @@
 package decoding
 
 import (
-	"fmt"
-
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
 	"github.com/PES-Innovation-Lab/willow-go/utils"
@@ -110,9 +108,8 @@ func DecodeDataSendEntry[DynamicToken string, ValueType constraints.Unsigned](by
 				PathScheme:                opts.PathScheme,
 			}, bytes, opts.AoiHandlesToArea(SenderHandle, ReceiverHandle), opts.AoiHandlesToNamespace(SenderHandle, ReceiverHandle),
 		) //gotta check this out
-	} else {
-		fmt.Errorf("could not decode entry encoded relative to area when no handles are provided")
 	}
+	// Without handles there is nothing the entry could have been encoded relative to, so it stays empty
 
 	if !IsOffsetEncoded {
 		if IsOffsetPayloadLengthOrZero {
@@ -144,9 +141,9 @@ func DecodeDataSendPayload(bytes *utils.GrowingBytes) wgpstypes.MsgDataSendPaylo
 
 	Amount, _ := utils.DecodeIntMax64(received[1 : 1+CompactWidthAmount])
 
-	bytes.Prune(1 + CompactWidthAmount + int(Amount))
+	received = bytes.NextAbsolute(1 + CompactWidthAmount + int(Amount))
 
-	MsgBytes := received[1+CompactWidthAmount : 1+CompactWidthAmount+int(Amount)]
+	MsgBytes := append([]byte{}, received[1+CompactWidthAmount:1+CompactWidthAmount+int(Amount)]...)
 
 	bytes.Prune(1 + CompactWidthAmount + int(Amount))
 
@@ -232,7 +229,7 @@ func DecodeDataBindPayloadRequest[ValueType constraints.Unsigned](bytes *utils.G
 		CompactWidthReceiverHandle = CompactWidthFromEndOfByte(int(SecondByte))
 	}
 
-	width1 := bytes.NextAbsolute(CompactWidthCapability + 2)
+	width1 := bytes.NextAbsolute(2 + CompactWidthCapability + CompactWidthOffset)
 
 	Capability, _ := utils.DecodeIntMax64(width1[2 : 2+CompactWidthCapability])
 
@@ -276,9 +273,8 @@ func DecodeDataBindPayloadRequest[ValueType constraints.Unsigned](bytes *utils.G
 				PathScheme:                opts.PathScheme,
 			}, bytes, opts.AoiHandlesToArea(SenderHandle, ReceiverHandle), opts.AoiHandlesToNamespace(SenderHandle, ReceiverHandle),
 		)
-	} else {
-		fmt.Errorf("Could not decode")
 	}
+	// Without handles there is nothing the entry could have been encoded relative to, so it stays empty
 	return wgpstypes.MsgDataBindPayloadRequest{
 		Kind: wgpstypes.DataBindPayloadRequest,
 		Data: wgpstypes.MsgDataBindPayloadRequestData{
@@ -16,7 +16,7 @@ type DecodeMessageOpts[
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -51,21 +51,32 @@ type DecodeMessageOpts[
 		K,
 	]
 	//Transport *transport.QuicTransport
-	//ChallengeLength int
-	//GetIntersectionPrivy      func(handle uint64) wgpstypes.ReadCapPrivy
-	//GetTheirCap               func(handle uint64) ReadCapability
+	// The area granted by the read capability the other peer bound to a handle, which may wait for it to be bound
+	GetCapabilityArea func(handle uint64) (types.Area, error)
+	ChallengeLength   int
+	// Called with the opening of the other peer, which comes before any message on the control channel
+	ReceiveOpening      func(maxPayloadSizePower uint8, commitment []byte)
+	ChallengeHashLength int
+	// Called with the length in bytes of every decoded message, messages it does not accept are dropped
+	AcceptMessage             func(length uint64) bool
 	GetCurrentlyReceivedEntry func() types.Entry
 	AoiHandlesToNamespace     func(senderHandle uint64, receiverHandle uint64) types.NamespaceId
 	AoiHandlesToArea          func(senderHandle uint64, receiverHandle uint64) types.Area
 }
 
+/*
+DecodeMessages decodes the bytes arriving on inChannel, which all belong to one logical channel, into messages
+on outChannel, leaving out the ones AcceptMessage does not accept. When ReceiveOpening is set the bytes start with
+the opening of the other peer. It returns once inChannel is closed, or with an error once it comes across bytes it
+cannot decode, in both cases closing outChannel.
+*/
 func DecodeMessages[
 	ReadCapability any,
 	Receiver types.SubspaceId,
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -95,121 +106,208 @@ func DecodeMessages[
 	DynamicToken,
 	AuthorisationOpts,
 	K,
-], inChannel chan []byte, outChannel chan wgpstypes.SyncMessage) {
+], inChannel chan []byte, outChannel chan wgpstypes.SyncMessage) (err error) {
+	defer close(outChannel)
+
 	reconcilerMsgTracker := reconciliation.NewReconcileMsgTracker[Fingerprint, DynamicToken](opts.Reconcile)
 
 	bytes := utils.NewGrowingBytes(inChannel)
 
+	// The decoders index into the bytes they asked for, which come up short when the stream ends part way through a message
+	// or when the message is malformed. Either way the other peer sent something we can not make sense of.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if bytes.IsClosed() {
+				err = fmt.Errorf("the stream ended in the middle of a message")
+				return
+			}
+			err = fmt.Errorf("could not decode a malformed message: %v", recovered)
+		}
+	}()
+
+	getCurrentlyReceivedEntry := func() types.Entry {
+		if opts.GetCurrentlyReceivedEntry == nil {
+			return types.Entry{}
+		}
+		return opts.GetCurrentlyReceivedEntry()
+	}
+
+	if opts.ReceiveOpening != nil {
+		maxPayloadSizePower, commitment, err := DecodeOpening(bytes, opts.ChallengeHashLength)
+		if err != nil {
+			return err
+		}
+		opts.ReceiveOpening(maxPayloadSizePower, commitment)
+	}
+
 	for {
 		received := bytes.NextAbsolute(1)
+		if len(received) == 0 {
+			// The stream ended cleanly
+			return nil
+		}
 
 		FirstByte := received[0]
+		start := bytes.Pruned
+		var message wgpstypes.SyncMessage
 
 		if FirstByte == 0x0 {
-			//outChannel <- DecodeCommitmentReveal(bytes, opts.ChallengeLength)
+			Message, err := DecodeCommitmentReveal(bytes, opts.ChallengeLength)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else if (FirstByte & 0x98) == 0x98 {
 			// Control aplogise
-			outChannel <- DecodeControlApologise(bytes)
+			message = DecodeControlApologise(bytes)
 		} else if (FirstByte & 0x90) == 0x90 {
 			// Control announce dropping
-			outChannel <- DecodeControlAnnounceDropping(bytes)
+			message = DecodeControlAnnounceDropping(bytes)
 		} else if (FirstByte & 0x8c) == 0x8c {
 			// Control free
-			outChannel <- DecodeControlFree(bytes)
+			message = DecodeControlFree(bytes)
 		} else if (FirstByte & 0x88) == 0x88 {
 			// Control plead
-			outChannel <- DecodeControlPlead(bytes)
+			message = DecodeControlPlead(bytes)
 		} else if (FirstByte & 0x84) == 0x84 {
 			// Control Absolve
-			outChannel <- DecodeControlAbsolve(bytes)
+			message = DecodeControlAbsolve(bytes)
 		} else if (FirstByte & 0x80) == 0x80 {
 			// Control Issue Guarantee.
-			outChannel <- DecodeControlIssueGuarantee(bytes)
+			message = DecodeControlIssueGuarantee(bytes)
 		} else if (FirstByte & 0x70) == 0x70 {
 			// Data Reply Payload
-			outChannel <- DecodeDataReplyPayload(bytes)
+			message = DecodeDataReplyPayload(bytes)
 		} else if (FirstByte & 0x6c) == 0x6c {
 			// Data Bind Payload request
-			outChannel <- DecodeDataBindPayloadRequest(bytes, DecodeOpts[K]{
+			message = DecodeDataBindPayloadRequest(bytes, DecodeOpts[K]{
 				DecodeNamespaceId:         opts.Schemes.NamespaceScheme.EncodingScheme.DecodeStream,
 				DecodeSubspaceId:          opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream,
+				DecodePayloadDigest:       opts.Schemes.Payload.EncodingScheme.DecodeStream,
 				PathScheme:                opts.Schemes.PathParams,
-				GetCurrentlyReceivedEntry: opts.GetCurrentlyReceivedEntry,
+				GetCurrentlyReceivedEntry: getCurrentlyReceivedEntry,
 				AoiHandlesToNamespace:     opts.AoiHandlesToNamespace,
 				AoiHandlesToArea:          opts.AoiHandlesToArea,
 			})
+		} else if (FirstByte & 0x68) == 0x68 {
+			// Data Set Metadata
+			message = DecodeDataSetEagerness(bytes)
+		} else if (FirstByte & 0x64) == 0x64 {
+			// Data Send Payload
+			message = DecodeDataSendPayload(bytes)
+		} else if (FirstByte & 0x60) == 0x60 {
+			// Data Send Entry
+			message = DecodeDataSendEntry(bytes, Opts[DynamicToken, K]{
+				DecodeNamespaceId: opts.Schemes.NamespaceScheme.EncodingScheme.DecodeStream,
+				DecodeSubspaceId:  opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream,
+				DecodeDynamicToken: func(bytes *utils.GrowingBytes) DynamicToken {
+					return <-opts.Schemes.AuthorisationToken.Encodings.DynamicToken.DecodeStream(bytes)
+				},
+				DecodePayloadDigest:    opts.Schemes.Payload.EncodingScheme.DecodeStream,
+				PathScheme:             opts.Schemes.PathParams,
+				CurrentlyReceivedEntry: getCurrentlyReceivedEntry(),
+				AoiHandlesToArea:       opts.AoiHandlesToArea,
+				AoiHandlesToNamespace:  opts.AoiHandlesToNamespace,
+			})
 		} else if (FirstByte & 0x50) == 0x50 {
 			if reconcilerMsgTracker.IsExpectingPayloadOrTermination() {
-				// Reconciliation Send Entry
 				if (FirstByte & 0x58) == 0x58 {
-					bytes.Prune(1)
-
-					outChannel <- wgpstypes.MsgReconciliationSendPayload{
-						Kind: wgpstypes.ReconciliationSendPayload,
-					}
+					// Reconciliation Terminate Payload
+					reconcilerMsgTracker.OnTerminatePayload()
+					message = DecodeReconciliationTerminatePayload(bytes)
 				} else {
-					outChannel <- DecodeReconciliationSendPayload(bytes)
+					// Reconciliation Send Payload
+					message = DecodeReconciliationSendPayload(bytes)
 				}
 			} else if reconcilerMsgTracker.IsExpectingReconciliationSendEntry() {
-				var tracker reconciliation.ReconcileMsgTracker[Fingerprint, DynamicToken]
+				// Reconciliation Send Entry
 				Message := DecodeReconciliationSendEntry[DynamicToken](bytes, EntryOpts[DynamicToken, K]{
 					DecodeNamespaceId:   opts.Schemes.NamespaceScheme.EncodingScheme.DecodeStream,
 					DecodeSubspaceId:    opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream,
 					PathScheme:          opts.Schemes.PathParams,
 					DecodeDynamicToken:  opts.Schemes.AuthorisationToken.Encodings.DynamicToken.DecodeStream,
 					DecodePayloadDigest: opts.Schemes.Payload.EncodingScheme.DecodeStream,
-					GetPrivy:            tracker.GetPrivy,
+					GetPrivy:            reconcilerMsgTracker.GetPrivy,
 				})
 				reconcilerMsgTracker.OnSendEntry(Message)
-				outChannel <- Message
+				message = Message
 			} else {
-				Message := DecodeReconciliationAnnounceEntries(bytes, AnnounceOpts[K]{ //NEED TO CHECK ANNOUNCEOPTS ONCE, THERE MIGHT BE A FIELD MISMATCH
-					DecodeSubspaceId: opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream,
-					PathScheme:       opts.Schemes.PathParams,
+				// Reconciliation Announce Entries
+				Message := DecodeReconciliationAnnounceEntries(bytes, AnnounceOpts[K]{
+					DecodeSubspaceId:    opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream,
+					PathScheme:          opts.Schemes.PathParams,
+					GetPrivy:            reconcilerMsgTracker.GetPrivy,
+					AoiHandlesToRange3d: opts.Reconcile.AoiHandlesToRange3d,
 				})
 				reconcilerMsgTracker.OnAnnounceEntries(Message)
-				outChannel <- Message
+				message = Message
 			}
 		} else if (FirstByte & 0x40) == 0x40 {
 			// Reconciliation Send Fingerprint
-			var tracker reconciliation.ReconcileMsgTracker[Fingerprint, DynamicToken]
 			Message := DecodeReconciliationSendFingerprint(bytes, SendOpts[Fingerprint, K]{
 				NeutralFingerprint:  opts.Schemes.Fingerprint.NeutralFinalised,
 				DecodeFingerprint:   opts.Schemes.Fingerprint.Encoding.DecodeStream,
 				DecodeSubspaceId:    opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream,
 				PathScheme:          opts.Schemes.PathParams,
-				GetPrivy:            tracker.GetPrivy,
+				GetPrivy:            reconcilerMsgTracker.GetPrivy,
 				AoiHandlesToRange3d: opts.Reconcile.AoiHandlesToRange3d,
 			})
 			reconcilerMsgTracker.OnSendFingerprint(Message)
-			outChannel <- Message
+			message = Message
 		} else if (FirstByte & 0x30) == 0x30 {
 			// Setup Bind Static Token
-			// outChannel <- DecodeSetupBindStaticToken[string](bytes, opts.Schemes.AuthorisationToken.Encodings.StaticToken.DecodeStream)
+			Message, err := DecodeSetupBindStaticToken(bytes, opts.Schemes.AuthorisationToken.Encodings.StaticToken.DecodeStream)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else if (FirstByte & 0x28) == 0x28 {
 			// Setup Bind Area of Interest
-			// outChannel <- DecodeSetupBindAreaOfInterest(bytes, func(authHandle uint64) types.Area {
-			// 	Cap := opts.GetTheirCap(authHandle)
-			// 	outChannel <- opts.Schemes.AccessControl.GetGrantedArea(Cap)
-			// }, opts.Schemes.SubspaceScheme.EncodingScheme.DecodeStream, opts.Schemes.PathParams)
+			if opts.GetCapabilityArea == nil {
+				return fmt.Errorf("can not decode areas of interest without read capabilities")
+			}
+			Message, err := DecodeSetupBindAreaOfInterest(bytes, opts.GetCapabilityArea, opts.Schemes.SubspaceScheme.EncodingScheme, opts.Schemes.PathParams)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else if (FirstByte & 0x20) == 0x20 {
 			// Setup Bind Read Capability
-			// outChannel <- DecodeSetupBindReadCapability(bytes, opts.Schemes.AccessControl.Encodings.ReadCap, opts.GetIntersectionPrivy, opts.Schemes.AccessControl.Encodings.SyncSignature.DecodeStream)
+			Message, err := DecodeSetupBindReadCapability(bytes, opts.Schemes.AccessControl.Encodings.ReadCap, opts.Schemes.AccessControl.Encodings.SyncSignature.DecodeStream)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else if (FirstByte & 0x10) == 0x10 {
 			// PAI Reply Subspace Capability
-			outChannel <- DecodePaiReplySubspaceCapability(bytes, opts.Schemes.SubspaceCap.Encodings.SubspaceCapability.DecodeStream, opts.Schemes.SubspaceCap.Encodings.SyncSubspaceSignature.DecodeStream)
+			Message, err := DecodePaiReplySubspaceCapability(bytes, opts.Schemes.SubspaceCap.Encodings.SubspaceCapability.DecodeStream, opts.Schemes.SubspaceCap.Encodings.SyncSubspaceSignature.DecodeStream)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else if (FirstByte & 0xc) == 0xc {
 			// PAI Request Subspace Capability
-			outChannel <- DecodePaiRequestSubspaceCapability(bytes)
+			message = DecodePaiRequestSubspaceCapability(bytes)
 		} else if (FirstByte & 0x8) == 0x8 {
 			// PAI Reply Fragment
-			outChannel <- DecodePaiReplyFragment(bytes, opts.Schemes.Pai.GroupMemberEncoding.DecodeStream)
+			Message, err := DecodePaiReplyFragment(bytes, opts.Schemes.Pai.GroupMemberEncoding.DecodeStream)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else if (FirstByte & 0x4) == 0x4 {
 			// PAI Bind Fragment
-			outChannel <- DecodePaiBindFragment(bytes, opts.Schemes.Pai.GroupMemberEncoding.DecodeStream)
+			Message, err := DecodePaiBindFragment(bytes, opts.Schemes.Pai.GroupMemberEncoding.DecodeStream)
+			if err != nil {
+				return err
+			}
+			message = Message
 		} else {
-			fmt.Errorf("Could not decode")
+			return fmt.Errorf("could not decode a message starting with %#x", FirstByte)
+		}
+
+		if opts.AcceptMessage == nil || opts.AcceptMessage(uint64(bytes.Pruned-start)) {
+			outChannel <- message
 		}
-		outChannel <- nil
 	}
 }
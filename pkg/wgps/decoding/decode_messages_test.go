@@ -0,0 +1,455 @@
+package decoding
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/encoding"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/reconciliation"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+type testSchemes = wgpstypes.SyncSchemes[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint8]
+
+func newTestSchemes() testSchemes {
+	schemes := testSchemes{
+		NamespaceScheme: store.TestNameSpaceScheme,
+		SubspaceScheme:  store.TestSubspaceScheme,
+		PathParams:      store.TestPathParams,
+		Payload:         store.TestPayloadScheme,
+		Fingerprint:     store.TestFingerprintScheme,
+	}
+	schemes.AuthorisationToken.Encodings.StaticToken = utils.LengthPrefixedEncoding[string]()
+	schemes.AuthorisationToken.Encodings.DynamicToken = utils.LengthPrefixedEncoding[string]()
+	schemes.Pai.GroupMemberEncoding = utils.LengthPrefixedEncoding[string]()
+	schemes.SubspaceCap.Encodings.SubspaceCapability = utils.LengthPrefixedEncoding[string]()
+	schemes.SubspaceCap.Encodings.SyncSubspaceSignature = utils.LengthPrefixedEncoding[string]()
+	schemes.AccessControl.Encodings.SyncSignature = utils.LengthPrefixedEncoding[string]()
+	// Read capabilities are encoded without regard for the intersection they are bound for
+	readCaps := utils.LengthPrefixedEncoding[string]()
+	schemes.AccessControl.Encodings.ReadCap.Encode = func(capability string, privy wgpstypes.ReadCapPrivy) []byte {
+		return readCaps.Encode(capability)
+	}
+	schemes.AccessControl.Encodings.ReadCap.DecodeStream = func(bytes *utils.GrowingBytes) (string, error) {
+		capability, ok := <-readCaps.DecodeStream(bytes)
+		if !ok {
+			return "", fmt.Errorf("the stream ended in the middle of a read capability")
+		}
+		return capability, nil
+	}
+	return schemes
+}
+
+func testEntry(subspace string, path string, timestamp uint64, payloadLength uint64) datamodeltypes.LengthyEntry {
+	return datamodeltypes.LengthyEntry{
+		Entry: types.Entry{
+			Namespace_id:   types.NamespaceId("Test"),
+			Subspace_id:    types.SubspaceId(subspace),
+			Path:           types.Path{[]byte("blog"), []byte(path)},
+			Timestamp:      timestamp,
+			Payload_length: payloadLength,
+			Payload_digest: types.PayloadDigest(strings.Repeat(fmt.Sprintf("%02x", timestamp%256), 32)),
+		},
+		Available: payloadLength,
+	}
+}
+
+func TestMessagesSurviveEncoding(t *testing.T) {
+	schemes := newTestSchemes()
+	trackerOpts := reconciliation.ReconcileMsgTrackerOpts{
+		DefaultNamespaceId: types.NamespaceId("Test"),
+		DefaultSubspaceId:  types.SubspaceId(""),
+		HandleToNamespaceId: func(aoiHandle uint64) types.NamespaceId {
+			return types.NamespaceId("Test")
+		},
+		AoiHandlesToRange3d: func(senderAoiHandle, receiverAoiHandle uint64) types.Range3d {
+			return utils.DefaultRange3d(types.SubspaceId(""))
+		},
+	}
+
+	someRange := types.Range3d{
+		SubspaceRange: types.Range[types.SubspaceId]{Start: types.SubspaceId("alfie"), End: types.SubspaceId("betty")},
+		PathRange:     types.Range[types.Path]{Start: types.Path{[]byte("blog")}, OpenEnd: true},
+		TimeRange:     types.Range[uint64]{Start: 1000, End: 70000},
+	}
+	otherRange := types.Range3d{
+		SubspaceRange: types.Range[types.SubspaceId]{Start: types.SubspaceId("alfie"), OpenEnd: true},
+		PathRange:     types.Range[types.Path]{Start: types.Path{[]byte("blog"), []byte("a")}, End: types.Path{[]byte("blog"), []byte("m")}},
+		TimeRange:     types.Range[uint64]{Start: 500, OpenEnd: true},
+	}
+	fingerprint := schemes.Fingerprint.FingerPrintSingleton(testEntry("alfie", "a", 1000, 5))
+
+	// The areas granted by the read capabilities the areas of interest are bound with
+	grantedAreas := map[uint64]types.Area{
+		3:   {Subspace_id: types.SubspaceId("alfie"), Path: types.Path{[]byte("blog")}, Times: types.Range[uint64]{Start: 500, OpenEnd: true}},
+		300: utils.FullArea(),
+	}
+	getCapabilityArea := func(handle uint64) (types.Area, error) {
+		area, found := grantedAreas[handle]
+		if !found {
+			return types.Area{}, fmt.Errorf("no read capability is bound to handle %v", handle)
+		}
+		return area, nil
+	}
+
+	messages := []wgpstypes.SyncMessage{
+		wgpstypes.MsgSetupBindStaticToken[string]{
+			Kind: wgpstypes.SetupBindStaticToken,
+			Data: wgpstypes.MsgSetupBindStaticTokenData[string]{StaticToken: "alfie"},
+		},
+		wgpstypes.MsgReconciliationSendFingerprint[string]{
+			Kind: wgpstypes.ReconciliationSendFingerprint,
+			Data: wgpstypes.MsgReconciliationSendFingerprintData[string]{
+				Range:       someRange,
+				Fingerprint: fingerprint,
+			},
+		},
+		wgpstypes.MsgReconciliationSendFingerprint[string]{
+			Kind: wgpstypes.ReconciliationSendFingerprint,
+			Data: wgpstypes.MsgReconciliationSendFingerprintData[string]{
+				Range:          otherRange,
+				Fingerprint:    schemes.Fingerprint.NeutralFinalised,
+				SenderHandle:   5,
+				ReceiverHandle: 70000,
+				Covers:         300,
+				DoesCover:      true,
+			},
+		},
+		wgpstypes.MsgReconciliationAnnounceEntries{
+			Kind: wgpstypes.ReconciliationAnnounceEntries,
+			Data: wgpstypes.MsgReconciliationAnnounceEntriesData{
+				Range:          someRange,
+				Count:          2,
+				WantResponse:   true,
+				SenderHandle:   5,
+				ReceiverHandle: 70000,
+				Covers:         3,
+				DoesCover:      true,
+			},
+		},
+		wgpstypes.MsgReconciliationSendEntry[string]{
+			Kind: wgpstypes.ReconciliationSendEntry,
+			Data: wgpstypes.MsgReconciliationSendEntryData[string]{
+				Entry:             testEntry("alfie", "a", 1000, 5),
+				StaticTokenHandle: 0,
+			},
+		},
+		wgpstypes.MsgReconciliationSendPayload{
+			Kind: wgpstypes.ReconciliationSendPayload,
+			Data: wgpstypes.MsgReconciliationSendPayloadData{Amount: 5, Bytes: []byte("hello")},
+		},
+		wgpstypes.MsgReconciliationTerminatePayload{Kind: wgpstypes.ReconciliationTerminatePayload},
+		wgpstypes.MsgReconciliationSendEntry[string]{
+			Kind: wgpstypes.ReconciliationSendEntry,
+			Data: wgpstypes.MsgReconciliationSendEntryData[string]{
+				Entry:             testEntry("betty", "b", 900, 300),
+				StaticTokenHandle: 100,
+				DynamicToken:      "dynamic",
+			},
+		},
+		wgpstypes.MsgReconciliationTerminatePayload{Kind: wgpstypes.ReconciliationTerminatePayload},
+		wgpstypes.MsgReconciliationAnnounceEntries{
+			Kind: wgpstypes.ReconciliationAnnounceEntries,
+			Data: wgpstypes.MsgReconciliationAnnounceEntriesData{
+				Range:          otherRange,
+				SenderHandle:   5,
+				ReceiverHandle: 1,
+				Covers:         260,
+				DoesCover:      true,
+			},
+		},
+		wgpstypes.MsgReconciliationSendFingerprint[string]{
+			Kind: wgpstypes.ReconciliationSendFingerprint,
+			Data: wgpstypes.MsgReconciliationSendFingerprintData[string]{
+				Range:          someRange,
+				Fingerprint:    fingerprint,
+				SenderHandle:   5,
+				ReceiverHandle: 1,
+			},
+		},
+		wgpstypes.MsgControlIssueGuarantee{
+			Kind: wgpstypes.ControlIssueGuarantee,
+			Data: wgpstypes.ControlIssueGuaranteeData{Amount: 70000, Channel: wgpstypes.ReconciliationChannel},
+		},
+		wgpstypes.MsgControlAbsolve{
+			Kind: wgpstypes.ControlAbsolve,
+			Data: wgpstypes.ControlAbsolveData{Amount: 3, Channel: wgpstypes.StaticTokenChannel},
+		},
+		wgpstypes.MsgControlPlead{
+			Kind: wgpstypes.ControlPlead,
+			Data: wgpstypes.ControlPleadData{Target: 300, Channel: wgpstypes.DataChannel},
+		},
+		wgpstypes.MsgControlAnnounceDropping{
+			Kind: wgpstypes.ControlAnnounceDropping,
+			Data: wgpstypes.ControlAnnounceDroppingData{Channel: wgpstypes.AreaOfInterestChannel},
+		},
+		wgpstypes.MsgControlApologise{
+			Kind: wgpstypes.ControlApologise,
+			Data: wgpstypes.ControlApologiseData{Channel: wgpstypes.CapabilityChannel},
+		},
+		wgpstypes.MsgControlFree{
+			Kind: wgpstypes.ControlFree,
+			Data: wgpstypes.MsgControlFreeData{Handle: 2, Mine: true, HandleType: wgpstypes.StaticTokenHandle},
+		},
+		wgpstypes.MsgCommitmentReveal{
+			Kind: wgpstypes.CommitmentReveal,
+			Data: wgpstypes.MsgCommitmentRevealData{Nonce: []byte("0123456789abcdef")},
+		},
+		wgpstypes.MsgPaiBindFragment[string]{
+			Kind: wgpstypes.PaiBindFragment,
+			Data: wgpstypes.MsgPaiBindFragmentData[string]{GroupMember: "primary"},
+		},
+		wgpstypes.MsgPaiBindFragment[string]{
+			Kind: wgpstypes.PaiBindFragment,
+			Data: wgpstypes.MsgPaiBindFragmentData[string]{GroupMember: "secondary", IsSecondary: true},
+		},
+		wgpstypes.MsgPaiReplyFragment[string]{
+			Kind: wgpstypes.PaiReplyFragment,
+			Data: wgpstypes.MsgPaiReplyFragmentData[string]{Handle: 300, GroupMember: "multiplied"},
+		},
+		wgpstypes.MsgPaiRequestSubspaceCapability{
+			Kind: wgpstypes.PaiRequestSubspaceCapability,
+			Data: wgpstypes.MsgPaiRequestSubspaceCapabilityData{Handle: 70000},
+		},
+		wgpstypes.MsgPaiReplySubspaceCapability[string, string]{
+			Kind: wgpstypes.PaiReplySubspaceCapability,
+			Data: wgpstypes.MsgPaiReplySubspaceCapabilityData[string, string]{Handle: 2, Capability: "subspaces", Signature: "signed"},
+		},
+		wgpstypes.MsgSetupBindReadCapability[string, string]{
+			Kind: wgpstypes.SetupBindReadCapability,
+			Data: wgpstypes.MsgSetupBindReadCapabilityData[string, string]{Handle: 5, Capability: "read", Signature: "signed"},
+		},
+		wgpstypes.MsgSetupBindAreaOfInterest{
+			Kind: wgpstypes.SetupBindAreaOfInterest,
+			Data: wgpstypes.MsgSetupBindAreaOfInterestData{
+				AreaOfInterest: types.AreaOfInterest{
+					Area: types.Area{Subspace_id: types.SubspaceId("alfie"), Path: types.Path{[]byte("blog"), []byte("a")}, Times: types.Range[uint64]{Start: 1000, End: 2000}},
+				},
+				Authorisation: 3,
+			},
+		},
+		wgpstypes.MsgSetupBindAreaOfInterest{
+			Kind: wgpstypes.SetupBindAreaOfInterest,
+			Data: wgpstypes.MsgSetupBindAreaOfInterestData{
+				AreaOfInterest: types.AreaOfInterest{Area: utils.SubspaceArea(types.SubspaceId("betty")), MaxCount: 5, MaxSize: 70000},
+				Authorisation:  300,
+			},
+		},
+		wgpstypes.MsgSetupBindAreaOfInterest{
+			Kind: wgpstypes.SetupBindAreaOfInterest,
+			Data: wgpstypes.MsgSetupBindAreaOfInterestData{
+				AreaOfInterest: types.AreaOfInterest{Area: utils.FullArea(), MaxSize: 1},
+				Authorisation:  300,
+			},
+		},
+		wgpstypes.MsgDataSetMetadata{
+			Kind: wgpstypes.DataSetMetadata,
+			Data: wgpstypes.MsgDataSetMetadataData{IsEager: false, SenderHandle: 70000, ReceiverHandle: 3},
+		},
+		wgpstypes.MsgDataSetMetadata{
+			Kind: wgpstypes.DataSetMetadata,
+			Data: wgpstypes.MsgDataSetMetadataData{IsEager: true, SenderHandle: 1, ReceiverHandle: 300},
+		},
+		wgpstypes.MsgDataBindPayloadRequest{
+			Kind: wgpstypes.DataBindPayloadRequest,
+			Data: wgpstypes.MsgDataBindPayloadRequestData{Entry: testEntry("alfie", "a", 1000, 5).Entry, Capability: 3},
+		},
+		wgpstypes.MsgDataBindPayloadRequest{
+			Kind: wgpstypes.DataBindPayloadRequest,
+			Data: wgpstypes.MsgDataBindPayloadRequestData{Entry: testEntry("betty", "b", 2000, 70000).Entry, Offset: 4096, Capability: 300},
+		},
+		wgpstypes.MsgDataSendEntry[string]{
+			Kind: wgpstypes.DataSendEntry,
+			Data: wgpstypes.MsgDataSendEntryData[string]{Entry: testEntry("alfie", "a", 1000, 5).Entry, StaticTokenHandle: 2, DynamicToken: "signed"},
+		},
+		wgpstypes.MsgDataSendPayload{
+			Kind: wgpstypes.DataSendPayload,
+			Data: wgpstypes.MsgDataSendPayloadData{Amount: 5, Bytes: []byte("hello")},
+		},
+		wgpstypes.MsgDataSendEntry[string]{
+			Kind: wgpstypes.DataSendEntry,
+			Data: wgpstypes.MsgDataSendEntryData[string]{Entry: testEntry("betty", "b", 2000, 70000).Entry, StaticTokenHandle: 300, Offset: 70000},
+		},
+		wgpstypes.MsgDataSendEntry[string]{
+			Kind: wgpstypes.DataSendEntry,
+			Data: wgpstypes.MsgDataSendEntryData[string]{Entry: testEntry("betty", "c", 3000, 70000).Entry, StaticTokenHandle: 1, Offset: 4096},
+		},
+		wgpstypes.MsgDataReplyPayload{
+			Kind: wgpstypes.DataReplyPayload,
+			Data: wgpstypes.MsgDataReplyPayloadData{Handle: 300},
+		},
+		wgpstypes.MsgDataSendPayload{
+			Kind: wgpstypes.DataSendPayload,
+			Data: wgpstypes.MsgDataSendPayloadData{Amount: 5, Bytes: []byte("hello")},
+		},
+	}
+
+	encoder := encoding.NewMessageEncoder(schemes, struct {
+		reconciliation.ReconcileMsgTrackerOpts
+		GetIntersectionPrivy  func(handle uint64) (wgpstypes.ReadCapPrivy, error)
+		GetCapabilityArea     func(handle uint64) (types.Area, error)
+		GetCurrentlySentEntry func() types.Entry
+	}{
+		ReconcileMsgTrackerOpts: trackerOpts,
+		GetIntersectionPrivy: func(handle uint64) (wgpstypes.ReadCapPrivy, error) {
+			return wgpstypes.ReadCapPrivy{}, nil
+		},
+		GetCapabilityArea: getCapabilityArea,
+	})
+
+	inChannels := map[wgpstypes.Channel]chan []byte{}
+	outChannels := map[wgpstypes.Channel]chan wgpstypes.SyncMessage{}
+	errs := map[wgpstypes.Channel]chan error{}
+	sent := map[wgpstypes.Channel][]wgpstypes.SyncMessage{}
+
+	for _, msg := range messages {
+		if err := encoder.Encode(msg); err != nil {
+			t.Fatalf("could not encode %T: %v", msg, err)
+		}
+		encoded := <-encoder.MessageChannel
+
+		if _, ok := inChannels[encoded.Channel]; !ok {
+			inChannels[encoded.Channel] = make(chan []byte, len(messages))
+			outChannels[encoded.Channel] = make(chan wgpstypes.SyncMessage, len(messages))
+			errs[encoded.Channel] = make(chan error, 1)
+			go func(in chan []byte, out chan wgpstypes.SyncMessage, errs chan error) {
+				errs <- DecodeMessages(DecodeMessageOpts[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint8]{
+					Reconcile:         trackerOpts,
+					Schemes:           schemes,
+					ChallengeLength:   16,
+					GetCapabilityArea: getCapabilityArea,
+				}, in, out)
+			}(inChannels[encoded.Channel], outChannels[encoded.Channel], errs[encoded.Channel])
+		}
+		// Split every message in two to exercise the decoders waiting for more bytes
+		half := len(encoded.Message) / 2
+		inChannels[encoded.Channel] <- encoded.Message[:half]
+		inChannels[encoded.Channel] <- encoded.Message[half:]
+		sent[encoded.Channel] = append(sent[encoded.Channel], msg)
+	}
+
+	for channel, in := range inChannels {
+		close(in)
+		var received []wgpstypes.SyncMessage
+		for msg := range outChannels[channel] {
+			received = append(received, msg)
+		}
+		if err := <-errs[channel]; err != nil {
+			t.Errorf("channel %d: %v", channel, err)
+		}
+		if len(received) != len(sent[channel]) {
+			t.Fatalf("channel %d: sent %d messages, received %d", channel, len(sent[channel]), len(received))
+		}
+		for i := range received {
+			// Empty and nil paths and ids are the same thing
+			if want, got := fmt.Sprintf("%+v", sent[channel][i]), fmt.Sprintf("%+v", received[i]); want != got {
+				t.Errorf("channel %d: message %d changed\n sent     %s\n received %s", channel, i, want, got)
+			}
+		}
+	}
+}
+
+func TestDecodeMessagesStopsAtTruncatedMessage(t *testing.T) {
+	schemes := newTestSchemes()
+	encoded := encoding.EncodeSetupBindStaticToken(wgpstypes.MsgSetupBindStaticToken[string]{
+		Kind: wgpstypes.SetupBindStaticToken,
+		Data: wgpstypes.MsgSetupBindStaticTokenData[string]{StaticToken: "a rather long static token"},
+	}, schemes.AuthorisationToken.Encodings.StaticToken.Encode)
+
+	in := make(chan []byte, 1)
+	out := make(chan wgpstypes.SyncMessage, 1)
+	in <- encoded[:len(encoded)-3]
+	close(in)
+
+	err := DecodeMessages(DecodeMessageOpts[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint8]{
+		Schemes: schemes,
+	}, in, out)
+	if err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+	for msg := range out {
+		t.Errorf("decoded a message from a truncated stream: %+v", msg)
+	}
+}
+
+func TestDecodeMessagesDropsRejectedMessages(t *testing.T) {
+	schemes := newTestSchemes()
+	first := encoding.EncodeControlAbsolve(wgpstypes.MsgControlAbsolve{
+		Kind: wgpstypes.ControlAbsolve,
+		Data: wgpstypes.ControlAbsolveData{Amount: 70000, Channel: wgpstypes.DataChannel},
+	})
+	second := encoding.EncodeControlApologise(wgpstypes.MsgControlApologise{
+		Kind: wgpstypes.ControlApologise,
+		Data: wgpstypes.ControlApologiseData{Channel: wgpstypes.DataChannel},
+	})
+
+	in := make(chan []byte, 2)
+	out := make(chan wgpstypes.SyncMessage, 2)
+	in <- first
+	in <- second
+	close(in)
+
+	var lengths []uint64
+	err := DecodeMessages(DecodeMessageOpts[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint8]{
+		Schemes: schemes,
+		AcceptMessage: func(length uint64) bool {
+			lengths = append(lengths, length)
+			return len(lengths) > 1
+		},
+	}, in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lengths) != 2 || lengths[0] != uint64(len(first)) || lengths[1] != uint64(len(second)) {
+		t.Errorf("expected the lengths %d and %d, got %v", len(first), len(second), lengths)
+	}
+	var received []wgpstypes.SyncMessage
+	for msg := range out {
+		received = append(received, msg)
+	}
+	if len(received) != 1 || received[0].GetKind() != wgpstypes.ControlApologise {
+		t.Errorf("expected only the apology to be passed on, got %+v", received)
+	}
+}
+
+func TestDecodeMessagesStartsWithOpening(t *testing.T) {
+	schemes := newTestSchemes()
+	commitment := []byte("a commitment of 32 bytes length!")
+	reveal := encoding.EncodeCommitmentReveal(wgpstypes.MsgCommitmentReveal{
+		Kind: wgpstypes.CommitmentReveal,
+		Data: wgpstypes.MsgCommitmentRevealData{Nonce: []byte("nonce")},
+	})
+
+	in := make(chan []byte, 2)
+	out := make(chan wgpstypes.SyncMessage, 1)
+	in <- encoding.EncodeOpening(40, commitment)
+	in <- reveal
+	close(in)
+
+	var receivedPower uint8
+	var receivedCommitment []byte
+	err := DecodeMessages(DecodeMessageOpts[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint8]{
+		Schemes:             schemes,
+		ChallengeLength:     5,
+		ChallengeHashLength: len(commitment),
+		ReceiveOpening: func(maxPayloadSizePower uint8, commitment []byte) {
+			receivedPower, receivedCommitment = maxPayloadSizePower, commitment
+		},
+	}, in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receivedPower != 40 || string(receivedCommitment) != string(commitment) {
+		t.Errorf("decoded the opening as %d %q", receivedPower, receivedCommitment)
+	}
+	msg, ok := <-out
+	if !ok || string(msg.(wgpstypes.MsgCommitmentReveal).Data.Nonce) != "nonce" {
+		t.Errorf("expected the commitment reveal after the opening, got %+v", msg)
+	}
+}
@@ -1,19 +1,23 @@
 package decoding
 
 import (
+	"fmt"
+
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
-	"github.com/PES-Innovation-Lab/willow-go/types"
 	"github.com/PES-Innovation-Lab/willow-go/utils"
 )
 
-func DecodePaiBindFragment[PsiGroup types.OrderableGeneric](bytes *utils.GrowingBytes, groupDecoder utils.StreamDecoder[PsiGroup]) wgpstypes.MsgPaiBindFragment[PsiGroup] {
+func DecodePaiBindFragment[PsiGroup any](bytes *utils.GrowingBytes, groupDecoder func(bytes *utils.GrowingBytes) chan PsiGroup) (wgpstypes.MsgPaiBindFragment[PsiGroup], error) {
 	bytes.NextAbsolute(1)
 
 	IsSecondary := bytes.Array[0] == 0x6
 
 	bytes.Prune(1)
 
-	GroupMember := groupDecoder(bytes)
+	GroupMember, ok := <-groupDecoder(bytes)
+	if !ok {
+		return wgpstypes.MsgPaiBindFragment[PsiGroup]{}, fmt.Errorf("the stream ended in the middle of a group member")
+	}
 
 	return wgpstypes.MsgPaiBindFragment[PsiGroup]{
 		Kind: wgpstypes.PaiBindFragment,
@@ -21,19 +25,24 @@ func DecodePaiBindFragment[PsiGroup types.OrderableGeneric](bytes *utils.Growing
 			IsSecondary: IsSecondary,
 			GroupMember: GroupMember,
 		},
-	}
+	}, nil
 }
 
-func DecodePaiReplyFragment[PsiGroup types.OrderableGeneric](bytes *utils.GrowingBytes, groupDecoder utils.StreamDecoder[PsiGroup]) wgpstypes.MsgPaiReplyFragment[PsiGroup] {
+func DecodePaiReplyFragment[PsiGroup any](bytes *utils.GrowingBytes, groupDecoder func(bytes *utils.GrowingBytes) chan PsiGroup) (wgpstypes.MsgPaiReplyFragment[PsiGroup], error) {
 	bytes.NextAbsolute(1)
 
 	CompactWidth := CompactWidthFromEndOfByte(int(bytes.Array[0]))
 
+	bytes.NextAbsolute(1 + CompactWidth)
+
 	Handle, _ := utils.DecodeIntMax64(bytes.Array[1 : 1+CompactWidth])
 
 	bytes.Prune(1 + CompactWidth)
 
-	GroupMember := groupDecoder(bytes)
+	GroupMember, ok := <-groupDecoder(bytes)
+	if !ok {
+		return wgpstypes.MsgPaiReplyFragment[PsiGroup]{}, fmt.Errorf("the stream ended in the middle of a group member")
+	}
 
 	return wgpstypes.MsgPaiReplyFragment[PsiGroup]{
 		Kind: wgpstypes.PaiReplyFragment,
@@ -41,7 +50,7 @@ func DecodePaiReplyFragment[PsiGroup types.OrderableGeneric](bytes *utils.Growin
 			Handle:      uint64(Handle),
 			GroupMember: GroupMember,
 		},
-	}
+	}, nil
 }
 
 func DecodePaiRequestSubspaceCapability(bytes *utils.GrowingBytes) wgpstypes.MsgPaiRequestSubspaceCapability {
@@ -49,6 +58,8 @@ func DecodePaiRequestSubspaceCapability(bytes *utils.GrowingBytes) wgpstypes.Msg
 
 	CompactWidth := CompactWidthFromEndOfByte(int(bytes.Array[0]))
 
+	bytes.NextAbsolute(1 + CompactWidth)
+
 	Handle, _ := utils.DecodeIntMax64(bytes.Array[1 : 1+CompactWidth])
 
 	bytes.Prune(1 + CompactWidth)
@@ -61,24 +72,32 @@ func DecodePaiRequestSubspaceCapability(bytes *utils.GrowingBytes) wgpstypes.Msg
 	}
 }
 
-func DecodePaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature any](bytes *utils.GrowingBytes, decodeCap utils.StreamDecoder[SubspaceCapability], decodeSig utils.StreamDecoder[SyncSubspaceSignature]) wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature] { //NEED TO CHANGE THESE TYPE DEFINITIONS
+func DecodePaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature any](bytes *utils.GrowingBytes, decodeCap func(bytes *utils.GrowingBytes) chan SubspaceCapability, decodeSig func(bytes *utils.GrowingBytes) chan SyncSubspaceSignature) (wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature], error) {
 	bytes.NextAbsolute(1)
 
 	CompactWidth := CompactWidthFromEndOfByte(int(bytes.Array[0]))
 
+	bytes.NextAbsolute(1 + CompactWidth)
+
 	Handle, _ := utils.DecodeIntMax64(bytes.Array[1 : 1+CompactWidth])
 
 	bytes.Prune(1 + CompactWidth)
 
-	Capability := decodeCap(bytes)
-	Signature := decodeSig(bytes)
+	Capability, ok := <-decodeCap(bytes)
+	if !ok {
+		return wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]{}, fmt.Errorf("the stream ended in the middle of a subspace capability")
+	}
+	Signature, ok := <-decodeSig(bytes)
+	if !ok {
+		return wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]{}, fmt.Errorf("the stream ended in the middle of a signature")
+	}
 
-	return wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]{ //need to check this out
+	return wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]{
 		Kind: wgpstypes.PaiReplySubspaceCapability,
 		Data: wgpstypes.MsgPaiReplySubspaceCapabilityData[SubspaceCapability, SyncSubspaceSignature]{
 			Handle:     uint64(Handle),
 			Capability: Capability,
 			Signature:  Signature,
 		},
-	}
+	}, nil
 }
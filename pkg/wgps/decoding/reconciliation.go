@@ -40,8 +40,6 @@ func DecodeReconciliationSendFingerprint[Fingerprint string, ValueType constrain
 	CoversNotNone := (SecondByte & 0x8) == 0x8
 	CoversCompactWidth := int(math.Pow(2, float64(int(SecondByte&0x3))))
 
-	COVERS_NONE := uint64(0)
-
 	var Covers uint64
 	var SenderHandle uint64
 	var ReceiverHandle uint64
@@ -54,8 +52,6 @@ func DecodeReconciliationSendFingerprint[Fingerprint string, ValueType constrain
 		Covers, _ = utils.DecodeIntMax64(width1[:CoversCompactWidth])
 
 		bytes.Prune(CoversCompactWidth)
-	} else {
-		Covers = COVERS_NONE
 	}
 
 	if !IsSenderPrevSender {
@@ -104,7 +100,7 @@ func DecodeReconciliationSendFingerprint[Fingerprint string, ValueType constrain
 			SenderHandle:   SenderHandle,
 			ReceiverHandle: ReceiverHandle,
 			Covers:         Covers,
-			DoesCover:      true,
+			DoesCover:      CoversNotNone,
 		},
 	}
 }
@@ -136,8 +132,6 @@ func DecodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](bytes *
 
 	CoversNotNone := (SecondByte & 0x1) == 0x1
 
-	COVERS_NONE := uint64(0)
-
 	var Covers uint64
 
 	bytes.Prune(2)
@@ -148,9 +142,9 @@ func DecodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](bytes *
 		CoversLength := width1[0]
 
 		if (CoversLength & 0xfc) == 0xfc {
-			CoversCompactWidth := int(math.Pow(2, float64(int((SecondByte & 0x3)))))
+			CoversCompactWidth := CompactWidthFromEndOfByte(int(CoversLength))
 
-			width2 := bytes.NextAbsolute(CoversCompactWidth)
+			width2 := bytes.NextAbsolute(1 + CoversCompactWidth)
 
 			Covers, _ = utils.DecodeIntMax64(width2[1 : 1+CoversCompactWidth])
 
@@ -159,8 +153,6 @@ func DecodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](bytes *
 			Covers = uint64(CoversLength)
 			bytes.Prune(1)
 		}
-	} else {
-		Covers = COVERS_NONE
 	}
 
 	var SenderHandle uint64
@@ -214,7 +206,7 @@ func DecodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](bytes *
 			SenderHandle:   SenderHandle,
 			ReceiverHandle: ReceiverHandle,
 			Covers:         Covers,
-			DoesCover:      true,
+			DoesCover:      CoversNotNone,
 		},
 	}
 }
@@ -260,14 +252,15 @@ func DecodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 			CompactWidth = 1
 		}
 
-		width2 := bytes.NextAbsolute(2 + CompactWidth)
-
 		if StaticTokensizeByte < 63 {
+			// Small handles fit into the size byte itself
 			StaticTokenHandle = uint64(StaticTokensizeByte)
+			bytes.Prune(2)
 		} else {
+			width2 := bytes.NextAbsolute(2 + CompactWidth)
 			StaticTokenHandle, _ = utils.DecodeIntMax64(width2[2 : 2+CompactWidth])
+			bytes.Prune(2 + CompactWidth)
 		}
-		bytes.Prune(2 + CompactWidth)
 	}
 
 	width1 := bytes.NextAbsolute(CompactWidthAvailable)
@@ -276,7 +269,8 @@ func DecodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 
 	bytes.Prune(CompactWidthAvailable)
 
-	dynamicToken := opts.DecodeDynamicToken(bytes)
+	// The dynamic token has to be decoded before the entry following it
+	dynamicToken := <-opts.DecodeDynamicToken(bytes)
 
 	var decodeResultChan chan utils.DecodeResult
 	var Entry types.Entry
@@ -308,6 +302,8 @@ func DecodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 				PathScheme:                opts.PathScheme,
 			}, bytes, Privy.Announced.Range, Privy.Announced.Namespace,
 		)
+		result := <-decodeResultChan
+		Entry = result.Entry
 	}
 
 	return wgpstypes.MsgReconciliationSendEntry[DynamicToken]{ //need to verify the type of DynamicToken
@@ -317,7 +313,7 @@ func DecodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 				Available: Available,
 				Entry:     Entry,
 			},
-			DynamicToken:      <-dynamicToken,
+			DynamicToken:      dynamicToken,
 			StaticTokenHandle: StaticTokenHandle,
 		},
 	}
@@ -336,7 +332,8 @@ func DecodeReconciliationSendPayload(bytes *utils.GrowingBytes) wgpstypes.MsgRec
 
 	width2 := bytes.NextAbsolute(int(Amount))
 
-	MessageBytes := width2[:int(Amount)]
+	// Copy the chunk, the accumulated bytes are reused once pruned
+	MessageBytes := append([]byte{}, width2[:int(Amount)]...)
 
 	bytes.Prune(int(Amount))
 
@@ -348,3 +345,12 @@ func DecodeReconciliationSendPayload(bytes *utils.GrowingBytes) wgpstypes.MsgRec
 		},
 	}
 }
+
+func DecodeReconciliationTerminatePayload(bytes *utils.GrowingBytes) wgpstypes.MsgReconciliationTerminatePayload {
+	bytes.NextAbsolute(1)
+	bytes.Prune(1)
+
+	return wgpstypes.MsgReconciliationTerminatePayload{
+		Kind: wgpstypes.ReconciliationTerminatePayload,
+	}
+}
@@ -1,13 +1,20 @@
 package decoding
 
 import (
+	"fmt"
+
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
 	"github.com/PES-Innovation-Lab/willow-go/utils"
 	"golang.org/x/exp/constraints"
 )
 
-func DecodeSetupBindReadCapability[ReadCapability, SyncSigntature any, ValueType constraints.Unsigned](bytes *utils.GrowingBytes, readCapScheme wgpstypes.ReadCapEncodingScheme[ReadCapability, ValueType], gerPrivy func(handle uint64) wgpstypes.ReadCapPrivy, decodeSignature func(bytes *utils.GrowingBytes) SyncSigntature) wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSigntature] { //need to fix the type definition
+/*
+Decodes a SetupBindReadCapability message. The read capability is encoded relative to the intersection the handle
+refers to, but it can be decoded from the stream without it, so the message is decoded before the intersection is
+dereferenced.
+*/
+func DecodeSetupBindReadCapability[ReadCapability, SyncSignature any, ValueType constraints.Unsigned](bytes *utils.GrowingBytes, readCapScheme wgpstypes.ReadCapEncodingScheme[ReadCapability, ValueType], decodeSignature func(bytes *utils.GrowingBytes) chan SyncSignature) (wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature], error) {
 	bytes.NextAbsolute(1)
 
 	CompactWidth := CompactWidthFromEndOfByte(int(bytes.Array[0]))
@@ -18,21 +25,31 @@ func DecodeSetupBindReadCapability[ReadCapability, SyncSigntature any, ValueType
 
 	bytes.Prune(1 + CompactWidth)
 
-	Capability, _ := readCapScheme.DecodeStream(bytes) //where is this defined?
+	Capability, err := readCapScheme.DecodeStream(bytes)
+	if err != nil {
+		return wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]{}, err
+	}
 
-	Signature := decodeSignature(bytes)
+	Signature, ok := <-decodeSignature(bytes)
+	if !ok {
+		return wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]{}, fmt.Errorf("the stream ended in the middle of a signature")
+	}
 
-	return wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSigntature]{
+	return wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]{
 		Kind: wgpstypes.SetupBindReadCapability,
-		Data: wgpstypes.MsgSetupBindReadCapabilityData[ReadCapability, SyncSigntature]{
+		Data: wgpstypes.MsgSetupBindReadCapabilityData[ReadCapability, SyncSignature]{
 			Handle:     Handle,
 			Capability: Capability,
 			Signature:  Signature,
 		},
-	}
+	}, nil
 }
 
-func DecodeSetupBindAreaOfInterest[ValueType constraints.Unsigned](bytes *utils.GrowingBytes, getPrivy func(handle uint64) types.Area, decodeStreamSubspace utils.EncodingScheme[ValueType], pathScheme types.PathParams[ValueType]) wgpstypes.MsgSetupBindAreaOfInterest { //need to check the types out once
+/*
+Decodes a SetupBindAreaOfInterest message. The area is encoded relative to the area granted by the read capability
+the message refers to, which getOuter is asked for once the handle of the capability is decoded.
+*/
+func DecodeSetupBindAreaOfInterest[ValueType constraints.Unsigned](bytes *utils.GrowingBytes, getOuter func(authorisation uint64) (types.Area, error), decodeStreamSubspace utils.EncodingScheme[types.SubspaceId], pathScheme types.PathParams[ValueType]) (wgpstypes.MsgSetupBindAreaOfInterest, error) {
 	bytes.NextAbsolute(1)
 	HasALimit := (0x4 & bytes.Array[0]) == 0x4
 
@@ -44,66 +61,60 @@ func DecodeSetupBindAreaOfInterest[ValueType constraints.Unsigned](bytes *utils.
 
 	bytes.Prune(1 + CompactWidth)
 
-	Outer := getPrivy(uint64(AuthHandle))
+	Outer, err := getOuter(AuthHandle)
+	if err != nil {
+		return wgpstypes.MsgSetupBindAreaOfInterest{}, err
+	}
 
-	Area, _ := utils.DecodeStreamAreaInArea[ValueType](utils.DecodeStreamAreaInAreaOptions[ValueType]{
+	Area, err := utils.DecodeStreamAreaInArea[ValueType](utils.DecodeStreamAreaInAreaOptions[ValueType]{
 		PathScheme:           pathScheme,
-		DecodeStreamSubspace: utils.EncodingScheme[types.SubspaceId]{},
+		DecodeStreamSubspace: decodeStreamSubspace,
 	}, bytes, Outer)
-
-	if !HasALimit {
-		return wgpstypes.MsgSetupBindAreaOfInterest{
-			Kind: wgpstypes.SetupBindAreaOfInterest,
-			Data: wgpstypes.MsgSetupBindAreaOfInterestData{
-				AreaOfInterest: types.AreaOfInterest{
-					Area:     Area,
-					MaxCount: 0,
-					MaxSize:  0,
-				}, //just check this out once later
-				Authorisation: uint64(AuthHandle),
-			},
-		}
+	if err != nil {
+		return wgpstypes.MsgSetupBindAreaOfInterest{}, err
 	}
 
-	bytes.NextAbsolute(1)
+	AreaOfInterest := types.AreaOfInterest{Area: Area}
 
-	Maxes := bytes.Array[0]
+	if HasALimit {
+		bytes.NextAbsolute(1)
 
-	CompactWidthCount := CompactWidthFromEndOfByte(int(Maxes >> 6))
-	CompactWidthSize := CompactWidthFromEndOfByte(int(Maxes >> 4))
+		Widths := int(bytes.Array[0])
 
-	bytes.NextAbsolute(1 + CompactWidthCount + CompactWidthSize)
+		CompactWidthCount := CompactWidthFromEndOfByte(Widths >> 6)
+		CompactWidthSize := CompactWidthFromEndOfByte(Widths >> 4)
 
-	MaxCount, _ := utils.DecodeIntMax64(bytes.Array[1 : 1+CompactWidthCount])
+		bytes.NextAbsolute(1 + CompactWidthCount + CompactWidthSize)
 
-	MaxSize, _ := utils.DecodeIntMax64(bytes.Array[1+CompactWidthCount : 1+CompactWidthCount+CompactWidthSize])
+		AreaOfInterest.MaxCount, _ = utils.DecodeIntMax64(bytes.Array[1 : 1+CompactWidthCount])
+		AreaOfInterest.MaxSize, _ = utils.DecodeIntMax64(bytes.Array[1+CompactWidthCount : 1+CompactWidthCount+CompactWidthSize])
 
-	bytes.Prune(1 + CompactWidthCount + CompactWidthSize)
+		bytes.Prune(1 + CompactWidthCount + CompactWidthSize)
+	}
 
 	return wgpstypes.MsgSetupBindAreaOfInterest{
 		Kind: wgpstypes.SetupBindAreaOfInterest,
 		Data: wgpstypes.MsgSetupBindAreaOfInterestData{
-			AreaOfInterest: types.AreaOfInterest{
-				Area:     Area,
-				MaxCount: MaxCount,
-				MaxSize:  uint64(MaxSize),
-			}, //just check this out once later
-			Authorisation: uint64(AuthHandle),
+			AreaOfInterest: AreaOfInterest,
+			Authorisation:  AuthHandle,
 		},
-	}
+	}, nil
 }
 
-func DecodeSetupBindStaticToken[StaticToken string](bytes *utils.GrowingBytes, decodeStaticToken func(bytes *utils.GrowingBytes) StaticToken) wgpstypes.MsgSetupBindStaticToken[StaticToken] {
+func DecodeSetupBindStaticToken[StaticToken string](bytes *utils.GrowingBytes, decodeStaticToken func(bytes *utils.GrowingBytes) chan StaticToken) (wgpstypes.MsgSetupBindStaticToken[StaticToken], error) {
 	bytes.NextAbsolute(1)
 
 	bytes.Prune(1)
 
-	staticToken := decodeStaticToken(bytes)
+	staticToken, ok := <-decodeStaticToken(bytes)
+	if !ok {
+		return wgpstypes.MsgSetupBindStaticToken[StaticToken]{}, fmt.Errorf("the stream ended in the middle of a static token")
+	}
 
 	return wgpstypes.MsgSetupBindStaticToken[StaticToken]{
 		Kind: wgpstypes.SetupBindStaticToken,
 		Data: wgpstypes.MsgSetupBindStaticTokenData[StaticToken]{
 			StaticToken: staticToken,
 		},
-	}
+	}, nil
 }
@@ -0,0 +1,342 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+// The multicast group nodes announce themselves to, within the block scoped to the local network
+const DEFAULT_GROUP = "239.255.86.87:4249"
+
+// How often a node announces itself
+const DEFAULT_ANNOUNCE_INTERVAL = 5 * time.Second
+
+// Announcements start with this, so that other traffic on the group is told apart
+const ANNOUNCEMENT_MAGIC = "willow-go"
+
+// The version of the announcements this package sends and understands
+const ANNOUNCEMENT_VERSION = 1
+
+// Bytes of the random id a node announces itself by, and of the salt its namespaces are hashed with
+const idLength = 16
+
+type DiscoveryOpts struct {
+	Group string // The UDP multicast group to announce ourselves to and listen on, defaults to DEFAULT_GROUP
+	// The interface to listen for announcements on, the default multicast interface of the system when nil
+	Interface *net.Interface
+	Interval  time.Duration // Defaults to DEFAULT_ANNOUNCE_INTERVAL
+	// How long a peer is remembered after its last announcement, defaults to three intervals
+	Expiry time.Duration
+	// The port we accept sessions on, which peers connect to. Only peers are found and we are not announced when 0
+	Port int
+	// The public key of our identity, which peers learn who they connect to by. Left out of the announcements when nil
+	PublicKey ed25519.PublicKey
+	/*
+		The namespaces we sync, announced as hashes so that only peers which know a namespace learn we sync it.
+		The namespaces a peer shares with us are found among these.
+	*/
+	Namespaces []types.NamespaceId
+	/*
+		Told about a peer every time it announces itself, on the goroutine receiving the announcements, so it should
+		hand the peer off rather than block.
+	*/
+	OnPeer func(peer Peer)
+}
+
+// Peer is a node found on the local network
+type Peer struct {
+	Addr string // The address the peer accepts sessions on
+	// The public key the peer announced, which it only proves to hold once we connect to it. Nil when it announced none
+	PublicKey ed25519.PublicKey
+	// The namespaces of ours the peer announced it syncs as well
+	SharedNamespaces []types.NamespaceId
+	/*
+		Whether we are the one to connect to the peer, rather than wait for it to connect to us. Of two nodes which
+		found each other only one is, so they sync in a single session.
+	*/
+	Initiate bool
+	LastSeen time.Time
+}
+
+// Whether the peer announced it syncs a namespace
+func (p Peer) Shares(namespace types.NamespaceId) bool {
+	for _, shared := range p.SharedNamespaces {
+		if bytes.Equal(shared, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Discovery announces our node to the other nodes on the local network over UDP multicast, and finds the nodes which
+announce themselves. Announcements are not authenticated, a peer only proves the key it announced once we connect.
+*/
+type Discovery struct {
+	Opts DiscoveryOpts
+
+	// The random id of this node, by which it tells its own announcements apart
+	id [idLength]byte
+
+	mu sync.Mutex
+	// The peers heard of, by the id they announced
+	peers map[[idLength]byte]Peer
+}
+
+func NewDiscovery(opts DiscoveryOpts) (*Discovery, error) {
+	if opts.Group == "" {
+		opts.Group = DEFAULT_GROUP
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DEFAULT_ANNOUNCE_INTERVAL
+	}
+	if opts.Expiry <= 0 {
+		opts.Expiry = 3 * opts.Interval
+	}
+	discovery := &Discovery{
+		Opts:  opts,
+		peers: make(map[[idLength]byte]Peer),
+	}
+	if _, err := rand.Read(discovery.id[:]); err != nil {
+		return nil, err
+	}
+	return discovery, nil
+}
+
+// Announces our node and listens for the announcements of others until ctx is done, returning the error of ctx
+func (d *Discovery) Run(ctx context.Context) error {
+	group, err := net.ResolveUDPAddr("udp4", d.Opts.Group)
+	if err != nil {
+		return err
+	}
+	listener, err := net.ListenMulticastUDP("udp4", d.Opts.Interface, group)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	var sender *net.UDPConn
+	if d.Opts.Port != 0 {
+		// Sent from a socket of its own, which the system picks the interface of by its multicast route
+		if sender, err = net.DialUDP("udp4", nil, group); err != nil {
+			return err
+		}
+		defer sender.Close()
+	}
+
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		buffer := make([]byte, 1<<16)
+		for {
+			n, from, err := listener.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			d.receive(buffer[:n], from)
+		}
+	}()
+
+	ticker := time.NewTicker(d.Opts.Interval)
+	defer ticker.Stop()
+	for {
+		if sender != nil {
+			announcement, err := d.announcement()
+			if err == nil {
+				_, err = sender.Write(announcement)
+			}
+			if err != nil {
+				log.Printf("could not announce ourselves to %v: %v", group, err)
+			}
+		}
+		d.expire()
+		select {
+		case <-ctx.Done():
+			listener.Close()
+			<-received
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// The peers heard of recently enough, ordered by their address
+func (d *Discovery) Peers() []Peer {
+	d.expire()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	peers := make([]Peer, 0, len(d.peers))
+	for _, peer := range d.peers {
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Addr < peers[j].Addr
+	})
+	return peers
+}
+
+// Forgets the peers which were not heard of for longer than Expiry
+func (d *Discovery) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, peer := range d.peers {
+		if time.Since(peer.LastSeen) > d.Opts.Expiry {
+			delete(d.peers, id)
+		}
+	}
+}
+
+/*
+Encodes our announcement, which is the magic and version followed by our id, our port, our public key prefixed by
+its length, a salt and the number of namespaces we sync, and the SHA-256 hashes of the salt followed by each of them.
+The salt is new for every announcement, so that announcements of the same namespaces are not told apart by it.
+*/
+func (d *Discovery) announcement() ([]byte, error) {
+	if len(d.Opts.Namespaces) > 255 {
+		return nil, fmt.Errorf("can not announce more than 255 namespaces, got %v", len(d.Opts.Namespaces))
+	}
+	if d.Opts.Port <= 0 || d.Opts.Port > 65535 {
+		return nil, fmt.Errorf("can not announce port %v", d.Opts.Port)
+	}
+	var salt [idLength]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+
+	announcement := append([]byte(ANNOUNCEMENT_MAGIC), ANNOUNCEMENT_VERSION)
+	announcement = append(announcement, d.id[:]...)
+	announcement = binary.BigEndian.AppendUint16(announcement, uint16(d.Opts.Port))
+	announcement = append(announcement, byte(len(d.Opts.PublicKey)))
+	announcement = append(announcement, d.Opts.PublicKey...)
+	announcement = append(announcement, salt[:]...)
+	announcement = append(announcement, byte(len(d.Opts.Namespaces)))
+	for _, namespace := range d.Opts.Namespaces {
+		announcement = append(announcement, hashNamespace(salt[:], namespace)...)
+	}
+	return announcement, nil
+}
+
+// Remembers the peer which sent an announcement, and tells OnPeer about it. Whatever does not decode is ignored
+func (d *Discovery) receive(announcement []byte, from *net.UDPAddr) {
+	decoded, err := decodeAnnouncement(announcement)
+	if err != nil || decoded.Id == d.id {
+		return
+	}
+
+	peer := Peer{
+		// The address of the peer is the one it sent its announcement from
+		Addr:      net.JoinHostPort(from.IP.String(), strconv.Itoa(int(decoded.Port))),
+		PublicKey: decoded.PublicKey,
+		Initiate:  bytes.Compare(d.id[:], decoded.Id[:]) < 0,
+		LastSeen:  time.Now(),
+	}
+	for _, namespace := range d.Opts.Namespaces {
+		hash := hashNamespace(decoded.Salt, namespace)
+		for _, announced := range decoded.Namespaces {
+			if bytes.Equal(hash, announced) {
+				peer.SharedNamespaces = append(peer.SharedNamespaces, namespace)
+				break
+			}
+		}
+	}
+
+	d.mu.Lock()
+	d.peers[decoded.Id] = peer
+	d.mu.Unlock()
+	if d.Opts.OnPeer != nil {
+		d.Opts.OnPeer(peer)
+	}
+}
+
+type decodedAnnouncement struct {
+	Id         [idLength]byte
+	Port       uint16
+	PublicKey  ed25519.PublicKey
+	Salt       []byte
+	Namespaces [][]byte
+}
+
+func decodeAnnouncement(announcement []byte) (decodedAnnouncement, error) {
+	var decoded decodedAnnouncement
+	// Takes the next n bytes of the announcement
+	take := func(n int) ([]byte, error) {
+		if len(announcement) < n {
+			return nil, fmt.Errorf("the announcement ends too early")
+		}
+		taken := announcement[:n]
+		announcement = announcement[n:]
+		return taken, nil
+	}
+
+	header, err := take(len(ANNOUNCEMENT_MAGIC) + 1)
+	if err != nil {
+		return decoded, err
+	}
+	if string(header[:len(ANNOUNCEMENT_MAGIC)]) != ANNOUNCEMENT_MAGIC || header[len(ANNOUNCEMENT_MAGIC)] != ANNOUNCEMENT_VERSION {
+		return decoded, fmt.Errorf("not an announcement of a version we understand")
+	}
+	id, err := take(idLength)
+	if err != nil {
+		return decoded, err
+	}
+	copy(decoded.Id[:], id)
+	port, err := take(2)
+	if err != nil {
+		return decoded, err
+	}
+	decoded.Port = binary.BigEndian.Uint16(port)
+	keyLength, err := take(1)
+	if err != nil {
+		return decoded, err
+	}
+	if keyLength[0] != 0 && keyLength[0] != ed25519.PublicKeySize {
+		return decoded, fmt.Errorf("a public key of %v bytes is no Ed25519 key", keyLength[0])
+	}
+	publicKey, err := take(int(keyLength[0]))
+	if err != nil {
+		return decoded, err
+	}
+	if len(publicKey) > 0 {
+		decoded.PublicKey = ed25519.PublicKey(bytes.Clone(publicKey))
+	}
+	salt, err := take(idLength)
+	if err != nil {
+		return decoded, err
+	}
+	decoded.Salt = bytes.Clone(salt)
+	count, err := take(1)
+	if err != nil {
+		return decoded, err
+	}
+	for i := 0; i < int(count[0]); i++ {
+		hash, err := take(sha256.Size)
+		if err != nil {
+			return decoded, err
+		}
+		decoded.Namespaces = append(decoded.Namespaces, bytes.Clone(hash))
+	}
+	if len(announcement) > 0 {
+		return decoded, fmt.Errorf("%v bytes follow the announcement", len(announcement))
+	}
+	return decoded, nil
+}
+
+// The hash a namespace is announced as
+func hashNamespace(salt []byte, namespace types.NamespaceId) []byte {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write(namespace)
+	return hash.Sum(nil)
+}
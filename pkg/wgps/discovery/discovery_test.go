@@ -0,0 +1,128 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+func newTestDiscovery(t *testing.T, port int, namespaces ...string) *Discovery {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DiscoveryOpts{
+		// A group of its own, so that nodes announcing themselves on the default group are not heard
+		Group:     "239.255.86.87:4250",
+		Interval:  20 * time.Millisecond,
+		Port:      port,
+		PublicKey: publicKey,
+	}
+	for _, namespace := range namespaces {
+		opts.Namespaces = append(opts.Namespaces, types.NamespaceId(namespace))
+	}
+	discovery, err := NewDiscovery(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return discovery
+}
+
+// Runs a discovery until the test ends
+func runTestDiscovery(t *testing.T, discovery *Discovery) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan error, 1)
+	go func() { ran <- discovery.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-ran; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected discovery to stop with its context, got %v", err)
+		}
+	})
+}
+
+// Waits until a discovery heard of a peer announcing a port
+func heardOf(t *testing.T, discovery *Discovery, port int) Peer {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		for _, peer := range discovery.Peers() {
+			if _, announced, _ := net.SplitHostPort(peer.Addr); announced == strconv.Itoa(port) {
+				return peer
+			}
+		}
+	}
+	t.Skipf("never heard of the peer at port %v, the network may not loop multicast back", port)
+	return Peer{}
+}
+
+func TestDiscoveryFindsPeersSharingNamespaces(t *testing.T) {
+	alfie := newTestDiscovery(t, 4251, "myspace", "alfiespace")
+	betty := newTestDiscovery(t, 4252, "myspace", "bettyspace")
+	// Only finds peers, without announcing itself
+	listener := newTestDiscovery(t, 0, "myspace")
+	for _, discovery := range []*Discovery{alfie, betty, listener} {
+		runTestDiscovery(t, discovery)
+	}
+
+	bettyToAlfie := heardOf(t, alfie, 4252)
+	alfieToBetty := heardOf(t, betty, 4251)
+	if !bytes.Equal(bettyToAlfie.PublicKey, betty.Opts.PublicKey) || !bytes.Equal(alfieToBetty.PublicKey, alfie.Opts.PublicKey) {
+		t.Error("expected the peers to learn the public keys they announced")
+	}
+	if len(bettyToAlfie.SharedNamespaces) != 1 || !bettyToAlfie.Shares(types.NamespaceId("myspace")) || bettyToAlfie.Shares(types.NamespaceId("alfiespace")) {
+		t.Errorf("expected alfie to find she shares only myspace with betty, found %q", bettyToAlfie.SharedNamespaces)
+	}
+	if bettyToAlfie.Initiate == alfieToBetty.Initiate {
+		t.Error("expected exactly one of the peers to connect to the other")
+	}
+
+	heardOf(t, listener, 4251)
+	heardOf(t, listener, 4252)
+	for _, peer := range alfie.Peers() {
+		if _, port, _ := net.SplitHostPort(peer.Addr); port == "0" {
+			t.Error("expected a discovery which only finds peers not to announce itself")
+		}
+	}
+}
+
+func TestAnnouncementsDecodeAndOthersAreIgnored(t *testing.T) {
+	alfie := newTestDiscovery(t, 4251, "myspace")
+	betty := newTestDiscovery(t, 4252, "myspace")
+	announcement, err := alfie.announcement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeAnnouncement(announcement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Id != alfie.id || decoded.Port != 4251 || !bytes.Equal(decoded.PublicKey, alfie.Opts.PublicKey) || len(decoded.Namespaces) != 1 {
+		t.Errorf("expected the announcement to decode to what alfie announced, got %+v", decoded)
+	}
+	again, _ := alfie.announcement()
+	if bytes.Equal(again, announcement) {
+		t.Error("expected announcements of the same namespaces to differ by their salt")
+	}
+
+	from := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000}
+	for _, ignored := range [][]byte{nil, []byte("some other traffic"), announcement[:len(announcement)-1], append(bytes.Clone(announcement), 0)} {
+		betty.receive(ignored, from)
+	}
+	// Alfie does not hear of herself
+	alfie.receive(announcement, from)
+	if peers := append(betty.Peers(), alfie.Peers()...); len(peers) != 0 {
+		t.Errorf("expected broken announcements and our own to be ignored, heard of %+v", peers)
+	}
+
+	betty.receive(announcement, from)
+	if peers := betty.Peers(); len(peers) != 1 || peers[0].Addr != "192.0.2.1:4251" || !peers[0].Shares(types.NamespaceId("myspace")) {
+		t.Errorf("expected betty to hear of alfie at the port she announced, heard of %+v", peers)
+	}
+}
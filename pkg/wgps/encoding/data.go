@@ -21,7 +21,7 @@ func EncodeDataSendEntry[DynamicToken string, K constraints.Unsigned](
 	},
 ) []byte {
 	var messageTypeMask = 0x60
-	var compactWidthStaticTokenFlag = CompactWidthOr(0, int(msg.Data.StaticTokenHandle))
+	var compactWidthStaticTokenFlag = CompactWidthOr(0, utils.GetWidthMax64Int(msg.Data.StaticTokenHandle))
 	var firstByte = byte(messageTypeMask | compactWidthStaticTokenFlag)
 	var encodeOffsetFlag int
 	if msg.Data.Offset != 0 && msg.Data.Offset != msg.Data.Entry.Payload_length {
@@ -36,7 +36,7 @@ func EncodeDataSendEntry[DynamicToken string, K constraints.Unsigned](
 		if msg.Data.Offset == msg.Data.Entry.Payload_length {
 			compactWidthOffsetFlag = 0x20
 		} else {
-			compactWidthOffsetFlag = CompactWidthOr(0, utils.GetWidthMax64Int(msg.Data.Offset)<<5)
+			compactWidthOffsetFlag = CompactWidthOr(0, utils.GetWidthMax64Int(msg.Data.Offset)) << 5
 		}
 	}
 	// This is always flagged to true
@@ -54,6 +54,9 @@ func EncodeDataSendEntry[DynamicToken string, K constraints.Unsigned](
 	var encodedStaticToken = utils.EncodeIntMax64(msg.Data.StaticTokenHandle)
 	var encodedDynamicToken = opts.EncodeDynamicToken(msg.Data.DynamicToken)
 	var encodedOffset []byte
+	if encodeOffsetFlag != 0 {
+		encodedOffset = utils.EncodeIntMax64(msg.Data.Offset)
+	}
 	var encodedEntry = utils.EncodeEntryRelativeEntry[K](
 		struct {
 			EncodeNamespace     func(namespace types.NamespaceId) []byte
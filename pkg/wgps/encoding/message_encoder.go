@@ -14,6 +14,8 @@ import (
 type EncodedSyncMessage struct {
 	Channel wgpstypes.Channel
 	Message []byte
+	// Whether the message carries payload bytes, which are sent as bulk data whatever its channel
+	Bulk bool
 }
 
 type MessageEncoder[
@@ -22,7 +24,7 @@ type MessageEncoder[
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -41,8 +43,10 @@ type MessageEncoder[
 	Schemes             wgpstypes.SyncSchemes[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]
 	Opts                struct {
 		reconciliation.ReconcileMsgTrackerOpts
-		//GetIntersectionPrivy  func(handle uint64) wgpstypes.ReadCapPrivy
-		//GetCap                func(handle uint64) ReadCapability
+		// The intersection a read capability we bind is encoded relative to
+		GetIntersectionPrivy func(handle uint64) (wgpstypes.ReadCapPrivy, error)
+		// The area granted by the read capability we bound to a handle
+		GetCapabilityArea     func(handle uint64) (types.Area, error)
 		GetCurrentlySentEntry func() types.Entry
 	}
 }
@@ -52,7 +56,7 @@ func NewMessageEncoder[ReadCapability any,
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -65,8 +69,8 @@ func NewMessageEncoder[ReadCapability any,
 	AuthorisationOpts []byte,
 	K constraints.Unsigned](schemes wgpstypes.SyncSchemes[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K], opts struct {
 	reconciliation.ReconcileMsgTrackerOpts
-	//GetIntersectionPrivy  func(handle uint64) wgpstypes.ReadCapPrivy
-	//GetCap                func(handle uint64) ReadCapability
+	GetIntersectionPrivy  func(handle uint64) (wgpstypes.ReadCapPrivy, error)
+	GetCapabilityArea     func(handle uint64) (types.Area, error)
 	GetCurrentlySentEntry func() types.Entry
 }) *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K] {
 
@@ -86,12 +90,19 @@ func NewMessageEncoder[ReadCapability any,
 }
 
 func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]) Encode(message wgpstypes.SyncMessage) error {
-	Push := func(channel wgpstypes.Channel, message []byte) {
-		me.MessageChannel <- EncodedSyncMessage{Channel: channel, Message: message}
+	Push := func(channel wgpstypes.Channel, message []byte, bulk bool) {
+		me.MessageChannel <- EncodedSyncMessage{Channel: channel, Message: message, Bulk: bulk}
 	}
 
 	var bytes []byte
 
+	getCurrentlySentEntry := func() types.Entry {
+		if me.Opts.GetCurrentlySentEntry == nil {
+			return types.Entry{}
+		}
+		return me.Opts.GetCurrentlySentEntry()
+	}
+
 	switch msg := message.(type) {
 	case wgpstypes.MsgControlIssueGuarantee:
 		bytes = EncodeControlIssueGuarantee(msg)
@@ -131,13 +142,23 @@ func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecret
 
 	// Setup
 	case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-		//Privy := me.Opts.GetIntersectionPrivy(msg.Data.Handle)
-		//bytes = EncodeSetupBindReadCapability[ReadCapability, SyncSignature](msg, me.Schemes.AccessControl.Encodings.ReadCap, me.Schemes.AccessControl.Encodings.SyncSignature.Encode, Privy)
-		break
+		if me.Opts.GetIntersectionPrivy == nil {
+			return fmt.Errorf("can not encode read capabilities without intersections")
+		}
+		Privy, err := me.Opts.GetIntersectionPrivy(msg.Data.Handle)
+		if err != nil {
+			return err
+		}
+		bytes = EncodeSetupBindReadCapability[ReadCapability, SyncSignature](msg, me.Schemes.AccessControl.Encodings.ReadCap, me.Schemes.AccessControl.Encodings.SyncSignature.Encode, Privy)
 	case wgpstypes.MsgSetupBindAreaOfInterest:
-		//Cap := me.Opts.GetCap(msg.Data.Authorisation)
-		//Outer := me.Schemes.AccessControl.GetGrantedArea(Cap)
-		/*bytes = EncodeSetupBindAreaOfInterest[K](msg, struct {
+		if me.Opts.GetCapabilityArea == nil {
+			return fmt.Errorf("can not encode areas of interest without read capabilities")
+		}
+		Outer, err := me.Opts.GetCapabilityArea(msg.Data.Authorisation)
+		if err != nil {
+			return err
+		}
+		bytes = EncodeSetupBindAreaOfInterest[K](msg, struct {
 			Outer          types.Area
 			PathScheme     types.PathParams[K]
 			EncodeSubspace func(subspace types.SubspaceId) []byte
@@ -147,8 +168,7 @@ func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecret
 			PathScheme:     me.Schemes.PathParams,
 			EncodeSubspace: me.Schemes.SubspaceScheme.EncodingScheme.Encode,
 			OrderSubspace:  me.Schemes.SubspaceScheme.Order,
-		}) */
-		break
+		})
 	case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
 		bytes = EncodeSetupBindStaticToken[StaticToken](msg, me.Schemes.AuthorisationToken.Encodings.StaticToken.Encode)
 		break
@@ -216,6 +236,7 @@ func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecret
 		break
 	case wgpstypes.MsgReconciliationTerminatePayload:
 		bytes = EncodeReconciliationTerminatePayload()
+		me.ReconcileMsgTracker.OnTerminatePayload()
 		break
 	case wgpstypes.MsgDataSendEntry[DynamicToken]:
 		bytes = EncodeDataSendEntry[DynamicToken](msg, struct {
@@ -229,7 +250,7 @@ func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecret
 			PathParams          types.PathParams[K]
 		}{
 			EncodeDynamicToken:  me.Schemes.AuthorisationToken.Encodings.DynamicToken.Encode,
-			CurrentlySentEntry:  me.Opts.GetCurrentlySentEntry(),
+			CurrentlySentEntry:  getCurrentlySentEntry(),
 			IsEqualNamespace:    me.Schemes.NamespaceScheme.IsEqual,
 			OrderSubspace:       me.Schemes.SubspaceScheme.Order,
 			EncodeNamespace:     me.Schemes.NamespaceScheme.EncodingScheme.Encode,
@@ -254,7 +275,7 @@ func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecret
 			EncodePayloadDigest func(payloadDigest types.PayloadDigest) []byte
 			PathParams          types.PathParams[K]
 		}{
-			CurrentlySentEntry:  me.Opts.GetCurrentlySentEntry(),
+			CurrentlySentEntry:  getCurrentlySentEntry(),
 			IsEqualNamespace:    me.Schemes.NamespaceScheme.IsEqual,
 			OrderSubspace:       me.Schemes.SubspaceScheme.Order,
 			EncodeNamespace:     me.Schemes.NamespaceScheme.EncodingScheme.Encode,
@@ -269,6 +290,7 @@ func (me *MessageEncoder[ReadCapability, Receiver, SyncSignature, ReceiverSecret
 	default:
 		return fmt.Errorf("did not know how to encode message")
 	}
-	Push(channels.MsgLogicalChannels[message.GetKind()], bytes)
+	kind := message.GetKind()
+	Push(channels.MsgLogicalChannels[kind], bytes, kind == wgpstypes.ReconciliationSendPayload || kind == wgpstypes.DataSendPayload)
 	return nil
 }
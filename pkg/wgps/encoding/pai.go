@@ -7,10 +7,22 @@ import (
 
 func EncodeCommitmentReveal(msg wgpstypes.MsgCommitmentReveal) []byte {
 	var Result []byte
+	Result = append(Result, 0)
 	Result = append(Result, msg.Data.Nonce...)
 	return Result
 }
 
+/*
+EncodeOpening encodes the bytes each peer sends on the control channel before any message: the power of two of its
+maximum payload size, followed by the hash of the nonce it commits to.
+*/
+func EncodeOpening(maxPayloadSizePower uint8, commitment []byte) []byte {
+	var Result []byte
+	Result = append(Result, maxPayloadSizePower)
+	Result = append(Result, commitment...)
+	return Result
+}
+
 func EncodePaiBindFragment[PsiGroup any](msg wgpstypes.MsgPaiBindFragment[PsiGroup], encodeGroupMember func(group PsiGroup) []byte) []byte {
 	var Result []byte
 	if msg.Data.IsSecondary {
@@ -40,7 +52,7 @@ func EncodePaiReplyFragment[PsiGroup any](msg wgpstypes.MsgPaiReplyFragment[PsiG
 
 	var Result []byte
 	Result = append(Result, Header)
-	Result = append(Result, byte(utils.GetWidthMax64Int(msg.Data.Handle)))
+	Result = append(Result, utils.EncodeIntMax64(msg.Data.Handle)...)
 	Result = append(Result, (encodeGroupMember(msg.Data.GroupMember))...)
 
 	return Result
@@ -62,7 +74,7 @@ func EncodePaiRequestSubspaceCapability(msg wgpstypes.MsgPaiRequestSubspaceCapab
 
 	var Result []byte
 	Result = append(Result, Header)
-	Result = append(Result, byte(utils.GetWidthMax64Int(msg.Data.Handle)))
+	Result = append(Result, utils.EncodeIntMax64(msg.Data.Handle)...)
 
 	return Result
 }
@@ -87,7 +99,7 @@ func EncodePaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature
 
 	var Result []byte
 	Result = append(Result, Header)
-	Result = append(Result, byte(utils.GetWidthMax64Int(msg.Data.Handle)))
+	Result = append(Result, utils.EncodeIntMax64(msg.Data.Handle)...)
 	Result = append(Result, encodeSubspaceCapability(msg.Data.Capability)...)
 	Result = append(Result, encodeSubspaceSignature(msg.Data.Signature)...)
 
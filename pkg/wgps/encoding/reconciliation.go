@@ -24,8 +24,6 @@ func EncodeReconciliationSendFingerprint[Fingerprint string, ValueType constrain
 		NeutralMask = 0x0
 	}
 
-	COVERS_NONE := 1
-
 	EncodedRelativeToPrevRange := byte(0x4)
 
 	SenderHandleIsSame := msg.Data.SenderHandle == opts.Privy.PrevSenderHandle
@@ -51,47 +49,47 @@ func EncodeReconciliationSendFingerprint[Fingerprint string, ValueType constrain
 
 	HandleLengthNumber := byte(0x0)
 
-	CompactWidthSender := utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.SenderHandle))
-	CompactWidthReceiver := utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.ReceiverHandle))
+	CompactWidthSender := utils.GetWidthMax64Int(msg.Data.SenderHandle)
+	CompactWidthReceiver := utils.GetWidthMax64Int(msg.Data.ReceiverHandle)
 
 	if !SenderHandleIsSame {
-		Unshifted := CompactWidthOr(0x0, CompactWidthReceiver)
+		Unshifted := CompactWidthOr(0x0, CompactWidthSender)
 		Shifted := byte(Unshifted << 6)
 		HandleLengthNumber = HandleLengthNumber | Shifted
 	}
 
 	if !ReceiverHandleIsSame {
-		Unshifted := CompactWidthOr(0x0, CompactWidthSender)
+		Unshifted := CompactWidthOr(0x0, CompactWidthReceiver)
 		Shifted := byte(Unshifted << 4)
 		HandleLengthNumber = HandleLengthNumber | Shifted
 	}
 
-	if msg.Data.Covers != uint64(COVERS_NONE) {
+	if msg.Data.DoesCover {
 		HandleLengthNumber = HandleLengthNumber | 0x8
-		HandleLengthNumber = byte(CompactWidthOr(int(HandleLengthNumber), utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.Covers))))
+		HandleLengthNumber = byte(CompactWidthOr(int(HandleLengthNumber), utils.GetWidthMax64Int(msg.Data.Covers)))
 	}
 
 	HandleLengthByte := []byte{HandleLengthNumber}
 
 	var EncodedCovers []byte
-	if msg.Data.Covers == uint64(COVERS_NONE) {
+	if !msg.Data.DoesCover {
 		EncodedCovers = []byte{}
 	} else {
-		EncodedCovers = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.Covers))
+		EncodedCovers = utils.EncodeIntMax64(msg.Data.Covers)
 	}
 
 	var EncodedSenderhandle []byte
 	if SenderHandleIsSame {
 		EncodedSenderhandle = []byte{}
 	} else {
-		EncodedSenderhandle = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.SenderHandle))
+		EncodedSenderhandle = utils.EncodeIntMax64(msg.Data.SenderHandle)
 	}
 
 	var EncodedReceiverHandle []byte
 	if ReceiverHandleIsSame {
 		EncodedReceiverHandle = []byte{}
 	} else {
-		EncodedReceiverHandle = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.ReceiverHandle))
+		EncodedReceiverHandle = utils.EncodeIntMax64(msg.Data.ReceiverHandle)
 	}
 
 	var EncodedFingerprint []byte
@@ -112,7 +110,7 @@ func EncodeReconciliationSendFingerprint[Fingerprint string, ValueType constrain
 	}, msg.Data.Range, opts.Privy.PrevRange)
 
 	var Result []byte
-	Result = append(Result, MessageTypeMask|HeaderByte)
+	Result = append(Result, HeaderByte)
 	Result = append(Result, HandleLengthByte...)
 	Result = append(Result, EncodedCovers...)
 	Result = append(Result, EncodedSenderhandle...)
@@ -130,8 +128,6 @@ func EncodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](msg wgp
 	PathScheme       types.PathParams[ValueType]
 }) []byte {
 
-	COVERS_NONE := 1
-
 	MessageTyoeMask := byte(0x50)
 
 	var WantResponseBit byte
@@ -162,8 +158,8 @@ func EncodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](msg wgp
 
 	FirstByte := MessageTyoeMask | WantResponseBit | EncodedRelativebit | UsingPrevSenderHandleMask | UsingPrevReceiverHandleMask
 
-	CompactWidthSender := byte(utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.SenderHandle)))
-	CompactWidthReceiver := byte(utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.ReceiverHandle)))
+	CompactWidthSender := byte(utils.GetWidthMax64Int(msg.Data.SenderHandle))
+	CompactWidthReceiver := byte(utils.GetWidthMax64Int(msg.Data.ReceiverHandle))
 
 	var SenderReceiverWidthFlags byte
 
@@ -182,7 +178,7 @@ func EncodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](msg wgp
 		SenderReceiverWidthFlags = 0x0
 	}
 
-	CountCompactWidth := byte(utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.Count)))
+	CountCompactWidth := byte(utils.GetWidthMax64Int(msg.Data.Count))
 
 	CountCompactWidthFlags := byte(CompactWidthOr(0, int(CountCompactWidth))) << 2
 
@@ -195,7 +191,7 @@ func EncodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](msg wgp
 
 	var CoversNotNone byte
 
-	if msg.Data.Covers != uint64(COVERS_NONE) {
+	if msg.Data.DoesCover {
 		CoversNotNone = 0x1
 	} else {
 		CoversNotNone = 0x0
@@ -206,12 +202,12 @@ func EncodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](msg wgp
 	var CoversCompactWidth []byte
 	var CoversEncoded []byte
 
-	if msg.Data.Covers == uint64(COVERS_NONE) {
+	if !msg.Data.DoesCover {
 		CoversCompactWidth = []byte{}
 		CoversEncoded = []byte{}
 	} else if msg.Data.Covers >= 252 {
-		CoversCompactWidth = []byte{byte(CompactWidthOr(0xfc, utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.Covers))))}
-		CoversEncoded = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.Covers))
+		CoversCompactWidth = []byte{byte(CompactWidthOr(0xfc, utils.GetWidthMax64Int(msg.Data.Covers)))}
+		CoversEncoded = utils.EncodeIntMax64(msg.Data.Covers)
 	} else {
 		CoversCompactWidth = []byte{byte(msg.Data.Covers)}
 		CoversEncoded = []byte{}
@@ -219,19 +215,19 @@ func EncodeReconciliationAnnounceEntries[ValueType constraints.Unsigned](msg wgp
 
 	var EncodedSenderHandle []byte
 	if !SenderHandleIsSame {
-		EncodedSenderHandle = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.SenderHandle))
+		EncodedSenderHandle = utils.EncodeIntMax64(msg.Data.SenderHandle)
 	} else {
 		EncodedSenderHandle = []byte{}
 	}
 
 	var EncodedReceiverHandle []byte
 	if !ReceiverHandleIsSame {
-		EncodedReceiverHandle = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.ReceiverHandle))
+		EncodedReceiverHandle = utils.EncodeIntMax64(msg.Data.ReceiverHandle)
 	} else {
 		EncodedReceiverHandle = []byte{}
 	}
 
-	EncodedCount := utils.EncodeIntMax64[ValueType](ValueType(msg.Data.Count))
+	EncodedCount := utils.EncodeIntMax64(msg.Data.Count)
 
 	EncodedRelativeRange := utils.EncodeRange3dRelative[ValueType](struct {
 		OrderSubspace    types.TotalOrder[types.SubspaceId]
@@ -283,13 +279,13 @@ func EncodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 
 	IsEncodedRelativeToPrevEntryFlag := byte(0x4)
 
-	CompactWidthAvailableFlag := byte(CompactWidthOr(0, utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.Entry.Available))))
+	CompactWidthAvailableFlag := byte(CompactWidthOr(0, utils.GetWidthMax64Int(msg.Data.Entry.Available)))
 
 	Header := MessageTypeMask | IsPrevStaticTokenFlag | IsEncodedRelativeToPrevEntryFlag | CompactWidthAvailableFlag
 
 	var EncodedStaticTokenWidth []byte
 
-	CompactWidthStaticToken := utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.StaticTokenHandle))
+	CompactWidthStaticToken := utils.GetWidthMax64Int(msg.Data.StaticTokenHandle)
 
 	if IsPrevTokenEqual {
 		EncodedStaticTokenWidth = []byte{}
@@ -307,13 +303,13 @@ func EncodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 
 	var EncodedStaticToken []byte
 
-	if !IsPrevTokenEqual && msg.Data.StaticTokenHandle > uint64(63) {
-		EncodedStaticToken = utils.EncodeIntMax64[ValueType](ValueType(msg.Data.StaticTokenHandle))
+	if !IsPrevTokenEqual && msg.Data.StaticTokenHandle >= uint64(63) {
+		EncodedStaticToken = utils.EncodeIntMax64(msg.Data.StaticTokenHandle)
 	} else {
 		EncodedStaticToken = []byte{}
 	}
 
-	EncodedAvailable := utils.EncodeIntMax64[ValueType](ValueType(msg.Data.Entry.Available))
+	EncodedAvailable := utils.EncodeIntMax64(msg.Data.Entry.Available)
 
 	EncodedDynamicToken := opts.EncodeDynamicToken(msg.Data.DynamicToken)
 
@@ -346,8 +342,8 @@ func EncodeReconciliationSendEntry[DynamicToken string, ValueType constraints.Un
 }
 
 func EncodeReconciliationSendPayload[ValueType constraints.Unsigned](msg wgpstypes.MsgReconciliationSendPayload) []byte {
-	Header := byte(CompactWidthOr(0x50, utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.Amount))))
-	AmountEncoded := utils.EncodeIntMax64[ValueType](ValueType(msg.Data.Amount))
+	Header := byte(CompactWidthOr(0x50, utils.GetWidthMax64Int(msg.Data.Amount)))
+	AmountEncoded := utils.EncodeIntMax64(msg.Data.Amount)
 
 	var Result []byte
 	Result = append(Result, Header)
@@ -357,6 +353,7 @@ func EncodeReconciliationSendPayload[ValueType constraints.Unsigned](msg wgpstyp
 	return Result
 }
 
+// The 0x8 bit tells the end of a payload apart from another chunk of it
 func EncodeReconciliationTerminatePayload() []byte {
-	return []byte{0x50}
+	return []byte{0x58}
 }
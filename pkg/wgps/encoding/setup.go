@@ -27,6 +27,10 @@ func EncodeSetupBindReadCapability[ReadCapability, SyncSignature any, ValueType
 	return Result
 }
 
+/*
+Encodes a SetupBindAreaOfInterest message. The area is encoded relative to the area granted by the read capability
+it is bound with, and the limits are only encoded when there are any.
+*/
 func EncodeSetupBindAreaOfInterest[ValueType constraints.Unsigned](
 	msg wgpstypes.MsgSetupBindAreaOfInterest,
 	opts struct {
@@ -36,16 +40,13 @@ func EncodeSetupBindAreaOfInterest[ValueType constraints.Unsigned](
 		OrderSubspace  types.TotalOrder[types.SubspaceId]
 	},
 ) []byte {
-	var Value byte
-	if byte(msg.Data.AreaOfInterest.MaxCount) != 0 || byte(msg.Data.AreaOfInterest.MaxSize) != 0 {
-		Value = 0x4
-	} else {
-		Value = 0x0
+	HasALimit := msg.Data.AreaOfInterest.MaxCount != 0 || msg.Data.AreaOfInterest.MaxSize != 0
 
+	Header := 0x28
+	if HasALimit {
+		Header |= 0x4
 	}
-	Header := byte(CompactWidthOr(0x28, int(byte(utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.Authorisation)))|Value)))
-
-	AuthHandle := utils.EncodeIntMax64[ValueType](ValueType(msg.Data.Authorisation))
+	Header = CompactWidthOr(Header, utils.GetWidthMax64Int(msg.Data.Authorisation))
 
 	AreaInArea := utils.EncodeAreaInArea(
 		utils.EncodeAreaOpts[ValueType]{
@@ -57,27 +58,21 @@ func EncodeSetupBindAreaOfInterest[ValueType constraints.Unsigned](
 		opts.Outer,
 	)
 
-	if msg.Data.AreaOfInterest.MaxCount == 0 && msg.Data.AreaOfInterest.MaxSize == 0 {
-		return append([]byte{Header}, append(AuthHandle, AreaInArea...)...)
+	var Result []byte
+	Result = append(Result, byte(Header))
+	Result = append(Result, utils.EncodeIntMax64(msg.Data.Authorisation)...)
+	Result = append(Result, AreaInArea...)
+	if !HasALimit {
+		return Result
 	}
 
-	MaxCountMask := byte(CompactWidthOr(0, int(byte(utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.AreaOfInterest.MaxCount))))))
-
-	Shifted := byte(MaxCountMask << 2)
-
-	MaxSizeMask := byte(CompactWidthOr(int(Shifted), int(byte(utils.GetWidthMax64Int[ValueType](ValueType(msg.Data.AreaOfInterest.MaxSize))))))
+	// The widths of the maximum count and the maximum size, in the two most significant pairs of bits
+	Widths := CompactWidthOr(0, utils.GetWidthMax64Int(msg.Data.AreaOfInterest.MaxCount)) << 6
+	Widths |= CompactWidthOr(0, utils.GetWidthMax64Int(msg.Data.AreaOfInterest.MaxSize)) << 4
 
-	LengthBytes := byte(MaxSizeMask << 4)
-
-	var Result []byte
-	Result = append(Result, Header)
-	Result = append(Result, AuthHandle...)
-	Result = append(Result, AreaInArea...)
-	var Res []byte
-	Res = append(Res, LengthBytes)
-	Result = append(Result, Res...)
-	Result = append(Result, utils.EncodeIntMax64[ValueType](ValueType(msg.Data.AreaOfInterest.MaxCount))...)
-	Result = append(Result, utils.EncodeIntMax64[ValueType](ValueType(msg.Data.AreaOfInterest.MaxSize))...)
+	Result = append(Result, byte(Widths))
+	Result = append(Result, utils.EncodeIntMax64(msg.Data.AreaOfInterest.MaxCount)...)
+	Result = append(Result, utils.EncodeIntMax64(msg.Data.AreaOfInterest.MaxSize)...)
 
 	return Result
 }
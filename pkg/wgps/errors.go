@@ -0,0 +1,68 @@
+package wgps
+
+import (
+	"errors"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+/*
+ProtocolViolationError is an error caused by the other peer violating the protocol. It is declared in wgpstypes, so
+that the packages the messenger builds on can return it too.
+*/
+type ProtocolViolationError = wgpstypes.ProtocolViolationError
+
+/*
+AuthFailureError is an error caused by the other peer not proving who it is, or not proving it may read what it asks
+for. This is a peer whose key is not trusted, or which binds a capability that is invalid or not signed over the
+challenge of the session.
+*/
+type AuthFailureError struct {
+	Err error
+}
+
+func (e AuthFailureError) Error() string {
+	return "auth failure: " + e.Err.Error()
+}
+
+func (e AuthFailureError) Unwrap() error {
+	return e.Err
+}
+
+// TransportLossError is an error caused by the connection to the other peer breaking down before either peer closed the session
+type TransportLossError struct {
+	Err error
+}
+
+func (e TransportLossError) Error() string {
+	return "transport loss: " + e.Err.Error()
+}
+
+func (e TransportLossError) Unwrap() error {
+	return e.Err
+}
+
+// Returned when something is asked of a session which is over, or of one which never started
+var ErrSessionEnded = errors.New("the session has ended")
+
+/*
+Returned by NewWgpsMessenger and NewServer for a store without ingestion listeners. The messenger keeps a copy of the
+store, whose listeners would not be the ones of the store it was copied from, so the entries ingested into either
+would never reach the other.
+*/
+var ErrStoreWithoutListeners = errors.New("the store has no ingestion listeners, they are set up by the constructor of the store")
+
+/*
+Returned by NewWgpsMessenger and NewServer for a store without an ingestion lock. Their sessions ingest into copies of
+the store, which would ingest at the same time without a lock they share.
+*/
+var ErrStoreWithoutLock = errors.New("the store has no ingestion lock, it is set up by the constructor of the store")
+
+/*
+Ends a session with a message to send which is longer than the buffer the other peer keeps for its logical channel,
+so that the message would wait for guarantees forever.
+*/
+var ErrMessageTooLong = errors.New("the message is longer than the buffer of the other peer")
+
+// Wrapped by the errors of Server.Connect for an address the server connected to already and still syncs with
+var ErrAlreadyConnected = errors.New("already syncing with the peer")
@@ -1,69 +1,205 @@
 package wgps
 
 import (
-	"context"
-	"strconv"
+	"fmt"
+	"sync"
 )
 
+/*
+GuaranteedQueue holds the messages we send on one logical channel until the other peer guarantees to have room
+for them. Messages only leave the queue through Queue, in order, once enough guarantees were issued for all of
+their bytes.
+
+A peer guarantees the whole of its buffer to begin with, and all of it again once the buffer is empty, as ReceiveBuffer
+does. A message longer than the most the peer ever guaranteed at once would wait forever, so the queue fails instead.
+A plead does not tell how far the buffer shrank, so a message which only fitted the buffer before still waits for it.
+*/
 type GuaranteedQueue struct {
-	Guarantees    uint64
-	Queue         chan []byte
-	ReceivedBytes []byte
-	OutGoingBytes []byte
+	Guarantees uint64
+	// The most guarantees the other peer issued at once, which is the size of its buffer as far as we know
+	Capacity uint64
+	Queue    chan QueuedMessage
+	// Messages waiting for guarantees, in the order they were pushed
+	Pending []QueuedMessage
+	mu      sync.Mutex
+	// Closed once nobody takes messages from Queue anymore
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// A message of a GuaranteedQueue, Bulk when it carries payload bytes
+type QueuedMessage struct {
+	Bytes []byte
+	Bulk  bool
+}
+
+func NewGuaranteedQueue() *GuaranteedQueue {
+	return &GuaranteedQueue{
+		Queue:  make(chan QueuedMessage, 32),
+		closed: make(chan struct{}),
+	}
+}
+
+// Drops the messages which are pending, and those pushed from now on, once the session they were for is over
+func (q *GuaranteedQueue) Close() {
+	q.closeOnce.Do(func() { close(q.closed) })
+}
+
+/** Add some bytes to the queue. Fails with ErrMessageTooLong once a message can never be sent. */
+func (q *GuaranteedQueue) Push(bytes []byte) error {
+	return q.push(QueuedMessage{Bytes: bytes})
+}
+
+// Add some bytes carrying (a part of) a payload to the queue
+func (q *GuaranteedQueue) PushBulk(bytes []byte) error {
+	return q.push(QueuedMessage{Bytes: bytes, Bulk: true})
 }
 
-/** Add some bytes to the queue. */
-func (q *GuaranteedQueue) Push(bytes []byte) {
-	q.Queue <- bytes
-	q.ReceivedBytes = append(q.ReceivedBytes, bytes...)
-	q.UseGuarantees()
+func (q *GuaranteedQueue) push(message QueuedMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Pending = append(q.Pending, message)
+	return q.useGuarantees()
 }
 
-/** Add guarantees received from the server. */
-func (q *GuaranteedQueue) AddGuarantees(bytes uint64) {
+/** Add guarantees received from the server. Fails with ErrMessageTooLong once a message can never be sent. */
+func (q *GuaranteedQueue) AddGuarantees(bytes uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	q.Guarantees += bytes
-	q.UseGuarantees()
+	q.Capacity = max(q.Capacity, q.Guarantees)
+	return q.useGuarantees()
 }
 
 /** Received a plea from the server to shrink the buffer to a certain size.
  *
- * This implementation always absolves them.
+ * This implementation always absolves them, and returns the amount to absolve them of.
  */
 func (q *GuaranteedQueue) Plead(targetSize uint64) uint64 {
-	var AbsolveAmount = q.Guarantees - targetSize
-	q.Guarantees -= AbsolveAmount
-	return AbsolveAmount
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.Guarantees <= targetSize {
+		return 0
+	}
+	absolveAmount := q.Guarantees - targetSize
+	q.Guarantees = targetSize
+	return absolveAmount
 }
 
-func (q *GuaranteedQueue) UseGuarantees() {
-	for len(q.Queue) > 0 {
-		var peekHead = string((q.ReceivedBytes)[0])
-
-		if len(peekHead) == 0 || len(peekHead) > len(strconv.FormatUint(q.Guarantees, 10)) {
-			return
+// Moves the messages at the head of the queue which are covered by our guarantees to Queue
+func (q *GuaranteedQueue) useGuarantees() error {
+	for len(q.Pending) > 0 {
+		head := q.Pending[0]
+		if q.Capacity > 0 && uint64(len(head.Bytes)) > q.Capacity {
+			return fmt.Errorf("%w: the message takes %v bytes, the buffer holds %v", ErrMessageTooLong, len(head.Bytes), q.Capacity)
 		}
+		if uint64(len(head.Bytes)) > q.Guarantees {
+			return nil
+		}
+		q.Pending = q.Pending[1:]
+		q.Guarantees -= uint64(len(head.Bytes))
+		select {
+		case q.Queue <- head:
+		case <-q.closed:
+			q.Pending = nil
+			return nil
+		}
+	}
+	return nil
+}
 
-		head := q.ReceivedBytes[0]
-		q.ReceivedBytes = q.ReceivedBytes[1:]
-		q.OutGoingBytes = append(q.OutGoingBytes, head)
-		q.Guarantees -= uint64(len(string(head)))
+/*
+ReceiveBuffer accounts for the bytes the other peer may send us on one logical channel. We guarantee the other peer
+as many bytes as the buffer has room for, and issue new guarantees as the messages in it are handled.
+
+A peer which sends more than it was guaranteed has its messages dropped, until it apologises. Ours never does, and
+ends the session when told of dropped messages, rather than sending them again.
+*/
+type ReceiveBuffer struct {
+	Capacity uint64
+	// Guarantees issued to the other peer which it has not used or been absolved of yet
+	Issued uint64
+	// Bytes of messages received but not handled yet
+	Used     uint64
+	Dropping bool
+	mu       sync.Mutex
+}
+
+func NewReceiveBuffer(capacity uint64) *ReceiveBuffer {
+	return &ReceiveBuffer{
+		Capacity: capacity,
 	}
 }
 
-func FetchOutgoingBytes(ctx context.Context, outgoingBytes [][]byte) chan []byte {
-	ch := make(chan []byte)
+/*
+Accounts for a message of the given length arriving, and tells whether to accept it. The first message which
+exceeds the guarantees starts dropping, which has to be announced to the other peer.
+*/
+func (b *ReceiveBuffer) Receive(length uint64) (accept bool, startedDropping bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Dropping {
+		return false, false
+	}
+	if length > b.Issued {
+		b.Dropping = true
+		return false, true
+	}
+	b.Issued -= length
+	b.Used += length
+	return true, false
+}
 
-	go func() {
-		defer close(ch)
-		for _, bytes := range outgoingBytes {
-			select {
-			case <-ctx.Done(): // Allows for graceful shutdown
-				return
-			case ch <- bytes:
-				// Simulate some asynchronous operation
-			}
-		}
-	}()
+// Frees the room of a message which was handled
+func (b *ReceiveBuffer) Handled(length uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Used -= min(length, b.Used)
+}
+
+/*
+Returns the amount of new guarantees to issue, if any. Guarantees are issued once at most half of the capacity is
+guaranteed, to not answer every message with a guarantee, and once every message was handled. The other peer may wait
+for a message longer than half of the buffer, which it can send once the whole buffer is guaranteed again.
+*/
+func (b *ReceiveBuffer) TopUp() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Issued+b.Used >= b.Capacity || (b.Issued > b.Capacity/2 && b.Used > 0) {
+		return 0
+	}
+	amount := b.Capacity - b.Issued - b.Used
+	b.Issued += amount
+	return amount
+}
+
+/*
+Shrinks the capacity of the buffer. When more than the new capacity was guaranteed it returns the target to plead
+the other peer to shrink its guarantees to, and whether pleading is necessary at all.
+*/
+func (b *ReceiveBuffer) Shrink(capacity uint64) (target uint64, plead bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Capacity = capacity
+	if b.Issued+b.Used <= capacity {
+		return 0, false
+	}
+	if b.Used >= capacity {
+		return 0, true
+	}
+	return capacity - b.Used, true
+}
+
+// The other peer gave up some of its guarantees
+func (b *ReceiveBuffer) Absolve(amount uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Issued -= min(amount, b.Issued)
+}
 
-	return ch
+// The other peer apologised for sending more than it was guaranteed, its messages are accepted again
+func (b *ReceiveBuffer) Apologise() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Dropping = false
 }
@@ -0,0 +1,139 @@
+package wgps
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGuaranteedQueueWaitsForGuarantees(t *testing.T) {
+	queue := NewGuaranteedQueue()
+
+	queue.Push([]byte("hello"))
+	queue.Push([]byte("hi"))
+	if len(queue.Queue) != 0 {
+		t.Fatalf("sent %d messages without guarantees", len(queue.Queue))
+	}
+
+	// Enough for the first message only, and a third one which would fit may not overtake the second
+	if err := queue.AddGuarantees(6); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-queue.Queue; !bytes.Equal(got.Bytes, []byte("hello")) {
+		t.Errorf("sent %q first, expected hello", got.Bytes)
+	}
+	queue.Push([]byte("!"))
+	if len(queue.Queue) != 0 || queue.Guarantees != 1 {
+		t.Fatalf("expected to wait for guarantees for the second message, have %d", queue.Guarantees)
+	}
+
+	queue.AddGuarantees(10)
+	if got := <-queue.Queue; !bytes.Equal(got.Bytes, []byte("hi")) {
+		t.Errorf("sent %q second, expected hi", got.Bytes)
+	}
+	if got := <-queue.Queue; !bytes.Equal(got.Bytes, []byte("!")) {
+		t.Errorf("sent %q third, expected !", got.Bytes)
+	}
+	if queue.Guarantees != 8 {
+		t.Errorf("expected 8 guarantees left, have %d", queue.Guarantees)
+	}
+
+	if amount := queue.Plead(3); amount != 5 || queue.Guarantees != 3 {
+		t.Errorf("expected to absolve 5 guarantees down to 3, absolved %d down to %d", amount, queue.Guarantees)
+	}
+	if amount := queue.Plead(10); amount != 0 || queue.Guarantees != 3 {
+		t.Errorf("expected a plead above our guarantees to be ignored, absolved %d", amount)
+	}
+}
+
+func TestGuaranteedQueueFailsForMessagesBeyondTheBuffer(t *testing.T) {
+	queue := NewGuaranteedQueue()
+	if err := queue.Push(make([]byte, 12)); err != nil {
+		t.Fatalf("expected to wait while the buffer of the other peer is unknown, got %v", err)
+	}
+
+	// The other peer guarantees its whole buffer, which is too small for the message
+	if err := queue.AddGuarantees(10); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("expected the message to never fit, got %v", err)
+	}
+	if err := queue.Push(make([]byte, 1)); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("expected the queue to stay stuck behind the message, got %v", err)
+	}
+	if len(queue.Queue) != 0 {
+		t.Errorf("sent %d messages behind the one which never fits", len(queue.Queue))
+	}
+}
+
+func TestReceiveBufferIssuesGuaranteesForRoom(t *testing.T) {
+	buffer := NewReceiveBuffer(100)
+
+	if amount := buffer.TopUp(); amount != 100 {
+		t.Fatalf("expected to guarantee the whole capacity, guaranteed %d", amount)
+	}
+	if amount := buffer.TopUp(); amount != 0 {
+		t.Fatalf("guaranteed %d bytes more than the capacity", amount)
+	}
+
+	for i := 0; i < 3; i++ {
+		if accept, _ := buffer.Receive(20); !accept {
+			t.Fatalf("dropped a guaranteed message")
+		}
+		buffer.Handled(20)
+	}
+	// 40 bytes are still guaranteed, which is less than half of the capacity
+	if amount := buffer.TopUp(); amount != 60 {
+		t.Errorf("expected to guarantee the 60 bytes handled, guaranteed %d", amount)
+	}
+
+	if accept, _ := buffer.Receive(50); !accept {
+		t.Fatalf("dropped a guaranteed message")
+	}
+	// The unhandled message still takes up room
+	if amount := buffer.TopUp(); amount != 0 {
+		t.Errorf("guaranteed %d bytes of room taken by an unhandled message", amount)
+	}
+
+	target, plead := buffer.Shrink(60)
+	if !plead || target != 10 {
+		t.Errorf("expected to plead for 10 remaining guarantees, pleading %v for %d", plead, target)
+	}
+	buffer.Absolve(40)
+	buffer.Handled(50)
+	if amount := buffer.TopUp(); amount != 50 {
+		t.Errorf("expected to guarantee up to the shrunk capacity, guaranteed %d", amount)
+	}
+}
+
+func TestReceiveBufferGuaranteesItsWholeBufferOnceEmpty(t *testing.T) {
+	buffer := NewReceiveBuffer(100)
+	buffer.TopUp()
+	if accept, _ := buffer.Receive(30); !accept {
+		t.Fatalf("dropped a guaranteed message")
+	}
+	if amount := buffer.TopUp(); amount != 0 {
+		t.Errorf("guaranteed %d bytes while more than half of the capacity is guaranteed", amount)
+	}
+
+	// A message longer than the 70 bytes still guaranteed can be sent once the buffer is empty
+	buffer.Handled(30)
+	if amount := buffer.TopUp(); amount != 30 {
+		t.Errorf("expected to guarantee the whole buffer once it is empty, guaranteed %d more", amount)
+	}
+}
+
+func TestReceiveBufferDropsUntilApology(t *testing.T) {
+	buffer := NewReceiveBuffer(10)
+	buffer.TopUp()
+
+	if accept, startedDropping := buffer.Receive(11); accept || !startedDropping {
+		t.Fatalf("expected a message beyond the guarantees to start dropping")
+	}
+	if accept, startedDropping := buffer.Receive(1); accept || startedDropping {
+		t.Fatalf("expected to keep dropping without announcing it again")
+	}
+
+	buffer.Apologise()
+	if accept, _ := buffer.Receive(10); !accept {
+		t.Errorf("expected to accept guaranteed messages after an apology")
+	}
+}
@@ -20,6 +20,8 @@ type HandleStoreTriple[ValueType any] struct {
 
 type HandleStore[ValueType any] struct {
 	LeastUnassignedHandle uint64
+	// The most handles which may be bound at once, unbounded when 0
+	MaxHandles uint64
 	/** A map of handles (numeric IDs) to a triple made up of:
 	 * - The bound data
 	 * - Whether we've asked to free that data (and in doing so committing to no longer using it)
@@ -30,6 +32,17 @@ type HandleStore[ValueType any] struct {
 /** Indicates whether this a store of handles we have bound, or a store of handles bound by another peer. */
 // private isOurs: boolean;
 
+/*
+Handles are the operations of a HandleStore which do not depend on the data bound to its handles, so that the
+stores of every HandleType can be managed alike.
+*/
+type Handles interface {
+	CheckHandle(handle uint64) error
+	WasBound(handle uint64) bool
+	Full() bool
+	Free(handle uint64) error
+}
+
 func (s *HandleStore[ValueType]) Get(handle uint64) (ValueType, bool) {
 	value, found := s.Map[handle]
 	return value.Value, found
@@ -58,8 +71,39 @@ func (s *HandleStore[ValueType]) CanUse(handle uint64) bool {
 	return found && !triple.AskedToFree
 }
 
+/*
+Returns an error if a handle can not be used, either because it was never bound or because it was freed.
+*/
+func (s *HandleStore[ValueType]) CheckHandle(handle uint64) error {
+	triple, found := s.Map[handle]
+	if !found && handle >= s.LeastUnassignedHandle {
+		return fmt.Errorf("handle %d was never bound", handle)
+	}
+	if !found || triple.AskedToFree {
+		return fmt.Errorf("handle %d was freed", handle)
+	}
+	return nil
+}
+
+// Whether a handle was bound at some point, even if it was freed since
+func (s *HandleStore[ValueType]) WasBound(handle uint64) bool {
+	return handle < s.LeastUnassignedHandle
+}
+
+// Whether binding another handle would exceed MaxHandles
+func (s *HandleStore[ValueType]) Full() bool {
+	return s.MaxHandles > 0 && uint64(len(s.Map)) >= s.MaxHandles
+}
+
+/*
+Frees a handle, which can not be used anymore afterwards. Its data is kept until no unprocessed message refers to
+it. Freeing a handle twice is harmless, freeing a handle which was never bound is an error.
+*/
 func (s *HandleStore[ValueType]) Free(handle uint64) error {
 	triple, found := s.Map[handle]
+	if !found && handle < s.LeastUnassignedHandle {
+		return nil
+	}
 	if !found {
 		return fmt.Errorf("no handle found to free")
 	}
@@ -0,0 +1,67 @@
+package handlestore
+
+import (
+	"testing"
+)
+
+func TestFreedHandlesCanNotBeUsed(t *testing.T) {
+	store := HandleStore[string]{Map: NewMap[string]()}
+	handle := store.Bind("a")
+
+	if err := store.CheckHandle(handle); err != nil {
+		t.Fatalf("could not use a bound handle: %v", err)
+	}
+	if err := store.CheckHandle(handle + 1); err == nil {
+		t.Errorf("used a handle which was never bound")
+	}
+
+	if err := store.Free(handle); err != nil {
+		t.Fatalf("could not free a bound handle: %v", err)
+	}
+	if err := store.CheckHandle(handle); err == nil {
+		t.Errorf("used a freed handle")
+	}
+	if err := store.Free(handle); err != nil {
+		t.Errorf("freeing a handle twice failed: %v", err)
+	}
+	if err := store.Free(handle + 1); err == nil {
+		t.Errorf("freed a handle which was never bound")
+	}
+}
+
+func TestFreedHandlesWaitForMessages(t *testing.T) {
+	store := HandleStore[string]{Map: NewMap[string]()}
+	handle := store.Bind("a")
+
+	store.IncrementMessageRefCount(handle)
+	store.Free(handle)
+	if _, found := store.Get(handle); !found {
+		t.Fatalf("dropped the data of a handle an unprocessed message refers to")
+	}
+	if err := store.CheckHandle(handle); err == nil {
+		t.Errorf("used a handle which was asked to be freed")
+	}
+
+	store.DecrementMessageRefCount(handle)
+	if _, found := store.Get(handle); found {
+		t.Errorf("kept the data of a freed handle after processing its messages")
+	}
+}
+
+func TestHandleStoreIsFullAtMaxHandles(t *testing.T) {
+	store := HandleStore[string]{Map: NewMap[string](), MaxHandles: 2}
+
+	store.Bind("a")
+	if store.Full() {
+		t.Fatalf("full after binding one of two handles")
+	}
+	handle := store.Bind("b")
+	if !store.Full() {
+		t.Fatalf("not full after binding two of two handles")
+	}
+
+	store.Free(handle)
+	if store.Full() {
+		t.Errorf("still full after freeing a handle")
+	}
+}
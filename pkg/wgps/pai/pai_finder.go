@@ -1,6 +1,8 @@
 package pai
 
 import (
+	"fmt"
+
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/syncutils"
@@ -10,69 +12,53 @@ import (
 	"golang.org/x/exp/constraints"
 )
 
-type PaiFinderOpts[ReadCapability, PsiGroup, SubspaceReadCapability any, PsiScalar int, K constraints.Unsigned] struct {
+type PaiFinderOpts[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar any, K constraints.Unsigned] struct {
 	NamespaceScheme           datamodeltypes.NamespaceScheme
 	PaiScheme                 wgpstypes.PaiScheme[ReadCapability, PsiGroup, PsiScalar, K]
-	IntersectionHandlesOurs   handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
-	IntersectionHandlesTheirs handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+	IntersectionHandlesOurs   *handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+	IntersectionHandlesTheirs *handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
 }
 
+// What to do once a fragment we bound turns out to be held by the other peer as well
 const (
 	BIND_READ_CAP = iota // iota is reset to 0
 	REQUEST_SUBSPACE_CAP
 	REPLY_READ_CAP
 )
 
-// Define an interface that both SubspaceId and any type of ANY_SUBSPACE can satisfy.
-type SubspaceOrAny interface{}
-
-const ANY_SUBSPACE = -1
-
 type LocalFragmentInfo[ReadCapability, SubspaceReadCapability any] struct {
-	ID             int //set this to 1 if defined, otherwise 0 (default value)
 	OnIntersection int
-	Authorisation  wgpstypes.ReadAuthorisation[ReadCapability, SubspaceReadCapability]
+	Authorisation  *wgpstypes.ReadAuthorisation[ReadCapability, SubspaceReadCapability]
 	Path           types.Path
 	Namespace      types.NamespaceId
-	Subspace       SubspaceOrAny
+	Subspace       types.SubspaceId
+	AnySubspace    bool
 }
 
-/** Given `ReadAuthorisation`s, emits the intersected ones  */
-
+/** A read authorisation of ours, which we may bind because the other peer holds the fragment we bound to Handle */
 type Intersection[ReadCapability, SubspaceReadCapability any] struct {
 	NamespaceId       types.NamespaceId
-	ReadAuthorisation wgpstypes.ReadAuthorisation[ReadCapability, SubspaceReadCapability]
-	Uint64            uint64
-}
-
-type BindFragment[PsiGroup any] struct {
-	PsiGroup    PsiGroup
-	IsSecondary bool
-}
-
-type ReplyFragment[PsiGroup any] struct {
-	FragmentGrp uint64
-	PsiGroup    PsiGroup
+	ReadAuthorisation *wgpstypes.ReadAuthorisation[ReadCapability, SubspaceReadCapability]
+	Handle            uint64
 }
 
+/** A subspace capability of ours the other peer asked for with one of its handles */
 type SubspaceCapReply[SubspaceReadCapability any] struct {
 	Handle                 uint64
 	SubspaceReadCapability SubspaceReadCapability
 }
 
-type PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability any, PsiScalar int, K constraints.Unsigned] struct {
-	IntersectionHandlesOurs   handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
-	IntersectionHandlesTheirs handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+/*
+PaiFinder runs private area intersection: both peers bind the fragments of their read authorisations multiplied by a
+secret scalar, and multiply the fragments of the other peer by their own. A fragment both peers hold ends up as the
+same group member on either side, while nothing is learned about the others.
 
-	IntersectionQueue []Intersection[ReadCapability, SubspaceReadCapability]
-
-	BindFragmentQueue []BindFragment[PsiGroup]
-
-	ReplyFragmentQueue []ReplyFragment[PsiGroup]
-
-	SubspaceCapRequestQueue []uint64
-
-	SubspaceCapReplyQueue []SubspaceCapReply[SubspaceReadCapability]
+Like the reconciliation engine, it is not safe for concurrent use, and returns the messages to send in reply to each
+step.
+*/
+type PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar any, K constraints.Unsigned] struct {
+	IntersectionHandlesOurs   *handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+	IntersectionHandlesTheirs *handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
 
 	FragmentsInfo map[uint64]LocalFragmentInfo[ReadCapability, SubspaceReadCapability]
 
@@ -83,13 +69,16 @@ type PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability any, PsiScalar i
 	Scalar PsiScalar
 
 	RequestedSubspaceCapHandles map[uint64]bool
+	// Our handles whose read authorisation was already emitted as an intersection
+	IntersectedHandles map[uint64]bool
 }
 
-func NewPaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability any, PsiScalar int, K constraints.Unsigned](opts PaiFinderOpts[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K] {
+func NewPaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar any, K constraints.Unsigned](opts PaiFinderOpts[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K] {
 	return &PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]{
 		NamespaceScheme:             opts.NamespaceScheme,
 		PaiScheme:                   opts.PaiScheme,
 		RequestedSubspaceCapHandles: make(map[uint64]bool),
+		IntersectedHandles:          make(map[uint64]bool),
 		Scalar:                      opts.PaiScheme.GetScalar(),
 		IntersectionHandlesOurs:     opts.IntersectionHandlesOurs,
 		IntersectionHandlesTheirs:   opts.IntersectionHandlesTheirs,
@@ -97,11 +86,16 @@ func NewPaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability any, PsiScala
 	}
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) SubmitAuthorisation(authorisation wgpstypes.ReadAuthorisation[ReadCapability, SubspaceReadCapability]) {
-	FragmentKit := p.PaiScheme.GetFragmentKit(authorisation.Capability)
-	Fragments := CreateFragmentSet(FragmentKit)
+/*
+Binds every fragment of a read authorisation, returning the messages binding them. The most specific fragment of a
+capability binds it on intersection, the less specific ones only in reply to the other peer binding a capability
+within them. The secondary fragments of a selective capability ask for a subspace capability first, as only peers
+which may learn about every subspace may learn ours.
+*/
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) SubmitAuthorisation(authorisation *wgpstypes.ReadAuthorisation[ReadCapability, SubspaceReadCapability]) []wgpstypes.SyncMessage {
+	var replies []wgpstypes.SyncMessage
 
-	SubmitFragment := func(fragment wgpstypes.Fragment, isSecondary bool) uint64 {
+	submitFragment := func(fragment wgpstypes.Fragment, isSecondary bool, info LocalFragmentInfo[ReadCapability, SubspaceReadCapability]) {
 		unmixed := p.PaiScheme.FragmentToGroup(fragment)
 		multiplied := p.PaiScheme.ScalarMult(unmixed, p.Scalar)
 		handle := p.IntersectionHandlesOurs.Bind(wgpstypes.Intersection[PsiGroup]{
@@ -109,482 +103,307 @@ func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar,
 			IsComplete:  false,
 			IsSecondary: isSecondary,
 		})
-		p.BindFragmentQueue = append(p.BindFragmentQueue, BindFragment[PsiGroup]{
-			PsiGroup:    multiplied,
-			IsSecondary: isSecondary,
+		info.Authorisation = authorisation
+		p.FragmentsInfo[handle] = info
+		replies = append(replies, wgpstypes.MsgPaiBindFragment[PsiGroup]{
+			Kind: wgpstypes.PaiBindFragment,
+			Data: wgpstypes.MsgPaiBindFragmentData[PsiGroup]{
+				GroupMember: multiplied,
+				IsSecondary: isSecondary,
+			},
 		})
-		return handle
 	}
-
-	if !IsSelectiveFragmentKit(Fragments) {
-		for i, fragment := range Fragments.(wgpstypes.FragmentsComplete) {
-			namespace, path := fragment.NamespaceId, fragment.Path
-			isMostSpecific := i == len(Fragments.(wgpstypes.FragmentsComplete))-1
-
-			groupHandle := SubmitFragment(fragment, false)
-
-			var fragmentInfo LocalFragmentInfo[ReadCapability, SubspaceReadCapability]
-
-			if isMostSpecific {
-				// Use Variant1 as an example for the most specific case
-				fragmentInfo = LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
-					ID:             1,
-					OnIntersection: BIND_READ_CAP,
-					Authorisation:  authorisation,
-					Path:           path,
-					Namespace:      namespace,
-					Subspace:       ANY_SUBSPACE,
-				}
-			} else {
-				fragmentInfo = LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
-					ID:             1,
-					OnIntersection: REPLY_READ_CAP, // Assuming REPLY_READ_CAP is an int constant
-					Authorisation:  authorisation,
-					Path:           path,
-					Namespace:      namespace,
-					Subspace:       ANY_SUBSPACE,
-				}
-			}
-
-			// Set the fragment info in the map
-			p.FragmentsInfo[groupHandle] = fragmentInfo
-		}
-	} else {
-		for i, fragment := range Fragments.(wgpstypes.FragmentsSelective).Primary {
-			namespace, subspace, path := fragment.NamespaceId, fragment.SubspaceId, fragment.Path
-			isMostSpecific := i == len(Fragments.(wgpstypes.FragmentsSelective).Primary)-1
-
-			groupHandle := SubmitFragment(fragment, false)
-
-			var fragmentInfo LocalFragmentInfo[ReadCapability, SubspaceReadCapability]
-
-			if isMostSpecific {
-				// Use Variant1 as an example for the most specific case
-				fragmentInfo = LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
-					ID:             1,
-					OnIntersection: BIND_READ_CAP,
-					Authorisation:  authorisation,
-					Path:           path,
-					Namespace:      namespace,
-					Subspace:       subspace,
-				}
-			} else {
-				// Use Variant2 as an example for the non-specific case
-				fragmentInfo = LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
-					ID:             1,
-					OnIntersection: REPLY_READ_CAP, // Assuming REPLY_READ_CAP is an int constant
-					Authorisation:  authorisation,
-					Path:           path,
-					Namespace:      namespace,
-					Subspace:       subspace,
-				}
-			}
-
-			// Set the fragment info in the map
-			p.FragmentsInfo[groupHandle] = fragmentInfo
+	onIntersection := func(isMostSpecific bool, onMostSpecific int) int {
+		if isMostSpecific {
+			return onMostSpecific
 		}
+		return REPLY_READ_CAP
 	}
 
-	for i, fragment := range Fragments.(wgpstypes.FragmentsSelective).Secondary {
-		namespace, path := fragment.NamespaceId, fragment.Path
-		isMostSpecific := i == len(Fragments.(wgpstypes.FragmentsSelective).Secondary)-1
-
-		groupHandle := SubmitFragment(fragment, false)
-
-		var fragmentInfo LocalFragmentInfo[ReadCapability, SubspaceReadCapability]
-
-		if isMostSpecific {
-			// Use Variant1 as an example for the most specific case
-			fragmentInfo = LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
-				ID:             1,
-				OnIntersection: BIND_READ_CAP,
-				Authorisation:  authorisation,
-				Path:           path,
-				Namespace:      namespace,
-				Subspace:       ANY_SUBSPACE,
-			}
-		} else {
-			// Use Variant2 as an example for the non-specific case
-			fragmentInfo = LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
-				ID:             1,
-				OnIntersection: REPLY_READ_CAP, // Assuming REPLY_READ_CAP is an int constant
-				Authorisation:  authorisation,
-				Path:           path,
-				Namespace:      namespace,
-				Subspace:       ANY_SUBSPACE,
-			}
+	switch fragments := CreateFragmentSet(p.PaiScheme.GetFragmentKit(authorisation.Capability)).(type) {
+	case wgpstypes.FragmentsComplete:
+		for i, fragment := range fragments {
+			submitFragment(fragment, false, LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
+				OnIntersection: onIntersection(i == len(fragments)-1, BIND_READ_CAP),
+				Path:           fragment.Path,
+				Namespace:      fragment.NamespaceId,
+				AnySubspace:    true,
+			})
+		}
+	case wgpstypes.FragmentsSelective:
+		for i, fragment := range fragments.Primary {
+			submitFragment(fragment, false, LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
+				OnIntersection: onIntersection(i == len(fragments.Primary)-1, BIND_READ_CAP),
+				Path:           fragment.Path,
+				Namespace:      fragment.NamespaceId,
+				Subspace:       fragment.SubspaceId,
+			})
+		}
+		for i, fragment := range fragments.Secondary {
+			submitFragment(fragment, true, LocalFragmentInfo[ReadCapability, SubspaceReadCapability]{
+				OnIntersection: onIntersection(i == len(fragments.Secondary)-1, REQUEST_SUBSPACE_CAP),
+				Path:           fragment.Path,
+				Namespace:      fragment.NamespaceId,
+				AnySubspace:    true,
+			})
 		}
-
-		// Set the fragment info in the map
-		p.FragmentsInfo[groupHandle] = fragmentInfo
 	}
+	return replies
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedBind(groupMember PsiGroup, isSecondary bool) {
-	multiplied := p.PaiScheme.ScalarMult(groupMember, p.PaiScheme.GetScalar())
-	handle := p.IntersectionHandlesOurs.Bind(wgpstypes.Intersection[PsiGroup]{
+// Multiplies a fragment the other peer bound by our scalar, and returns the reply telling it the result
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedBind(groupMember PsiGroup, isSecondary bool) ([]wgpstypes.SyncMessage, []Intersection[ReadCapability, SubspaceReadCapability], error) {
+	multiplied := p.PaiScheme.ScalarMult(groupMember, p.Scalar)
+	handle := p.IntersectionHandlesTheirs.Bind(wgpstypes.Intersection[PsiGroup]{
 		Group:       multiplied,
 		IsComplete:  true,
 		IsSecondary: isSecondary,
 	})
-	p.ReplyFragmentQueue = append(p.ReplyFragmentQueue, ReplyFragment[PsiGroup]{
-		FragmentGrp: handle,
-		PsiGroup:    multiplied,
-	})
-	p.CheckForIntersections(handle, false)
+	replies, intersections, err := p.CheckForIntersections(handle, false)
+	reply := wgpstypes.MsgPaiReplyFragment[PsiGroup]{
+		Kind: wgpstypes.PaiReplyFragment,
+		Data: wgpstypes.MsgPaiReplyFragmentData[PsiGroup]{
+			Handle:      handle,
+			GroupMember: multiplied,
+		},
+	}
+	return append([]wgpstypes.SyncMessage{reply}, replies...), intersections, err
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, PsiScalar, SubspaceReadCapability, K]) ReceivedReply(handle uint64, groupMember PsiGroup) {
-	_, found := p.IntersectionHandlesOurs.Get(handle)
+// Completes a fragment we bound with the result of the other peer multiplying it by its scalar
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedReply(handle uint64, groupMember PsiGroup) ([]wgpstypes.SyncMessage, []Intersection[ReadCapability, SubspaceReadCapability], error) {
+	intersection, found := p.IntersectionHandlesOurs.Get(handle)
 	if !found {
-		//throw an error
+		return nil, nil, fmt.Errorf("the other peer replied to fragment %d, which we never bound", handle)
+	}
+	if intersection.IsComplete {
+		return nil, nil, fmt.Errorf("the other peer replied to fragment %d twice", handle)
 	}
 	p.IntersectionHandlesOurs.Update(handle, wgpstypes.Intersection[PsiGroup]{
 		Group:       groupMember,
 		IsComplete:  true,
-		IsSecondary: false,
+		IsSecondary: intersection.IsSecondary,
 	})
-	p.CheckForIntersections(handle, true)
+	return p.CheckForIntersections(handle, true)
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedSubspaceCapRequest(handle uint64, isReply bool) {
-	result, found := p.IntersectionHandlesTheirs.Get(handle)
+// The subspace capability to reply with to the other peer asking for one with its handle, if we have one
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedSubspaceCapRequest(handle uint64) (*SubspaceCapReply[SubspaceReadCapability], error) {
+	theirs, found := p.IntersectionHandlesTheirs.Get(handle)
 	if !found {
-		//throw an error
+		return nil, fmt.Errorf("the other peer asked for a subspace capability with fragment %d, which it never bound", handle)
 	}
-	for ourHandle, intersection := range p.IntersectionHandlesOurs {
-		//Need to tell AC to incorporate the iterator into handle store
-		if !intersection.IsComplete {
+	for ourHandle, ours := range p.IntersectionHandlesOurs.Map {
+		if !ours.Value.IsComplete || !p.PaiScheme.IsGroupEqual(ours.Value.Group, theirs.Group) {
 			continue
 		}
-		if !p.PaiScheme.IsGroupEqual(intersection.Group, result.Group) {
+		fragmentInfo, found := p.FragmentsInfo[ourHandle]
+		if !found || !syncutils.IsSubspaceReadAuthorisation(*fragmentInfo.Authorisation) {
 			continue
 		}
-		var FragmentInfo LocalFragmentInfo[ReadCapability, SubspaceReadCapability] = p.FragmentsInfo[ourHandle]
-
-		if FragmentInfo.ID != 1 {
-			//throw an error
-		}
-
-		if !syncutils.IsSubspaceReadAuthorisation(FragmentInfo.Authorisation) {
-			continue
-		}
-		p.SubspaceCapReplyQueue = append(p.SubspaceCapReplyQueue, SubspaceCapReply[SubspaceReadCapability]{
+		return &SubspaceCapReply[SubspaceReadCapability]{
 			Handle:                 handle,
-			SubspaceReadCapability: FragmentInfo.Authorisation.SubspaceCapability, //need to see how to do this
-		})
+			SubspaceReadCapability: fragmentInfo.Authorisation.SubspaceCapability,
+		}, nil
 	}
+	return nil, nil
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedVerifiedSubspaceCapReply(handle uint64, namespace types.NamespaceId) {
+// Emits the intersection a subspace capability we asked for with our handle, whose signature was verified, allows
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedVerifiedSubspaceCapReply(handle uint64, namespace types.NamespaceId) ([]Intersection[ReadCapability, SubspaceReadCapability], error) {
 	if !p.RequestedSubspaceCapHandles[handle] {
-		//throw a willow error
+		return nil, fmt.Errorf("the other peer sent a subspace capability for fragment %d, which we did not ask for", handle)
 	}
 	delete(p.RequestedSubspaceCapHandles, handle)
-	_, choice := p.IntersectionHandlesTheirs.Get(handle)
-	if !choice {
-		//throw an error
-	}
-	fragmentInfo := p.FragmentsInfo[handle]
-	if fragmentInfo.ID != 1 {
-		//throw an error
+	fragmentInfo, found := p.FragmentsInfo[handle]
+	if !found {
+		return nil, fmt.Errorf("fragment %d was never bound", handle)
 	}
-	if !p.NamespaceScheme.IsEqual(fragmentInfo.Namespace, namespace) { //need to see how to do this
-		//throw an error
+	if !p.NamespaceScheme.IsEqual(fragmentInfo.Namespace, namespace) {
+		return nil, fmt.Errorf("the other peer sent a subspace capability for another namespace than the one of fragment %d", handle)
 	}
-	p.IntersectionQueue = append(p.IntersectionQueue, Intersection[ReadCapability, SubspaceReadCapability]{
-		NamespaceId:       namespace,
-		ReadAuthorisation: fragmentInfo.Authorisation,
-		Uint64:            handle,
-	})
+	return p.intersect(handle, fragmentInfo), nil
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) CheckForIntersections(handle uint64, ours bool) {
-	var storeToGetHandleFrom handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
-	if ours {
-		storeToGetHandleFrom = p.IntersectionHandlesOurs
-	} else {
-		storeToGetHandleFrom = p.IntersectionHandlesTheirs
-	}
-	var storeToCheckAgainst handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+/*
+Checks whether a complete fragment, which we bound or the other peer bound, is held by both peers. Returns the
+messages asking for the subspace capabilities of the other peer, and the read authorisations of ours to bind.
+*/
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) CheckForIntersections(handle uint64, ours bool) ([]wgpstypes.SyncMessage, []Intersection[ReadCapability, SubspaceReadCapability], error) {
+	storeToGetHandleFrom, storeToCheckAgainst := p.IntersectionHandlesTheirs, p.IntersectionHandlesOurs
 	if ours {
-		storeToCheckAgainst = p.IntersectionHandlesTheirs
-	} else {
-		storeToCheckAgainst = p.IntersectionHandlesOurs
+		storeToGetHandleFrom, storeToCheckAgainst = p.IntersectionHandlesOurs, p.IntersectionHandlesTheirs
 	}
 
-	intersection, choice := storeToGetHandleFrom.Get(handle)
-	if !choice {
-		//throw an error
+	intersection, found := storeToGetHandleFrom.Get(handle)
+	if !found {
+		return nil, nil, fmt.Errorf("fragment %d was never bound", handle)
 	}
 	if !intersection.IsComplete {
-		return
+		return nil, nil, nil
 	}
-	for otherHandle, otherIntersection := range storeToCheckAgainst {
-		if !otherIntersection.IsComplete {
-			continue
-		}
-		if intersection.IsSecondary && otherIntersection.IsSecondary {
-			continue
-		}
-		if !p.PaiScheme.IsGroupEqual(intersection.Group, otherIntersection.Group) {
+
+	var replies []wgpstypes.SyncMessage
+	var intersections []Intersection[ReadCapability, SubspaceReadCapability]
+	for otherHandle, other := range storeToCheckAgainst.Map {
+		if !p.isIntersection(intersection, other.Value) {
 			continue
 		}
-		var ourHandle uint64
+		ourHandle := otherHandle
 		if ours {
 			ourHandle = handle
-		} else {
-			ourHandle = otherHandle
 		}
-
-		fragmentInfo := p.FragmentsInfo[ourHandle]
-
-		if fragmentInfo.ID != 1 {
-			//throw an error
+		fragmentInfo, found := p.FragmentsInfo[ourHandle]
+		if !found {
+			return nil, nil, fmt.Errorf("fragment %d was never bound", ourHandle)
 		}
-		if fragmentInfo.OnIntersection == BIND_READ_CAP {
-			p.IntersectionQueue = append(p.IntersectionQueue, Intersection[ReadCapability, SubspaceReadCapability]{
-				NamespaceId:       fragmentInfo.Namespace,
-				ReadAuthorisation: fragmentInfo.Authorisation,
-				Uint64:            ourHandle,
-			})
-		} else if fragmentInfo.OnIntersection == REQUEST_SUBSPACE_CAP {
+		switch fragmentInfo.OnIntersection {
+		case BIND_READ_CAP:
+			intersections = append(intersections, p.intersect(ourHandle, fragmentInfo)...)
+		case REQUEST_SUBSPACE_CAP:
+			if p.RequestedSubspaceCapHandles[ourHandle] {
+				continue
+			}
 			p.RequestedSubspaceCapHandles[ourHandle] = true
-			p.SubspaceCapRequestQueue = append(p.SubspaceCapRequestQueue, handle)
+			replies = append(replies, wgpstypes.MsgPaiRequestSubspaceCapability{
+				Kind: wgpstypes.PaiRequestSubspaceCapability,
+				Data: wgpstypes.MsgPaiRequestSubspaceCapabilityData{Handle: ourHandle},
+			})
 		}
 	}
+	return replies, intersections, nil
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, PsiScalar, SubspaceReadCapability, K]) GetHandleOuterArea(handle uint64) types.Area {
-	fragmentInfo := p.FragmentsInfo[handle]
-	if fragmentInfo.ID != 1 {
-		//throw an error
-	}
-	return types.Area{
-		Subspace_id: fragmentInfo.Subspace.(types.SubspaceId),
-		Path:        fragmentInfo.Path,
-		Times:       types.Range[uint64]{Start: 0, OpenEnd: true},
-	}
-}
-
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedReadCapForIntersection(theirIntersectionHandle uint64) {
-	theirIntersection, choice := p.IntersectionHandlesTheirs.Get(theirIntersectionHandle)
-	if !choice {
-		//throw an error
+// Emits the read authorisations of ours to bind in reply to the other peer binding a read capability for its handle
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) ReceivedReadCapForIntersection(theirIntersectionHandle uint64) ([]Intersection[ReadCapability, SubspaceReadCapability], error) {
+	theirIntersection, found := p.IntersectionHandlesTheirs.Get(theirIntersectionHandle)
+	if !found {
+		return nil, fmt.Errorf("the other peer bound a read capability for fragment %d, which it never bound", theirIntersectionHandle)
 	}
-	for ourHandle, ourIntersection := range p.IntersectionHandlesOurs {
-		if !ourIntersection.IsComplete {
-			continue
-		}
-		if ourIntersection.IsSecondary && theirIntersection.IsSecondary {
-			continue
-		}
-		if !p.PaiScheme.IsGroupEqual(ourIntersection.Group, theirIntersection.Group) {
+	var intersections []Intersection[ReadCapability, SubspaceReadCapability]
+	for ourHandle, ours := range p.IntersectionHandlesOurs.Map {
+		if !p.isIntersection(ours.Value, theirIntersection) {
 			continue
 		}
-		fragmentInfo := p.FragmentsInfo[ourHandle]
-		if fragmentInfo.ID != 1 {
-			//throw an error
-		}
-		if fragmentInfo.OnIntersection == REPLY_READ_CAP {
-			p.IntersectionQueue = append(p.IntersectionQueue, Intersection[ReadCapability, SubspaceReadCapability]{
-				NamespaceId:       fragmentInfo.Namespace,
-				ReadAuthorisation: fragmentInfo.Authorisation,
-				Uint64:            ourHandle,
-			})
+		fragmentInfo, found := p.FragmentsInfo[ourHandle]
+		if found && fragmentInfo.OnIntersection == REPLY_READ_CAP {
+			intersections = append(intersections, p.intersect(ourHandle, fragmentInfo)...)
 		}
 	}
+	return intersections, nil
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) GetIntersectionPrivy(handle uint64, ours bool) wgpstypes.ReadCapPrivy {
-	var storeToGetHandleFrom handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
-	if ours {
-		storeToGetHandleFrom = p.IntersectionHandlesOurs
-	} else {
-		storeToGetHandleFrom = p.IntersectionHandlesTheirs
-	}
-	var storeToCheckAgainst handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+/*
+The namespace and outer area of the intersection a handle belongs to, which read capabilities bound for it are
+encoded relative to. Both peers arrive at the same one, as the fragment is the same on either side.
+*/
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) GetIntersectionPrivy(handle uint64, ours bool) (wgpstypes.ReadCapPrivy, error) {
+	storeToGetHandleFrom, storeToCheckAgainst := p.IntersectionHandlesTheirs, p.IntersectionHandlesOurs
 	if ours {
-		storeToCheckAgainst = p.IntersectionHandlesTheirs
-	} else {
-		storeToCheckAgainst = p.IntersectionHandlesOurs
+		storeToGetHandleFrom, storeToCheckAgainst = p.IntersectionHandlesOurs, p.IntersectionHandlesTheirs
 	}
-	intersection, choice := storeToGetHandleFrom.Get(handle)
-	if !choice {
-		//throw an error
+	intersection, found := storeToGetHandleFrom.Get(handle)
+	if !found {
+		return wgpstypes.ReadCapPrivy{}, fmt.Errorf("fragment %d was never bound", handle)
 	}
 	// Here we are looping through the whole contents of the handle store because...
 	// otherwise we need to build a special handle store just for intersections.
 	// Which we might do one day, but I'm not convinced it's worth it yet.
-	for otherHandle, otherIntersection := range storeToCheckAgainst {
-		if !otherIntersection.IsComplete {
-			continue
-		}
-		if intersection.IsSecondary && otherIntersection.IsSecondary {
-			continue
-		}
-		if !p.PaiScheme.IsGroupEqual(intersection.Group, otherIntersection.Group) {
+	for otherHandle, other := range storeToCheckAgainst.Map {
+		if !p.isIntersection(intersection, other.Value) {
 			continue
 		}
-		// If there is an intersection, check what we have to do!
-		var ourHandle uint64
+		ourHandle := otherHandle
 		if ours {
 			ourHandle = handle
-		} else {
-			ourHandle = otherHandle
 		}
-		fragmentInfo := p.FragmentsInfo[ourHandle]
-		if fragmentInfo.ID != 1 {
-			//throw an error
+		fragmentInfo, found := p.FragmentsInfo[ourHandle]
+		if !found {
+			continue
 		}
-		outer := p.GetHandleOuterArea(ourHandle)
 		return wgpstypes.ReadCapPrivy{
 			Namespace: fragmentInfo.Namespace,
-			Outer:     outer,
-		}
+			Outer:     p.GetHandleOuterArea(fragmentInfo),
+		}, nil
 	}
-	//throw an error
-	return wgpstypes.ReadCapPrivy{} // This is a placeholder
+	return wgpstypes.ReadCapPrivy{}, fmt.Errorf("fragment %d is not held by both peers", handle)
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) FragmentBinds() <-chan BindFragment[PsiGroup] {
-	// Assuming p.BindFragmentQueue is a channel or can be iterated over in some way
-	// This channel is where we'll send our FragmentBind structs
-	ch := make(chan BindFragment[PsiGroup])
-
-	go func() {
-		defer close(ch) // Ensure the channel is closed when we're done sending data
-
-		// Simulate iterating over bindFragmentQueue similar to the JavaScript for-await loop
-		// This is a placeholder loop. You'll need to adapt this to your actual data source.
-		for bind := range p.BindFragmentQueue { // Assuming BindFragmentQueue is a channel
-			Group := p.BindFragmentQueue[bind].PsiGroup
-			IsSecondary := p.BindFragmentQueue[bind].IsSecondary
-			// Send a FragmentBind struct to the channel
-			ch <- BindFragment[PsiGroup]{
-				PsiGroup:    Group,
-				IsSecondary: IsSecondary,
-			}
-		}
-	}()
-
-	return ch // Return the channel to the caller
+// The area of a fragment, which the capabilities it was derived from are within
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) GetHandleOuterArea(fragmentInfo LocalFragmentInfo[ReadCapability, SubspaceReadCapability]) types.Area {
+	return types.Area{
+		Subspace_id:  fragmentInfo.Subspace,
+		Any_subspace: fragmentInfo.AnySubspace,
+		Path:         fragmentInfo.Path,
+		Times:        types.Range[uint64]{Start: 0, OpenEnd: true},
+	}
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) FragmentReplies() <-chan ReplyFragment[PsiGroup] {
-	ch := make(chan ReplyFragment[PsiGroup])
-
-	go func() {
-		defer close(ch)
-
-		for bind := range p.ReplyFragmentQueue {
-			Group := p.ReplyFragmentQueue[bind].PsiGroup
-			FragmentGrp := p.ReplyFragmentQueue[bind].FragmentGrp
-
-			ch <- ReplyFragment[PsiGroup]{
-				PsiGroup:    Group,
-				FragmentGrp: FragmentGrp,
-			}
+// Whether some fragment we bound still waits for the other peer to reply to it, until then intersections may turn up
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) AwaitingReplies() bool {
+	for _, ours := range p.IntersectionHandlesOurs.Map {
+		if !ours.Value.IsComplete {
+			return true
 		}
-	}()
-
-	return ch
-}
-
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) Intersections() <-chan Intersection[ReadCapability, SubspaceReadCapability] {
-	ch := make(chan Intersection[ReadCapability, SubspaceReadCapability])
-
-	go func() {
-		defer close(ch)
-
-		for bind := range p.IntersectionQueue {
-			NamespaceId := p.IntersectionQueue[bind].NamespaceId
-			ReadAuthorisation := p.IntersectionQueue[bind].ReadAuthorisation
-			Uint64 := p.IntersectionQueue[bind].Uint64
-
-			ch <- Intersection[ReadCapability, SubspaceReadCapability]{
-				NamespaceId:       NamespaceId,
-				ReadAuthorisation: ReadAuthorisation,
-				Uint64:            Uint64,
-			}
-		}
-	}()
-
-	return ch
+	}
+	return false
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) SubspaceCapRequests() <-chan uint64 {
-	ch := make(chan uint64)
-
-	go func() {
-		defer close(ch)
-
-		for bind := range p.SubspaceCapRequestQueue {
-			handle := p.SubspaceCapRequestQueue[bind]
-
-			ch <- handle
-		}
-	}()
-
-	return ch
+// Whether two complete fragments are the same one, and not both secondary
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) isIntersection(a, b wgpstypes.Intersection[PsiGroup]) bool {
+	if !a.IsComplete || !b.IsComplete {
+		return false
+	}
+	if a.IsSecondary && b.IsSecondary {
+		return false
+	}
+	return p.PaiScheme.IsGroupEqual(a.Group, b.Group)
 }
 
-func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) SubspaceCaReplies() <-chan SubspaceCapReply[SubspaceReadCapability] {
-	ch := make(chan SubspaceCapReply[SubspaceReadCapability])
-
-	go func() {
-		defer close(ch)
-
-		for bind := range p.SubspaceCapReplyQueue {
-			handle := p.SubspaceCapReplyQueue[bind].Handle
-			subspaceReadCapability := p.SubspaceCapReplyQueue[bind].SubspaceReadCapability
-
-			ch <- SubspaceCapReply[SubspaceReadCapability]{
-				Handle:                 handle,
-				SubspaceReadCapability: subspaceReadCapability,
-			}
-		}
-	}()
-
-	return ch
+// The intersection for a handle of ours, unless one was emitted already
+func (p *PaiFinder[ReadCapability, PsiGroup, SubspaceReadCapability, PsiScalar, K]) intersect(handle uint64, fragmentInfo LocalFragmentInfo[ReadCapability, SubspaceReadCapability]) []Intersection[ReadCapability, SubspaceReadCapability] {
+	if p.IntersectedHandles[handle] {
+		return nil
+	}
+	p.IntersectedHandles[handle] = true
+	return []Intersection[ReadCapability, SubspaceReadCapability]{{
+		NamespaceId:       fragmentInfo.Namespace,
+		ReadAuthorisation: fragmentInfo.Authorisation,
+		Handle:            handle,
+	}}
 }
 
 func CreateFragmentSet(kit wgpstypes.FragmentKit) wgpstypes.FragmentSet {
-	primaryFragment := []wgpstypes.FragmentTriple{}
-	secondaryFragment := []wgpstypes.FragmentPair{}
 	switch v := kit.(type) {
 	case wgpstypes.FragmentKitSelective:
-		prefixes := utils.PrefixesOf(kit.(wgpstypes.FragmentKitSelective).GrantedPath)
-		for _, prefix := range prefixes {
-			primaryFragmentInfo := wgpstypes.FragmentTriple{
+		primaryFragment := []wgpstypes.FragmentTriple{}
+		secondaryFragment := []wgpstypes.FragmentPair{}
+		for _, prefix := range utils.PrefixesOf(v.GrantedPath) {
+			primaryFragment = append(primaryFragment, wgpstypes.FragmentTriple{
 				NamespaceId: v.GrantedNamespace,
 				SubspaceId:  v.GrantedSubspace,
 				Path:        prefix,
-			}
-			secondaryFragmentInfo := wgpstypes.FragmentPair{
+			})
+			secondaryFragment = append(secondaryFragment, wgpstypes.FragmentPair{
 				NamespaceId: v.GrantedNamespace,
 				Path:        prefix,
-			}
-			primaryFragment = append(primaryFragment, primaryFragmentInfo)
-			secondaryFragment = append(secondaryFragment, secondaryFragmentInfo)
+			})
 		}
 		return wgpstypes.FragmentsSelective{
 			Primary:   primaryFragment,
 			Secondary: secondaryFragment,
 		}
-	}
-	prefixes := utils.PrefixesOf(kit.(wgpstypes.FragmentKitComplete).GrantedPath)
-	pairs := []wgpstypes.FragmentPair{}
-	for _, prefix := range prefixes {
-		pairsInfo := wgpstypes.FragmentPair{
-			NamespaceId: kit.(wgpstypes.FragmentKitComplete).GrantedNamespace,
-			Path:        prefix,
+	case wgpstypes.FragmentKitComplete:
+		pairs := wgpstypes.FragmentsComplete{}
+		for _, prefix := range utils.PrefixesOf(v.GrantedPath) {
+			pairs = append(pairs, wgpstypes.FragmentPair{
+				NamespaceId: v.GrantedNamespace,
+				Path:        prefix,
+			})
 		}
-		pairs = append(pairs, pairsInfo)
-	}
-	return wgpstypes.FragmentsSelective{
-		Secondary: pairs,
+		return pairs
 	}
+	return wgpstypes.FragmentsComplete{}
 }
 
 func IsSelectiveFragmentKit(set wgpstypes.FragmentSet) bool {
@@ -593,6 +412,6 @@ func IsSelectiveFragmentKit(set wgpstypes.FragmentSet) bool {
 }
 
 func IsFragmentTriple(fragment wgpstypes.Fragment) bool {
-	_, ok := fragment.(*wgpstypes.FragmentTriple)
+	_, ok := fragment.(wgpstypes.FragmentTriple)
 	return ok
 }
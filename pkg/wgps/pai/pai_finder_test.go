@@ -0,0 +1,178 @@
+package pai
+
+import (
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+// The read capabilities of the tests are their fragment kits, and subspace capabilities the namespace they are for
+type testFinder = PaiFinder[wgpstypes.FragmentKit, X25519Group, string, X25519Scalar, uint8]
+
+func newTestFinder() *testFinder {
+	return NewPaiFinder(PaiFinderOpts[wgpstypes.FragmentKit, X25519Group, string, X25519Scalar, uint8]{
+		NamespaceScheme: store.TestNameSpaceScheme,
+		PaiScheme: NewX25519PaiScheme(X25519PaiSchemeOpts[wgpstypes.FragmentKit, uint8]{
+			NamespaceScheme: store.TestNameSpaceScheme,
+			SubspaceScheme:  store.TestSubspaceScheme,
+			PathParams:      store.TestPathParams,
+			GetFragmentKit: func(cap wgpstypes.FragmentKit) wgpstypes.FragmentKit {
+				return cap
+			},
+		}),
+		IntersectionHandlesOurs:   &handlestore.HandleStore[wgpstypes.Intersection[X25519Group]]{Map: handlestore.NewMap[wgpstypes.Intersection[X25519Group]]()},
+		IntersectionHandlesTheirs: &handlestore.HandleStore[wgpstypes.Intersection[X25519Group]]{Map: handlestore.NewMap[wgpstypes.Intersection[X25519Group]]()},
+	})
+}
+
+type sentMessage struct {
+	from int
+	msg  wgpstypes.SyncMessage
+}
+
+// Runs private area intersection between two finders, returning the authorisations each of them would bind
+func intersect(t *testing.T, authorisations [2][]wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string]) [2][]Intersection[wgpstypes.FragmentKit, string] {
+	finders := [2]*testFinder{newTestFinder(), newTestFinder()}
+	var queue []sentMessage
+	var intersections [2][]Intersection[wgpstypes.FragmentKit, string]
+
+	for peer, finder := range finders {
+		for i := range authorisations[peer] {
+			for _, msg := range finder.SubmitAuthorisation(&authorisations[peer][i]) {
+				queue = append(queue, sentMessage{peer, msg})
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		sent := queue[0]
+		queue = queue[1:]
+		to := 1 - sent.from
+		finder := finders[to]
+
+		var replies []wgpstypes.SyncMessage
+		var found []Intersection[wgpstypes.FragmentKit, string]
+		var err error
+		switch msg := sent.msg.(type) {
+		case wgpstypes.MsgPaiBindFragment[X25519Group]:
+			replies, found, err = finder.ReceivedBind(msg.Data.GroupMember, msg.Data.IsSecondary)
+		case wgpstypes.MsgPaiReplyFragment[X25519Group]:
+			replies, found, err = finder.ReceivedReply(msg.Data.Handle, msg.Data.GroupMember)
+		case wgpstypes.MsgPaiRequestSubspaceCapability:
+			var reply *SubspaceCapReply[string]
+			reply, err = finder.ReceivedSubspaceCapRequest(msg.Data.Handle)
+			if reply != nil {
+				replies = append(replies, wgpstypes.MsgPaiReplySubspaceCapability[string, string]{
+					Kind: wgpstypes.PaiReplySubspaceCapability,
+					Data: wgpstypes.MsgPaiReplySubspaceCapabilityData[string, string]{
+						Handle:     reply.Handle,
+						Capability: reply.SubspaceReadCapability,
+					},
+				})
+			}
+		case wgpstypes.MsgPaiReplySubspaceCapability[string, string]:
+			found, err = finder.ReceivedVerifiedSubspaceCapReply(msg.Data.Handle, types.NamespaceId(msg.Data.Capability))
+		case wgpstypes.MsgSetupBindReadCapability[wgpstypes.FragmentKit, string]:
+			found, err = finder.ReceivedReadCapForIntersection(msg.Data.Handle)
+		}
+		if err != nil {
+			t.Fatalf("peer %d could not handle a message of kind %v: %v", to, sent.msg.GetKind(), err)
+		}
+
+		for _, intersection := range found {
+			intersections[to] = append(intersections[to], intersection)
+			replies = append(replies, wgpstypes.MsgSetupBindReadCapability[wgpstypes.FragmentKit, string]{
+				Kind: wgpstypes.SetupBindReadCapability,
+				Data: wgpstypes.MsgSetupBindReadCapabilityData[wgpstypes.FragmentKit, string]{
+					Capability: intersection.ReadAuthorisation.Capability,
+					Handle:     intersection.Handle,
+				},
+			})
+		}
+		for _, reply := range replies {
+			queue = append(queue, sentMessage{to, reply})
+		}
+	}
+	return intersections
+}
+
+func complete(namespace string, path ...string) wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string] {
+	kit := wgpstypes.FragmentKitComplete{GrantedNamespace: types.NamespaceId(namespace)}
+	for _, component := range path {
+		kit.GrantedPath = append(kit.GrantedPath, []byte(component))
+	}
+	return wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string]{Capability: kit}
+}
+
+func TestX25519ScalarMultiplicationCommutes(t *testing.T) {
+	scheme := newTestFinder().PaiScheme
+	fragment := scheme.FragmentToGroup(wgpstypes.FragmentPair{NamespaceId: types.NamespaceId("family")})
+	a, b := scheme.GetScalar(), scheme.GetScalar()
+
+	ab := scheme.ScalarMult(scheme.ScalarMult(fragment, a), b)
+	ba := scheme.ScalarMult(scheme.ScalarMult(fragment, b), a)
+	if !scheme.IsGroupEqual(ab, ba) {
+		t.Fatalf("multiplying by two scalars depends on their order")
+	}
+	if scheme.IsGroupEqual(scheme.ScalarMult(fragment, a), ab) {
+		t.Errorf("multiplying by a scalar did not change the group member")
+	}
+
+	other := scheme.FragmentToGroup(wgpstypes.FragmentTriple{NamespaceId: types.NamespaceId("family")})
+	if scheme.IsGroupEqual(scheme.ScalarMult(scheme.ScalarMult(other, a), b), ab) {
+		t.Errorf("a pair and a triple of the same namespace and path map to the same group member")
+	}
+}
+
+func TestPaiFinderIntersectsSharedNamespaces(t *testing.T) {
+	intersections := intersect(t, [2][]wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string]{
+		{complete("family", "blog"), complete("work")},
+		{complete("family", "blog", "recipes"), complete("hobbies")},
+	})
+
+	// The capability of the first peer contains the one of the second, so the first binds and the second replies
+	for peer, want := range []string{"blog", "recipes"} {
+		if len(intersections[peer]) != 1 {
+			t.Fatalf("peer %d found %d intersections, expected one", peer, len(intersections[peer]))
+		}
+		found := intersections[peer][0]
+		path := found.ReadAuthorisation.Capability.(wgpstypes.FragmentKitComplete).GrantedPath
+		if string(found.NamespaceId) != "family" || string(path[len(path)-1]) != want {
+			t.Errorf("peer %d bound the read capability for %s %s", peer, found.NamespaceId, path)
+		}
+	}
+}
+
+func TestPaiFinderAsksForSubspaceCapabilities(t *testing.T) {
+	selective := wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string]{
+		Capability: wgpstypes.FragmentKitSelective{
+			GrantedNamespace: types.NamespaceId("family"),
+			GrantedSubspace:  types.SubspaceId("alfie"),
+		},
+	}
+	withSubspaceCap := complete("family")
+	withSubspaceCap.SubspaceCapability = "family"
+	withSubspaceCap.HasSubspaceCapability = true
+
+	intersections := intersect(t, [2][]wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string]{
+		{selective},
+		{complete("family")},
+	})
+	if len(intersections[0]) != 0 {
+		t.Errorf("revealed a selective capability to a peer without a subspace capability")
+	}
+	if len(intersections[1]) != 1 {
+		t.Errorf("did not bind a complete capability for a namespace both peers hold")
+	}
+
+	intersections = intersect(t, [2][]wgpstypes.ReadAuthorisation[wgpstypes.FragmentKit, string]{
+		{selective},
+		{withSubspaceCap},
+	})
+	if len(intersections[0]) != 1 || len(intersections[1]) != 1 {
+		t.Errorf("both peers should bind their capabilities after a subspace capability, found %d and %d", len(intersections[0]), len(intersections[1]))
+	}
+}
@@ -0,0 +1,134 @@
+package pai
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+	"golang.org/x/exp/constraints"
+)
+
+// A group member of the X25519 PSI group, the u-coordinate of a point on Curve25519
+type X25519Group [32]byte
+
+// A scalar of the X25519 PSI group, which X25519 clamps before multiplying
+type X25519Scalar [32]byte
+
+const X25519_GROUP_MEMBER_LENGTH = 32
+
+// Fragments are hashed with distinct prefixes, so that a pair and a triple never map to the same group member
+const (
+	fragmentPairTag   = 0
+	fragmentTripleTag = 1
+)
+
+type X25519PaiSchemeOpts[ReadCapability any, K constraints.Unsigned] struct {
+	NamespaceScheme datamodeltypes.NamespaceScheme
+	SubspaceScheme  datamodeltypes.SubspaceScheme
+	PathParams      types.PathParams[K]
+	GetFragmentKit  func(cap ReadCapability) wgpstypes.FragmentKit
+}
+
+/*
+NewX25519PaiScheme returns a PaiScheme whose group is Curve25519, with scalar multiplication done by X25519. Fragments
+are hashed onto the curve, and since multiplying by one scalar and then another gives the same point as multiplying
+in the other order, two peers arrive at the same group member for a fragment exactly when both of them hold it.
+
+Neither peer learns anything about the fragments of the other peer it does not hold itself, as long as the
+Diffie-Hellman problem is hard on Curve25519.
+*/
+func NewX25519PaiScheme[ReadCapability any, K constraints.Unsigned](opts X25519PaiSchemeOpts[ReadCapability, K]) wgpstypes.PaiScheme[ReadCapability, X25519Group, X25519Scalar, K] {
+	return wgpstypes.PaiScheme[ReadCapability, X25519Group, X25519Scalar, K]{
+		FragmentToGroup: func(fragment wgpstypes.Fragment) X25519Group {
+			var encoded []byte
+			switch fragment := fragment.(type) {
+			case wgpstypes.FragmentPair:
+				encoded = append(encoded, fragmentPairTag)
+				encoded = append(encoded, opts.NamespaceScheme.EncodingScheme.Encode(fragment.NamespaceId)...)
+				encoded = append(encoded, utils.EncodePath(opts.PathParams, fragment.Path)...)
+			case wgpstypes.FragmentTriple:
+				encoded = append(encoded, fragmentTripleTag)
+				encoded = append(encoded, opts.NamespaceScheme.EncodingScheme.Encode(fragment.NamespaceId)...)
+				encoded = append(encoded, opts.SubspaceScheme.EncodingScheme.Encode(fragment.SubspaceId)...)
+				encoded = append(encoded, utils.EncodePath(opts.PathParams, fragment.Path)...)
+			}
+			hash := sha512.Sum512(encoded)
+			var group X25519Group
+			copy(group[:], hash[:X25519_GROUP_MEMBER_LENGTH])
+			// X25519 ignores the most significant bit of a u-coordinate
+			group[X25519_GROUP_MEMBER_LENGTH-1] &= 0x7f
+			return group
+		},
+		GetScalar: func() X25519Scalar {
+			var scalar X25519Scalar
+			_, err := rand.Read(scalar[:])
+			if err != nil {
+				panic("could not generate a scalar for private area intersection: " + err.Error())
+			}
+			return scalar
+		},
+		ScalarMult: X25519ScalarMult,
+		IsGroupEqual: func(a X25519Group, b X25519Group) bool {
+			// The zero group member is what a low order point multiplies to, which must never count as an intersection
+			return a == b && a != X25519Group{}
+		},
+		GetFragmentKit:      opts.GetFragmentKit,
+		GroupMemberEncoding: X25519GroupEncoding,
+	}
+}
+
+// Multiplies a group member by a scalar, giving the zero group member for points of low order
+func X25519ScalarMult(group X25519Group, scalar X25519Scalar) X25519Group {
+	curve := ecdh.X25519()
+	private, err := curve.NewPrivateKey(scalar[:])
+	if err != nil {
+		return X25519Group{}
+	}
+	public, err := curve.NewPublicKey(group[:])
+	if err != nil {
+		return X25519Group{}
+	}
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return X25519Group{}
+	}
+	var result X25519Group
+	copy(result[:], shared)
+	return result
+}
+
+// Group members are encoded as their 32 bytes
+var X25519GroupEncoding = utils.EncodingScheme[X25519Group]{
+	Encode: func(group X25519Group) []byte {
+		return append([]byte{}, group[:]...)
+	},
+	Decode: func(encoded []byte) (X25519Group, error) {
+		var group X25519Group
+		if len(encoded) < X25519_GROUP_MEMBER_LENGTH {
+			return group, fmt.Errorf("not enough bytes to decode a group member")
+		}
+		copy(group[:], encoded)
+		return group, nil
+	},
+	EncodedLength: func(group X25519Group) uint64 {
+		return X25519_GROUP_MEMBER_LENGTH
+	},
+	DecodeStream: func(bytes *utils.GrowingBytes) chan X25519Group {
+		ch := make(chan X25519Group, 1)
+		defer close(ch)
+		accumulated := bytes.NextAbsolute(X25519_GROUP_MEMBER_LENGTH)
+		if len(accumulated) < X25519_GROUP_MEMBER_LENGTH {
+			return ch
+		}
+		var group X25519Group
+		copy(group[:], accumulated)
+		bytes.Prune(X25519_GROUP_MEMBER_LENGTH)
+		ch <- group
+		return ch
+	},
+}
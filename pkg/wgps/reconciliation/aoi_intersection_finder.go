@@ -1,8 +1,6 @@
 package reconciliation
 
 import (
-	"fmt"
-
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
 	"github.com/PES-Innovation-Lab/willow-go/types"
@@ -12,25 +10,30 @@ import (
 type AoiIntersectionFinderOpts struct {
 	NamespaceScheme datamodeltypes.NamespaceScheme
 	SubspaceScheme  datamodeltypes.SubspaceScheme
-	HandlesOurs     handlestore.HandleStore[types.AreaOfInterest]
-	HandlesTheirs   handlestore.HandleStore[types.AreaOfInterest]
+	HandlesOurs     *handlestore.HandleStore[types.AreaOfInterest]
+	HandlesTheirs   *handlestore.HandleStore[types.AreaOfInterest]
+}
+
+// A pair of intersecting areas of interest of the same namespace, one bound by us and one by the other peer
+type AoiIntersection struct {
+	Namespace types.NamespaceId
+	Ours      uint64
+	Theirs    uint64
 }
 
-// Define the AoiIntersectionFinder struct with generic types
+/*
+AoiIntersectionFinder keeps track of the namespaces of the areas of interest bound by either peer, and finds the
+areas of interest of the other peer which intersect one bound later. Every intersecting pair is found exactly
+once, when the second of its areas of interest is added.
+*/
 type AoiIntersectionFinder struct {
 	NamespaceScheme datamodeltypes.NamespaceScheme
 	SubspaceScheme  datamodeltypes.SubspaceScheme
-	HandlesOurs     handlestore.HandleStore[types.AreaOfInterest]
-	HandlesTheirs   handlestore.HandleStore[types.AreaOfInterest]
+	HandlesOurs     *handlestore.HandleStore[types.AreaOfInterest]
+	HandlesTheirs   *handlestore.HandleStore[types.AreaOfInterest]
 
 	HandlesOursNamespaceMap   map[uint64]types.NamespaceId
 	HandlesTheirsNamespaceMap map[uint64]types.NamespaceId
-
-	IntersectingAoiQueue []struct {
-		Namespace types.NamespaceId
-		Ours      uint64
-		Theirs    uint64
-	}
 }
 
 // NewAoiIntersectionFinder is the constructor function for AoiIntersectionFinder
@@ -42,59 +45,38 @@ func NewAoiIntersectionFinder(opts AoiIntersectionFinderOpts) *AoiIntersectionFi
 		HandlesTheirs:             opts.HandlesTheirs,
 		HandlesOursNamespaceMap:   make(map[uint64]types.NamespaceId),
 		HandlesTheirsNamespaceMap: make(map[uint64]types.NamespaceId),
-		IntersectingAoiQueue: make([]struct {
-			Namespace types.NamespaceId
-			Ours      uint64
-			Theirs    uint64
-		}, 0),
 	}
 }
 
-func (a *AoiIntersectionFinder) AddAoiHandleToNamespace(handle uint64, namespace types.NamespaceId, ours bool) {
-	var HandleNamespaceMap map[uint64]types.NamespaceId
+// Adds an area of interest bound to a handle, returning the ones bound by the other peer it intersects with
+func (a *AoiIntersectionFinder) AddAoiHandleToNamespace(handle uint64, namespace types.NamespaceId, ours bool) []AoiIntersection {
+	HandleNamespaceMap, OtherHandleNamespaceMap := a.HandlesTheirsNamespaceMap, a.HandlesOursNamespaceMap
+	HandleStore, OtherHandleStore := a.HandlesTheirs, a.HandlesOurs
 	if ours {
-		HandleNamespaceMap = a.HandlesOursNamespaceMap
-	} else {
-		HandleNamespaceMap = a.HandlesTheirsNamespaceMap
+		HandleNamespaceMap, OtherHandleNamespaceMap = a.HandlesOursNamespaceMap, a.HandlesTheirsNamespaceMap
+		HandleStore, OtherHandleStore = a.HandlesOurs, a.HandlesTheirs
 	}
 
-	var OtherHandleNamespaceMap map[uint64]types.NamespaceId
-	if ours {
-		OtherHandleNamespaceMap = a.HandlesTheirsNamespaceMap
-	} else {
-		OtherHandleNamespaceMap = a.HandlesOursNamespaceMap
-	}
-
-	var HandleStore handlestore.HandleStore[types.AreaOfInterest]
-	if ours {
-		HandleStore = a.HandlesOurs
-	} else {
-		HandleStore = a.HandlesTheirs
-	}
+	HandleNamespaceMap[handle] = namespace
 
-	var OtherHandleStore handlestore.HandleStore[types.AreaOfInterest]
-	if ours {
-		OtherHandleStore = a.HandlesTheirs
-	} else {
-		OtherHandleStore = a.HandlesOurs
+	Aoi, found := HandleStore.Get(handle)
+	if !found {
+		// Could not dereference an AOI handle
+		return nil
 	}
 
-	HandleNamespaceMap[handle] = namespace
-
+	var intersections []AoiIntersection
 	// Now check for all other AOIs with the same namespace.
 	for OtherHandle, OtherNamespace := range OtherHandleNamespaceMap {
 		if !a.NamespaceScheme.IsEqual(namespace, OtherNamespace) {
 			continue
 		}
 
-		Aoi, found := HandleStore.Get(handle)
-		if !found {
-			fmt.Errorf("Could not dereference an AOI handle")
-		}
-
 		AoiOther, foundother := OtherHandleStore.Get(OtherHandle)
 		if !foundother {
-			fmt.Errorf("Could not dereference an AOI handle")
+			// The handle was freed since
+			delete(OtherHandleNamespaceMap, OtherHandle)
+			continue
 		}
 
 		Intersection := utils.IntersectArea(a.SubspaceScheme.Order, Aoi.Area, AoiOther.Area)
@@ -102,30 +84,18 @@ func (a *AoiIntersectionFinder) AddAoiHandleToNamespace(handle uint64, namespace
 		if Intersection == nil {
 			continue
 		}
-		var Ours uint64
-		if ours {
-			Ours = handle
-		} else {
-			Ours = OtherHandle
-		}
 
-		var Theirs uint64
+		Ours, Theirs := OtherHandle, handle
 		if ours {
-			Theirs = OtherHandle
-		} else {
-			Theirs = handle
+			Ours, Theirs = handle, OtherHandle
 		}
-		a.IntersectingAoiQueue = append(a.IntersectingAoiQueue, struct {
-			Namespace types.NamespaceId
-			Ours      uint64
-			Theirs    uint64
-		}{
+		intersections = append(intersections, AoiIntersection{
 			Namespace: namespace,
 			Ours:      Ours,
 			Theirs:    Theirs,
 		})
-
 	}
+	return intersections
 }
 
 func (a *AoiIntersectionFinder) HandleToNamespaceId(handle uint64, ours bool) types.NamespaceId {
@@ -138,32 +108,3 @@ func (a *AoiIntersectionFinder) HandleToNamespaceId(handle uint64, ours bool) ty
 
 	return HandleNamespaceMap[handle]
 }
-
-// Intersections returns a channel through which intersections can be received.
-func (a *AoiIntersectionFinder) Intersections() <-chan struct {
-	Namespace types.NamespaceId
-	Ours      uint64
-	Theirs    uint64
-} {
-	// Create a channel for sending Intersection values.
-	ch := make(chan struct {
-		Namespace types.NamespaceId
-		Ours      uint64
-		Theirs    uint64
-	})
-
-	// Start a goroutine to send values to the channel.
-	go func() {
-		// Ensure the channel is closed when all values have been sent.
-		defer close(ch)
-
-		// Iterate over the slice of intersections.
-		for _, intersection := range a.IntersectingAoiQueue {
-			// Send each intersection through the channel.
-			ch <- intersection
-		}
-	}()
-
-	// Return the channel to the caller.
-	return ch
-}
@@ -0,0 +1,51 @@
+package reconciliation
+
+import (
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+func TestAoiIntersectionFinderFindsEachPairOnce(t *testing.T) {
+	ours := handlestore.HandleStore[types.AreaOfInterest]{Map: handlestore.NewMap[types.AreaOfInterest]()}
+	theirs := handlestore.HandleStore[types.AreaOfInterest]{Map: handlestore.NewMap[types.AreaOfInterest]()}
+	finder := NewAoiIntersectionFinder(AoiIntersectionFinderOpts{
+		NamespaceScheme: store.TestNameSpaceScheme,
+		SubspaceScheme:  store.TestSubspaceScheme,
+		HandlesOurs:     &ours,
+		HandlesTheirs:   &theirs,
+	})
+
+	subspaceArea := func(subspace string) types.AreaOfInterest {
+		return types.AreaOfInterest{Area: utils.SubspaceArea(types.SubspaceId(subspace))}
+	}
+
+	full := ours.Bind(types.AreaOfInterest{Area: utils.FullArea()})
+	if found := finder.AddAoiHandleToNamespace(full, types.NamespaceId("myspace"), true); len(found) != 0 {
+		t.Fatalf("found %v without any area of interest of the other peer", found)
+	}
+	alfie := ours.Bind(subspaceArea("alfie"))
+	finder.AddAoiHandleToNamespace(alfie, types.NamespaceId("myspace"), true)
+	// Only intersects areas of interest of the same namespace
+	other := ours.Bind(types.AreaOfInterest{Area: utils.FullArea()})
+	finder.AddAoiHandleToNamespace(other, types.NamespaceId("otherspace"), true)
+
+	betty := theirs.Bind(subspaceArea("betty"))
+	found := finder.AddAoiHandleToNamespace(betty, types.NamespaceId("myspace"), false)
+	if len(found) != 1 || found[0].Ours != full || found[0].Theirs != betty {
+		t.Fatalf("expected only the full area to intersect the area of betty, found %v", found)
+	}
+
+	// A freed area of interest is not found anymore
+	if err := ours.Free(full); err != nil {
+		t.Fatal(err)
+	}
+	both := theirs.Bind(types.AreaOfInterest{Area: utils.FullArea()})
+	found = finder.AddAoiHandleToNamespace(both, types.NamespaceId("myspace"), false)
+	if len(found) != 1 || found[0].Ours != alfie || found[0].Theirs != both {
+		t.Fatalf("expected only the area of alfie to intersect the full area, found %v", found)
+	}
+}
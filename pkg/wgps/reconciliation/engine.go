@@ -0,0 +1,660 @@
+package reconciliation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/data"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+	"golang.org/x/exp/constraints"
+)
+
+// Size in bytes of the ReconciliationSendPayload messages a payload is split into
+const PAYLOAD_CHUNK_SIZE = 4096
+
+// Payloads longer than this many bytes are left for the other peer to request, even when it is eager
+const EAGER_PAYLOAD_THRESHOLD = 1 << 20
+
+type EngineOpts[
+	K constraints.Unsigned,
+	PreFingerPrint, FingerPrint string,
+	AuthorisationOpts []byte, AuthorisationToken string,
+	StaticToken, DynamicToken string] struct {
+	Role                     wgpstypes.SyncRole
+	Store                    *store.Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]
+	AuthorisationTokenScheme wgpstypes.AuthorisationTokenScheme[AuthorisationToken, StaticToken, DynamicToken]
+	SendEntriesThreshold     uint64 // Defaults to SEND_ENTRIES_THRESHOLD
+	SplitFactor              int    // Defaults to SPLIT_FACTOR
+	PayloadChunkSize         int    // Defaults to PAYLOAD_CHUNK_SIZE
+	EagerPayloadThreshold    uint64 // Defaults to EAGER_PAYLOAD_THRESHOLD
+	// Whether the other peer may read an entry of ours, every entry is readable if unset
+	MayRead func(entry types.Entry) bool
+}
+
+/*
+Engine runs 3d range-based set reconciliation between our store and the store of another peer.
+
+It knows nothing about transports or encodings: every reconciliation message received from the other peer
+is passed to HandleMessage, which returns the messages to send back, in order. Alfie starts reconciling every
+pair of intersecting areas of interest with the message returned by AddAoiPair. Once neither peer has anything
+left to send both stores hold the same entries within the intersections, which Progress tells.
+
+An Engine is not safe for concurrent use.
+*/
+type Engine[
+	K constraints.Unsigned,
+	PreFingerPrint, FingerPrint string,
+	AuthorisationOpts []byte, AuthorisationToken string,
+	StaticToken, DynamicToken string] struct {
+	Role                     wgpstypes.SyncRole
+	Store                    *store.Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]
+	AuthorisationTokenScheme wgpstypes.AuthorisationTokenScheme[AuthorisationToken, StaticToken, DynamicToken]
+	SendEntriesThreshold     uint64
+	SplitFactor              int
+	PayloadChunkSize         int
+	EagerPayloadThreshold    uint64
+	MayRead                  func(entry types.Entry) bool
+
+	Reconcilers        *ReconcilerMap[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]
+	StaticTokensOurs   handlestore.HandleStore[StaticToken]
+	StaticTokensTheirs handlestore.HandleStore[StaticToken]
+
+	// The handles we bound our static tokens to, so each of them is only sent once
+	staticTokenHandles map[StaticToken]uint64
+	// Eagerness the other peer set for pairs of areas of interest we had not reconciled yet
+	pendingEagerness map[[2]uint64]bool
+	// How far reconciling each pair of areas of interest got, by our handle and theirs
+	progress map[[2]uint64]*Progress
+	// Entries we ingested from the other peer, which are not worth sending back to it
+	receivedEntries map[string]struct{}
+	// Number of fingerprints and announcements received so far, used for the Covers of our replies
+	receivedRanges uint64
+	// The announcement whose entries are currently being received
+	announcement *receivingAnnouncement
+	// The entry whose payload is currently being received
+	entry *receivingEntry
+}
+
+/*
+Progress of reconciling the intersection of one of our areas of interest with one of theirs. Every fingerprint either
+peer sends is answered by the other one, with an announcement or the fingerprints of the parts of its range, and so
+is every announcement which asks for the entries of the other peer. A range is open from being sent until its answer
+arrived.
+*/
+type Progress struct {
+	AoiHandleOurs   uint64
+	AoiHandleTheirs uint64
+	// Whether reconciling started, which is once alfie sent the first fingerprint, or betty received it
+	Started bool
+	// Ranges we sent which the other peer has yet to answer
+	OpenRanges uint64
+	// Entries sent and received within announcements, along with the bytes of their payloads
+	EntriesSent          uint64
+	EntriesReceived      uint64
+	PayloadBytesSent     uint64
+	PayloadBytesReceived uint64
+}
+
+/*
+Whether both peers agree on every entry within the intersection. The other peer only opens ranges in answer to ours,
+and we answer them as soon as they arrive, so once none of ours is open the other peer has nothing left to send
+either, and none of its ranges stays open once our answers arrived.
+*/
+func (p Progress) IsReconciled() bool {
+	return p.Started && p.OpenRanges == 0
+}
+
+// Counts a range of ours answered by the other peer, which a misbehaving peer may answer more often than we sent it
+func (p *Progress) answered() {
+	if p.OpenRanges > 0 {
+		p.OpenRanges--
+	}
+}
+
+type receivingAnnouncement struct {
+	Data      wgpstypes.MsgReconciliationAnnounceEntriesData
+	Counter   uint64
+	Remaining uint64
+}
+
+type receivingEntry struct {
+	Entry        types.Entry
+	WantsPayload bool
+	Payload      []byte
+}
+
+func NewEngine[
+	K constraints.Unsigned,
+	PreFingerPrint, FingerPrint string,
+	AuthorisationOpts []byte, AuthorisationToken string,
+	StaticToken, DynamicToken string](
+	opts EngineOpts[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken],
+) *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken] {
+	engine := &Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]{
+		Role:                     opts.Role,
+		Store:                    opts.Store,
+		AuthorisationTokenScheme: opts.AuthorisationTokenScheme,
+		SendEntriesThreshold:     opts.SendEntriesThreshold,
+		SplitFactor:              opts.SplitFactor,
+		PayloadChunkSize:         opts.PayloadChunkSize,
+		EagerPayloadThreshold:    opts.EagerPayloadThreshold,
+		MayRead:                  opts.MayRead,
+		Reconcilers:              NewReconcilerMap[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken](),
+		StaticTokensOurs:         handlestore.HandleStore[StaticToken]{Map: handlestore.NewMap[StaticToken]()},
+		StaticTokensTheirs:       handlestore.HandleStore[StaticToken]{Map: handlestore.NewMap[StaticToken]()},
+		staticTokenHandles:       make(map[StaticToken]uint64),
+		pendingEagerness:         make(map[[2]uint64]bool),
+		progress:                 make(map[[2]uint64]*Progress),
+		receivedEntries:          make(map[string]struct{}),
+	}
+	if engine.SendEntriesThreshold == 0 {
+		engine.SendEntriesThreshold = SEND_ENTRIES_THRESHOLD
+	}
+	if engine.SplitFactor == 0 {
+		engine.SplitFactor = SPLIT_FACTOR
+	}
+	if engine.PayloadChunkSize == 0 {
+		engine.PayloadChunkSize = PAYLOAD_CHUNK_SIZE
+	}
+	if engine.EagerPayloadThreshold == 0 {
+		engine.EagerPayloadThreshold = EAGER_PAYLOAD_THRESHOLD
+	}
+	if engine.MayRead == nil {
+		engine.MayRead = func(entry types.Entry) bool { return true }
+	}
+	return engine
+}
+
+/*
+Registers a pair of intersecting areas of interest, one of ours and one of theirs, under the handles both peers
+bound them to. Alfie gets back the fingerprint which starts reconciling the pair, betty waits for it.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) AddAoiPair(
+	aoiHandleOurs, aoiHandleTheirs uint64,
+	aoiOurs, aoiTheirs types.AreaOfInterest,
+) ([]wgpstypes.SyncMessage, error) {
+	reconciler, err := NewReconciler(&ReconcilerOpts[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]{
+		Role:                 e.Role,
+		SubspaceScheme:       e.Store.Schemes.SubspaceScheme,
+		FingerPrintScheme:    e.Store.Schemes.FingerprintScheme,
+		Namespace:            e.Store.NameSpaceId,
+		AoiOurs:              aoiOurs,
+		AoiTheirs:            aoiTheirs,
+		AoiHandleOurs:        aoiHandleOurs,
+		AoiHandleTheirs:      aoiHandleTheirs,
+		Store:                e.Store,
+		SplitFactor:          e.SplitFactor,
+		SendEntriesThreshold: e.SendEntriesThreshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if eager, found := e.pendingEagerness[[2]uint64{aoiHandleOurs, aoiHandleTheirs}]; found {
+		reconciler.Eager = eager
+		delete(e.pendingEagerness, [2]uint64{aoiHandleOurs, aoiHandleTheirs})
+	}
+	e.Reconcilers.AddReconciler(aoiHandleOurs, aoiHandleTheirs, reconciler)
+	progress := &Progress{AoiHandleOurs: aoiHandleOurs, AoiHandleTheirs: aoiHandleTheirs}
+	e.progress[[2]uint64{aoiHandleOurs, aoiHandleTheirs}] = progress
+
+	if !wgpstypes.IsAlfie(e.Role) {
+		return nil, nil
+	}
+	progress.Started = true
+	progress.OpenRanges++
+	return []wgpstypes.SyncMessage{reconciler.Initiate()}, nil
+}
+
+// How far reconciling every pair of areas of interest got, ordered by our handle and then theirs
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) Progress() []Progress {
+	progress := make([]Progress, 0, len(e.progress))
+	for _, pair := range e.progress {
+		progress = append(progress, *pair)
+	}
+	sort.Slice(progress, func(i, j int) bool {
+		if progress[i].AoiHandleOurs != progress[j].AoiHandleOurs {
+			return progress[i].AoiHandleOurs < progress[j].AoiHandleOurs
+		}
+		return progress[i].AoiHandleTheirs < progress[j].AoiHandleTheirs
+	})
+	return progress
+}
+
+// Whether every pair of areas of interest added so far is reconciled, which is never the case before the first one is
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) IsReconciled() bool {
+	if len(e.progress) == 0 {
+		return false
+	}
+	for _, progress := range e.progress {
+		if !progress.IsReconciled() {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Sets whether the other peer wants the payloads within the intersection of a pair of areas of interest sent along
+with the entries. Lazy peers only receive the entries, and request the payloads they want. The other peer may
+find the intersection before we do, in which case its eagerness applies once we add the pair.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) SetEagerness(
+	aoiHandleOurs, aoiHandleTheirs uint64,
+	eager bool,
+) error {
+	reconciler, err := e.Reconcilers.GetReconciler(aoiHandleOurs, aoiHandleTheirs)
+	if err != nil {
+		e.pendingEagerness[[2]uint64{aoiHandleOurs, aoiHandleTheirs}] = eager
+		return nil
+	}
+	reconciler.Eager = eager
+	return nil
+}
+
+// A protocol violation of the other peer, which ends the session
+func violation(reason string) error {
+	return wgpstypes.ProtocolViolationError{Err: errors.New(reason)}
+}
+
+// Handles a message received from the other peer, and returns the messages to send in reply
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) HandleMessage(
+	msg wgpstypes.SyncMessage,
+) ([]wgpstypes.SyncMessage, error) {
+	switch msg := msg.(type) {
+	case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
+		e.StaticTokensTheirs.Bind(msg.Data.StaticToken)
+		return nil, nil
+	case wgpstypes.MsgReconciliationSendFingerprint[FingerPrint]:
+		return e.handleFingerprint(msg.Data)
+	case wgpstypes.MsgReconciliationAnnounceEntries:
+		return e.handleAnnouncement(msg.Data)
+	case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
+		return nil, e.handleEntry(msg.Data)
+	case wgpstypes.MsgReconciliationSendPayload:
+		if e.entry == nil {
+			return nil, violation("received a payload without an entry")
+		}
+		if uint64(len(msg.Data.Bytes)) > e.entry.Entry.Payload_length-uint64(len(e.entry.Payload)) {
+			return nil, violation("received more bytes than the payload of the entry is long")
+		}
+		e.entry.Payload = append(e.entry.Payload, msg.Data.Bytes...)
+		e.progressOf(e.announcement.Data).PayloadBytesReceived += uint64(len(msg.Data.Bytes))
+		return nil, nil
+	case wgpstypes.MsgReconciliationTerminatePayload:
+		return e.handleTerminatePayload()
+	default:
+		return nil, fmt.Errorf("reconciliation can not handle a message of kind %v", msg.GetKind())
+	}
+}
+
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) handleFingerprint(
+	data wgpstypes.MsgReconciliationSendFingerprintData[FingerPrint],
+) ([]wgpstypes.SyncMessage, error) {
+	counter := e.receivedRanges
+	e.receivedRanges++
+
+	// Their sender handle is one of their areas of interest, their receiver handle one of ours
+	reconciler, err := e.Reconcilers.GetReconciler(data.ReceiverHandle, data.SenderHandle)
+	if err != nil {
+		return nil, wgpstypes.ProtocolViolationError{Err: err}
+	}
+
+	// The last of the parts of a range of ours answers it, any other fingerprint is the other peer opening a range
+	progress := e.progress[[2]uint64{data.ReceiverHandle, data.SenderHandle}]
+	progress.Started = true
+	if data.DoesCover {
+		progress.answered()
+	}
+
+	fingerprints, announcement := reconciler.Respond(data.Range, data.Fingerprint, counter)
+	if announcement.Announce {
+		return e.announce(reconciler, announcement.Range, announcement.WantResponse, announcement.Covers)
+	}
+
+	progress.OpenRanges += uint64(len(fingerprints))
+	replies := make([]wgpstypes.SyncMessage, 0, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		replies = append(replies, fingerprint)
+	}
+	return replies, nil
+}
+
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) handleAnnouncement(
+	data wgpstypes.MsgReconciliationAnnounceEntriesData,
+) ([]wgpstypes.SyncMessage, error) {
+	if e.announcement != nil {
+		return nil, violation("received an announcement before all entries of the previous one")
+	}
+	counter := e.receivedRanges
+	e.receivedRanges++
+
+	if _, err := e.Reconcilers.GetReconciler(data.ReceiverHandle, data.SenderHandle); err != nil {
+		return nil, wgpstypes.ProtocolViolationError{Err: err}
+	}
+
+	e.announcement = &receivingAnnouncement{
+		Data:      data,
+		Counter:   counter,
+		Remaining: data.Count,
+	}
+	if data.Count == 0 {
+		return e.finishAnnouncement()
+	}
+	return nil, nil
+}
+
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) handleEntry(
+	data wgpstypes.MsgReconciliationSendEntryData[DynamicToken],
+) error {
+	if e.announcement == nil || e.announcement.Remaining == 0 {
+		return violation("received an entry which was not announced")
+	}
+	if e.entry != nil {
+		return violation("received an entry before the payload of the previous one was terminated")
+	}
+
+	// Only an entry which was received counts towards the announcement
+	wantsPayload, err := e.ReceiveEntry(data.Entry.Entry, data.StaticTokenHandle, data.DynamicToken)
+	if err != nil {
+		return err
+	}
+	e.announcement.Remaining--
+	e.progressOf(e.announcement.Data).EntriesReceived++
+	e.entry = &receivingEntry{
+		Entry:        data.Entry.Entry,
+		WantsPayload: wantsPayload,
+	}
+	return nil
+}
+
+/*
+Ingests an entry the other peer sent us, authorised by a static token it bound and a dynamic token. Returns whether
+we want its payload, which an entry we already hold may still be missing (a part of), unless we hold a newer entry.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) ReceiveEntry(
+	entry types.Entry,
+	staticTokenHandle uint64,
+	dynamicToken DynamicToken,
+) (bool, error) {
+	if err := e.StaticTokensTheirs.CheckHandle(staticTokenHandle); err != nil {
+		return false, wgpstypes.ProtocolViolationError{Err: fmt.Errorf("could not dereference a static token handle: %w", err)}
+	}
+	staticToken, _ := e.StaticTokensTheirs.Get(staticTokenHandle)
+	authToken := e.AuthorisationTokenScheme.RecomposeAuthToken(staticToken, dynamicToken)
+
+	_, err := e.Store.IngestEntry(entry, authToken)
+	if errors.Is(err, store.ErrInvalidEntry) {
+		return false, wgpstypes.ProtocolViolationError{Err: err}
+	}
+	if err != nil && !errors.Is(err, store.ErrNewerEntryExists) {
+		return false, err
+	}
+	if err == nil {
+		e.receivedEntries[entryKey(entry)] = struct{}{}
+	}
+	return err == nil || e.holdsEntry(entry), nil
+}
+
+/*
+Whether an entry ingested into our store is of interest to the other peer in any pair of areas of interest we
+reconcile, and the other peer may read it, and if so whether the other peer wants its payload sent along with it.
+Entries we received from the other peer are not shared back to it.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) Shares(entry types.Entry) (shared, eager bool) {
+	if _, received := e.receivedEntries[entryKey(entry)]; received {
+		delete(e.receivedEntries, entryKey(entry))
+		return false, false
+	}
+	if !e.MayRead(entry) {
+		return false, false
+	}
+	for _, reconcilers := range e.Reconcilers.Map {
+		for _, reconciler := range reconcilers {
+			if reconciler.IsOfInterest(entry) {
+				shared = true
+				eager = eager || reconciler.Eager
+			}
+		}
+	}
+	return shared, eager
+}
+
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) handleTerminatePayload() ([]wgpstypes.SyncMessage, error) {
+	if e.entry == nil {
+		return nil, violation("received a payload termination without an entry")
+	}
+	received := e.entry
+	e.entry = nil
+	// Only a payload which is complete has a digest to check, the store checks it again once it completes a partial one
+	if uint64(len(received.Payload)) == received.Entry.Payload_length {
+		if digest := <-e.Store.Schemes.PayloadScheme.FromBytes(received.Payload); digest != received.Entry.Payload_digest {
+			return nil, violation("received a payload which does not match the digest of its entry")
+		}
+	}
+	if err := e.storePayload(received); err != nil {
+		return nil, err
+	}
+
+	if e.announcement != nil && e.announcement.Remaining == 0 {
+		return e.finishAnnouncement()
+	}
+	return nil, nil
+}
+
+// Stores what was received of the payload of an entry, if we asked for it
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) storePayload(received *receivingEntry) error {
+	if !received.WantsPayload || len(received.Payload) == 0 {
+		return nil
+	}
+	_, err := e.Store.IngestPayload(types.Position3d{
+		Subspace: received.Entry.Subspace_id,
+		Path:     received.Entry.Path,
+		Time:     received.Entry.Timestamp,
+	}, received.Payload, true, 0)
+	return err
+}
+
+/*
+Called once the session ends, stores what was received of the payload of the entry being received, and returns a
+request for the rest of it to resume in a later session. Returns nothing when no payload we asked for was cut off.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) Abort() []data.PayloadRequest {
+	received := e.entry
+	e.entry = nil
+	if received == nil || !received.WantsPayload || uint64(len(received.Payload)) >= received.Entry.Payload_length {
+		return nil
+	}
+	if err := e.storePayload(received); err != nil {
+		log.Printf("could not store the payload of %v cut off by the end of the session: %v", received.Entry.Path, err)
+		return nil
+	}
+	return []data.PayloadRequest{{
+		Offset: uint64(len(received.Payload)),
+		Entry:  received.Entry,
+	}}
+}
+
+// Called once all entries of an announcement were received, answers with our own entries if they were asked for
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) finishAnnouncement() ([]wgpstypes.SyncMessage, error) {
+	announcement := e.announcement
+	e.announcement = nil
+	// Every announcement answers a range of ours
+	e.progressOf(announcement.Data).answered()
+	if !announcement.Data.WantResponse {
+		return nil, nil
+	}
+	reconciler, err := e.Reconcilers.GetReconciler(announcement.Data.ReceiverHandle, announcement.Data.SenderHandle)
+	if err != nil {
+		return nil, err
+	}
+	return e.announce(reconciler, announcement.Data.Range, false, announcement.Counter)
+}
+
+/*
+The handle a static token of ours is bound to, binding it if it was not bound yet or its handle was freed since.
+alreadyExisted tells whether the other peer knows the handle already, otherwise it has to be sent the static token.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) GetStaticTokenHandle(
+	staticToken StaticToken,
+) (handle uint64, alreadyExisted bool) {
+	handle, found := e.staticTokenHandles[staticToken]
+	if found && e.StaticTokensOurs.CanUse(handle) {
+		return handle, true
+	}
+	handle = e.StaticTokensOurs.Bind(staticToken)
+	e.staticTokenHandles[staticToken] = handle
+	return handle, false
+}
+
+/*
+Announces all our entries within a range and sends them along with their payloads. The static tokens of the
+entries which were not bound yet are bound first, followed by the announcement and then every entry, its payload and the termination
+of the payload. Entries the other peer may not read, or which are beyond the limits of the areas of interest, are
+left out along with their payloads. Payloads are only sent to an eager peer, and only up to EagerPayloadThreshold.
+*/
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) announce(
+	reconciler *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken],
+	yourRange types.Range3d,
+	wantResponse bool,
+	covers uint64,
+) ([]wgpstypes.SyncMessage, error) {
+	var extendedEntries []datamodeltypes.ExtendedEntry
+	var err error
+	e.Store.ReadEntries(func() {
+		extendedEntries, err = e.Store.EntryDriver.Query(yourRange)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	progress := e.progress[[2]uint64{reconciler.AoiHandleOurs, reconciler.AoiHandleTheirs}]
+	var staticTokenBinds, entries []wgpstypes.SyncMessage
+	var count uint64
+	for _, extendedEntry := range extendedEntries {
+		if !e.MayRead(extendedEntry.Entry) || !reconciler.IsOfInterest(extendedEntry.Entry) {
+			continue
+		}
+		count++
+		encodedToken, err := e.Store.PayloadDriver.Get(extendedEntry.AuthDigest)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve the authorisation token of an entry: %w", err)
+		}
+		authToken, err := e.Store.Schemes.AuthorisationScheme.TokenEncoding.Decode(encodedToken.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		staticToken, dynamicToken := e.AuthorisationTokenScheme.DecomposeAuthToken(authToken)
+		staticTokenHandle, alreadyExisted := e.GetStaticTokenHandle(staticToken)
+		if !alreadyExisted {
+			staticTokenBinds = append(staticTokenBinds, wgpstypes.MsgSetupBindStaticToken[StaticToken]{
+				Kind: wgpstypes.SetupBindStaticToken,
+				Data: wgpstypes.MsgSetupBindStaticTokenData[StaticToken]{
+					StaticToken: staticToken,
+				},
+			})
+		}
+
+		// Only complete payloads are sent eagerly, but the peer is told of partial ones as well
+		available := e.Store.HeldPayload(extendedEntry.Entry.Payload_digest)
+		sent := e.Store.AvailablePayload(extendedEntry.Entry.Payload_digest)
+		if !reconciler.Eager || extendedEntry.Entry.Payload_length > e.EagerPayloadThreshold {
+			sent = 0
+		}
+		entries = append(entries, wgpstypes.MsgReconciliationSendEntry[DynamicToken]{
+			Kind: wgpstypes.ReconciliationSendEntry,
+			Data: wgpstypes.MsgReconciliationSendEntryData[DynamicToken]{
+				Entry: datamodeltypes.LengthyEntry{
+					Entry:     extendedEntry.Entry,
+					Available: available,
+				},
+				StaticTokenHandle: staticTokenHandle,
+				DynamicToken:      dynamicToken,
+			},
+		})
+		payload := e.payloadMessages(extendedEntry.Entry, sent)
+		for _, msg := range payload {
+			if chunk, isChunk := msg.(wgpstypes.MsgReconciliationSendPayload); isChunk {
+				progress.PayloadBytesSent += chunk.Data.Amount
+			}
+		}
+		entries = append(entries, payload...)
+	}
+	progress.EntriesSent += count
+	if wantResponse {
+		progress.OpenRanges++
+	}
+
+	replies := append(staticTokenBinds, wgpstypes.MsgReconciliationAnnounceEntries{
+		Kind: wgpstypes.ReconciliationAnnounceEntries,
+		Data: wgpstypes.MsgReconciliationAnnounceEntriesData{
+			Range:          yourRange,
+			Count:          count,
+			WantResponse:   wantResponse,
+			WillSort:       false,
+			SenderHandle:   reconciler.AoiHandleOurs,
+			ReceiverHandle: reconciler.AoiHandleTheirs,
+			Covers:         covers,
+			DoesCover:      true,
+		},
+	})
+	return append(replies, entries...), nil
+}
+
+// The payload of an entry split into chunks, always followed by the termination of the payload
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) payloadMessages(
+	entry types.Entry,
+	available uint64,
+) []wgpstypes.SyncMessage {
+	var messages []wgpstypes.SyncMessage
+	if available > 0 && available == entry.Payload_length {
+		payload, err := e.Store.PayloadDriver.Get(entry.Payload_digest)
+		if err == nil {
+			bytes := payload.Bytes()
+			for start := 0; start < len(bytes); start += e.PayloadChunkSize {
+				end := min(start+e.PayloadChunkSize, len(bytes))
+				messages = append(messages, wgpstypes.MsgReconciliationSendPayload{
+					Kind: wgpstypes.ReconciliationSendPayload,
+					Data: wgpstypes.MsgReconciliationSendPayloadData{
+						Amount: uint64(end - start),
+						Bytes:  bytes[start:end],
+					},
+				})
+			}
+		}
+	}
+	return append(messages, wgpstypes.MsgReconciliationTerminatePayload{
+		Kind: wgpstypes.ReconciliationTerminatePayload,
+	})
+}
+
+// The progress of the pair of areas of interest an announcement of the other peer is about
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) progressOf(
+	announcement wgpstypes.MsgReconciliationAnnounceEntriesData,
+) *Progress {
+	return e.progress[[2]uint64{announcement.ReceiverHandle, announcement.SenderHandle}]
+}
+
+// Identifies an entry by its position and payload
+func entryKey(entry types.Entry) string {
+	return fmt.Sprintf("%v %v", utils.EntryPosition(entry), entry.Payload_digest)
+}
+
+// Whether our store holds exactly this entry
+func (e *Engine[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]) holdsEntry(entry types.Entry) bool {
+	var ours datamodeltypes.ExtendedEntry
+	var err error
+	e.Store.ReadEntries(func() {
+		ours, err = e.Store.EntryDriver.Get(entry.Subspace_id, entry.Path)
+	})
+	if err != nil {
+		return false
+	}
+	return ours.Entry.Timestamp == entry.Timestamp && ours.Entry.Payload_digest == entry.Payload_digest
+}
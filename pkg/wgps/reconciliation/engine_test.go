@@ -0,0 +1,748 @@
+package reconciliation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+type testStore = store.Store[string, string, uint, []byte, string]
+type testEngine = Engine[uint, string, string, []byte, string, string, string]
+
+// Stores keep their entries in memory and their payloads in a directory of the test
+func newTestStore(t *testing.T) *testStore {
+	s := pinagoladastore.InitMemoryStorage(types.NamespaceId("Test"), t.TempDir())
+	pinagoladastore.InitKDTree(s)
+	return s
+}
+
+func setEntry(t *testing.T, s *testStore, subspace, path string, timestamp uint64, payload string) {
+	_, err := s.Set(datamodeltypes.EntryInput{
+		Subspace:  types.SubspaceId(subspace),
+		Path:      types.Path{[]byte(path)},
+		Payload:   []byte(payload),
+		Timestamp: timestamp,
+	}, []byte(subspace))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Passes messages back and forth between two engines until neither has anything left to say
+func runEngines(t *testing.T, alfie, betty *testEngine, initial []wgpstypes.SyncMessage) int {
+	toBetty, toAlfie := initial, []wgpstypes.SyncMessage{}
+	rounds := 0
+	for len(toBetty) > 0 || len(toAlfie) > 0 {
+		rounds++
+		if rounds > 1000 {
+			t.Fatal("reconciliation did not terminate")
+		}
+		var nextToBetty, nextToAlfie []wgpstypes.SyncMessage
+		for _, msg := range toBetty {
+			replies, err := betty.HandleMessage(msg)
+			if err != nil {
+				t.Fatalf("betty could not handle %T: %v", msg, err)
+			}
+			nextToAlfie = append(nextToAlfie, replies...)
+		}
+		for _, msg := range toAlfie {
+			replies, err := alfie.HandleMessage(msg)
+			if err != nil {
+				t.Fatalf("alfie could not handle %T: %v", msg, err)
+			}
+			nextToBetty = append(nextToBetty, replies...)
+		}
+		toBetty, toAlfie = nextToBetty, nextToAlfie
+	}
+	return rounds
+}
+
+func storeContents(t *testing.T, s *testStore) map[string]string {
+	entries, err := s.EntryDriver.Query(utils.DefaultRange3d(types.SubspaceId("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, entry := range entries {
+		payload, err := s.GetPayload(types.Position3d{
+			Subspace: entry.Entry.Subspace_id,
+			Path:     entry.Entry.Path,
+			Time:     entry.Entry.Timestamp,
+		})
+		if err != nil {
+			t.Fatalf("missing payload for %s/%s: %v", entry.Entry.Subspace_id, entry.Entry.Path, err)
+		}
+		contents[fmt.Sprintf("%s/%s@%d", entry.Entry.Subspace_id, bytes.Join(entry.Entry.Path, []byte("/")), entry.Entry.Timestamp)] = string(payload)
+	}
+	return contents
+}
+
+func TestEngineReconcilesTwoStores(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+
+	// Entries both peers have
+	for i := 0; i < 40; i++ {
+		setEntry(t, storeAlfie, "shared", fmt.Sprintf("entry%02d", i), uint64(1000+i), fmt.Sprintf("payload %d", i))
+		setEntry(t, storeBetty, "shared", fmt.Sprintf("entry%02d", i), uint64(1000+i), fmt.Sprintf("payload %d", i))
+	}
+	// Entries only one of them has
+	for i := 0; i < 25; i++ {
+		setEntry(t, storeAlfie, "alfie", fmt.Sprintf("only%02d", i), uint64(2000+i), fmt.Sprintf("alfie's payload %d", i))
+	}
+	for i := 0; i < 7; i++ {
+		setEntry(t, storeBetty, "betty", fmt.Sprintf("only%02d", i), uint64(3000+i), fmt.Sprintf("betty's payload %d", i))
+	}
+	// A newer version of an entry alfie has, and an entry with a payload spanning several messages
+	setEntry(t, storeAlfie, "shared", "changed", 500, "old")
+	setEntry(t, storeBetty, "shared", "changed", 600, "new")
+	large := make([]byte, 3*PAYLOAD_CHUNK_SIZE+17)
+	for i := range large {
+		large[i] = byte(i)
+	}
+	setEntry(t, storeBetty, "betty", "large", 3100, string(large))
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 {
+		t.Fatalf("alfie should start with a single fingerprint, got %d messages", len(initial))
+	}
+	if replies, err := betty.AddAoiPair(0, 0, everything, everything); err != nil || len(replies) != 0 {
+		t.Fatalf("betty should wait for alfie, got %v %v", replies, err)
+	}
+
+	runEngines(t, alfie, betty, initial)
+
+	contentsAlfie, contentsBetty := storeContents(t, storeAlfie), storeContents(t, storeBetty)
+	if len(contentsAlfie) != 40+25+7+1+1 {
+		t.Errorf("expected %d entries, alfie has %d", 40+25+7+1+1, len(contentsAlfie))
+	}
+	if len(contentsAlfie) != len(contentsBetty) {
+		t.Fatalf("alfie has %d entries, betty has %d", len(contentsAlfie), len(contentsBetty))
+	}
+	for key, payload := range contentsAlfie {
+		if contentsBetty[key] != payload {
+			t.Errorf("stores differ at %s", key)
+		}
+	}
+	if contentsAlfie["shared/changed@600"] != "new" {
+		t.Errorf("the newer entry did not replace the older one")
+	}
+	if contentsAlfie["betty/large@3100"] != string(large) {
+		t.Errorf("large payload was not transferred")
+	}
+
+	// Reconciling again only takes a single round trip
+	initial, err = alfie.AddAoiPair(1, 1, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(1, 1, everything, everything)
+	if rounds := runEngines(t, alfie, betty, initial); rounds != 2 {
+		t.Errorf("reconciling equal stores took %d rounds", rounds)
+	}
+}
+
+func TestEngineReportsProgress(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	for i := 0; i < 20; i++ {
+		setEntry(t, storeAlfie, "alfie", fmt.Sprintf("only%02d", i), uint64(1000+i), fmt.Sprintf("alfie's payload %d", i))
+	}
+	setEntry(t, storeBetty, "betty", "only", 2000, "betty's payload")
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	if alfie.IsReconciled() {
+		t.Error("expected nothing to be reconciled before any areas of interest are")
+	}
+
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, everything, everything)
+	if progress := alfie.Progress(); len(progress) != 1 || progress[0].OpenRanges != 1 || alfie.IsReconciled() {
+		t.Errorf("expected alfie to wait for the answer to her first fingerprint, got %+v", progress)
+	}
+	// Betty has not heard of alfie yet, so she can not tell that they agree
+	if betty.IsReconciled() {
+		t.Error("expected betty not to be reconciled before the first fingerprint arrived")
+	}
+
+	runEngines(t, alfie, betty, initial)
+
+	if !alfie.IsReconciled() || !betty.IsReconciled() {
+		t.Fatalf("expected both to be reconciled, got %+v and %+v", alfie.Progress(), betty.Progress())
+	}
+	progressAlfie, progressBetty := alfie.Progress()[0], betty.Progress()[0]
+	if progressAlfie.EntriesSent != progressBetty.EntriesReceived || progressBetty.EntriesSent != progressAlfie.EntriesReceived {
+		t.Errorf("expected the entries one sent to be the ones the other received, got %+v and %+v", progressAlfie, progressBetty)
+	}
+	if progressAlfie.EntriesSent < 20 || progressBetty.EntriesSent < 1 {
+		t.Errorf("expected each to send the entries only it had, got %+v and %+v", progressAlfie, progressBetty)
+	}
+	if progressBetty.PayloadBytesSent != uint64(len("betty's payload")) || progressAlfie.PayloadBytesReceived != progressBetty.PayloadBytesSent {
+		t.Errorf("expected betty's payload to be counted on both sides, got %+v and %+v", progressAlfie, progressBetty)
+	}
+
+	// A new pair opens again until it is reconciled as well
+	initial, err = alfie.AddAoiPair(1, 1, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(1, 1, everything, everything)
+	if alfie.IsReconciled() {
+		t.Error("expected a new pair of areas of interest not to be reconciled yet")
+	}
+	runEngines(t, alfie, betty, initial)
+	if !alfie.IsReconciled() || !betty.IsReconciled() {
+		t.Errorf("expected both to be reconciled again, got %+v and %+v", alfie.Progress(), betty.Progress())
+	}
+}
+
+func TestEngineOnlySendsReadableEntries(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	for i := 0; i < 20; i++ {
+		setEntry(t, storeAlfie, "public", fmt.Sprintf("entry%02d", i), uint64(1000+i), "public payload")
+		setEntry(t, storeAlfie, "private", fmt.Sprintf("entry%02d", i), uint64(1000+i), "private payload")
+	}
+	setEntry(t, storeBetty, "betty", "entry", 1000, "betty's payload")
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+		MayRead: func(entry types.Entry) bool {
+			return string(entry.Subspace_id) != "private"
+		},
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, everything, everything)
+	runEngines(t, alfie, betty, initial)
+
+	contentsAlfie, contentsBetty := storeContents(t, storeAlfie), storeContents(t, storeBetty)
+	if len(contentsAlfie) != 20+20+1 {
+		t.Errorf("expected alfie to hold %d entries, it holds %d", 20+20+1, len(contentsAlfie))
+	}
+	if len(contentsBetty) != 20+1 {
+		t.Errorf("expected betty to hold %d entries, it holds %d", 20+1, len(contentsBetty))
+	}
+	for key, payload := range contentsBetty {
+		if payload == "private payload" {
+			t.Fatalf("betty received %s, which it may not read", key)
+		}
+	}
+}
+
+func TestEngineRespectsAreaOfInterestLimits(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	for i := 0; i < 10; i++ {
+		setEntry(t, storeAlfie, "alfie", fmt.Sprintf("entry%02d", i), uint64(1000+i), fmt.Sprintf("payload %d", i))
+	}
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+
+	// Betty is only interested in the three newest entries
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	newest := types.AreaOfInterest{Area: utils.FullArea(), MaxCount: 3}
+	initial, err := alfie.AddAoiPair(0, 0, everything, newest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, newest, everything)
+	runEngines(t, alfie, betty, initial)
+
+	contentsBetty := storeContents(t, storeBetty)
+	if len(contentsBetty) != 3 {
+		t.Fatalf("betty asked for 3 entries and received %d", len(contentsBetty))
+	}
+	for i := 7; i < 10; i++ {
+		if _, found := contentsBetty[fmt.Sprintf("alfie/entry%02d@%d", i, 1000+i)]; !found {
+			t.Errorf("betty did not receive entry %d, one of the newest", i)
+		}
+	}
+
+	// Limiting the size leaves out entries whose payloads do not fit anymore
+	limited, err := storeAlfie.AreaOfInterestToRange(types.AreaOfInterest{Area: utils.FullArea(), MaxSize: uint64(2*len("payload 0") + 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limited.TimeRange.Start != 1008 {
+		t.Errorf("expected the range of the two newest entries to start at 1008, it starts at %d", limited.TimeRange.Start)
+	}
+}
+
+func TestEngineLeavesPayloadsOfLazyPeers(t *testing.T) {
+	// Returns how many bytes of the payload of each entry betty holds
+	receivedPayloads := func(lazy bool) map[string]uint64 {
+		storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+		setEntry(t, storeAlfie, "alfie", "short", 1000, "tiny")
+		setEntry(t, storeAlfie, "alfie", "long", 1001, "a payload beyond the threshold")
+
+		alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+			Role:                     wgpstypes.SyncRoleAlfie,
+			Store:                    storeAlfie,
+			AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+			EagerPayloadThreshold:    10,
+		})
+		betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+			Role:                     wgpstypes.SyncRoleBetty,
+			Store:                    storeBetty,
+			AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+		})
+
+		// Betty may say she is lazy before alfie found the intersection
+		if err := alfie.SetEagerness(0, 0, !lazy); err != nil {
+			t.Fatal(err)
+		}
+		everything := types.AreaOfInterest{Area: utils.FullArea()}
+		initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+		if err != nil {
+			t.Fatal(err)
+		}
+		betty.AddAoiPair(0, 0, everything, everything)
+		runEngines(t, alfie, betty, initial)
+
+		entries, err := storeBetty.EntryDriver.Query(utils.DefaultRange3d(types.SubspaceId("")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := map[string]uint64{}
+		for _, entry := range entries {
+			received[string(bytes.Join(entry.Entry.Path, []byte("/")))] = storeBetty.AvailablePayload(entry.Entry.Payload_digest)
+		}
+		return received
+	}
+
+	eager := receivedPayloads(false)
+	if len(eager) != 2 {
+		t.Fatalf("expected betty to receive both entries, received %v", eager)
+	}
+	if eager["short"] != uint64(len("tiny")) || eager["long"] != 0 {
+		t.Errorf("expected only the payload within the threshold to be sent, betty holds %v", eager)
+	}
+
+	lazy := receivedPayloads(true)
+	if len(lazy) != 2 {
+		t.Fatalf("expected lazy betty to receive both entries, received %v", lazy)
+	}
+	if lazy["short"] != 0 || lazy["long"] != 0 {
+		t.Errorf("expected no payloads to be sent to lazy betty, betty holds %v", lazy)
+	}
+}
+
+func TestFingerprintsAgreeOncePayloadsArrive(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	setEntry(t, storeAlfie, "alfie", "later", 1000, "a payload sent later")
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	// Betty is lazy, so she receives the entry without its payload
+	if err := alfie.SetEagerness(0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, everything, everything)
+	runEngines(t, alfie, betty, initial)
+
+	fingerprint := func(s *testStore) string {
+		return s.EntryDriver.Storage.Summarise(utils.DefaultRange3d(types.SubspaceId(""))).FingerPrint
+	}
+	if fingerprint(storeAlfie) == fingerprint(storeBetty) {
+		t.Fatal("expected the fingerprints to differ while betty lacks the payload")
+	}
+	_, err = storeBetty.IngestPayload(types.Position3d{
+		Subspace: types.SubspaceId("alfie"),
+		Path:     types.Path{[]byte("later")},
+		Time:     1000,
+	}, []byte("a payload sent later"), false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint(storeAlfie) != fingerprint(storeBetty) {
+		t.Error("expected the fingerprints to agree once betty holds the payload")
+	}
+}
+
+func TestFingerprintsFollowPartialAndSharedPayloads(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	shared := bytes.Repeat([]byte("s"), 2*PAYLOAD_CHUNK_SIZE)
+	setEntry(t, storeAlfie, "alfie", "first", 1000, string(shared))
+	setEntry(t, storeAlfie, "alfie", "second", 1001, string(shared))
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	// Betty is lazy, so she receives both entries without their payload
+	if err := alfie.SetEagerness(0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, everything, everything)
+	runEngines(t, alfie, betty, initial)
+
+	fingerprint := func(s *testStore) string {
+		return s.EntryDriver.Storage.Summarise(utils.DefaultRange3d(types.SubspaceId(""))).FingerPrint
+	}
+	first := types.Position3d{Subspace: types.SubspaceId("alfie"), Path: types.Path{[]byte("first")}, Time: 1000}
+
+	// Half of the payload already counts towards the fingerprints of betty
+	without := fingerprint(storeBetty)
+	if _, err := storeBetty.IngestPayload(first, shared[:PAYLOAD_CHUNK_SIZE], true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint(storeBetty) == without {
+		t.Error("expected the fingerprints of betty to change with a partial payload")
+	}
+
+	// The rest of it, ingested for the first entry, completes the second entry as well
+	if _, err := storeBetty.IngestPayload(first, shared[PAYLOAD_CHUNK_SIZE:], false, PAYLOAD_CHUNK_SIZE); err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint(storeAlfie) != fingerprint(storeBetty) {
+		t.Error("expected the fingerprints to agree once betty holds the shared payload")
+	}
+	initial, err = alfie.AddAoiPair(1, 1, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(1, 1, everything, everything)
+	if rounds := runEngines(t, alfie, betty, initial); rounds != 2 {
+		t.Errorf("reconciling equal stores took %d rounds", rounds)
+	}
+}
+
+func TestEngineKeepsPayloadsCutOffByTheEndOfTheSession(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	large := bytes.Repeat([]byte("a"), 3*PAYLOAD_CHUNK_SIZE)
+	setEntry(t, storeAlfie, "alfie", "large", 1000, string(large))
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	toBetty, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, everything, everything)
+
+	// The session ends once betty received the first chunk of the payload
+	for len(toBetty) > 0 {
+		msg := toBetty[0]
+		toBetty = toBetty[1:]
+		replies, err := betty.HandleMessage(msg)
+		if err != nil {
+			t.Fatalf("betty could not handle %T: %v", msg, err)
+		}
+		if _, isChunk := msg.(wgpstypes.MsgReconciliationSendPayload); isChunk {
+			break
+		}
+		for _, reply := range replies {
+			more, err := alfie.HandleMessage(reply)
+			if err != nil {
+				t.Fatalf("alfie could not handle %T: %v", reply, err)
+			}
+			toBetty = append(toBetty, more...)
+		}
+	}
+
+	interrupted := betty.Abort()
+	if len(interrupted) != 1 || interrupted[0].Offset != PAYLOAD_CHUNK_SIZE || string(interrupted[0].Entry.Path[0]) != "large" {
+		t.Fatalf("expected betty to resume the payload after its first chunk, she resumes %v", interrupted)
+	}
+	if partial := storeBetty.PartialPayload(interrupted[0].Entry.Payload_digest); partial != PAYLOAD_CHUNK_SIZE {
+		t.Errorf("expected betty to keep the first chunk of the payload, she holds %d bytes", partial)
+	}
+	if again := betty.Abort(); len(again) != 0 {
+		t.Errorf("expected the payload to be resumed once, betty resumes %v", again)
+	}
+}
+
+func TestEngineRefusesEntriesWhichAreNotAuthorised(t *testing.T) {
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    newTestStore(t),
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	betty.AddAoiPair(0, 0, everything, everything)
+
+	announcement := wgpstypes.MsgReconciliationAnnounceEntries{
+		Kind: wgpstypes.ReconciliationAnnounceEntries,
+		Data: wgpstypes.MsgReconciliationAnnounceEntriesData{Range: utils.DefaultRange3d(types.SubspaceId("")), Count: 1},
+	}
+	// The entry is in the subspace of alfie, but authorised by mallory
+	forged := wgpstypes.MsgReconciliationSendEntry[string]{
+		Kind: wgpstypes.ReconciliationSendEntry,
+		Data: wgpstypes.MsgReconciliationSendEntryData[string]{
+			Entry: datamodeltypes.LengthyEntry{Entry: types.Entry{
+				Namespace_id: types.NamespaceId("Test"),
+				Subspace_id:  types.SubspaceId("alfie"),
+				Path:         types.Path{[]byte("forged")},
+				Timestamp:    1000,
+			}},
+		},
+	}
+	for _, msg := range []wgpstypes.SyncMessage{
+		wgpstypes.MsgSetupBindStaticToken[string]{Kind: wgpstypes.SetupBindStaticToken, Data: wgpstypes.MsgSetupBindStaticTokenData[string]{StaticToken: "mallory"}},
+		announcement,
+	} {
+		if _, err := betty.HandleMessage(msg); err != nil {
+			t.Fatalf("betty could not handle %T: %v", msg, err)
+		}
+	}
+
+	_, err := betty.HandleMessage(forged)
+	if !errors.As(err, &wgpstypes.ProtocolViolationError{}) {
+		t.Fatalf("expected an unauthorised entry to violate the protocol, got %v", err)
+	}
+	if received := betty.Progress()[0].EntriesReceived; received != 0 {
+		t.Errorf("expected the unauthorised entry not to count as received, %d entries did", received)
+	}
+	// A payload for the entry which was refused belongs to no entry
+	_, err = betty.HandleMessage(wgpstypes.MsgReconciliationTerminatePayload{Kind: wgpstypes.ReconciliationTerminatePayload})
+	if !errors.As(err, &wgpstypes.ProtocolViolationError{}) {
+		t.Errorf("expected a payload without an entry to violate the protocol, got %v", err)
+	}
+}
+
+func TestEngineRefusesPayloadsWhichDoNotMatchTheirEntry(t *testing.T) {
+	payload := []byte("payload")
+	entry := types.Entry{
+		Namespace_id:   types.NamespaceId("Test"),
+		Subspace_id:    types.SubspaceId("alfie"),
+		Path:           types.Path{[]byte("entry")},
+		Timestamp:      1000,
+		Payload_length: uint64(len(payload)),
+		Payload_digest: <-store.TestPayloadScheme.FromBytes(payload),
+	}
+	chunk := func(bytes string) wgpstypes.SyncMessage {
+		return wgpstypes.MsgReconciliationSendPayload{
+			Kind: wgpstypes.ReconciliationSendPayload,
+			Data: wgpstypes.MsgReconciliationSendPayloadData{Amount: uint64(len(bytes)), Bytes: []byte(bytes)},
+		}
+	}
+	terminate := wgpstypes.MsgReconciliationTerminatePayload{Kind: wgpstypes.ReconciliationTerminatePayload}
+
+	for name, payloadMessages := range map[string][]wgpstypes.SyncMessage{
+		"too long":  {chunk("pay"), chunk("load and more")},
+		"forged":    {chunk("payl0ad"), terminate},
+		"forged to": {chunk("pay"), chunk("l0ad"), terminate},
+	} {
+		storeBetty := newTestStore(t)
+		betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+			Role:                     wgpstypes.SyncRoleBetty,
+			Store:                    storeBetty,
+			AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+		})
+		everything := types.AreaOfInterest{Area: utils.FullArea()}
+		betty.AddAoiPair(0, 0, everything, everything)
+
+		messages := []wgpstypes.SyncMessage{
+			wgpstypes.MsgSetupBindStaticToken[string]{Kind: wgpstypes.SetupBindStaticToken, Data: wgpstypes.MsgSetupBindStaticTokenData[string]{StaticToken: "alfie"}},
+			wgpstypes.MsgReconciliationAnnounceEntries{
+				Kind: wgpstypes.ReconciliationAnnounceEntries,
+				Data: wgpstypes.MsgReconciliationAnnounceEntriesData{Range: utils.DefaultRange3d(types.SubspaceId("")), Count: 1},
+			},
+			wgpstypes.MsgReconciliationSendEntry[string]{
+				Kind: wgpstypes.ReconciliationSendEntry,
+				Data: wgpstypes.MsgReconciliationSendEntryData[string]{Entry: datamodeltypes.LengthyEntry{Entry: entry}},
+			},
+		}
+		messages = append(messages, payloadMessages...)
+		var err error
+		for _, msg := range messages {
+			if _, err = betty.HandleMessage(msg); err != nil {
+				break
+			}
+		}
+		if !errors.As(err, &wgpstypes.ProtocolViolationError{}) {
+			t.Errorf("%s: expected the payload to violate the protocol, got %v", name, err)
+		}
+		if available := storeBetty.PartialPayload(entry.Payload_digest); available != 0 {
+			t.Errorf("%s: expected betty not to store the payload, she holds %d bytes of it", name, available)
+		}
+	}
+}
+
+func TestEngineBindsEachStaticTokenOnce(t *testing.T) {
+	storeAlfie, storeBetty := newTestStore(t), newTestStore(t)
+	// The static token of an entry is its subspace
+	for i := 0; i < 30; i++ {
+		setEntry(t, storeAlfie, fmt.Sprintf("subspace%d", i%2), fmt.Sprintf("entry%02d", i), uint64(1000+i), fmt.Sprintf("payload %d", i))
+	}
+
+	alfie := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleAlfie,
+		Store:                    storeAlfie,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+	betty := NewEngine(EngineOpts[uint, string, string, []byte, string, string, string]{
+		Role:                     wgpstypes.SyncRoleBetty,
+		Store:                    storeBetty,
+		AuthorisationTokenScheme: pinagoladastore.TestAuthorisationTokenScheme,
+	})
+
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+	initial, err := alfie.AddAoiPair(0, 0, everything, everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	betty.AddAoiPair(0, 0, everything, everything)
+	runEngines(t, alfie, betty, initial)
+
+	if len(storeContents(t, storeBetty)) != 30 {
+		t.Fatalf("betty did not receive all entries")
+	}
+	if bound := betty.StaticTokensTheirs.LeastUnassignedHandle; bound != 2 {
+		t.Errorf("expected alfie to bind 2 static tokens, it bound %d", bound)
+	}
+
+	// A static token whose handle was freed is bound again
+	if err := alfie.StaticTokensOurs.Free(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, alreadyExisted := alfie.GetStaticTokenHandle("subspace1"); !alreadyExisted {
+		t.Errorf("the static token with a handle in use was bound again")
+	}
+	if handle, alreadyExisted := alfie.GetStaticTokenHandle("subspace0"); alreadyExisted || handle != 2 {
+		t.Errorf("expected the freed static token to be bound to handle 2, got %d", handle)
+	}
+}
+
+func TestReconcilerThresholds(t *testing.T) {
+	s := newTestStore(t)
+	for i := 0; i < 30; i++ {
+		setEntry(t, s, "subspace", fmt.Sprintf("entry%02d", i), uint64(1000+i), "payload")
+	}
+	everything := types.AreaOfInterest{Area: utils.FullArea()}
+
+	for _, tc := range []struct {
+		threshold uint64
+		split     int
+		announce  bool
+	}{
+		{threshold: 30, split: 2, announce: true},
+		{threshold: 29, split: 2},
+		{threshold: 8, split: 4},
+	} {
+		reconciler, err := NewReconciler(&ReconcilerOpts[string, string, uint, []byte, string]{
+			SubspaceScheme:       store.TestSubspaceScheme,
+			FingerPrintScheme:    store.TestFingerprintScheme,
+			AoiOurs:              everything,
+			AoiTheirs:            everything,
+			Store:                s,
+			SplitFactor:          tc.split,
+			SendEntriesThreshold: tc.threshold,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fingerprints, announcement := reconciler.Respond(reconciler.Range, store.TestFingerprintScheme.NeutralFinalised, 3)
+		if announcement.Announce != tc.announce {
+			t.Errorf("threshold %d: expected announcing to be %v", tc.threshold, tc.announce)
+		}
+		if tc.announce {
+			if !announcement.WantResponse || announcement.Covers != 3 {
+				t.Errorf("threshold %d: unexpected announcement %+v", tc.threshold, announcement)
+			}
+			continue
+		}
+		if len(fingerprints) != tc.split {
+			t.Fatalf("expected %d fingerprints, got %d", tc.split, len(fingerprints))
+		}
+		for i, fingerprint := range fingerprints {
+			isLast := i == len(fingerprints)-1
+			if fingerprint.Data.DoesCover != isLast || (isLast && fingerprint.Data.Covers != 3) {
+				t.Errorf("only the last fingerprint should cover the received range, got %+v", fingerprint.Data)
+			}
+		}
+
+		// The same fingerprint as ours is acknowledged with an empty announcement
+		_, announcement = reconciler.Respond(reconciler.Range, reconciler.Summarise(reconciler.Range).FingerPrint, 4)
+		if !announcement.Announce || announcement.WantResponse {
+			t.Errorf("equal fingerprints should not need a response, got %+v", announcement)
+		}
+	}
+}
@@ -1,6 +1,5 @@
 package reconciliation
 
-/*
 import (
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
@@ -16,6 +15,11 @@ type ReconcileMsgTrackerOpts struct {
 	AoiHandlesToRange3d func(senderAoiHandle, receiverAoiHandle uint64) types.Range3d
 }
 
+/*
+ReconcileMsgTracker keeps the state both peers need to agree on to encode reconciliation messages relative to
+the previous ones. The encoding side and the decoding side of a reconciliation channel each keep one, and feed it
+every message in the order it was sent.
+*/
 type ReconcileMsgTracker[FingerPrint string, DynamicToken string] struct {
 	PrevRange                 types.Range3d
 	PrevSenderHandle          uint64
@@ -62,7 +66,9 @@ func (r *ReconcileMsgTracker[FingerPrint, DynamicToken]) OnSendEntry(msg wgpstyp
 
 	r.PrevEntry = msg.Data.Entry.Entry
 	r.PrevToken = msg.Data.StaticTokenHandle
-	r.AnnouncedEntriesRemaining -= 1
+	if r.AnnouncedEntriesRemaining > 0 {
+		r.AnnouncedEntriesRemaining -= 1
+	}
 	r.IsAwaitingTermination = true
 
 }
@@ -76,10 +82,7 @@ func (r *ReconcileMsgTracker[FingerPrint, DynamicToken]) IsExpectingPayloadOrTer
 }
 
 func (r *ReconcileMsgTracker[FingerPrint, DynamicToken]) IsExpectingReconciliationSendEntry() bool {
-	if r.AnnouncedEntriesRemaining > 0 {
-		return true
-	}
-	return false
+	return r.AnnouncedEntriesRemaining > 0
 }
 
 func (r *ReconcileMsgTracker[FingerPrint, DynamicToken]) GetPrivy() wgpstypes.ReconciliationPrivy {
@@ -98,4 +101,4 @@ func (r *ReconcileMsgTracker[FingerPrint, DynamicToken]) GetPrivy() wgpstypes.Re
 			Namespace: r.AnnouncedNamespace,
 		},
 	}
-} */
+}
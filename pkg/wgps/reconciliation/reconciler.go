@@ -2,7 +2,6 @@ package reconciliation
 
 import (
 	"fmt"
-	"sync"
 
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
@@ -16,82 +15,101 @@ type ReconcilerOpts[
 	PreFingerPrint, FingerPrint string,
 	K constraints.Unsigned,
 	AuthorisationOpts []byte, AuthorisationToken string] struct {
-	Role              wgpstypes.SyncRole
-	SubspaceScheme    datamodeltypes.SubspaceScheme
-	FingerPrintScheme datamodeltypes.FingerprintScheme[PreFingerPrint, FingerPrint]
-	Namespace         types.NamespaceId
-	AoiOurs           types.AreaOfInterest
-	AoiTheirs         types.AreaOfInterest
-	Store             *store.Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]
+	Role                 wgpstypes.SyncRole
+	SubspaceScheme       datamodeltypes.SubspaceScheme
+	FingerPrintScheme    datamodeltypes.FingerprintScheme[PreFingerPrint, FingerPrint]
+	Namespace            types.NamespaceId
+	AoiOurs              types.AreaOfInterest
+	AoiTheirs            types.AreaOfInterest
+	AoiHandleOurs        uint64
+	AoiHandleTheirs      uint64
+	Store                *store.Store[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken]
+	SplitFactor          int    // Defaults to SPLIT_FACTOR
+	SendEntriesThreshold uint64 // Defaults to SEND_ENTRIES_THRESHOLD
 }
 
+// Ranges holding at most this many entries are reconciled by sending the entries instead of splitting further
 const SEND_ENTRIES_THRESHOLD = 8
 
+// Number of subranges a range is split into when its fingerprints do not match
+const SPLIT_FACTOR = 2
+
+/*
+Reconciler runs 3d range-based set reconciliation over the intersection of one of our areas of interest
+with one of theirs. It only decides what to say about a range; binding static tokens, transmitting entries
+and keeping count of the messages of a session is up to the Engine.
+*/
 type Reconciler[
 	K constraints.Unsigned,
 	PreFingerprint, Fingerprint string, AuthorisationOpts []byte, AuthorisationToken string] struct {
-	SubspaceScheme    datamodeltypes.SubspaceScheme
-	FingerprintScheme datamodeltypes.FingerprintScheme[PreFingerprint, Fingerprint]
-	Store             *store.Store[PreFingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken]
-	FingerPrintQueue  chan struct {
-		Range       types.Range3d
-		FingerPrint Fingerprint
-		Covers      uint64
-	}
-	AnnounceQueue chan struct {
-		Range        types.Range3d
-		Count        int
-		WantResponse bool
-		Covers       uint64
-	}
-	Ranges chan types.Range3d
+	Role                 wgpstypes.SyncRole
+	SubspaceScheme       datamodeltypes.SubspaceScheme
+	FingerprintScheme    datamodeltypes.FingerprintScheme[PreFingerprint, Fingerprint]
+	Store                *store.Store[PreFingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken]
+	Namespace            types.NamespaceId
+	AoiHandleOurs        uint64
+	AoiHandleTheirs      uint64
+	SplitFactor          int
+	SendEntriesThreshold uint64
+	// The intersection of the areas of both areas of interest, which both peers agree on
+	Range types.Range3d
+	// The part of Range within the MaxCount and MaxSize of both areas of interest in our store, the entries we send
+	Interest types.Range3d
+	// Whether the other peer wants the payloads within the intersection sent along with the entries, until it says otherwise
+	Eager bool
 }
 
 func NewReconciler[PreFingerPrint, FingerPrint string,
 	K constraints.Unsigned, AuthorisationOpts []byte, AuthorisationToken string](opts *ReconcilerOpts[PreFingerPrint, FingerPrint, K, AuthorisationOpts, AuthorisationToken],
-) *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken] {
+) (*Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken], error) {
 
 	newReconciler := &Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]{
-		SubspaceScheme:    opts.SubspaceScheme,
-		FingerprintScheme: opts.FingerPrintScheme,
-		Store:             opts.Store,
-		FingerPrintQueue: make(chan struct {
-			Range       types.Range3d
-			FingerPrint FingerPrint
-			Covers      uint64
-		}, SEND_ENTRIES_THRESHOLD),
-		AnnounceQueue: make(chan struct {
-			Range        types.Range3d
-			Count        int
-			WantResponse bool
-			Covers       uint64
-		}, SEND_ENTRIES_THRESHOLD),
-
-		Ranges: make(chan types.Range3d, 100),
+		Role:                 opts.Role,
+		SubspaceScheme:       opts.SubspaceScheme,
+		FingerprintScheme:    opts.FingerPrintScheme,
+		Store:                opts.Store,
+		Namespace:            opts.Namespace,
+		AoiHandleOurs:        opts.AoiHandleOurs,
+		AoiHandleTheirs:      opts.AoiHandleTheirs,
+		SplitFactor:          opts.SplitFactor,
+		SendEntriesThreshold: opts.SendEntriesThreshold,
+		Eager:                true,
 	}
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		newReconciler.DetermineRange(opts.AoiOurs, opts.AoiTheirs, opts.Role)
-	}()
-	if wgpstypes.IsAlfie(opts.Role) {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			newReconciler.Initiate()
-		}()
-		wg.Wait()
+	if newReconciler.SplitFactor == 0 {
+		newReconciler.SplitFactor = SPLIT_FACTOR
+	}
+	if newReconciler.SendEntriesThreshold == 0 {
+		newReconciler.SendEntriesThreshold = SEND_ENTRIES_THRESHOLD
+	}
+
+	intersection, err := newReconciler.DetermineRange(
+		types.AreaOfInterest{Area: opts.AoiOurs.Area},
+		types.AreaOfInterest{Area: opts.AoiTheirs.Area},
+	)
+	if err != nil {
+		return nil, err
+	}
+	newReconciler.Range = intersection
+
+	interest, err := newReconciler.DetermineRange(opts.AoiOurs, opts.AoiTheirs)
+	if err != nil {
+		// None of our entries are within the limits of both
+		interest = intersection
+		interest.TimeRange = types.Range[uint64]{Start: intersection.TimeRange.Start, End: intersection.TimeRange.Start}
 	}
-	return newReconciler
+	newReconciler.Interest = interest
+	return newReconciler, nil
 }
 
-func (r Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) DetermineRange(
-	aoi1, aoi2 types.AreaOfInterest, role wgpstypes.SyncRole,
-) error {
+func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) DetermineRange(
+	aoi1, aoi2 types.AreaOfInterest,
+) (types.Range3d, error) {
 	// Remove the interest from both.
-	range1, _ := (*r.Store).AreaOfInterestToRange(aoi1)
-	range2, _ := r.Store.AreaOfInterestToRange(aoi2)
+	var range1, range2 types.Range3d
+	r.Store.ReadEntries(func() {
+		range1, _ = r.Store.AreaOfInterestToRange(aoi1)
+		range2, _ = r.Store.AreaOfInterestToRange(aoi2)
+	})
 
 	isIntersecting, intersection := utils.IntersectRange3d(
 		r.SubspaceScheme.Order,
@@ -100,132 +118,119 @@ func (r Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, Authorisat
 	)
 
 	if !isIntersecting {
-		return fmt.Errorf("There was no intersection between two range-ified AOIs. That shouldn't happen...")
-	}
-	if wgpstypes.IsAlfie(role) {
-		r.Ranges <- intersection
+		return types.Range3d{}, fmt.Errorf("there was no intersection between two range-ified AOIs")
 	}
-	return nil
+	return intersection, nil
+}
+
+// The fingerprint of the intersection within the limits of both areas of interest, which alfie sends to start reconciling
+func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) Initiate() wgpstypes.MsgReconciliationSendFingerprint[FingerPrint] {
+	return r.fingerprintMessage(r.Interest, r.Summarise(r.Interest).FingerPrint, 0, false)
 }
 
-func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) Initiate() {
-	// Initialize the reconciliation process.
-	intersection := <-r.Ranges
-	// TODO : Implement Summarise function in store
+// Whether an entry of ours is within the limits of both areas of interest, so that we send it
+func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) IsOfInterest(entry types.Entry) bool {
+	return utils.IsIncluded3d(r.SubspaceScheme.Order, r.Interest, utils.EntryPosition(entry))
+}
 
-	preFingerprint := r.Store.EntryDriver.Storage.Summarise(intersection)
-	finalised := r.FingerprintScheme.FingerPrintFinalise(PreFingerPrint(preFingerprint.FingerPrint))
-	r.FingerPrintQueue <- struct {
-		Range       types.Range3d
+// Summarises a range of our store, with the fingerprint finalised
+func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) Summarise(yourRange types.Range3d) struct {
+	FingerPrint FingerPrint
+	Size        uint64
+} {
+	var preFingerprint PreFingerPrint
+	var size uint64
+	r.Store.ReadEntries(func() {
+		summary := r.Store.EntryDriver.Storage.Summarise(yourRange)
+		preFingerprint, size = PreFingerPrint(summary.FingerPrint), summary.Size
+	})
+	return struct {
 		FingerPrint FingerPrint
-		Covers      uint64
+		Size        uint64
 	}{
-		Range:       intersection,
-		FingerPrint: finalised,
+		FingerPrint: r.FingerprintScheme.FingerPrintFinalise(preFingerprint),
+		Size:        size,
 	}
 }
 
+/*
+Responds to a fingerprint of the other peer, yourRangeCounter is the number we assigned to their message.
+Either both peers agree on the range, which is acknowledged with an empty announcement, the range is small
+enough (or cannot be split) so we announce our entries and ask for theirs, or the range is split up and the
+fingerprints of the parts are returned, the last of which covers the range we were sent.
+*/
 func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) Respond(
 	yourRange types.Range3d,
 	fingerprint FingerPrint,
-	yourRangeCounter int,
+	yourRangeCounter uint64,
 
-) (struct {
-	Range       types.Range3d
-	FingerPrint FingerPrint
-	Covers      uint64
-}, struct {
-	Range       types.Range3d
-	FingerPrint FingerPrint
-	Covers      uint64
-}, struct {
+) ([]wgpstypes.MsgReconciliationSendFingerprint[FingerPrint], struct {
+	Announce     bool
 	WantResponse bool
 	Range        types.Range3d
+	Covers       uint64
 }) {
-	// TODO Implement Summarise function in store
-	ourFingerprint := r.Store.EntryDriver.Storage.Summarise(yourRange)
+	type announcement = struct {
+		Announce     bool
+		WantResponse bool
+		Range        types.Range3d
+		Covers       uint64
+	}
+
+	ourFingerprint := r.Summarise(yourRange)
 	size := ourFingerprint.Size
-	fingerprintOursFinal := r.FingerprintScheme.FingerPrintFinalise(PreFingerPrint(ourFingerprint.FingerPrint))
-	if r.FingerprintScheme.IsEqual(fingerprint, fingerprintOursFinal) {
-		return struct {
-				Range       types.Range3d
-				FingerPrint FingerPrint
-				Covers      uint64
-			}{}, struct {
-				Range       types.Range3d
-				FingerPrint FingerPrint
-				Covers      uint64
-			}{}, struct {
-				WantResponse bool
-				Range        types.Range3d
-			}{
-				WantResponse: false,
-				Range:        types.Range3d{},
-			}
-	} else if size <= SEND_ENTRIES_THRESHOLD {
-		return struct {
-				Range       types.Range3d
-				FingerPrint FingerPrint
-				Covers      uint64
-			}{}, struct {
-				Range       types.Range3d
-				FingerPrint FingerPrint
-				Covers      uint64
-			}{}, struct {
-				WantResponse bool
-				Range        types.Range3d
-			}{
-				WantResponse: true,
-				Range:        yourRange,
-			}
-	} else {
-		// TODO: Implement Store Split Range
-		left, right := r.Store.EntryDriver.Storage.SplitRange(yourRange, int(size))
-		fingerprintLeftFinal := r.FingerprintScheme.FingerPrintFinalise(PreFingerPrint(r.Store.EntryDriver.Storage.Summarise(left).FingerPrint)) //Most readable code in Willow-Go
-		fingerprintRightFinal := r.FingerprintScheme.FingerPrintFinalise(PreFingerPrint(r.Store.EntryDriver.Storage.Summarise(right).FingerPrint))
-
-		return struct {
-				Range       types.Range3d
-				FingerPrint FingerPrint
-				Covers      uint64 
-			}{
-				Range:       left,
-				FingerPrint: fingerprintLeftFinal,
-			}, struct {
-				Range       types.Range3d
-				FingerPrint FingerPrint
-				Covers      uint64
-			}{
-				Range:       right,
-				FingerPrint: fingerprintRightFinal,
-				Covers:      uint64(yourRangeCounter),
-			}, struct {
-				WantResponse bool
-				Range        types.Range3d
-			}{
-				WantResponse: false,
-				Range:        types.Range3d{},
-			}
+	if r.FingerprintScheme.IsEqual(fingerprint, ourFingerprint.FingerPrint) {
+		return nil, announcement{
+			Announce:     true,
+			WantResponse: false,
+			Range:        yourRange,
+			Covers:       yourRangeCounter,
+		}
 	}
-}
 
-func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) announcements() chan struct {
-	Range        types.Range3d
-	Count        int
-	WantResponse bool
-	Covers       uint64
-} {
-	out := make(chan struct {
-		Range        types.Range3d
-		Count        int
-		WantResponse bool
-		Covers       uint64
-	})
-	go func() {
-		defer close(out)
-		for details := range r.AnnounceQueue {
-			out <- details
+	var parts []types.Range3d
+	if size > r.SendEntriesThreshold {
+		r.Store.ReadEntries(func() {
+			parts = r.Store.EntryDriver.Storage.SplitRange(yourRange, int(size), r.SplitFactor)
+		})
+	}
+	if len(parts) < 2 {
+		// Either small enough to send the entries, or there is no way to split the range any further
+		return nil, announcement{
+			Announce:     true,
+			WantResponse: true,
+			Range:        yourRange,
+			Covers:       yourRangeCounter,
 		}
-	}()
-	return out
+	}
+
+	fingerprints := make([]wgpstypes.MsgReconciliationSendFingerprint[FingerPrint], len(parts))
+	for i, part := range parts {
+		// The last of the subranges covers the range we were sent
+		isLast := i == len(parts)-1
+		fingerprints[i] = r.fingerprintMessage(part, r.Summarise(part).FingerPrint, yourRangeCounter, isLast)
+	}
+	return fingerprints, announcement{}
+}
+
+func (r *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) fingerprintMessage(
+	yourRange types.Range3d,
+	fingerprint FingerPrint,
+	covers uint64,
+	doesCover bool,
+) wgpstypes.MsgReconciliationSendFingerprint[FingerPrint] {
+	if !doesCover {
+		covers = 0
+	}
+	return wgpstypes.MsgReconciliationSendFingerprint[FingerPrint]{
+		Kind: wgpstypes.ReconciliationSendFingerprint,
+		Data: wgpstypes.MsgReconciliationSendFingerprintData[FingerPrint]{
+			Range:          yourRange,
+			Fingerprint:    fingerprint,
+			SenderHandle:   r.AoiHandleOurs,
+			ReceiverHandle: r.AoiHandleTheirs,
+			Covers:         covers,
+			DoesCover:      doesCover,
+		},
+	}
 }
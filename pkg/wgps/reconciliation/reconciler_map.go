@@ -7,34 +7,36 @@ import (
 )
 
 type ReconcilerMap[K constraints.Unsigned, PreFingerPrint, FingerPrint string, AuthorisationOpts []byte, AuthorisationToken string] struct {
-	Map map[uint64]map[uint64]Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]
+	Map map[uint64]map[uint64]*Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]
+}
+
+func NewReconcilerMap[K constraints.Unsigned, PreFingerPrint, FingerPrint string, AuthorisationOpts []byte, AuthorisationToken string]() *ReconcilerMap[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken] {
+	return &ReconcilerMap[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]{
+		Map: make(map[uint64]map[uint64]*Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]),
+	}
 }
 
 func (r *ReconcilerMap[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) AddReconciler(
-	aoiHandleOurs, aoiHandleTheirs uint64, reconciler Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken],
+	aoiHandleOurs, aoiHandleTheirs uint64, reconciler *Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken],
 ) {
-	existingInnerMap, ok := r.Map[aoiHandleOurs]
-	if ok {
-		existingInnerMap[aoiHandleTheirs] = reconciler
+	innerMap, ok := r.Map[aoiHandleOurs]
+	if !ok {
+		innerMap = make(map[uint64]*Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken])
+		r.Map[aoiHandleOurs] = innerMap
 	}
-	newInnerMap := make(map[uint64]Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken])
-	newInnerMap[aoiHandleTheirs] = reconciler
-	r.Map[aoiHandleOurs] = newInnerMap
-
+	innerMap[aoiHandleTheirs] = reconciler
 }
 
 func (r *ReconcilerMap[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]) GetReconciler(
 	aoiHandleOurs, aoiHandleTheirs uint64,
-) (re Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken], err error) {
+) (*Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken], error) {
 	innerMap, ok := r.Map[aoiHandleOurs]
-	var reconciler Reconciler[K, PreFingerPrint, FingerPrint, AuthorisationOpts, AuthorisationToken]
 	if !ok {
-
-		return reconciler, fmt.Errorf("Could not dereference one of our AOI handles to a reconciler")
+		return nil, fmt.Errorf("could not dereference one of our AOI handles to a reconciler")
 	}
-	reconciler, ok = innerMap[aoiHandleTheirs]
+	reconciler, ok := innerMap[aoiHandleTheirs]
 	if !ok {
-		return reconciler, fmt.Errorf("Could not dereference one of their AOI handles to a reconciler")
+		return nil, fmt.Errorf("could not dereference one of their AOI handles to a reconciler")
 	}
 	return reconciler, nil
 }
@@ -0,0 +1,273 @@
+package wgps
+
+import (
+	"sync"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+// The order in which the logical channels take turns sending, first their other messages and then their bulk data
+var sendPriority = []wgpstypes.Channel{
+	wgpstypes.ControlChannel,
+	wgpstypes.IntersectionChannel,
+	wgpstypes.CapabilityChannel,
+	wgpstypes.AreaOfInterestChannel,
+	wgpstypes.StaticTokenChannel,
+	wgpstypes.PayloadRequestChannel,
+	wgpstypes.ReconciliationChannel,
+	wgpstypes.DataChannel,
+}
+
+type SessionLimits struct {
+	UploadRate   int // Bytes per second a session sends at most, unlimited when 0
+	DownloadRate int // Bytes per second a session receives at most, unlimited when 0
+	// Bytes per second all sessions given the same limiters send and receive at most together, unlimited when nil
+	GlobalUpload   *RateLimiter
+	GlobalDownload *RateLimiter
+	// How many of the sessions given the same limiter send payloads at a time, unlimited when nil
+	PayloadTransfers *TransferLimiter
+}
+
+/*
+RateLimiter lets a number of bytes per second through, which may be used up at once after a second of not being
+used. Messages are never split, a message longer than what is left is let through once the bytes it took too many
+have been made up for. A nil RateLimiter lets everything through.
+*/
+type RateLimiter struct {
+	Rate int
+
+	mu sync.Mutex
+	// Bytes which may be let through right away, negative when more was let through than the rate allows so far
+	available float64
+	updated   time.Time
+}
+
+// A limiter of the given bytes per second, which is nil when the rate is not positive
+func NewRateLimiter(bytesPerSecond int) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		Rate:      bytesPerSecond,
+		available: float64(bytesPerSecond),
+		updated:   time.Now(),
+	}
+}
+
+// Takes some bytes, returning how long to wait before they may go through
+func (l *RateLimiter) take(bytes int) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.available = min(l.available+now.Sub(l.updated).Seconds()*float64(l.Rate), float64(l.Rate))
+	l.updated = now
+	l.available -= float64(bytes)
+	if l.available >= 0 {
+		return 0
+	}
+	return time.Duration(-l.available / float64(l.Rate) * float64(time.Second))
+}
+
+/*
+Waits until some bytes may go through all of the given limiters, returning false when done is closed first. The
+bytes are taken from the limiters right away, so that whoever comes next waits for them as well.
+*/
+func waitForRate(bytes int, done <-chan struct{}, limiters ...*RateLimiter) bool {
+	var wait time.Duration
+	for _, limiter := range limiters {
+		wait = max(wait, limiter.take(bytes))
+	}
+	if wait == 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Takes some bytes from the given limiters without waiting, which whoever comes next waits for instead
+func takeRate(bytes int, limiters ...*RateLimiter) {
+	for _, limiter := range limiters {
+		limiter.take(bytes)
+	}
+}
+
+// TransferLimiter bounds how many sessions send payloads at a time. A nil TransferLimiter bounds nothing
+type TransferLimiter struct {
+	// Holds a value for every session sending payloads
+	slots chan struct{}
+}
+
+// A limiter of the given number of transfers, which is nil when the number is not positive
+func NewTransferLimiter(transfers int) *TransferLimiter {
+	if transfers <= 0 {
+		return nil
+	}
+	return &TransferLimiter{slots: make(chan struct{}, transfers)}
+}
+
+/*
+sendScheduler decides which of the messages of a session is sent next. Messages wait in the scheduler once the other
+peer guaranteed to have room for them, so they never wait for long, and are taken by the logical channel they are
+for in the order of sendPriority. A channel gets no more messages while it is still sending the last one, so a channel
+which is slow to send holds up no other.
+
+Messages carrying payloads are bulk data whatever their channel, eager payloads going out on the reconciliation
+channel as well. They are only taken once no other message is ready, and only while the session holds a place among
+the ones sending payloads.
+*/
+type sendScheduler struct {
+	mu      sync.Mutex
+	pending map[wgpstypes.Channel][]QueuedMessage
+	// How many of the pending messages are bulk data
+	bulk int
+	// The channels still sending the last message they took
+	sending map[wgpstypes.Channel]bool
+	// Signalled whenever a message is pushed or a channel is done sending
+	wake chan struct{}
+
+	transfers *TransferLimiter
+	// Whether the session holds a place among the ones sending payloads, until it has no bulk data left to send
+	transferring bool
+}
+
+func newSendScheduler(transfers *TransferLimiter) *sendScheduler {
+	return &sendScheduler{
+		pending:   make(map[wgpstypes.Channel][]QueuedMessage),
+		sending:   make(map[wgpstypes.Channel]bool),
+		wake:      make(chan struct{}, 1),
+		transfers: transfers,
+	}
+}
+
+func (s *sendScheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Queues a message which may be sent as soon as it is its turn
+func (s *sendScheduler) Push(bytes []byte, channel wgpstypes.Channel) {
+	s.push(QueuedMessage{Bytes: bytes}, channel)
+}
+
+// Queues a message carrying (a part of) a payload, which is sent as bulk data
+func (s *sendScheduler) PushBulk(bytes []byte, channel wgpstypes.Channel) {
+	s.push(QueuedMessage{Bytes: bytes, Bulk: true}, channel)
+}
+
+func (s *sendScheduler) push(message QueuedMessage, channel wgpstypes.Channel) {
+	s.mu.Lock()
+	s.pending[channel] = append(s.pending[channel], message)
+	if message.Bulk {
+		s.bulk++
+	}
+	s.mu.Unlock()
+	s.signal()
+}
+
+// The channel is done sending the last message it took, and may take the next one
+func (s *sendScheduler) Sent(channel wgpstypes.Channel) {
+	s.mu.Lock()
+	s.sending[channel] = false
+	s.mu.Unlock()
+	s.signal()
+}
+
+/*
+Waits for the next message to send, which the channel it is for has to report as sent before it gets another one.
+Returns false once done is closed.
+*/
+func (s *sendScheduler) Next(done <-chan struct{}) ([]byte, wgpstypes.Channel, bool) {
+	for {
+		s.mu.Lock()
+		bytes, channel, found := s.take()
+		// Bulk data waits for a place among the sessions sending payloads, while the other messages go ahead
+		_, bulkReady := s.ready(true)
+		waitForTransfer := !found && s.transfers != nil && !s.transferring && bulkReady
+		s.mu.Unlock()
+		if found {
+			return bytes, channel, true
+		}
+
+		var transfer chan struct{}
+		if waitForTransfer {
+			transfer = s.transfers.slots
+		}
+		select {
+		case <-s.wake:
+		case transfer <- struct{}{}:
+			s.mu.Lock()
+			s.transferring = true
+			s.mu.Unlock()
+		case <-done:
+			return nil, 0, false
+		}
+	}
+}
+
+// Takes the next message to send, bulk data only once no other message is ready
+func (s *sendScheduler) take() ([]byte, wgpstypes.Channel, bool) {
+	if s.transfers != nil && s.bulk == 0 {
+		// The payloads sent so far are through, which makes room for another session
+		s.endTransfer()
+	}
+	channel, found := s.ready(false)
+	if !found {
+		channel, found = s.ready(true)
+		if !found {
+			return nil, 0, false
+		}
+		if s.transfers != nil && !s.transferring {
+			select {
+			case s.transfers.slots <- struct{}{}:
+				s.transferring = true
+			default:
+				return nil, 0, false
+			}
+		}
+	}
+
+	message := s.pending[channel][0]
+	s.pending[channel] = s.pending[channel][1:]
+	if message.Bulk {
+		s.bulk--
+	}
+	s.sending[channel] = true
+	return message.Bytes, channel, true
+}
+
+// The channel first in sendPriority which is not sending and has a message of the given kind next in line
+func (s *sendScheduler) ready(bulk bool) (wgpstypes.Channel, bool) {
+	for _, channel := range sendPriority {
+		queue := s.pending[channel]
+		if !s.sending[channel] && len(queue) > 0 && queue[0].Bulk == bulk {
+			return channel, true
+		}
+	}
+	return 0, false
+}
+
+// Gives up the place of the session among the ones sending payloads, once it sends nothing anymore
+func (s *sendScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endTransfer()
+}
+
+func (s *sendScheduler) endTransfer() {
+	if s.transferring {
+		<-s.transfers.slots
+		s.transferring = false
+	}
+}
@@ -0,0 +1,173 @@
+package wgps
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+func TestSchedulerSendsControlAndReconciliationAheadOfData(t *testing.T) {
+	scheduler := newSendScheduler(nil)
+	done := make(chan struct{})
+	scheduler.PushBulk([]byte("chunk 1"), wgpstypes.DataChannel)
+	scheduler.PushBulk([]byte("chunk 2"), wgpstypes.DataChannel)
+	scheduler.Push([]byte("fingerprint"), wgpstypes.ReconciliationChannel)
+	scheduler.Push([]byte("guarantee"), wgpstypes.ControlChannel)
+
+	var order []string
+	for range 3 {
+		bytes, _, ok := scheduler.Next(done)
+		if !ok {
+			t.Fatal("expected a message to send")
+		}
+		order = append(order, string(bytes))
+	}
+	// The data channel still sends its first chunk, and gets the next one once it is done
+	close(done)
+	if _, _, ok := scheduler.Next(done); ok {
+		t.Error("expected a channel not to get another message while it sends the last one")
+	}
+	scheduler.Sent(wgpstypes.DataChannel)
+	bytes, _, _ := scheduler.Next(make(chan struct{}))
+	order = append(order, string(bytes))
+
+	if strings.Join(order, ", ") != "guarantee, fingerprint, chunk 1, chunk 2" {
+		t.Errorf("expected control and reconciliation messages to go ahead of data, sent %v", order)
+	}
+}
+
+func TestTransferLimiterLetsSessionsSendPayloadsInTurn(t *testing.T) {
+	transfers := NewTransferLimiter(1)
+	alfie, betty := newSendScheduler(transfers), newSendScheduler(transfers)
+	alfie.PushBulk([]byte("alfie's payload"), wgpstypes.DataChannel)
+	betty.PushBulk([]byte("betty's payload"), wgpstypes.DataChannel)
+	betty.Push([]byte("betty's fingerprint"), wgpstypes.ReconciliationChannel)
+
+	if bytes, _, _ := alfie.Next(make(chan struct{})); string(bytes) != "alfie's payload" {
+		t.Fatalf("expected alfie to send her payload, she sends %q", bytes)
+	}
+	// Betty goes on reconciling while alfie sends her payload
+	if bytes, _, _ := betty.Next(make(chan struct{})); string(bytes) != "betty's fingerprint" {
+		t.Fatalf("expected betty to send her fingerprint, she sends %q", bytes)
+	}
+	betty.Sent(wgpstypes.ReconciliationChannel)
+	bettyNext := make(chan string, 1)
+	go func() {
+		bytes, _, _ := betty.Next(make(chan struct{}))
+		bettyNext <- string(bytes)
+	}()
+	select {
+	case bytes := <-bettyNext:
+		t.Fatalf("expected betty to wait for alfie to send her payload, she sends %q", bytes)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Alfie makes room for betty once she has no payloads left to send
+	alfie.Sent(wgpstypes.DataChannel)
+	alfieDone := make(chan struct{})
+	close(alfieDone)
+	alfie.Next(alfieDone)
+	select {
+	case bytes := <-bettyNext:
+		if bytes != "betty's payload" {
+			t.Errorf("expected betty to send her payload, she sends %q", bytes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for betty to send her payload")
+	}
+}
+
+func TestSchedulerSendsEagerPayloadsAsBulkData(t *testing.T) {
+	transfers := NewTransferLimiter(1)
+	alfie, betty := newSendScheduler(transfers), newSendScheduler(transfers)
+	alfie.PushBulk([]byte("alfie's payload"), wgpstypes.DataChannel)
+	if bytes, _, _ := alfie.Next(make(chan struct{})); string(bytes) != "alfie's payload" {
+		t.Fatalf("expected alfie to send her payload, she sends %q", bytes)
+	}
+
+	// A large eager payload of betty goes after her messages on the other channels
+	for range 256 {
+		betty.PushBulk(make([]byte, 4096), wgpstypes.ReconciliationChannel)
+	}
+	betty.Push([]byte("fingerprint"), wgpstypes.ReconciliationChannel)
+	betty.Push([]byte("guarantee"), wgpstypes.ControlChannel)
+	betty.Push([]byte("area of interest"), wgpstypes.AreaOfInterestChannel)
+	var order []string
+	for range 2 {
+		bytes, channel, _ := betty.Next(make(chan struct{}))
+		order = append(order, string(bytes))
+		betty.Sent(channel)
+	}
+	if strings.Join(order, ", ") != "guarantee, area of interest" {
+		t.Errorf("expected the control messages to go ahead of the payload, sent %v", order)
+	}
+
+	// The payload waits for alfie to make room among the sessions sending payloads, while control messages go ahead
+	bettyNext := make(chan []byte, 1)
+	go func() {
+		bytes, _, _ := betty.Next(make(chan struct{}))
+		bettyNext <- bytes
+	}()
+	select {
+	case bytes := <-bettyNext:
+		t.Fatalf("expected betty to wait for alfie to send her payload, she sends %q", bytes)
+	case <-time.After(50 * time.Millisecond):
+	}
+	betty.Push([]byte("absolve"), wgpstypes.ControlChannel)
+	select {
+	case bytes := <-bettyNext:
+		if string(bytes) != "absolve" {
+			t.Fatalf("expected betty to send her control message, she sends %q", bytes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for betty to send her control message")
+	}
+	betty.Sent(wgpstypes.ControlChannel)
+
+	alfie.Sent(wgpstypes.DataChannel)
+	alfieDone := make(chan struct{})
+	close(alfieDone)
+	alfie.Next(alfieDone)
+	if bytes, channel, _ := betty.Next(make(chan struct{})); channel != wgpstypes.ReconciliationChannel || len(bytes) != 4096 {
+		t.Errorf("expected betty to send her payload once alfie is done, she sends %q", bytes)
+	}
+}
+
+func TestRateLimiterLetsThroughItsRate(t *testing.T) {
+	limiter := NewRateLimiter(1000)
+	if wait := limiter.take(1000); wait != 0 {
+		t.Errorf("expected a second of bytes to go through at once, waited %v", wait)
+	}
+	if wait := limiter.take(500); wait < 450*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("expected half a second of bytes too many to wait about half a second, waited %v", wait)
+	}
+	if NewRateLimiter(0) != nil || NewRateLimiter(0).take(1<<20) != 0 {
+		t.Error("expected a limiter without a rate to let everything through")
+	}
+}
+
+func TestSessionsSyncWithinTheirUploadRate(t *testing.T) {
+	payload := strings.Repeat("a payload which takes a while at a low rate ", 1000)
+	alfieMessenger, alfieStore := newTestMessenger(t, nil)
+	bettyMessenger, _ := newTestMessenger(t, map[string]string{"slow": payload})
+	bettyMessenger.Limits = SessionLimits{UploadRate: 20000}
+
+	started := time.Now()
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	if err := bettyMessenger.AcceptOver(bettyTransport); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieMessenger.InitiateOver(alfieTransport); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "alfie to receive the payload", func() bool {
+		return alfieStore.AvailablePayload(<-alfieMessenger.Schemes.Payload.FromBytes([]byte(payload))) == uint64(len(payload))
+	})
+	// A second of bytes goes through at once, the rest at the rate
+	if elapsed, least := time.Since(started), time.Duration(len(payload)-20000)*time.Second/20000; elapsed < least {
+		t.Errorf("expected sending %d bytes at 20000 bytes per second to take at least %v, took %v", len(payload), least, elapsed)
+	}
+}
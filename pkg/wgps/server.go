@@ -0,0 +1,645 @@
+package wgps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/discovery"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"golang.org/x/exp/constraints"
+)
+
+type ServerOpts[
+	ReadCapability any,
+	Receiver types.SubspaceId,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup any,
+	PsiScalar any,
+	SubspaceCapability any,
+	SubspaceReceiver types.SubspaceId,
+	SyncSubspaceSignature,
+	SubspaceSecretKey any,
+	Prefingerprint,
+	Fingerprint string,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken string,
+	AuthorisationOpts []byte,
+	K constraints.Unsigned,
+] struct {
+	// The options every session is set up with
+	Messenger WgpsMessengerOpts[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]
+	// The store every session syncs, entries one peer brings are forwarded to the others
+	Store store.Store[Prefingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken]
+
+	MaxPeers           int // Most sessions at a time, unlimited when 0
+	MaxSessionsPerHost int // Most sessions at a time with peers connecting from the same host, unlimited when 0
+}
+
+/*
+Server keeps accepting peers from a listener, and syncs its store with every one of them in a session of its own.
+A session ends when its peer disconnects or the server is closed, which frees its place for other peers.
+The server can connect to peers itself as well, such as the ones found on the local network.
+*/
+type Server[
+	ReadCapability any,
+	Receiver types.SubspaceId,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup any,
+	PsiScalar any,
+	SubspaceCapability any,
+	SubspaceReceiver types.SubspaceId,
+	SyncSubspaceSignature,
+	SubspaceSecretKey any,
+	Prefingerprint,
+	Fingerprint string,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken string,
+	AuthorisationOpts []byte,
+	K constraints.Unsigned,
+] struct {
+	Opts ServerOpts[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]
+	Listener wgpstypes.Listener
+
+	mu sync.Mutex
+	// The messengers of the sessions going on, with the peer they are with
+	sessions map[*WgpsMessenger[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]]serverPeer
+	hostSessions map[string]int
+	// Sessions being set up, which take up their place among MaxPeers before they are among the sessions
+	pending int
+	// The addresses of the peers we connected to, which we do not connect to again while we sync with them
+	dialed map[string]bool
+	closed bool
+	ended  sync.WaitGroup
+}
+
+// Makes a server accepting peers on the listener. The store has to be made by its constructor, see ErrStoreWithoutListeners and ErrStoreWithoutLock.
+func NewServer[
+	ReadCapability any,
+	Receiver types.SubspaceId,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup any,
+	PsiScalar any,
+	SubspaceCapability any,
+	SubspaceReceiver types.SubspaceId,
+	SyncSubspaceSignature,
+	SubspaceSecretKey any,
+	Prefingerprint,
+	Fingerprint string,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken string,
+	AuthorisationOpts []byte,
+	K constraints.Unsigned,
+](
+	opts ServerOpts[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	],
+	listener wgpstypes.Listener,
+) (*Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+], error) {
+	// Copies of the store only share the entries ingested into them when they share their listeners, and only take turns ingesting when they share their lock
+	if opts.Store.Listeners == nil {
+		return nil, ErrStoreWithoutListeners
+	}
+	if opts.Store.IngestionMutexLock == nil {
+		return nil, ErrStoreWithoutLock
+	}
+	// The sessions connecting to peers prove the same identity, and remember the peers they trusted on first use together
+	if opts.Messenger.Identity == nil {
+		identity, err := transport.NewIdentity()
+		if err != nil {
+			return nil, fmt.Errorf("could not create an identity for the server: %w", err)
+		}
+		opts.Messenger.Identity = identity
+	}
+	if opts.Messenger.PeerTrust == nil {
+		opts.Messenger.PeerTrust = &transport.KnownPeers{}
+	}
+	return &Server[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]{
+		Opts:     opts,
+		Listener: listener,
+		sessions: make(map[*WgpsMessenger[
+			ReadCapability,
+			Receiver,
+			SyncSignature,
+			ReceiverSecretKey,
+			PsiGroup,
+			PsiScalar,
+			SubspaceCapability,
+			SubspaceReceiver,
+			SyncSubspaceSignature,
+			SubspaceSecretKey,
+			Prefingerprint,
+			Fingerprint,
+			AuthorisationToken,
+			StaticToken,
+			DynamicToken,
+			AuthorisationOpts,
+			K,
+		]]serverPeer),
+		hostSessions: make(map[string]int),
+		dialed:       make(map[string]bool),
+	}, nil
+}
+
+// The peer of a session of the server
+type serverPeer struct {
+	Host string
+	// The address we connected to the peer at, empty when the peer connected to us
+	Dialed string
+}
+
+// Accepts peers until the server is closed, which is not an error, or until the listener fails
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Serve() error {
+	for {
+		peerTransport, addr, err := s.Listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		peerAddr := ""
+		if addr != nil {
+			peerAddr = addr.String()
+		}
+		if err := s.startSession(wgpstypes.SyncRoleBetty, peerTransport, peerAddr, nil); err != nil {
+			log.Printf("could not sync with %v: %v", addr, err)
+			peerTransport.Close()
+		}
+	}
+}
+
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) startSession(role wgpstypes.SyncRole, peerTransport wgpstypes.Transport, addr string, trust transport.PeerTrust) error {
+	peer := serverPeer{Host: peerHost(addr)}
+	host := peer.Host
+	// Without a transport we connect to the peer ourselves
+	if peerTransport == nil {
+		peer.Dialed = addr
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return fmt.Errorf("the server is closed")
+	}
+	if peer.Dialed != "" && s.dialed[addr] {
+		s.mu.Unlock()
+		return fmt.Errorf("%w at %v", ErrAlreadyConnected, addr)
+	}
+	if peers := len(s.sessions) + s.pending; s.Opts.MaxPeers > 0 && peers >= s.Opts.MaxPeers {
+		s.mu.Unlock()
+		return fmt.Errorf("already syncing with %v peers", peers)
+	}
+	if hostPeers := s.hostSessions[host]; s.Opts.MaxSessionsPerHost > 0 && hostPeers >= s.Opts.MaxSessionsPerHost {
+		s.mu.Unlock()
+		return fmt.Errorf("already syncing with %v peers at %v", hostPeers, host)
+	}
+	// Hold the place of the session while it is set up
+	s.pending++
+	s.hostSessions[host]++
+	if peer.Dialed != "" {
+		s.dialed[addr] = true
+	}
+	s.mu.Unlock()
+
+	// The peer we connect to may have to prove a key of its own, rather than one PeerTrust of the server trusts
+	messengerOpts := s.Opts.Messenger
+	if trust != nil {
+		messengerOpts.PeerTrust = trust
+	}
+	newMessenger, err := NewWgpsMessenger(messengerOpts, "", s.Opts.Store)
+	var session *Session[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]
+	if err == nil && peer.Dialed != "" {
+		peerTransport, err = newMessenger.dial(addr)
+	}
+	if err == nil {
+		session = newMessenger.NewSession(role, peerTransport)
+		err = session.Start(context.Background())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	if err != nil || s.closed {
+		s.hostSessions[host]--
+		if peer.Dialed != "" {
+			delete(s.dialed, addr)
+			if peerTransport != nil {
+				peerTransport.Close()
+			}
+		}
+		if newMessenger != nil {
+			newMessenger.Close()
+		}
+		if err == nil {
+			err = fmt.Errorf("the server is closed")
+		}
+		return err
+	}
+	s.sessions[newMessenger] = peer
+	s.ended.Add(1)
+
+	// Tear the session down once it is over, whichever peer ended it and why
+	go func() {
+		defer s.ended.Done()
+		if err := session.Wait(); err != nil {
+			log.Printf("the session with %v ended: %v", addr, err)
+		}
+		s.endSession(newMessenger)
+	}()
+	return nil
+}
+
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) endSession(messenger *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) {
+	s.mu.Lock()
+	peer, found := s.sessions[messenger]
+	if found {
+		delete(s.sessions, messenger)
+		s.hostSessions[peer.Host]--
+		if s.hostSessions[peer.Host] == 0 {
+			delete(s.hostSessions, peer.Host)
+		}
+		if peer.Dialed != "" {
+			delete(s.dialed, peer.Dialed)
+		}
+	}
+	s.mu.Unlock()
+
+	if found {
+		if err := messenger.Close(); err != nil {
+			log.Printf("could not close the session with %v: %v", peer.Host, err)
+		}
+	}
+}
+
+// Connects to the peer at addr, and syncs the store with it as alfie in a session of its own
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Connect(addr string) error {
+	return s.startSession(wgpstypes.SyncRoleAlfie, nil, addr, nil)
+}
+
+/*
+Connects to a peer found on the local network, unless it does not share the namespace of our store, it is the one
+to connect to us, or we sync with it already. A peer which announced a public key has to prove it holds that key.
+Discovery tells us about a peer again every time it announces itself, so a peer we lost the session with is connected
+to again once it is heard of next:
+
+	OnPeer: func(peer discovery.Peer) { go server.ConnectDiscovered(peer) }
+*/
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) ConnectDiscovered(peer discovery.Peer) error {
+	if !peer.Initiate || !peer.Shares(s.Opts.Store.NameSpaceId) {
+		return nil
+	}
+	var trust transport.PeerTrust
+	if peer.PublicKey != nil {
+		trust = transport.PinnedPeers{peer.PublicKey}
+	}
+	err := s.startSession(wgpstypes.SyncRoleAlfie, nil, peer.Addr, trust)
+	if errors.Is(err, ErrAlreadyConnected) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("could not sync with %v found on the local network: %v", peer.Addr, err)
+		return err
+	}
+	return nil
+}
+
+// The number of peers the server is syncing with
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Peers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Stops accepting peers and ends every session, returning once they are torn down
+func (s *Server[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	messengers := make([]*WgpsMessenger[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	], 0, len(s.sessions))
+	for messenger := range s.sessions {
+		messengers = append(messengers, messenger)
+	}
+	s.mu.Unlock()
+
+	// A server which only connects to peers has no listener
+	var err error
+	if s.Listener != nil {
+		err = s.Listener.Close()
+	}
+	for _, messenger := range messengers {
+		s.endSession(messenger)
+	}
+	// Wait for the sessions to notice they are over
+	s.ended.Wait()
+	return err
+}
+
+// The host of a peer, sessions with peers at the same host count towards the same limit
+func peerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
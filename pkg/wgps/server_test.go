@@ -0,0 +1,277 @@
+package wgps
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/discovery"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/pai"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+// Waits for a condition which holds once the peers are done with what they are doing
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %v", what)
+}
+
+// Listens with a new identity which lets in any peer, peers trust the server by the key of the returned identity
+func newTestListener(t *testing.T, addr string) (*transport.QuicListener, *transport.Identity) {
+	identity, err := transport.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := identity.ServerTLSConfig(transport.AnyPeer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := transport.NewQuicListener(addr, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener, identity
+}
+
+// Connects with a new identity to the peer holding the given key
+func dialTestPeer(t *testing.T, addr string, key ed25519.PublicKey) *transport.QuicTransport {
+	identity, err := transport.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := identity.ClientTLSConfig(addr, transport.PinnedPeers{key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerTransport, err := transport.DialQuic(addr, tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return peerTransport
+}
+
+func TestServerSyncsWithManyPeers(t *testing.T) {
+	// The messenger of the server only lends its options and store to the sessions
+	serverMessenger, serverStore := newTestMessenger(t, map[string]string{"server": "of the server"})
+	listener, serverIdentity := newTestListener(t, "localhost:4245")
+	server, err := NewServer(ServerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+		Messenger: WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+			Schemes:   serverMessenger.Schemes,
+			Interests: serverMessenger.Interests,
+		},
+		Store:              *serverStore,
+		MaxSessionsPerHost: 2,
+	}, listener)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve() }()
+
+	alfieMessenger, alfieStore := newTestMessenger(t, map[string]string{"alfie": "of alfie"})
+	bettyMessenger, _ := newTestMessenger(t, map[string]string{"betty": "of betty"})
+	for _, messenger := range []*testMessenger{alfieMessenger, bettyMessenger} {
+		peerTransport := dialTestPeer(t, "localhost:4245", serverIdentity.PublicKey())
+		if err := messenger.InitiateOver(peerTransport); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// What betty brings the server reaches alfie through it
+	holds := func(willowStore *testStore, payload string) bool {
+		return willowStore.AvailablePayload(<-serverMessenger.Schemes.Payload.FromBytes([]byte(payload))) > 0
+	}
+	eventually(t, "the peers to sync through the server", func() bool {
+		return holds(serverStore, "of alfie") && holds(serverStore, "of betty") && holds(alfieStore, "of betty") && holds(alfieStore, "of the server")
+	})
+	if server.Peers() != 2 {
+		t.Fatalf("expected the server to sync with 2 peers, it syncs with %d", server.Peers())
+	}
+
+	// A third peer from the same host is one too many
+	refused := dialTestPeer(t, "localhost:4245", serverIdentity.PublicKey())
+	if _, err := refused.Recv(0); err == nil {
+		t.Error("expected the server to refuse a third peer from the same host")
+	}
+
+	// The place of a peer which disconnects is freed
+	bettyMessenger.Close()
+	eventually(t, "the session with betty to end", func() bool { return server.Peers() == 1 })
+
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Peers() != 0 {
+		t.Errorf("expected no sessions after closing the server, %d are left", server.Peers())
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected the server to stop serving without an error, got %v", err)
+	}
+}
+
+func TestServerConnectsToDiscoveredPeers(t *testing.T) {
+	newTestServer := func(addr string, payloads map[string]string) (*Server[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint], *testStore) {
+		messenger, willowStore := newTestMessenger(t, payloads)
+		listener, identity := newTestListener(t, addr)
+		server, err := NewServer(ServerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+			Messenger: WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+				Schemes:   messenger.Schemes,
+				Interests: messenger.Interests,
+				Identity:  identity,
+				PeerTrust: transport.AnyPeer{},
+			},
+			Store: *willowStore,
+		}, listener)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go server.Serve()
+		t.Cleanup(func() { server.Close() })
+		return server, willowStore
+	}
+	alfieServer, alfieStore := newTestServer("localhost:4248", map[string]string{"alfie": "of alfie"})
+	bettyServer, bettyStore := newTestServer("localhost:4253", map[string]string{"betty": "of betty"})
+
+	// Betty is the one to connect, or does not share our namespace
+	for _, peer := range []discovery.Peer{
+		{Addr: "localhost:4253", SharedNamespaces: []types.NamespaceId{alfieStore.NameSpaceId}},
+		{Addr: "localhost:4253", SharedNamespaces: []types.NamespaceId{types.NamespaceId("otherspace")}, Initiate: true},
+	} {
+		if err := alfieServer.ConnectDiscovered(peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if alfieServer.Peers() != 0 {
+		t.Fatalf("expected alfie not to connect to betty, she syncs with %d peers", alfieServer.Peers())
+	}
+
+	// A peer announcing a key has to prove it holds the key
+	stranger, err := transport.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	impostor := discovery.Peer{Addr: "localhost:4253", PublicKey: stranger.PublicKey(), SharedNamespaces: []types.NamespaceId{alfieStore.NameSpaceId}, Initiate: true}
+	if err := alfieServer.ConnectDiscovered(impostor); !errors.As(err, &AuthFailureError{}) {
+		t.Fatalf("expected alfie not to sync with a peer announcing a key it does not hold, got %v", err)
+	}
+
+	betty := discovery.Peer{Addr: "localhost:4253", PublicKey: bettyServer.Opts.Messenger.Identity.PublicKey(), SharedNamespaces: []types.NamespaceId{alfieStore.NameSpaceId}, Initiate: true}
+	if err := alfieServer.ConnectDiscovered(betty); err != nil {
+		t.Fatal(err)
+	}
+	holds := func(willowStore *testStore, payload string) bool {
+		return willowStore.AvailablePayload(<-pinagoladastore.TestPayloadScheme.FromBytes([]byte(payload))) > 0
+	}
+	eventually(t, "the servers to sync", func() bool {
+		return holds(alfieStore, "of betty") && holds(bettyStore, "of alfie")
+	})
+
+	// Hearing of betty again does not connect to her again
+	if err := alfieServer.ConnectDiscovered(betty); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieServer.Connect(betty.Addr); !errors.Is(err, ErrAlreadyConnected) {
+		t.Errorf("expected alfie not to connect to betty while syncing with her, got %v", err)
+	}
+	if alfieServer.Peers() != 1 || bettyServer.Peers() != 1 {
+		t.Errorf("expected the servers to sync in a single session, alfie syncs with %d peers and betty with %d", alfieServer.Peers(), bettyServer.Peers())
+	}
+}
+
+func TestServerSessionsShareTheStore(t *testing.T) {
+	serverMessenger, serverStore := newTestMessenger(t, nil)
+	opts := ServerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+		Messenger: WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+			Schemes: serverMessenger.Schemes,
+		},
+	}
+
+	// A store which was not made by its constructor is refused, rather than set up on the copy the server holds
+	withoutListeners, withoutLock := *serverStore, *serverStore
+	withoutListeners.Listeners, withoutLock.IngestionMutexLock = nil, nil
+	opts.Store = withoutListeners
+	if _, err := NewServer(opts, nil); !errors.Is(err, ErrStoreWithoutListeners) {
+		t.Errorf("expected a store without listeners to be refused, got %v", err)
+	}
+	opts.Store = withoutLock
+	if _, err := NewServer(opts, nil); !errors.Is(err, ErrStoreWithoutLock) {
+		t.Errorf("expected a store without a lock to be refused, got %v", err)
+	}
+	if _, err := NewWgpsMessenger(opts.Messenger, "", withoutLock); !errors.Is(err, ErrStoreWithoutLock) {
+		t.Errorf("expected a messenger to refuse a store without a lock, got %v", err)
+	}
+
+	opts.Store = *serverStore
+	server, err := NewServer(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := NewWgpsMessenger(server.Opts.Messenger, "", server.Opts.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := NewWgpsMessenger(server.Opts.Messenger, "", server.Opts.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if first.Store.IngestionMutexLock != serverStore.IngestionMutexLock || second.Store.IngestionMutexLock != serverStore.IngestionMutexLock ||
+		first.Store.Listeners != serverStore.Listeners || second.Store.Listeners != serverStore.Listeners {
+		t.Error("expected the sessions of a server to share the lock and listeners of its store")
+	}
+}
+
+func TestServerHoldsThePlaceOfSessionsWhileTheyStart(t *testing.T) {
+	serverMessenger, serverStore := newTestMessenger(t, nil)
+	server, err := NewServer(ServerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+		Messenger: WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+			Schemes:   serverMessenger.Schemes,
+			Interests: serverMessenger.Interests,
+		},
+		Store:    *serverStore,
+		MaxPeers: 1,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// A peer which never answers keeps the server connecting to it until the handshake times out
+	silent, err := net.ListenPacket("udp", "localhost:4256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	connected := make(chan error, 1)
+	go func() { connected <- server.Connect("localhost:4256") }()
+	time.Sleep(100 * time.Millisecond)
+
+	accept := func() error {
+		serverTransport, _ := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+		err := server.startSession(wgpstypes.SyncRoleBetty, serverTransport, "peer:4242", nil)
+		if err != nil {
+			serverTransport.Close()
+		}
+		return err
+	}
+	if err := accept(); err == nil {
+		t.Error("expected the session being set up to take the only place of the server")
+	}
+	if err := <-connected; err == nil {
+		t.Fatal("expected connecting to a peer which never answers to fail")
+	}
+	if err := accept(); err != nil {
+		t.Errorf("expected the place of the session which failed to start to be freed, got %v", err)
+	}
+}
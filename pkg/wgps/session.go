@@ -0,0 +1,638 @@
+package wgps
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/reconciliation"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"golang.org/x/exp/constraints"
+)
+
+/*
+Session is the sync session of a messenger with one peer, over one transport, in the role the messenger has towards
+that peer. It runs from Start until either peer closes it, its context is done, the transport is lost or the other peer
+misbehaves, and Wait tells which of these it was. Progress tells how far reconciling with the other peer got, and
+Reconciled when it is done.
+*/
+type Session[
+	ReadCapability any,
+	Receiver types.SubspaceId,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup any,
+	PsiScalar any,
+	SubspaceCapability any,
+	SubspaceReceiver types.SubspaceId,
+	SyncSubspaceSignature,
+	SubspaceSecretKey any,
+	Prefingerprint,
+	Fingerprint string,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken string,
+	AuthorisationOpts []byte,
+	K constraints.Unsigned,
+] struct {
+	Messenger *WgpsMessenger[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]
+	Role      wgpstypes.SyncRole
+	Transport wgpstypes.Transport
+
+	mu      sync.Mutex
+	started bool
+	// Closed once the session is over, after which err holds why
+	ended chan struct{}
+	err   error
+	// Stop the goroutines of the session which would otherwise wait for it forever
+	teardown []func()
+
+	// Reconciles with the other peer once the session started, guarded by the lock of the role of the messenger
+	engine *reconciliation.Engine[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]
+	// The handles bound in the session, guarded by the lock of the role of the messenger as well
+	handles *SessionHandles[ReadCapability, PsiGroup, StaticToken]
+	// Messages queued which were not handed to the transport yet
+	unsent int
+	// Closed once reconciling is done and every message it took was handed to the transport, which is once isReconciled and unsent is 0
+	reconciled   chan struct{}
+	isReconciled bool
+	// Bytes of payloads transferred on the data channel
+	payloadBytesSent     uint64
+	payloadBytesReceived uint64
+}
+
+// Progress of a session, of which Session.Progress takes a snapshot
+type SessionProgress struct {
+	// Every pair of intersecting areas of interest found so far
+	Intersections []reconciliation.Progress
+	/*
+		Bytes of payloads transferred on the data channel, which are the ones requested from either peer and those of
+		entries forwarded as they were ingested. The ones sent along with the entries while reconciling are counted by
+		the intersection they are in.
+	*/
+	PayloadBytesSent     uint64
+	PayloadBytesReceived uint64
+	// Whether neither peer has a range open in any of the intersections, or both bound areas of interest which do not intersect
+	Reconciled bool
+}
+
+// Sets up a session with the peer at the other end of the transport, which starts syncing once it is started
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) NewSession(role wgpstypes.SyncRole, sessionTransport wgpstypes.Transport) *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+] {
+	return &Session[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]{
+		Messenger:  w,
+		Role:       role,
+		Transport:  sessionTransport,
+		ended:      make(chan struct{}),
+		reconciled: make(chan struct{}),
+	}
+}
+
+/*
+Starts syncing, and returns once our first messages are queued. The session ends with the error of ctx once ctx is
+done. A messenger syncs in one session per role at a time, so this fails while another session in the same role goes on.
+*/
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("the session was started already")
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	if err := s.Messenger.startSync(s); err != nil {
+		s.end(err)
+		return err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.end(ctx.Err())
+		case <-s.ended:
+		}
+	}()
+	return nil
+}
+
+/*
+Waits for the session to end, and returns why it did. This is nil for a session either peer closed, the error of the
+context for a session whose context is done, and otherwise a ProtocolViolationError, AuthFailureError or
+TransportLossError. Whatever the other peer sends ends its session at worst.
+*/
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Wait() error {
+	<-s.ended
+	return s.err
+}
+
+// Closed once the session is over
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Done() <-chan struct{} {
+	return s.ended
+}
+
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) IsEnded() bool {
+	select {
+	case <-s.ended:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+Closed once both peers have reconciled every pair of intersecting areas of interest found, and the last of our
+messages reconciling took was handed to the transport. Closing the session then leaves the other peer reconciled
+as well, so syncing once is waiting for this before closing. Private area intersection may still find pairs of areas
+of interest afterwards, which are reconciled all the same, and with a peer we share nothing with this is never closed.
+*/
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Reconciled() <-chan struct{} {
+	return s.reconciled
+}
+
+// Takes a snapshot of how far reconciling got, which is empty until the session started
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Progress() SessionProgress {
+	mu := &s.Messenger.acceptedMu
+	if wgpstypes.IsAlfie(s.Role) {
+		mu = &s.Messenger.initiatorMu
+	}
+	var progress SessionProgress
+	mu.Lock()
+	if s.engine != nil {
+		progress.Intersections = s.engine.Progress()
+		progress.Reconciled = s.reconciledSoFar()
+	}
+	mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	progress.PayloadBytesSent = s.payloadBytesSent
+	progress.PayloadBytesReceived = s.payloadBytesReceived
+	return progress
+}
+
+// Ends the session, closing the transport so the other peer sees it end as well
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Close() error {
+	return s.end(nil)
+}
+
+// Ends the session with the given error unless it is over already, and returns the error of closing the transport
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) end(err error) error {
+	s.mu.Lock()
+	if s.IsEnded() {
+		s.mu.Unlock()
+		return nil
+	}
+	s.err = err
+	close(s.ended)
+	teardown := s.teardown
+	s.mu.Unlock()
+
+	closeErr := s.Transport.Close()
+	for _, stop := range teardown {
+		stop()
+	}
+	return closeErr
+}
+
+// Registers how to stop a goroutine of the session once it ends, which is right away if it is over already
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) onEnd(stop func()) {
+	s.mu.Lock()
+	if !s.IsEnded() {
+		s.teardown = append(s.teardown, stop)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	stop()
+}
+
+/*
+Ends the session after the transport failed us. A transport which merely ended was closed by the other peer, which
+ends the session without an error, and a transport we closed ourselves is no news at all.
+*/
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) lost(err error) {
+	if s.IsEnded() {
+		return
+	}
+	if errors.Is(err, io.EOF) {
+		s.end(nil)
+		return
+	}
+	s.end(TransportLossError{Err: err})
+}
+
+// Counts a message queued to be sent, before it may be handed to the transport
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) queued() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unsent++
+}
+
+// Counts a message handed to the transport, or one which failed to encode after all
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) sent() {
+	s.mu.Lock()
+	s.unsent--
+	s.mu.Unlock()
+	s.settle()
+}
+
+// Counts the bytes of a payload transferred on the data channel
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) transferred(sent, received uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payloadBytesSent += sent
+	s.payloadBytesReceived += received
+}
+
+/*
+Whether every pair of areas of interest found so far is reconciled. Areas of interest which do not intersect leave
+nothing to reconcile, which is done once both peers bound theirs. Called with the lock of the role of the messenger held.
+*/
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) reconciledSoFar() bool {
+	if s.engine.IsReconciled() {
+		return true
+	}
+	return len(s.engine.Progress()) == 0 && s.handles.AreasOfInterestBound()
+}
+
+// Marks reconciling as done, which is announced once our messages were sent
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) reconcile() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isReconciled = true
+}
+
+// Announces reconciling is done if it is and nothing is left to send
+func (s *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) settle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isReconciled || s.unsent > 0 {
+		return
+	}
+	select {
+	case <-s.reconciled:
+	default:
+		close(s.reconciled)
+	}
+}
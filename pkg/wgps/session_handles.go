@@ -0,0 +1,164 @@
+package wgps
+
+import (
+	"fmt"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/data"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+// The most handles of each HandleType the other peer may have bound at once, unless configured otherwise
+const DEFAULT_MAX_HANDLES = 1 << 20
+
+/*
+SessionHandles holds the resource handles of every HandleType bound in a session, both the ones we bound and the
+ones the other peer bound. The stores of handles bound by the other peer are bounded by MaxHandles, a peer which
+binds more handles without freeing any violates the session.
+
+The static token handles are the ones of the reconciliation engine, which binds and dereferences them.
+*/
+type SessionHandles[ReadCapability, PsiGroup any, StaticToken string] struct {
+	IntersectionOurs   handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+	IntersectionTheirs handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
+
+	CapabilityOurs   handlestore.HandleStore[ReadCapability]
+	CapabilityTheirs handlestore.HandleStore[ReadCapability]
+
+	AreaOfInterestOurs   handlestore.HandleStore[types.AreaOfInterest]
+	AreaOfInterestTheirs handlestore.HandleStore[types.AreaOfInterest]
+
+	PayloadRequestOurs   handlestore.HandleStore[data.PayloadRequest]
+	PayloadRequestTheirs handlestore.HandleStore[data.PayloadRequest]
+
+	StaticTokenOurs   *handlestore.HandleStore[StaticToken]
+	StaticTokenTheirs *handlestore.HandleStore[StaticToken]
+}
+
+func NewSessionHandles[ReadCapability, PsiGroup any, StaticToken string](
+	maxHandles uint64,
+	staticTokenOurs, staticTokenTheirs *handlestore.HandleStore[StaticToken],
+) *SessionHandles[ReadCapability, PsiGroup, StaticToken] {
+	staticTokenTheirs.MaxHandles = maxHandles
+	return &SessionHandles[ReadCapability, PsiGroup, StaticToken]{
+		IntersectionOurs: handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]{
+			Map: handlestore.NewMap[wgpstypes.Intersection[PsiGroup]](),
+		},
+		IntersectionTheirs: handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]{
+			Map:        handlestore.NewMap[wgpstypes.Intersection[PsiGroup]](),
+			MaxHandles: maxHandles,
+		},
+		CapabilityOurs: handlestore.HandleStore[ReadCapability]{
+			Map: handlestore.NewMap[ReadCapability](),
+		},
+		CapabilityTheirs: handlestore.HandleStore[ReadCapability]{
+			Map:        handlestore.NewMap[ReadCapability](),
+			MaxHandles: maxHandles,
+		},
+		AreaOfInterestOurs: handlestore.HandleStore[types.AreaOfInterest]{
+			Map: handlestore.NewMap[types.AreaOfInterest](),
+		},
+		AreaOfInterestTheirs: handlestore.HandleStore[types.AreaOfInterest]{
+			Map:        handlestore.NewMap[types.AreaOfInterest](),
+			MaxHandles: maxHandles,
+		},
+		PayloadRequestOurs: handlestore.HandleStore[data.PayloadRequest]{
+			Map: handlestore.NewMap[data.PayloadRequest](),
+		},
+		PayloadRequestTheirs: handlestore.HandleStore[data.PayloadRequest]{
+			Map:        handlestore.NewMap[data.PayloadRequest](),
+			MaxHandles: maxHandles,
+		},
+		StaticTokenOurs:   staticTokenOurs,
+		StaticTokenTheirs: staticTokenTheirs,
+	}
+}
+
+// Whether both peers bound areas of interest, which leave nothing to reconcile if none of them intersect
+func (h *SessionHandles[ReadCapability, PsiGroup, StaticToken]) AreasOfInterestBound() bool {
+	return h.AreaOfInterestOurs.LeastUnassignedHandle > 0 && h.AreaOfInterestTheirs.LeastUnassignedHandle > 0
+}
+
+// The store of the handles of the given type which we bound, or which the other peer bound
+func (h *SessionHandles[ReadCapability, PsiGroup, StaticToken]) Store(handleType wgpstypes.HandleType, ours bool) (handlestore.Handles, error) {
+	switch handleType {
+	case wgpstypes.IntersectionHandle:
+		if ours {
+			return &h.IntersectionOurs, nil
+		}
+		return &h.IntersectionTheirs, nil
+	case wgpstypes.CapabilityHandle:
+		if ours {
+			return &h.CapabilityOurs, nil
+		}
+		return &h.CapabilityTheirs, nil
+	case wgpstypes.AreaOfInterestHandle:
+		if ours {
+			return &h.AreaOfInterestOurs, nil
+		}
+		return &h.AreaOfInterestTheirs, nil
+	case wgpstypes.PayloadRequestHandle:
+		if ours {
+			return &h.PayloadRequestOurs, nil
+		}
+		return &h.PayloadRequestTheirs, nil
+	case wgpstypes.StaticTokenHandle:
+		if ours {
+			return h.StaticTokenOurs, nil
+		}
+		return h.StaticTokenTheirs, nil
+	default:
+		return nil, fmt.Errorf("unknown handle type %v", handleType)
+	}
+}
+
+// A handle a message refers to, and whether we or the other peer bound it
+type handleReference struct {
+	HandleType wgpstypes.HandleType
+	Handle     uint64
+	Ours       bool
+}
+
+/*
+Whether the handles the other peer bound which a message refers to were bound yet. The other peer binds handles on
+other logical channels than the messages referring to them, so these may overtake the messages binding them.
+*/
+func (h *SessionHandles[ReadCapability, PsiGroup, StaticToken]) Arrived(references []handleReference) bool {
+	for _, reference := range references {
+		if reference.Ours {
+			continue
+		}
+		store, err := h.Store(reference.HandleType, false)
+		if err == nil && !store.WasBound(reference.Handle) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Checks that the handles a message received from the other peer refers to may be used, and that the handle it binds,
+if any, does not exceed the number of handles the other peer may bind.
+*/
+func (h *SessionHandles[ReadCapability, PsiGroup, StaticToken]) Check(binds *wgpstypes.HandleType, references []handleReference) error {
+	if binds != nil {
+		store, err := h.Store(*binds, false)
+		if err != nil {
+			return err
+		}
+		if store.Full() {
+			return fmt.Errorf("the other peer bound too many handles of type %v", *binds)
+		}
+	}
+	for _, reference := range references {
+		store, err := h.Store(reference.HandleType, reference.Ours)
+		if err != nil {
+			return err
+		}
+		if err := store.CheckHandle(reference.Handle); err != nil {
+			return fmt.Errorf("a message of the other peer refers to a handle of type %v it may not use: %w", reference.HandleType, err)
+		}
+	}
+	return nil
+}
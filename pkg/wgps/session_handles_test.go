@@ -0,0 +1,69 @@
+package wgps
+
+import (
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+func newTestSessionHandles(maxHandles uint64) *SessionHandles[string, int, string] {
+	return NewSessionHandles[string, int](
+		maxHandles,
+		&handlestore.HandleStore[string]{Map: handlestore.NewMap[string]()},
+		&handlestore.HandleStore[string]{Map: handlestore.NewMap[string]()},
+	)
+}
+
+func TestSessionHandlesRejectUnusableReferences(t *testing.T) {
+	handles := newTestSessionHandles(0)
+	ours := handles.AreaOfInterestOurs.Bind(types.AreaOfInterest{})
+	theirs := handles.AreaOfInterestTheirs.Bind(types.AreaOfInterest{})
+
+	references := []handleReference{
+		{wgpstypes.AreaOfInterestHandle, theirs, false},
+		{wgpstypes.AreaOfInterestHandle, ours, true},
+	}
+	if err := handles.Check(nil, references); err != nil {
+		t.Fatalf("rejected references to bound handles: %v", err)
+	}
+
+	unknown := []handleReference{{wgpstypes.StaticTokenHandle, 0, false}}
+	if err := handles.Check(nil, unknown); err == nil {
+		t.Errorf("accepted a reference to a static token which was never bound")
+	}
+
+	store, err := handles.Store(wgpstypes.AreaOfInterestHandle, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Free(ours)
+	if err := handles.Check(nil, references); err == nil {
+		t.Errorf("accepted a reference to a freed handle")
+	}
+}
+
+func TestSessionHandlesBoundTheirHandles(t *testing.T) {
+	handles := newTestSessionHandles(1)
+	binds := wgpstypes.StaticTokenHandle
+
+	if err := handles.Check(&binds, nil); err != nil {
+		t.Fatalf("rejected binding the first handle: %v", err)
+	}
+	handles.StaticTokenTheirs.Bind("token")
+	if err := handles.Check(&binds, nil); err == nil {
+		t.Errorf("accepted binding more handles than allowed")
+	}
+
+	// Only the handles the other peer binds are bounded
+	handles.StaticTokenOurs.Bind("token")
+	handles.StaticTokenOurs.Bind("token")
+	if handles.StaticTokenOurs.Full() {
+		t.Errorf("bounded the handles we bind")
+	}
+
+	if _, err := handles.Store(wgpstypes.HandleType(42), true); err == nil {
+		t.Errorf("found a store for an unknown handle type")
+	}
+}
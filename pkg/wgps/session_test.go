@@ -0,0 +1,256 @@
+package wgps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/encoding"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+// Waits for a session to end, and returns why it did
+func waitForSession(t *testing.T, session interface{ Wait() error }) error {
+	t.Helper()
+	ended := make(chan error, 1)
+	go func() { ended <- session.Wait() }()
+	select {
+	case err := <-ended:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the session to end")
+		return nil
+	}
+}
+
+func TestSessionEndsWithItsContext(t *testing.T) {
+	alfieMessenger, _ := newTestMessenger(t, map[string]string{"alfie": "of alfie"})
+	bettyMessenger, _ := newTestMessenger(t, map[string]string{"betty": "of betty"})
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	alfieSession := alfieMessenger.NewSession(wgpstypes.SyncRoleAlfie, alfieTransport)
+	if err := alfieSession.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	bettySession := bettyMessenger.NewSession(wgpstypes.SyncRoleBetty, bettyTransport)
+	if err := bettySession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieSession.Start(ctx); err == nil {
+		t.Error("expected a session to start only once")
+	}
+	refusedTransport, _ := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	if err := alfieMessenger.InitiateOver(refusedTransport); err == nil {
+		t.Error("expected a second session in the same role not to start while the first goes on")
+	}
+
+	cancel()
+	if err := waitForSession(t, alfieSession); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the session to end with its context, got %v", err)
+	}
+	// To betty alfie closed the session, which is no error
+	if err := waitForSession(t, bettySession); err != nil {
+		t.Errorf("expected the session of the other peer to end without an error, got %v", err)
+	}
+	if err := alfieMessenger.FreeHandle(wgpstypes.SyncRoleAlfie, wgpstypes.AreaOfInterestHandle, 0, true); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("expected nothing to be sent in a session which ended, got %v", err)
+	}
+
+	// The place of a session which ended is free for the next one
+	alfieTransport, bettyTransport = transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	if err := alfieMessenger.InitiateOver(alfieTransport); err != nil {
+		t.Fatalf("expected a new session to start once the last one ended, got %v", err)
+	}
+	bettyTransport.Close()
+}
+
+func TestSessionEndsWhenThePeerViolatesTheProtocol(t *testing.T) {
+	bettyMessenger, _ := newTestMessenger(t, nil)
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	bettySession := bettyMessenger.NewSession(wgpstypes.SyncRoleBetty, bettyTransport)
+	if err := bettySession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alfie reveals a nonce which is not the one it committed to
+	alfieTransport.Send(encoding.EncodeOpening(64, make([]byte, 32)), wgpstypes.ControlChannel)
+	alfieTransport.Send(encoding.EncodeCommitmentReveal(wgpstypes.MsgCommitmentReveal{
+		Kind: wgpstypes.CommitmentReveal,
+		Data: wgpstypes.MsgCommitmentRevealData{Nonce: make([]byte, 16)},
+	}), wgpstypes.ControlChannel)
+
+	err := waitForSession(t, bettySession)
+	if !errors.As(err, &ProtocolViolationError{}) {
+		t.Errorf("expected the session to end with a protocol violation, got %v", err)
+	}
+	// Betty closed the transport, after which alfie receives no more than betty sent before
+	for {
+		if _, err := alfieTransport.Recv(wgpstypes.ControlChannel); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("expected the transport of the session to be closed, got %v", err)
+			}
+			break
+		}
+	}
+}
+
+func TestSessionEndsWithAMessageBeyondTheBufferOfThePeer(t *testing.T) {
+	alfieMessenger, _ := newTestMessenger(t, map[string]string{"alfie": "of alfie"})
+	bettyMessenger, _ := newTestMessenger(t, nil)
+	// Betty has no room for the capability alfie binds
+	bettyMessenger.ChannelCapacity = 8
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+
+	bettySession := bettyMessenger.NewSession(wgpstypes.SyncRoleBetty, bettyTransport)
+	if err := bettySession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	alfieSession := alfieMessenger.NewSession(wgpstypes.SyncRoleAlfie, alfieTransport)
+	if err := alfieSession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForSession(t, alfieSession); !errors.Is(err, ErrMessageTooLong) {
+		t.Errorf("expected the session to end with a message which never fits, got %v", err)
+	}
+	waitForSession(t, bettySession)
+}
+
+// Carries nothing, and breaks down once lost is closed
+type breakingTransport struct {
+	lost chan struct{}
+}
+
+func (t *breakingTransport) Send(data []byte, channel wgpstypes.Channel) error {
+	return nil
+}
+
+func (t *breakingTransport) Recv(channel wgpstypes.Channel) ([]byte, error) {
+	<-t.lost
+	return nil, errors.New("connection reset by peer")
+}
+
+func (t *breakingTransport) Close() error {
+	return nil
+}
+
+func (t *breakingTransport) IsClosed() bool {
+	return false
+}
+
+func TestSessionEndsWhenTheTransportIsLost(t *testing.T) {
+	alfieMessenger, _ := newTestMessenger(t, nil)
+	alfieTransport := &breakingTransport{lost: make(chan struct{})}
+	alfieSession := alfieMessenger.NewSession(wgpstypes.SyncRoleAlfie, alfieTransport)
+	if err := alfieSession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	close(alfieTransport.lost)
+	if err := waitForSession(t, alfieSession); !errors.As(err, &TransportLossError{}) {
+		t.Errorf("expected the session to end with the loss of its transport, got %v", err)
+	}
+}
+
+// Waits for a session to be done reconciling
+func waitForReconciled(t *testing.T, session interface{ Reconciled() <-chan struct{} }) {
+	t.Helper()
+	select {
+	case <-session.Reconciled():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the session to be reconciled")
+	}
+}
+
+func TestSessionsSyncOnceAndExit(t *testing.T) {
+	alfieMessenger, alfieStore := newTestMessenger(t, map[string]string{"entry1": "payload1", "entry2": "payload2"})
+	bettyMessenger, bettyStore := newTestMessenger(t, map[string]string{"entry3": "payload3"})
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{
+		Latency: time.Millisecond,
+		Reorder: 3 * time.Millisecond,
+		Seed:    3,
+	})
+	alfieSession := alfieMessenger.NewSession(wgpstypes.SyncRoleAlfie, alfieTransport)
+	bettySession := bettyMessenger.NewSession(wgpstypes.SyncRoleBetty, bettyTransport)
+	if progress := alfieSession.Progress(); len(progress.Intersections) != 0 || progress.Reconciled {
+		t.Errorf("expected no progress before the session started, got %+v", progress)
+	}
+	if err := bettySession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieSession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Betty leaves as soon as she is done, which leaves alfie done as well
+	waitForReconciled(t, bettySession)
+	bettySession.Close()
+	waitForReconciled(t, alfieSession)
+	if err := waitForSession(t, alfieSession); err != nil {
+		t.Errorf("expected the session to end once betty closed it, got %v", err)
+	}
+
+	expected := map[string]string{"entry1": "payload1", "entry2": "payload2", "entry3": "payload3"}
+	alfieHolds, bettyHolds := storedPayloads(t, alfieStore), storedPayloads(t, bettyStore)
+	if fmt.Sprint(alfieHolds) != fmt.Sprint(expected) || fmt.Sprint(bettyHolds) != fmt.Sprint(expected) {
+		t.Errorf("expected both peers to hold %v, alfie holds %v and betty holds %v", expected, alfieHolds, bettyHolds)
+	}
+
+	alfieProgress, bettyProgress := alfieSession.Progress(), bettySession.Progress()
+	if !alfieProgress.Reconciled || len(alfieProgress.Intersections) != 1 || len(bettyProgress.Intersections) != 1 {
+		t.Fatalf("expected a single reconciled intersection, got %+v and %+v", alfieProgress, bettyProgress)
+	}
+	alfieIntersection, bettyIntersection := alfieProgress.Intersections[0], bettyProgress.Intersections[0]
+	if alfieIntersection.EntriesSent != bettyIntersection.EntriesReceived || alfieIntersection.EntriesReceived != bettyIntersection.EntriesSent {
+		t.Errorf("expected the entries one peer sent to be the ones the other received, got %+v and %+v", alfieIntersection, bettyIntersection)
+	}
+	// The entries of a range are announced all at once, along with the ones the other peer sent already
+	if alfieIntersection.PayloadBytesSent != bettyIntersection.PayloadBytesReceived || alfieIntersection.PayloadBytesReceived < uint64(len("payload3")) ||
+		bettyIntersection.PayloadBytesReceived < uint64(len("payload1payload2")) {
+		t.Errorf("expected the payloads each peer lacked to be counted, got %+v and %+v", alfieIntersection, bettyIntersection)
+	}
+}
+
+func TestSessionsWithDisjointAreasOfInterestReconcile(t *testing.T) {
+	alfieMessenger, alfieStore := newTestMessenger(t, map[string]string{"alfie": "of alfie"})
+	bettyMessenger, bettyStore := newTestMessenger(t, map[string]string{"betty": "of betty"})
+	// Each of them is only interested in the entry of its own
+	interestedIn := func(path string) map[*wgpstypes.ReadAuthorisation[string, string]][]types.AreaOfInterest {
+		area := utils.FullArea()
+		area.Path = types.Path{[]byte(path)}
+		return map[*wgpstypes.ReadAuthorisation[string, string]][]types.AreaOfInterest{{Capability: "myspace"}: {{Area: area}}}
+	}
+	alfieMessenger.Interests, bettyMessenger.Interests = interestedIn("alfie"), interestedIn("betty")
+
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	alfieSession := alfieMessenger.NewSession(wgpstypes.SyncRoleAlfie, alfieTransport)
+	bettySession := bettyMessenger.NewSession(wgpstypes.SyncRoleBetty, bettyTransport)
+	if err := bettySession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieSession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing intersects, so both are done reconciling without exchanging any entries
+	waitForReconciled(t, alfieSession)
+	waitForReconciled(t, bettySession)
+	alfieProgress, bettyProgress := alfieSession.Progress(), bettySession.Progress()
+	if !alfieProgress.Reconciled || !bettyProgress.Reconciled || len(alfieProgress.Intersections) != 0 || len(bettyProgress.Intersections) != 0 {
+		t.Errorf("expected both sessions to be reconciled without intersections, got %+v and %+v", alfieProgress, bettyProgress)
+	}
+	alfieSession.Close()
+	waitForSession(t, bettySession)
+	if holds := storedPayloads(t, alfieStore); len(holds) != 1 {
+		t.Errorf("expected alfie to only hold her entry, she holds %v", holds)
+	}
+	if holds := storedPayloads(t, bettyStore); len(holds) != 1 {
+		t.Errorf("expected betty to only hold her entry, she holds %v", holds)
+	}
+}
@@ -0,0 +1,132 @@
+package wgps
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+// Wait before connecting again after the connection to a supervised peer dropped
+const DEFAULT_MIN_BACKOFF = 500 * time.Millisecond
+
+// Longest wait before connecting again, however often connecting failed in a row
+const DEFAULT_MAX_BACKOFF = time.Minute
+
+type SuperviseOpts struct {
+	// How long to wait before the first reconnect, which doubles with every reconnect that fails. Defaults to DEFAULT_MIN_BACKOFF
+	MinBackoff time.Duration
+	// Longest to wait before reconnecting, a session which lasted this long starts over from MinBackoff. Defaults to DEFAULT_MAX_BACKOFF
+	MaxBackoff time.Duration
+	// Opens a transport to the peer, which is QUIC to the supervised address when nil
+	Dial func(ctx context.Context) (wgpstypes.Transport, error)
+	// Told why the session was lost and when the next one is tried, which is logged when nil
+	OnDisconnect func(err error, retryIn time.Duration)
+}
+
+/*
+Syncs with the peer at addr as alfie, and connects to it again whenever the connection drops, waiting longer after
+every attempt which fails. Every new session runs the handshake and binds our areas of interest again, and resumes
+the payloads the last one ended in the middle of from as much of them as we stored.
+
+Returns once ctx is done, once the messenger is closed or either peer closed the session, or once the peer failed to
+authenticate or violated the protocol, which connecting again does not mend.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Supervise(ctx context.Context, addr string, opts SuperviseOpts) error {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DEFAULT_MIN_BACKOFF
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = max(DEFAULT_MAX_BACKOFF, opts.MinBackoff)
+	}
+	if opts.Dial == nil {
+		opts.Dial = func(ctx context.Context) (wgpstypes.Transport, error) {
+			return w.dial(addr)
+		}
+	}
+	if opts.OnDisconnect == nil {
+		opts.OnDisconnect = func(err error, retryIn time.Duration) {
+			log.Printf("lost the session with %v, connecting again in %v: %v", addr, retryIn, err)
+		}
+	}
+
+	backoff := opts.MinBackoff
+	for {
+		sessionTransport, err := opts.Dial(ctx)
+		if err == nil {
+			session := w.NewSession(wgpstypes.SyncRoleAlfie, sessionTransport)
+			err = session.Start(ctx)
+			if err == nil {
+				started := time.Now()
+				err = session.Wait()
+				if time.Since(started) >= opts.MaxBackoff {
+					backoff = opts.MinBackoff
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if w.isClosed() {
+			return nil
+		}
+		// A session either peer closed is over for good, as is one with a peer we can not sync with
+		if err == nil || errors.As(err, &AuthFailureError{}) || errors.As(err, &ProtocolViolationError{}) {
+			return err
+		}
+
+		opts.OnDisconnect(err, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, opts.MaxBackoff)
+	}
+}
+
+// Whether the messenger was closed, after which it starts no more sessions
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) isClosed() bool {
+	w.ingestedMu.Lock()
+	defer w.ingestedMu.Unlock()
+	return w.Closed
+}
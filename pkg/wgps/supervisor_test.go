@@ -0,0 +1,190 @@
+package wgps
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/data"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+)
+
+// A transport whose connection drops on demand, rather than being closed by either peer
+type droppingTransport struct {
+	wgpstypes.Transport
+	dropOnce sync.Once
+	dropped  chan struct{}
+}
+
+func (d *droppingTransport) drop() {
+	d.dropOnce.Do(func() {
+		close(d.dropped)
+		d.Transport.Close()
+	})
+}
+
+func (d *droppingTransport) Recv(channel wgpstypes.Channel) ([]byte, error) {
+	bytes, err := d.Transport.Recv(channel)
+	select {
+	case <-d.dropped:
+		return nil, errors.New("connection lost")
+	default:
+		return bytes, err
+	}
+}
+
+func TestSuperviseReconnectsOnceTheConnectionDrops(t *testing.T) {
+	alfieMessenger, alfieStore := newTestMessenger(t, nil)
+	bettyMessenger, bettyStore := newTestMessenger(t, map[string]string{"before": "synced before the drop"})
+	digest := func(payload string) types.PayloadDigest {
+		return <-pinagoladastore.TestPayloadScheme.FromBytes([]byte(payload))
+	}
+
+	var mu sync.Mutex
+	var connections []*droppingTransport
+	var disconnects []error
+	opts := SuperviseOpts{
+		MinBackoff: time.Millisecond,
+		Dial: func(ctx context.Context) (wgpstypes.Transport, error) {
+			// Betty takes a new session once she noticed the last one ended
+			if session := bettyMessenger.Session(wgpstypes.SyncRoleBetty); session != nil && !session.IsEnded() {
+				return nil, errors.New("betty is still in the last session")
+			}
+			alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+			if err := bettyMessenger.AcceptOver(bettyTransport); err != nil {
+				return nil, err
+			}
+			connection := &droppingTransport{Transport: alfieTransport, dropped: make(chan struct{})}
+			mu.Lock()
+			connections = append(connections, connection)
+			mu.Unlock()
+			return connection, nil
+		},
+		OnDisconnect: func(err error, retryIn time.Duration) {
+			mu.Lock()
+			disconnects = append(disconnects, err)
+			mu.Unlock()
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	supervised := make(chan error, 1)
+	go func() { supervised <- alfieMessenger.Supervise(ctx, "", opts) }()
+
+	eventually(t, "alfie to sync before the drop", func() bool {
+		return alfieStore.AvailablePayload(digest("synced before the drop")) > 0
+	})
+	first := bettyMessenger.Session(wgpstypes.SyncRoleBetty)
+	mu.Lock()
+	connections[0].drop()
+	mu.Unlock()
+	// Written once the session it was lost in ended, so only a new session brings it to alfie
+	eventually(t, "betty to notice the drop", first.IsEnded)
+	if _, err := bettyStore.Set(datamodeltypes.EntryInput{
+		Subspace: types.SubspaceId("myspace"),
+		Path:     types.Path{[]byte("after")},
+		Payload:  []byte("synced after the drop"),
+	}, []byte("myspace")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "alfie to sync again after the drop", func() bool {
+		return alfieStore.AvailablePayload(digest("synced after the drop")) > 0
+	})
+
+	mu.Lock()
+	if len(connections) < 2 || len(disconnects) == 0 || !errors.As(disconnects[0], &TransportLossError{}) {
+		t.Errorf("expected alfie to connect again after losing the connection, connected %v times after %v", len(connections), disconnects)
+	}
+	mu.Unlock()
+	cancel()
+	select {
+	case err := <-supervised:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected supervising to stop with its context, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for supervising to stop")
+	}
+}
+
+func TestSuperviseStopsOnceThePeerClosesTheSession(t *testing.T) {
+	alfieMessenger, _ := newTestMessenger(t, nil)
+	bettyMessenger, _ := newTestMessenger(t, nil)
+	dials := 0
+	err := alfieMessenger.Supervise(context.Background(), "", SuperviseOpts{
+		MinBackoff: time.Millisecond,
+		Dial: func(ctx context.Context) (wgpstypes.Transport, error) {
+			dials++
+			alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+			if err := bettyMessenger.AcceptOver(bettyTransport); err != nil {
+				return nil, err
+			}
+			go bettyMessenger.Close()
+			return alfieTransport, nil
+		},
+	})
+	if err != nil || dials != 1 {
+		t.Errorf("expected alfie not to connect again to a peer which closed the session, got %v after %v dials", err, dials)
+	}
+}
+
+func TestSessionsResumeInterruptedPayloads(t *testing.T) {
+	video := "a video which was cut off in the middle, and continues where it was"
+	alfieMessenger, alfieStore := newTestMessenger(t, nil)
+	bettyMessenger, bettyStore := newTestMessenger(t, nil)
+
+	// Both hold the entry, of whose payload alfie received the first half before her last session ended
+	input := datamodeltypes.EntryInput{
+		Subspace:  types.SubspaceId("myspace"),
+		Path:      types.Path{[]byte("video")},
+		Payload:   []byte(video),
+		Timestamp: 1000,
+	}
+	for _, willowStore := range []*testStore{alfieStore, bettyStore} {
+		if _, err := willowStore.Set(input, []byte("myspace")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry := types.Entry{
+		Namespace_id:   alfieStore.NameSpaceId,
+		Subspace_id:    input.Subspace,
+		Path:           input.Path,
+		Timestamp:      input.Timestamp,
+		Payload_length: uint64(len(video)),
+		Payload_digest: <-pinagoladastore.TestPayloadScheme.FromBytes([]byte(video)),
+	}
+	if _, err := alfieStore.PayloadDriver.Erase(entry.Payload_digest); err != nil {
+		t.Fatal(err)
+	}
+	half := len(video) / 2
+	if _, err := alfieStore.IngestPayload(types.Position3d{Subspace: entry.Subspace_id, Path: entry.Path, Time: entry.Timestamp}, []byte(video[:half]), true, 0); err != nil {
+		t.Fatal(err)
+	}
+	alfieMessenger.interrupt([]data.PayloadRequest{{Entry: entry}})
+
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	if err := bettyMessenger.AcceptOver(bettyTransport); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieMessenger.InitiateOver(alfieTransport); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "alfie to resume the video", func() bool {
+		return alfieStore.AvailablePayload(entry.Payload_digest) == entry.Payload_length
+	})
+	payload, err := alfieStore.PayloadDriver.Get(entry.Payload_digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload.Bytes()) != video {
+		t.Errorf("expected alfie to hold the video once resumed, she holds %q", payload.Bytes())
+	}
+	if partial := alfieStore.PartialPayload(entry.Payload_digest); partial != 0 {
+		t.Errorf("expected no partial payload once the video is complete, %d bytes remain", partial)
+	}
+}
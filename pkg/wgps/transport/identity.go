@@ -0,0 +1,324 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// The application protocol peers agree on while setting up TLS
+const ALPN_PROTOCOL = "Willow-Go-Quic"
+
+// Wrapped by the errors of connections refused because the peer did not prove a key we trust
+var ErrUntrustedPeer = errors.New("untrusted peer")
+
+// Returned when a connection would let in peers without deciding whether to trust them
+var ErrNoPeerTrust = errors.New("no PeerTrust to decide which peers to trust, AnyPeer trusts all of them")
+
+// Returned by the QUIC transports when given no TLS configuration, without which peers are not authenticated
+var ErrNoTLSConfig = errors.New("no TLS configuration to authenticate peers with, see Identity")
+
+/*
+Identity is the Ed25519 key pair a node is known by. Its certificates are derived from the key pair, so peers
+recognise the node by its public key rather than by a certificate authority.
+*/
+type Identity struct {
+	PrivateKey ed25519.PrivateKey
+}
+
+// Creates an identity which is forgotten once the process ends
+func NewIdentity() (*Identity, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{PrivateKey: privateKey}, nil
+}
+
+// Loads the identity kept in a file, creating the file with a new identity if there is none yet
+func LoadOrCreateIdentity(path string) (*Identity, error) {
+	encoded, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		identity, err := NewIdentity()
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(identity.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		// Only we get to read the private key
+		err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+		if err != nil {
+			return nil, err
+		}
+		return identity, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(encoded)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%v does not hold a private key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%v does not hold an Ed25519 private key", path)
+	}
+	return &Identity{PrivateKey: privateKey}, nil
+}
+
+func (i *Identity) PublicKey() ed25519.PublicKey {
+	return i.PrivateKey.Public().(ed25519.PublicKey)
+}
+
+// Makes a self-signed certificate for the public key, which is all peers check it for
+func (i *Identity) Certificate() (tls.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: FormatPeerKey(i.PublicKey())},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(100 * 365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, i.PublicKey(), i.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: i.PrivateKey}, nil
+}
+
+/*
+The TLS configuration of a node accepting peers, which asks every peer for its certificate and only lets in the
+peers trust lets in. AnyPeer lets in any peer which proves its key.
+*/
+func (i *Identity) ServerTLSConfig(trust PeerTrust) (*tls.Config, error) {
+	if trust == nil {
+		return nil, ErrNoPeerTrust
+	}
+	certificate, err := i.Certificate()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates:          []tls.Certificate{certificate},
+		NextProtos:            []string{ALPN_PROTOCOL},
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: verifyPeerCertificate("", trust),
+	}, nil
+}
+
+/*
+The TLS configuration of a node connecting to the peer it knows by name, usually the address it dials. The usual
+verification against certificate authorities is replaced by checking the key of the peer with trust, so this does
+not skip verification even though InsecureSkipVerify is set.
+*/
+func (i *Identity) ClientTLSConfig(name string, trust PeerTrust) (*tls.Config, error) {
+	if trust == nil {
+		return nil, ErrNoPeerTrust
+	}
+	certificate, err := i.Certificate()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates:          []tls.Certificate{certificate},
+		NextProtos:            []string{ALPN_PROTOCOL},
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyPeerCertificate(name, trust),
+	}, nil
+}
+
+// Checks the certificate of a peer is a valid certificate of its own Ed25519 key, and that the key is trusted
+func verifyPeerCertificate(name string, trust PeerTrust) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("%w: the peer sent no certificate", ErrUntrustedPeer)
+		}
+		certificate, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUntrustedPeer, err)
+		}
+		key, ok := certificate.PublicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: the certificate of the peer is not for an Ed25519 key", ErrUntrustedPeer)
+		}
+		if err := certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature); err != nil {
+			return fmt.Errorf("%w: the certificate of the peer is not signed with its key: %w", ErrUntrustedPeer, err)
+		}
+		if now := time.Now(); now.Before(certificate.NotBefore) || now.After(certificate.NotAfter) {
+			return fmt.Errorf("%w: the certificate of the peer is not valid at this time", ErrUntrustedPeer)
+		}
+		if err := trust.Trust(name, key); err != nil {
+			return fmt.Errorf("%w: %w", ErrUntrustedPeer, err)
+		}
+		return nil
+	}
+}
+
+// Whether connecting to a peer failed because we do not trust it, or because it does not trust us
+func IsAuthFailure(err error) bool {
+	if errors.Is(err, ErrUntrustedPeer) {
+		return true
+	}
+	var transportErr *quic.TransportError
+	return errors.As(err, &transportErr) && transportErr.ErrorCode.IsCryptoError()
+}
+
+// Returns the key of the peer at the other end of a TLS connection, once it was verified
+func PeerKeyOf(state tls.ConnectionState) ed25519.PublicKey {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	key, _ := state.PeerCertificates[0].PublicKey.(ed25519.PublicKey)
+	return key
+}
+
+// How peer keys are written down, in known peers files among others
+func FormatPeerKey(key ed25519.PublicKey) string {
+	return hex.EncodeToString(key)
+}
+
+func ParsePeerKey(formatted string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(formatted)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("a peer key is %v bytes long, not %v", ed25519.PublicKeySize, len(key))
+	}
+	return key, nil
+}
+
+// PeerTrust decides which peers a node syncs with, by the keys they proved to hold
+type PeerTrust interface {
+	// Errors unless the peer with the key may sync with us. The name is the one we connected to it by, and empty when it connected to us.
+	Trust(name string, key ed25519.PublicKey) error
+}
+
+/*
+AnyPeer trusts every peer which proves to hold a key, whichever key it is. It is meant for networks whose every node is
+trusted, and has to be asked for explicitly.
+*/
+type AnyPeer struct{}
+
+func (AnyPeer) Trust(name string, key ed25519.PublicKey) error {
+	return nil
+}
+
+// PinnedPeers trusts the peers with the given keys, whatever they are called
+type PinnedPeers []ed25519.PublicKey
+
+func (p PinnedPeers) Trust(name string, key ed25519.PublicKey) error {
+	for _, pinned := range p {
+		if pinned.Equal(key) {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer %v is not pinned", FormatPeerKey(key))
+}
+
+/*
+KnownPeers trusts the key a peer we connect to has the first time we connect to it, and only that key from then on,
+the way SSH trusts hosts. Every line of its file holds the name of a peer and its key. Peers connecting to us have
+no name to be known by, so they are only let in when their key is known already or a Pinned key. The peers are only
+kept in memory when Path is empty, so that the zero value trusts peers on first use for as long as it lives.
+*/
+type KnownPeers struct {
+	Path   string
+	Pinned PinnedPeers // Peers trusted whatever their name, such as the ones allowed to connect to us
+
+	mu    sync.Mutex
+	peers map[string]ed25519.PublicKey
+}
+
+// Loads the known peers kept in a file, which is created once the first peer is known
+func LoadKnownPeers(path string) (*KnownPeers, error) {
+	knownPeers := &KnownPeers{Path: path, peers: make(map[string]ed25519.PublicKey)}
+	encoded, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return knownPeers, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(encoded))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %v of %v is not a name followed by a key", line, path)
+		}
+		key, err := ParsePeerKey(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %v of %v: %w", line, path, err)
+		}
+		knownPeers.peers[fields[0]] = key
+	}
+	return knownPeers, scanner.Err()
+}
+
+func (k *KnownPeers) Trust(name string, key ed25519.PublicKey) error {
+	if k.Pinned.Trust(name, key) == nil {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if name == "" {
+		for _, known := range k.peers {
+			if known.Equal(key) {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer %v is not known", FormatPeerKey(key))
+	}
+	if known, found := k.peers[name]; found {
+		if !known.Equal(key) {
+			return fmt.Errorf("peer %v changed its key from %v to %v", name, FormatPeerKey(known), FormatPeerKey(key))
+		}
+		return nil
+	}
+
+	// Trust on first use
+	if k.Path != "" {
+		file, err := os.OpenFile(k.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := fmt.Fprintf(file, "%v %v\n", name, FormatPeerKey(key)); err != nil {
+			return err
+		}
+	}
+	if k.peers == nil {
+		k.peers = make(map[string]ed25519.PublicKey)
+	}
+	k.peers[name] = key
+	return nil
+}
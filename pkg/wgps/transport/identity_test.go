@@ -0,0 +1,193 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+func newTestIdentity(t *testing.T) *Identity {
+	t.Helper()
+	identity, err := NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestLoadOrCreateIdentityKeepsTheKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.pem")
+	created, err := LoadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadOrCreateIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.PublicKey().Equal(loaded.PublicKey()) {
+		t.Error("expected the identity to be the same once loaded again")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected only the owner to read the identity, its mode is %v", info.Mode().Perm())
+	}
+}
+
+func TestQuicAuthenticatesPinnedPeers(t *testing.T) {
+	alfieIdentity, bettyIdentity := newTestIdentity(t), newTestIdentity(t)
+	serverConfig, err := bettyIdentity.ServerTLSConfig(PinnedPeers{alfieIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := NewQuicListener("localhost:4246", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan wgpstypes.Transport, 2)
+	go func() {
+		for {
+			betty, _, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- betty
+		}
+	}()
+
+	clientConfig, err := alfieIdentity.ClientTLSConfig("localhost:4246", PinnedPeers{bettyIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alfie, err := DialQuic("localhost:4246", clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alfie.Close()
+	if err := alfie.Send([]byte("hi betty"), wgpstypes.ControlChannel); err != nil {
+		t.Fatal(err)
+	}
+	betty := (<-accepted).(*QuicTransport)
+	defer betty.Close()
+	if received, err := betty.Recv(wgpstypes.ControlChannel); err != nil || !bytes.Equal(received, []byte("hi betty")) {
+		t.Fatalf("expected the message of alfie, received %q with %v", received, err)
+	}
+	if !alfie.PeerKey().Equal(bettyIdentity.PublicKey()) || !betty.PeerKey().Equal(alfieIdentity.PublicKey()) {
+		t.Error("expected the peers to know each other's keys")
+	}
+
+	// Alfie does not sync with a peer posing as betty
+	posingConfig, err := alfieIdentity.ClientTLSConfig("localhost:4246", PinnedPeers{newTestIdentity(t).PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posing, err := DialQuic("localhost:4246", posingConfig); err == nil {
+		posing.Close()
+		t.Error("expected alfie to refuse a peer which is not pinned")
+	}
+
+	// Betty does not sync with a stranger, though the stranger trusts her
+	strangerConfig, err := newTestIdentity(t).ClientTLSConfig("localhost:4246", PinnedPeers{bettyIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stranger, err := DialQuic("localhost:4246", strangerConfig); err == nil {
+		defer stranger.Close()
+		if _, err := stranger.Recv(wgpstypes.ControlChannel); err == nil {
+			t.Error("expected betty to refuse a peer which is not pinned")
+		}
+	}
+}
+
+func TestKnownPeersTrustTheFirstKeyOfAPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_peers")
+	knownPeers, err := LoadKnownPeers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bettyKey, impostorKey := newTestIdentity(t).PublicKey(), newTestIdentity(t).PublicKey()
+	if err := knownPeers.Trust("betty:4242", bettyKey); err != nil {
+		t.Fatalf("expected a new peer to be trusted, got %v", err)
+	}
+
+	// What was learnt is kept
+	knownPeers, err = LoadKnownPeers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := knownPeers.Trust("betty:4242", bettyKey); err != nil {
+		t.Errorf("expected a known peer to be trusted, got %v", err)
+	}
+	if err := knownPeers.Trust("betty:4242", impostorKey); err == nil {
+		t.Error("expected a known peer with another key not to be trusted")
+	}
+
+	// Peers connecting to us are only trusted once known
+	if err := knownPeers.Trust("", bettyKey); err != nil {
+		t.Errorf("expected a known peer connecting to us to be trusted, got %v", err)
+	}
+	if err := knownPeers.Trust("", impostorKey); err == nil {
+		t.Error("expected an unknown peer connecting to us not to be trusted")
+	}
+}
+
+func TestKnownPeersWithoutAFileKeepPeersInMemory(t *testing.T) {
+	var knownPeers KnownPeers
+	bettyKey, impostorKey := newTestIdentity(t).PublicKey(), newTestIdentity(t).PublicKey()
+	if err := knownPeers.Trust("betty:4242", bettyKey); err != nil {
+		t.Fatalf("expected a new peer to be trusted, got %v", err)
+	}
+	if err := knownPeers.Trust("betty:4242", impostorKey); err == nil {
+		t.Error("expected a known peer with another key not to be trusted")
+	}
+}
+
+func TestPeersAreOnlyLetInWhenAskedFor(t *testing.T) {
+	identity := newTestIdentity(t)
+	if _, err := identity.ServerTLSConfig(nil); !errors.Is(err, ErrNoPeerTrust) {
+		t.Errorf("expected a server without trust to be refused, got %v", err)
+	}
+	if _, err := identity.ClientTLSConfig("localhost:4254", nil); !errors.Is(err, ErrNoPeerTrust) {
+		t.Errorf("expected a client without trust to be refused, got %v", err)
+	}
+	if _, err := NewQuicListener("localhost:4254", nil); !errors.Is(err, ErrNoTLSConfig) {
+		t.Errorf("expected a listener without TLS configuration to be refused, got %v", err)
+	}
+	if _, err := DialQuic("localhost:4254", nil); !errors.Is(err, ErrNoTLSConfig) {
+		t.Errorf("expected dialing without TLS configuration to be refused, got %v", err)
+	}
+
+	// Anyone proving a key is let in when asked for
+	serverConfig, err := identity.ServerTLSConfig(AnyPeer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := NewQuicListener("localhost:4254", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if betty, _, err := listener.Accept(); err == nil {
+			defer betty.Close()
+			betty.Recv(0)
+		}
+	}()
+	clientConfig, err := newTestIdentity(t).ClientTLSConfig("localhost:4254", PinnedPeers{identity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alfie, err := DialQuic("localhost:4254", clientConfig)
+	if err != nil {
+		t.Fatalf("expected a stranger to be let in, got %v", err)
+	}
+	alfie.Close()
+}
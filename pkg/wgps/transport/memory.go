@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+/*
+Options of the network an in-memory transport pair simulates. The zero value delivers every message at once, the way
+a perfect network would.
+*/
+type MemoryTransportOpts struct {
+	Latency time.Duration // How long every message takes to arrive
+	// Bytes the link carries per second in each direction, unlimited when 0. Messages queue up behind each other on a slow link.
+	Bandwidth int
+	/*
+		Up to how much longer than the latency a message may take to arrive, chosen at random for every message. Messages
+		on different logical channels may overtake each other this way, as they may on separate QUIC streams. Messages
+		on the same logical channel still arrive in the order they were sent, which the Transport promises.
+	*/
+	Reorder time.Duration
+	Seed    int64 // Seeds the random delays, so a simulation can be replayed
+}
+
+/*
+MemoryTransport is one end of a pair of transports connected to each other within the same process, so sync sessions
+can be run without sockets. It implements wgpstypes.Transport.
+*/
+type MemoryTransport struct {
+	in  *memoryLink // Carries the messages the other end sends us
+	out *memoryLink // Carries the messages we send the other end
+}
+
+var _ wgpstypes.Transport = (*MemoryTransport)(nil)
+
+// Messages sent in one direction of an in-memory transport pair
+type memoryLink struct {
+	Opts MemoryTransportOpts
+
+	mu       sync.Mutex
+	arrived  *sync.Cond
+	queues   map[wgpstypes.Channel][]memoryMessage
+	random   *rand.Rand
+	busyTill time.Time                       // When the link is done carrying the messages sent so far
+	lastDue  map[wgpstypes.Channel]time.Time // When the last message sent on each logical channel arrives
+	closed   bool
+}
+
+type memoryMessage struct {
+	Bytes []byte
+	Due   time.Time // When the message arrives at the other end
+}
+
+func newMemoryLink(opts MemoryTransportOpts, seed int64) *memoryLink {
+	link := &memoryLink{
+		Opts:    opts,
+		queues:  make(map[wgpstypes.Channel][]memoryMessage),
+		random:  rand.New(rand.NewSource(seed)),
+		lastDue: make(map[wgpstypes.Channel]time.Time),
+	}
+	link.arrived = sync.NewCond(&link.mu)
+	return link
+}
+
+// Returns two transports connected to each other, whatever is sent on one is received on the other
+func NewMemoryTransportPair(opts MemoryTransportOpts) (*MemoryTransport, *MemoryTransport) {
+	// Each direction gets random delays of its own, which are the same whenever the seed is
+	alfieToBetty := newMemoryLink(opts, opts.Seed)
+	bettyToAlfie := newMemoryLink(opts, opts.Seed+1)
+	return &MemoryTransport{in: bettyToAlfie, out: alfieToBetty}, &MemoryTransport{in: alfieToBetty, out: bettyToAlfie}
+}
+
+// Send queues the bytes for the other end, they arrive on the given logical channel once the simulated network carried them
+func (m *MemoryTransport) Send(data []byte, channel wgpstypes.Channel) error {
+	link := m.out
+	link.mu.Lock()
+	defer link.mu.Unlock()
+	if link.closed {
+		return fmt.Errorf("transport is closed")
+	}
+
+	now := time.Now()
+	sent := now
+	if link.busyTill.After(sent) {
+		sent = link.busyTill
+	}
+	if link.Opts.Bandwidth > 0 {
+		sent = sent.Add(time.Duration(len(data)) * time.Second / time.Duration(link.Opts.Bandwidth))
+	}
+	link.busyTill = sent
+
+	due := sent.Add(link.Opts.Latency)
+	if link.Opts.Reorder > 0 {
+		due = due.Add(time.Duration(link.random.Int63n(int64(link.Opts.Reorder))))
+	}
+	if lastDue := link.lastDue[channel]; lastDue.After(due) {
+		due = lastDue
+	}
+	link.lastDue[channel] = due
+
+	// The sender may reuse its buffer
+	bytes := make([]byte, len(data))
+	copy(bytes, data)
+	link.queues[channel] = append(link.queues[channel], memoryMessage{Bytes: bytes, Due: due})
+	link.arrived.Broadcast()
+	return nil
+}
+
+// Recv returns the next message which arrived on the given logical channel, and io.EOF once either end was closed and it is drained
+func (m *MemoryTransport) Recv(channel wgpstypes.Channel) ([]byte, error) {
+	link := m.in
+	link.mu.Lock()
+	for len(link.queues[channel]) == 0 && !link.closed {
+		link.arrived.Wait()
+	}
+	if len(link.queues[channel]) == 0 {
+		link.mu.Unlock()
+		return nil, io.EOF
+	}
+	message := link.queues[channel][0]
+	link.queues[channel] = link.queues[channel][1:]
+	link.mu.Unlock()
+
+	// Messages on a logical channel are due in order, so waiting for this one holds up none which are due earlier
+	time.Sleep(time.Until(message.Due))
+	return message.Bytes, nil
+}
+
+// Close stops both directions, the messages already sent are still received
+func (m *MemoryTransport) Close() error {
+	for _, link := range []*memoryLink{m.in, m.out} {
+		link.mu.Lock()
+		link.closed = true
+		link.arrived.Broadcast()
+		link.mu.Unlock()
+	}
+	return nil
+}
+
+// IsClosed tells whether either end was closed, which closes both
+func (m *MemoryTransport) IsClosed() bool {
+	m.out.mu.Lock()
+	defer m.out.mu.Unlock()
+	return m.out.closed
+}
@@ -0,0 +1,68 @@
+package transport
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+func TestMemoryTransportKeepsTheOrderOfEachChannel(t *testing.T) {
+	alfie, betty := NewMemoryTransportPair(MemoryTransportOpts{
+		Latency:   time.Millisecond,
+		Bandwidth: 1 << 20,
+		Reorder:   5 * time.Millisecond,
+		Seed:      42,
+	})
+
+	channels := []wgpstypes.Channel{wgpstypes.ReconciliationChannel, wgpstypes.DataChannel}
+	for i := 0; i < 20; i++ {
+		for _, channel := range channels {
+			if err := alfie.Send([]byte{byte(channel), byte(i)}, channel); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	alfie.Close()
+
+	for _, channel := range channels {
+		for i := 0; i < 20; i++ {
+			bytes, err := betty.Recv(channel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes[0] != byte(channel) || bytes[1] != byte(i) {
+				t.Fatalf("expected message %d of channel %v, received %v", i, channel, bytes)
+			}
+		}
+		// The messages sent before closing still arrived, and nothing comes after them
+		if _, err := betty.Recv(channel); err != io.EOF {
+			t.Errorf("expected the end of channel %v, received %v", channel, err)
+		}
+	}
+	if err := betty.Send([]byte{1}, wgpstypes.DataChannel); err == nil {
+		t.Error("expected sending on a closed transport to fail")
+	}
+}
+
+func TestMemoryTransportSimulatesBandwidth(t *testing.T) {
+	alfie, betty := NewMemoryTransportPair(MemoryTransportOpts{Bandwidth: 100_000})
+	defer alfie.Close()
+
+	start := time.Now()
+	// 2000 bytes take 20ms at 100000 bytes per second, however the messages are split up
+	for i := 0; i < 4; i++ {
+		if err := alfie.Send(make([]byte, 500), wgpstypes.DataChannel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := betty.Recv(wgpstypes.DataChannel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took < 20*time.Millisecond {
+		t.Errorf("expected the link to take at least 20ms, it took %v", took)
+	}
+}
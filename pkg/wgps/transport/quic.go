@@ -2,223 +2,286 @@ package transport
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
+	"crypto/ed25519"
 	"crypto/tls"
-	"crypto/x509"
-	"encoding/binary"
-	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math/big"
+	"net"
+	"sync"
 
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
-	"github.com/PES-Innovation-Lab/willow-go/utils"
 	"github.com/quic-go/quic-go"
 )
 
 //const addr = "0.0.0.0:4242"
 
+/*
+QuicTransport carries the logical channels of a single WGPS session over one QUIC connection, with a stream per logical
+channel. It is the same whether we initiated the connection or accepted it, the messenger only sees a wgpstypes.Transport.
+*/
 type QuicTransport struct {
-	AcceptedStreams  []quic.Stream
-	InitiatedStreams []quic.Stream
+	Streams    []quic.Stream
+	RemoteAddr net.Addr
 
 	Closed bool
+
+	// Guards the streams and whether the transport is closed, which change while the streams are accepted
+	mu   sync.Mutex
+	conn quic.Connection
+	// Closed once all the streams of the connection are open, or once openErr tells why they could not be
+	open    chan struct{}
+	openErr error
+	// The listener of a transport which accepts a single peer, which stops listening once the transport is closed
+	listener *quic.Listener
 }
 
-func NewQuicTransport(addr string) (*QuicTransport, error) {
-	acceptedStreams := make([]quic.Stream, 8)
-	initatedStreams := make([]quic.Stream, 8)
-	newQuicTransport := &QuicTransport{
-		AcceptedStreams:  acceptedStreams,
-		InitiatedStreams: initatedStreams,
-		Closed:           false,
-	}
+var _ wgpstypes.AuthenticatedTransport = (*QuicTransport)(nil)
 
-	go func() {
-		listener, err := quic.ListenAddr(addr, generateTLSConfig(), nil)
-		if err != nil {
-			log.Fatalf("Failed to set up listener: %v", err)
-		}
-		//fmt.Println("listening")
-		var conn quic.Connection
-		conn, err = listener.Accept(context.Background())
+// Size of the buffer the bytes arriving on a stream are read into
+const RECV_BUFFER_SIZE = 4096
 
-		if err != nil {
-			log.Fatalf("Failed to set up connection: %v", err)
-		}
+// Number of logical channels, each of which gets a stream of its own
+const CHANNEL_COUNT = 8
 
-		for i := 0; i < 8; i++ {
-			newQuicTransport.AcceptedStreams[i], err = conn.AcceptStream(context.Background())
-			fmt.Println("Accepted stream")
-			if err != nil {
-				log.Fatalf("Failed to set up stream: %v", err)
-			}
+func newQuicTransport(conn quic.Connection) *QuicTransport {
+	return &QuicTransport{
+		Streams:    make([]quic.Stream, CHANNEL_COUNT),
+		RemoteAddr: conn.RemoteAddr(),
+		Closed:     false,
+		conn:       conn,
+		open:       make(chan struct{}),
+	}
+}
 
-		}
+/*
+QuicListener keeps accepting peers on an address, every connection carries a WGPS session of its own. It implements
+wgpstypes.Listener.
+*/
+type QuicListener struct {
+	listener *quic.Listener
+}
 
-	}()
+var _ wgpstypes.Listener = (*QuicListener)(nil)
 
-	return newQuicTransport, nil
+// Listens on the given address, letting in the peers tlsConfig lets in. See Identity.ServerTLSConfig for how to make one.
+func NewQuicListener(addr string, tlsConfig *tls.Config) (*QuicListener, error) {
+	if tlsConfig == nil {
+		return nil, ErrNoTLSConfig
+	}
+	listener, err := quic.ListenAddr(addr, tlsConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &QuicListener{listener: listener}, nil
+}
+
+/*
+Waits for the next peer to connect, and returns the transport carrying the session with it. The streams of the
+connection are accepted in the background, so a slow peer does not hold up the ones connecting after it.
+*/
+func (l *QuicListener) Accept() (wgpstypes.Transport, net.Addr, error) {
+	conn, err := l.listener.Accept(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	newQuicTransport := newQuicTransport(conn)
+	go newQuicTransport.acceptStreams()
+	return newQuicTransport, conn.RemoteAddr(), nil
+}
 
+// Stops accepting peers, the sessions with the peers accepted so far go on
+func (l *QuicListener) Close() error {
+	return l.listener.Close()
 }
 
-func (q *QuicTransport) Initiate(addr string) error {
-	tlsConf := &tls.Config{
-		InsecureSkipVerify: true,
-		NextProtos:         []string{"Willow-Go-Quic"},
+/*
+Listens on the given address, the returned transport carries the session with the first peer which connects to it and
+which tlsConfig lets in.
+*/
+func ListenQuic(addr string, tlsConfig *tls.Config) (*QuicTransport, error) {
+	if tlsConfig == nil {
+		return nil, ErrNoTLSConfig
 	}
-	var conn quic.Connection
-	conn, err := quic.DialAddr(context.Background(), addr, tlsConf, nil)
+	listener, err := quic.ListenAddr(addr, tlsConfig, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	newQuicTransport := &QuicTransport{
+		Streams:  make([]quic.Stream, CHANNEL_COUNT),
+		Closed:   false,
+		open:     make(chan struct{}),
+		listener: listener,
 	}
 
-	for i := 0; i < 8; i++ {
-		q.InitiatedStreams[i], err = conn.OpenStreamSync(context.Background())
-		fmt.Println("Opened Stream")
-		if err != nil {
-
-			return err
-		}
-		//fmt.Println("Initiated stream")
-		var initBytes []byte
-		initBytes = append(initBytes, utils.BigIntToBytes(uint64(len(string(i))))...) // Length of the string
-		initBytes = append(initBytes, byte(i))                                        // The string
-		_, err = q.InitiatedStreams[i].Write(initBytes)
+	go func() {
+		conn, err := listener.Accept(context.Background())
 		if err != nil {
-			return err
+			log.Printf("Failed to set up connection: %v", err)
+			newQuicTransport.openErr = err
+			close(newQuicTransport.open)
+			return
 		}
-	}
-	return nil
+		newQuicTransport.mu.Lock()
+		newQuicTransport.RemoteAddr = conn.RemoteAddr()
+		newQuicTransport.conn = conn
+		newQuicTransport.mu.Unlock()
+		newQuicTransport.acceptStreams()
+	}()
+
+	return newQuicTransport, nil
 }
 
-func (q *QuicTransport) Send(data []byte, channel wgpstypes.Channel, role wgpstypes.SyncRole) error {
-	if q.Closed {
-		return fmt.Errorf("transport is closed")
-	}
-	if wgpstypes.IsAlfie(role) {
-		_, err := q.InitiatedStreams[channel].Write(data)
+// Accepts a stream for every logical channel, which the peer opened in turn
+func (q *QuicTransport) acceptStreams() {
+	defer close(q.open)
+	for i := 0; i < CHANNEL_COUNT; i++ {
+		stream, err := q.conn.AcceptStream(context.Background())
 		if err != nil {
-			return err
+			q.openErr = fmt.Errorf("failed to set up stream: %w", err)
+			return
+		}
+		// Streams are not necessarily accepted in the order they were opened, the first byte tells the logical channel
+		channel := make([]byte, 1)
+		if _, err = io.ReadFull(stream, channel); err != nil {
+			q.openErr = fmt.Errorf("failed to read the channel of a stream: %w", err)
+			q.conn.CloseWithError(0, q.openErr.Error())
+			return
+		}
+		q.mu.Lock()
+		if int(channel[0]) >= CHANNEL_COUNT {
+			err = fmt.Errorf("there is no logical channel %v", channel[0])
+		} else if q.Streams[channel[0]] != nil {
+			// Another channel would be left without a stream
+			err = fmt.Errorf("the logical channel %v has a stream already", channel[0])
+		} else {
+			q.Streams[channel[0]] = stream
 		}
-	} else {
-		_, err := q.AcceptedStreams[channel].Write(data)
+		q.mu.Unlock()
 		if err != nil {
-			return err
+			q.openErr = wgpstypes.ProtocolViolationError{Err: err}
+			q.conn.CloseWithError(0, q.openErr.Error())
+			return
 		}
 	}
-
-	return nil
-
 }
 
-func (t *QuicTransport) Close() error {
+/*
+Connects to the peer listening on the given address, the returned transport carries the session with it once
+tlsConfig trusts the peer. See Identity.ClientTLSConfig for how to make one.
+*/
+func DialQuic(addr string, tlsConfig *tls.Config) (*QuicTransport, error) {
+	if tlsConfig == nil {
+		return nil, ErrNoTLSConfig
+	}
+	conn, err := quic.DialAddr(context.Background(), addr, tlsConfig, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	// Close each stream
-	for _, stream := range t.AcceptedStreams {
-		if err := stream.Close(); err != nil {
-			return err
+	newQuicTransport := newQuicTransport(conn)
+	for i := 0; i < CHANNEL_COUNT; i++ {
+		newQuicTransport.Streams[i], err = conn.OpenStreamSync(context.Background())
+		if err == nil {
+			_, err = newQuicTransport.Streams[i].Write([]byte{byte(i)})
 		}
-	}
-	for _, stream := range t.InitiatedStreams {
-		if err := stream.Close(); err != nil {
-			return err
+		if err != nil {
+			// The streams opened so far go with the connection
+			conn.CloseWithError(0, "")
+			return nil, fmt.Errorf("failed to set up stream: %w", err)
 		}
 	}
-	// Mark the transport as closed
-	t.Closed = true
-
-	return nil
+	close(newQuicTransport.open)
+	return newQuicTransport, nil
 }
 
-func (q *QuicTransport) IsClosed() bool {
-	return q.Closed
+// Waits until the stream of the given channel is open
+func (q *QuicTransport) stream(channel wgpstypes.Channel) (quic.Stream, error) {
+	if int(channel) < 0 || int(channel) >= CHANNEL_COUNT {
+		return nil, fmt.Errorf("there is no logical channel %v", channel)
+	}
+	<-q.open
+	if q.openErr != nil {
+		return nil, q.openErr
+	}
+	q.mu.Lock()
+	stream := q.Streams[channel]
+	q.mu.Unlock()
+	if stream == nil {
+		return nil, fmt.Errorf("the logical channel %v has no stream", channel)
+	}
+	return stream, nil
 }
 
-func (q QuicTransport) Recv(writeTo chan []byte, channel wgpstypes.Channel, role wgpstypes.SyncRole) {
-	dataChan := make(chan []byte)
-	go func() {
-		if wgpstypes.IsAlfie(role) {
-			for {
-				buffer := make([]byte, 8) // Adjust buffer size as needed
-				if q.InitiatedStreams[channel] != nil {
-					_, err := io.ReadFull(q.InitiatedStreams[channel], buffer)
-					if err != nil {
-						// Handle Error
-						return
-					}
-					fmt.Println(buffer)
-					bytesWithLen := binary.BigEndian.Uint64(buffer[:8])
-					fmt.Printf("Length of message is %v\n", bytesWithLen)
-					newBuffer := make([]byte, bytesWithLen)
-					if q.InitiatedStreams[channel] != nil {
-						n, err := io.ReadFull(q.InitiatedStreams[channel], newBuffer)
-						if err != nil {
-							// Handle Error
-							return
-						}
-
-						fmt.Printf("Writing message now %v\n", string(newBuffer[:n]))
-						writeTo <- newBuffer[:n]
-					}
-				}
-			}
-		} else {
-			for {
-				buffer := make([]byte, 8)
-				if q.AcceptedStreams[channel] != nil {
-					_, err := io.ReadFull(q.AcceptedStreams[channel], buffer)
-					if err != nil {
-						// Handle Error
-						return
-					}
-					fmt.Println(buffer)
-					fmt.Printf("Length is %v\n", binary.BigEndian.Uint64(buffer))
-					bytesWithLen := binary.BigEndian.Uint64(buffer[:8])
-					newBuffer := make([]byte, bytesWithLen)
-					if q.AcceptedStreams[channel] != nil {
-						n, err := io.ReadFull(q.AcceptedStreams[channel], newBuffer)
-						if err != nil {
-							// Handle Error
-							return
-						}
-
-						fmt.Printf("Writing message now %v\n", string(newBuffer[:n]))
-						writeTo <- newBuffer[:n]
-					}
-				}
-			}
-		}
-	}()
-	for {
-		for data := range dataChan {
-			writeTo <- data
-		}
+// Send writes the bytes to the stream of the given logical channel. WGPS messages delimit themselves, so no framing is added.
+func (q *QuicTransport) Send(data []byte, channel wgpstypes.Channel) error {
+	if q.IsClosed() {
+		return fmt.Errorf("transport is closed")
 	}
-
+	stream, err := q.stream(channel)
+	if err != nil {
+		return err
+	}
+	_, err = stream.Write(data)
+	return err
 }
 
-func generateTLSConfig() *tls.Config {
-	key, err := rsa.GenerateKey(rand.Reader, 1024)
+// Recv returns the next bytes arriving on the stream of the given logical channel, and io.EOF once the stream or the connection ended
+func (q *QuicTransport) Recv(channel wgpstypes.Channel) ([]byte, error) {
+	stream, err := q.stream(channel)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	template := x509.Certificate{SerialNumber: big.NewInt(1)}
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
-	if err != nil {
-		panic(err)
+	buffer := make([]byte, RECV_BUFFER_SIZE)
+	n, err := stream.Read(buffer)
+	if n > 0 {
+		// The error shows up again on the next read
+		return buffer[:n], nil
+	}
+	// A peer closing the connection without an error is done, as if it ended the stream
+	var applicationErr *quic.ApplicationError
+	if errors.As(err, &applicationErr) && applicationErr.Remote && applicationErr.ErrorCode == 0 {
+		return nil, io.EOF
 	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
-	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	return nil, err
+}
 
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		panic(err)
+// Closes the streams, the connection and the listener of a transport which accepted a single peer, even if closing one of them fails
+func (q *QuicTransport) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Closed = true
+
+	var errs []error
+	for _, stream := range q.Streams {
+		if stream == nil {
+			continue
+		}
+		errs = append(errs, stream.Close())
+	}
+	if q.conn != nil {
+		errs = append(errs, q.conn.CloseWithError(0, ""))
+	}
+	if q.listener != nil {
+		errs = append(errs, q.listener.Close())
 	}
-	return &tls.Config{
-		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"Willow-Go-Quic"},
+	return errors.Join(errs...)
+}
+
+func (q *QuicTransport) IsClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.Closed
+}
+
+// Returns the key the peer proved to hold during the TLS handshake, nil while no peer has connected
+func (q *QuicTransport) PeerKey() ed25519.PublicKey {
+	q.mu.Lock()
+	conn := q.conn
+	q.mu.Unlock()
+	if conn == nil {
+		return nil
 	}
+	return PeerKeyOf(conn.ConnectionState().TLS)
 }
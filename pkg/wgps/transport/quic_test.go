@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/quic-go/quic-go"
+)
+
+func TestQuicRefusesTwoStreamsForAChannel(t *testing.T) {
+	bettyIdentity := newTestIdentity(t)
+	serverConfig, err := bettyIdentity.ServerTLSConfig(AnyPeer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := NewQuicListener("localhost:4255", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan wgpstypes.Transport, 1)
+	go func() {
+		if betty, _, err := listener.Accept(); err == nil {
+			accepted <- betty
+		}
+	}()
+
+	// Every stream of the peer claims to be the control channel, which would leave the other channels without one
+	clientConfig, err := newTestIdentity(t).ClientTLSConfig("localhost:4255", PinnedPeers{bettyIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := quic.DialAddr(context.Background(), "localhost:4255", clientConfig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseWithError(0, "")
+	for i := 0; i < CHANNEL_COUNT; i++ {
+		stream, err := conn.OpenStreamSync(context.Background())
+		if err != nil {
+			break
+		}
+		if _, err := stream.Write([]byte{byte(wgpstypes.ControlChannel)}); err != nil {
+			break
+		}
+	}
+
+	betty := <-accepted
+	defer betty.Close()
+	if _, err := betty.Recv(wgpstypes.DataChannel); !errors.As(err, &wgpstypes.ProtocolViolationError{}) {
+		t.Errorf("expected a second stream for a channel to violate the protocol, got %v", err)
+	}
+	if err := betty.Send([]byte("hi"), wgpstypes.DataChannel); err == nil {
+		t.Error("expected nothing to be sent on a channel without a stream")
+	}
+}
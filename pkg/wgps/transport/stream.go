@@ -0,0 +1,296 @@
+package transport
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+// Most bytes a frame carries, larger messages are split up so the frames of other logical channels can go in between
+const MAX_FRAME_SIZE = 16 * 1024
+
+// A frame starts with the logical channel and the length of the bytes it carries
+const FRAME_HEADER_SIZE = 5
+
+/*
+StreamTransport multiplexes the logical channels of a WGPS session over a single ordered byte stream, such as a TCP
+connection, a TLS connection or a Unix domain socket. It works where UDP, and with it QUIC, does not get through.
+
+Every message is sent in frames of at most MAX_FRAME_SIZE bytes, each starting with the logical channel and the
+length of the frame. The logical channels with frames to send take turns, so a large payload on the data channel
+holds up a reconciliation message by one frame at most.
+*/
+type StreamTransport struct {
+	conn io.ReadWriteCloser
+
+	mu      sync.Mutex
+	changed *sync.Cond // Signalled whenever frames are queued or received, and once the transport is closed
+	// The messages waiting to be sent on each logical channel, and the logical channel which sent a frame last
+	sending  [CHANNEL_COUNT][]*streamSend
+	lastSent int
+	received [CHANNEL_COUNT][][]byte
+	err      error // Why no more bytes are received, set once the transport is closed
+	peerKey  ed25519.PublicKey
+
+	Closed bool
+}
+
+var _ wgpstypes.AuthenticatedTransport = (*StreamTransport)(nil)
+
+// A message being sent in frames
+type streamSend struct {
+	Frames [][]byte
+	Done   chan error
+}
+
+func newStreamTransport() *StreamTransport {
+	newStreamTransport := &StreamTransport{
+		lastSent: CHANNEL_COUNT - 1,
+	}
+	newStreamTransport.changed = sync.NewCond(&newStreamTransport.mu)
+	return newStreamTransport
+}
+
+// Carries a WGPS session over a connection which is already open
+func NewStreamTransport(conn io.ReadWriteCloser) *StreamTransport {
+	newStreamTransport := newStreamTransport()
+	newStreamTransport.start(conn)
+	return newStreamTransport
+}
+
+func (s *StreamTransport) start(conn io.ReadWriteCloser) {
+	var peerKey ed25519.PublicKey
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		// Accepted connections only shake hands once they are first used
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			s.shutDown(err)
+			return
+		}
+		peerKey = PeerKeyOf(tlsConn.ConnectionState())
+	}
+
+	s.mu.Lock()
+	s.conn = conn
+	s.peerKey = peerKey
+	closed := s.Closed
+	s.mu.Unlock()
+	// Closed before the peer connected
+	if closed {
+		conn.Close()
+		return
+	}
+	go s.write()
+	go s.read()
+}
+
+/*
+Listens on the given address, the returned transport carries the session with the first peer which connects to it.
+The network is "tcp" or "unix", and connections are wrapped in TLS unless tlsConfig is nil.
+*/
+func ListenStream(network, addr string, tlsConfig *tls.Config) (*StreamTransport, error) {
+	var listener net.Listener
+	var err error
+	if tlsConfig == nil {
+		listener, err = net.Listen(network, addr)
+	} else {
+		listener, err = tls.Listen(network, addr, tlsConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	newStreamTransport := newStreamTransport()
+
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Failed to set up connection: %v", err)
+			newStreamTransport.shutDown(err)
+			return
+		}
+		newStreamTransport.start(conn)
+	}()
+
+	return newStreamTransport, nil
+}
+
+// Connects to the peer listening on the given address, over TLS unless tlsConfig is nil
+func DialStream(network, addr string, tlsConfig *tls.Config) (*StreamTransport, error) {
+	var conn net.Conn
+	var err error
+	if tlsConfig == nil {
+		conn, err = net.Dial(network, addr)
+	} else {
+		conn, err = tls.Dial(network, addr, tlsConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewStreamTransport(conn), nil
+}
+
+// Send queues the bytes on the given logical channel, and returns once they are written to the connection
+func (s *StreamTransport) Send(data []byte, channel wgpstypes.Channel) error {
+	if int(channel) < 0 || int(channel) >= CHANNEL_COUNT {
+		return fmt.Errorf("there is no logical channel %v", channel)
+	}
+	send := &streamSend{Done: make(chan error, 1)}
+	for start := 0; start < len(data) || start == 0; start += MAX_FRAME_SIZE {
+		end := min(start+MAX_FRAME_SIZE, len(data))
+		frame := make([]byte, FRAME_HEADER_SIZE, FRAME_HEADER_SIZE+end-start)
+		frame[0] = byte(channel)
+		binary.BigEndian.PutUint32(frame[1:FRAME_HEADER_SIZE], uint32(end-start))
+		send.Frames = append(send.Frames, append(frame, data[start:end]...))
+	}
+
+	s.mu.Lock()
+	if s.Closed {
+		s.mu.Unlock()
+		return fmt.Errorf("transport is closed")
+	}
+	s.sending[channel] = append(s.sending[channel], send)
+	s.changed.Broadcast()
+	s.mu.Unlock()
+
+	return <-send.Done
+}
+
+// Writes the queued frames, one frame of every logical channel which has any at a time
+func (s *StreamTransport) write() {
+	for {
+		s.mu.Lock()
+		channel := -1
+		for !s.Closed && channel == -1 {
+			for i := 1; i <= CHANNEL_COUNT; i++ {
+				next := (s.lastSent + i) % CHANNEL_COUNT
+				if len(s.sending[next]) > 0 {
+					channel = next
+					break
+				}
+			}
+			if channel == -1 {
+				s.changed.Wait()
+			}
+		}
+		if s.Closed {
+			s.mu.Unlock()
+			return
+		}
+		s.lastSent = channel
+		send := s.sending[channel][0]
+		frame := send.Frames[0]
+		send.Frames = send.Frames[1:]
+		if len(send.Frames) == 0 {
+			s.sending[channel] = s.sending[channel][1:]
+		}
+		s.mu.Unlock()
+
+		if _, err := s.conn.Write(frame); err != nil {
+			// A message with frames left is still queued, and fails along with the others
+			if len(send.Frames) == 0 {
+				send.Done <- err
+			}
+			s.shutDown(err)
+			return
+		}
+		if len(send.Frames) == 0 {
+			send.Done <- nil
+		}
+	}
+}
+
+// Reads frames until the connection ends, and hands them to the logical channels they were sent on
+func (s *StreamTransport) read() {
+	header := make([]byte, FRAME_HEADER_SIZE)
+	for {
+		if _, err := io.ReadFull(s.conn, header); err != nil {
+			s.shutDown(err)
+			return
+		}
+		channel := int(header[0])
+		length := binary.BigEndian.Uint32(header[1:])
+		if channel >= CHANNEL_COUNT || length > MAX_FRAME_SIZE {
+			s.shutDown(fmt.Errorf("received a malformed frame for channel %v of %v bytes", channel, length))
+			return
+		}
+		bytes := make([]byte, length)
+		if _, err := io.ReadFull(s.conn, bytes); err != nil {
+			s.shutDown(err)
+			return
+		}
+		if length == 0 {
+			continue
+		}
+		s.mu.Lock()
+		s.received[channel] = append(s.received[channel], bytes)
+		s.changed.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// Recv returns the next bytes which arrived on the given logical channel, and an error once the connection ended and they are drained
+func (s *StreamTransport) Recv(channel wgpstypes.Channel) ([]byte, error) {
+	if int(channel) < 0 || int(channel) >= CHANNEL_COUNT {
+		return nil, fmt.Errorf("there is no logical channel %v", channel)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.received[channel]) == 0 && s.err == nil {
+		s.changed.Wait()
+	}
+	if len(s.received[channel]) == 0 {
+		return nil, s.err
+	}
+	bytes := s.received[channel][0]
+	s.received[channel] = s.received[channel][1:]
+	return bytes, nil
+}
+
+// Stops sending and receiving for good, the messages still waiting to be sent fail with err
+func (s *StreamTransport) shutDown(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.Closed = true
+	sending := s.sending
+	s.sending = [CHANNEL_COUNT][]*streamSend{}
+	conn := s.conn
+	s.changed.Broadcast()
+	s.mu.Unlock()
+
+	for _, sends := range sending {
+		for _, send := range sends {
+			send.Done <- fmt.Errorf("transport is closed: %w", err)
+		}
+	}
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func (s *StreamTransport) Close() error {
+	s.shutDown(io.EOF)
+	return nil
+}
+
+func (s *StreamTransport) IsClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Closed
+}
+
+// Returns the key the peer proved to hold during the TLS handshake, nil while no peer has connected or if the connection is not over TLS
+func (s *StreamTransport) PeerKey() ed25519.PublicKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peerKey
+}
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+)
+
+func TestStreamTransportSendsOverTlsAndUnixSockets(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "willow.sock")
+	alfieIdentity, bettyIdentity := newTestIdentity(t), newTestIdentity(t)
+	serverConfig, err := bettyIdentity.ServerTLSConfig(PinnedPeers{alfieIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig, err := alfieIdentity.ClientTLSConfig("localhost:4244", PinnedPeers{bettyIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connections := map[string]struct {
+		Network, Addr  string
+		Server, Client *tls.Config
+	}{
+		"tls":         {Network: "tcp", Addr: "localhost:4244", Server: serverConfig, Client: clientConfig},
+		"unix socket": {Network: "unix", Addr: socket},
+	}
+	for name, connection := range connections {
+		t.Run(name, func(t *testing.T) {
+			betty, err := ListenStream(connection.Network, connection.Addr, connection.Server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer betty.Close()
+			alfie, err := DialStream(connection.Network, connection.Addr, connection.Client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer alfie.Close()
+
+			// Larger than a frame, so it arrives in pieces
+			payload := bytes.Repeat([]byte("willow"), MAX_FRAME_SIZE)
+			go alfie.Send(payload, wgpstypes.DataChannel)
+			if err := betty.Send([]byte{1, 2, 3}, wgpstypes.ControlChannel); err != nil {
+				t.Fatal(err)
+			}
+
+			var received []byte
+			for len(received) < len(payload) {
+				chunk, err := betty.Recv(wgpstypes.DataChannel)
+				if err != nil {
+					t.Fatal(err)
+				}
+				received = append(received, chunk...)
+			}
+			if !bytes.Equal(received, payload) {
+				t.Errorf("received %d different bytes than the %d which were sent", len(received), len(payload))
+			}
+			if control, err := alfie.Recv(wgpstypes.ControlChannel); err != nil || !bytes.Equal(control, []byte{1, 2, 3}) {
+				t.Errorf("expected the control message, received %v with %v", control, err)
+			}
+
+			// Only the peers which shook hands over TLS know who they are syncing with
+			if authenticated := connection.Server != nil; authenticated != alfie.PeerKey().Equal(bettyIdentity.PublicKey()) || authenticated != betty.PeerKey().Equal(alfieIdentity.PublicKey()) {
+				t.Errorf("expected the peers to know each other's keys only over TLS")
+			}
+		})
+	}
+}
+
+// Records the logical channels of the frames written to it, and has nothing to read
+type recordingConn struct {
+	mu       sync.Mutex
+	channels []wgpstypes.Channel
+	closed   chan struct{}
+}
+
+func (c *recordingConn) Write(frame []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels = append(c.channels, wgpstypes.Channel(frame[0]))
+	return len(frame), nil
+}
+
+func (c *recordingConn) Read(bytes []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *recordingConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+func TestStreamTransportDoesNotStarveSmallMessages(t *testing.T) {
+	// The messages are queued before the connection is there, as they are while the connection is busy
+	alfie := newStreamTransport()
+	defer alfie.Close()
+	sent := make(chan error)
+	go func() { sent <- alfie.Send(make([]byte, 64*MAX_FRAME_SIZE), wgpstypes.DataChannel) }()
+	go func() { sent <- alfie.Send([]byte{42}, wgpstypes.ReconciliationChannel) }()
+	for queued := 0; queued < 2; {
+		alfie.mu.Lock()
+		queued = len(alfie.sending[wgpstypes.DataChannel]) + len(alfie.sending[wgpstypes.ReconciliationChannel])
+		alfie.mu.Unlock()
+	}
+
+	conn := &recordingConn{closed: make(chan struct{})}
+	alfie.start(conn)
+	for i := 0; i < 2; i++ {
+		if err := <-sent; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.channels) != 65 {
+		t.Fatalf("expected 65 frames, %d were written", len(conn.channels))
+	}
+	for i, channel := range conn.channels {
+		if channel == wgpstypes.ReconciliationChannel && i > 1 {
+			t.Errorf("the reconciliation message waited for %d frames of the payload", i)
+		}
+	}
+}
@@ -1,16 +1,17 @@
 package main
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
 	"time"
 
 	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/pai"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
 )
 
 func main() {
@@ -53,15 +54,14 @@ func main() {
 
 	WillowStore := (*pinagoladastore.InitStorage(types.NamespaceId("myspace")))
 	pinagoladastore.InitKDTree(&WillowStore)
-	newMessengerChan := make(chan wgps.NewMessengerReturn[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint], 1)
-	opts := wgps.WgpsMessengerOpts[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+	opts := wgps.WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
 		Schemes: wgpstypes.SyncSchemes[
 			string,
 			types.SubspaceId,
 			string,
 			string,
-			string,
-			int,
+			pai.X25519Group,
+			pai.X25519Scalar,
 			string,
 			types.SubspaceId,
 			string,
@@ -74,12 +74,22 @@ func main() {
 			[]byte,
 			uint,
 		]{
-			NamespaceScheme: pinagoladastore.TestNameSpaceScheme,
-			SubspaceScheme:  pinagoladastore.TestSubspaceScheme,
-			Payload:         pinagoladastore.TestPayloadScheme,
-			Fingerprint:     pinagoladastore.TestFingerprintScheme,
-			PathParams:      pinagoladastore.TestPathParams,
+			NamespaceScheme:    pinagoladastore.TestNameSpaceScheme,
+			SubspaceScheme:     pinagoladastore.TestSubspaceScheme,
+			Payload:            pinagoladastore.TestPayloadScheme,
+			Fingerprint:        pinagoladastore.TestFingerprintScheme,
+			PathParams:         pinagoladastore.TestPathParams,
+			AuthorisationToken: pinagoladastore.TestAuthorisationTokenScheme,
+			AccessControl:      pinagoladastore.TestAccessControlScheme,
+			SubspaceCap:        pinagoladastore.TestSubspaceCapScheme,
+			Pai:                pinagoladastore.TestPaiScheme,
+		},
+		// Both peers read all of myspace, which they find to share
+		Interests: map[*wgpstypes.ReadAuthorisation[string, string]][]types.AreaOfInterest{
+			{Capability: "myspace"}: {{Area: utils.FullArea()}},
 		},
+		// The demo peers let each other in without knowing the keys of one another
+		PeerTrust: transport.AnyPeer{},
 	}
 
 	testSets := []struct {
@@ -110,96 +120,17 @@ func main() {
 		WillowStore.Set(testSet.input, testSet.authOpts)
 	}
 
-	go wgps.NewWgpsMessenger(opts, newMessengerChan, "localhost:4241", WillowStore)
-	messenger := <-newMessengerChan
-	if messenger.Error != nil {
-		fmt.Println("Error in creating messenger:", messenger.Error)
+	messenger, err := wgps.NewWgpsMessenger(opts, "localhost:4241", WillowStore)
+	if err != nil {
+		fmt.Println("Error in creating messenger:", err)
 		return
 	}
 
 	fmt.Println("Messenger set up")
-	messenger.NewMessenger.Initiate("localhost:4242")
-	rangeToBeSent := types.Range3d{
-		PathRange: types.Range[types.Path]{
-			Start: types.Path{[]byte("path"), []byte("to"), []byte("entry1")},
-			End:   types.Path{[]byte("path"), []byte("to"), []byte("entry10")},
-		},
-		SubspaceRange: types.Range[types.SubspaceId]{
-			Start: types.SubspaceId("myspace"),
-			End:   types.SubspaceId("myspace1"),
-		},
-		TimeRange: types.Range[uint64]{
-			Start:   0,
-			End:     10,
-			OpenEnd: true,
-		},
+	err = messenger.Initiate("localhost:4242")
+	if err != nil {
+		fmt.Println("Error in initiating sync:", err)
+		return
 	}
-
-	summary := WillowStore.EntryDriver.Storage.Summarise(rangeToBeSent)
-	//encode using gob both summarise and range
-
-	encodedValue := EncodeSummary(summary, rangeToBeSent)
-
-	// hello1 := "Hello, world!"
-	// var hello1Bytes []byte
-	// hello1Bytes = append(hello1Bytes, utils.BigIntToBytes(uint64(len(hello1)))...)
-	// hello1Bytes = append(hello1Bytes, []byte(hello1)...)
-	// fmt.Printf("Sending %v now!!!\n", hello1Bytes)
-	// //r := bytes.NewReader(hello1Bytes)
-	// //// := binary.BigEndian.Uint64(hello1Bytes[:8])
-
-	// //fmt.Printf("Length of hello1Bytes is %v\n", intVal)
-
-	// hello2 := "Hello, world 2!"
-	// hello3 := "Hello, world 3!"
-	// var hello2Bytes []byte
-	// hello2Bytes = append(hello2Bytes, utils.BigIntToBytes(uint64(len(hello2)))...)
-	// hello2Bytes = append(hello2Bytes, []byte(hello2)...)
-	// var hello3Bytes []byte
-	// hello3Bytes = append(hello3Bytes, utils.BigIntToBytes(uint64(len(hello3)))...)
-	// hello3Bytes = append(hello3Bytes, []byte(hello3)...)
-	// messenger.NewMessenger.Transport.Send(hello1Bytes, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-	// messenger.NewMessenger.Transport.Send(hello2Bytes, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-	// messenger.NewMessenger.Transport.Send(hello3Bytes, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-	messenger.NewMessenger.Transport.Send(encodedValue, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
 	time.Sleep(time.Second * 2)
 }
-
-func EncodeSummary[Fingerprint string](value struct {
-	FingerPrint string
-	Size        uint64
-}, ourRange types.Range3d) []byte {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	encoder.Encode(struct {
-		value struct {
-			FingerPrint string
-			Size        uint64
-		}
-		ourRange types.Range3d
-	}{
-		value, ourRange,
-	})
-	return buffer.Bytes()
-}
-func DecodeSummary[Fingerprint string](value []byte) (struct {
-	FingerPrint string
-	Size        uint64
-}, types.Range3d) {
-	var decoded struct {
-		value struct {
-			FingerPrint string
-			Size        uint64
-		}
-		ourRange types.Range3d
-	}
-	buffer := bytes.NewBuffer(value)
-	decoder := gob.NewDecoder(buffer)
-	decoder.Decode(&decoded)
-	return struct {
-		FingerPrint string
-		Size        uint64
-	}{
-		decoded.value.FingerPrint, decoded.value.Size,
-	}, decoded.ourRange
-}
@@ -6,8 +6,11 @@ import (
 	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/pai"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
 )
 
 func main() {
@@ -48,16 +51,16 @@ func main() {
 	fmt.Println(bettyMessage)
 	return */
 
-	WillowStore := (*pinagoladastore.InitStorage(types.NamespaceId("thespace")))
-	newMessengerChan := make(chan wgps.NewMessengerReturn[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint], 1)
-	opts := wgps.WgpsMessengerOpts[string, types.SubspaceId, string, string, string, int, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+	WillowStore := (*pinagoladastore.InitStorage(types.NamespaceId("myspace")))
+	pinagoladastore.InitKDTree(&WillowStore)
+	opts := wgps.WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
 		Schemes: wgpstypes.SyncSchemes[
 			string,
 			types.SubspaceId,
 			string,
 			string,
-			string,
-			int,
+			pai.X25519Group,
+			pai.X25519Scalar,
 			string,
 			types.SubspaceId,
 			string,
@@ -70,12 +73,22 @@ func main() {
 			[]byte,
 			uint,
 		]{
-			NamespaceScheme: pinagoladastore.TestNameSpaceScheme,
-			SubspaceScheme:  pinagoladastore.TestSubspaceScheme,
-			Payload:         pinagoladastore.TestPayloadScheme,
-			Fingerprint:     pinagoladastore.TestFingerprintScheme,
-			PathParams:      pinagoladastore.TestPathParams,
+			NamespaceScheme:    pinagoladastore.TestNameSpaceScheme,
+			SubspaceScheme:     pinagoladastore.TestSubspaceScheme,
+			Payload:            pinagoladastore.TestPayloadScheme,
+			Fingerprint:        pinagoladastore.TestFingerprintScheme,
+			PathParams:         pinagoladastore.TestPathParams,
+			AuthorisationToken: pinagoladastore.TestAuthorisationTokenScheme,
+			AccessControl:      pinagoladastore.TestAccessControlScheme,
+			SubspaceCap:        pinagoladastore.TestSubspaceCapScheme,
+			Pai:                pinagoladastore.TestPaiScheme,
+		},
+		// Both peers read all of myspace, which they find to share
+		Interests: map[*wgpstypes.ReadAuthorisation[string, string]][]types.AreaOfInterest{
+			{Capability: "myspace"}: {{Area: utils.FullArea()}},
 		},
+		// The demo peers let each other in without knowing the keys of one another
+		PeerTrust: transport.AnyPeer{},
 	}
 
 	testSets := []struct {
@@ -105,12 +118,14 @@ func main() {
 		WillowStore.Set(testSet.input, testSet.authOpts)
 	}
 
-	go wgps.NewWgpsMessenger(opts, newMessengerChan, "localhost:4242", WillowStore)
-	messenger := <-newMessengerChan
-	if messenger.Error != nil {
-		fmt.Println("Error in creating messenger:", messenger.Error)
+	messenger, err := wgps.NewWgpsMessenger(opts, "localhost:4242", WillowStore)
+	if err != nil {
+		fmt.Println("Error in creating messenger:", err)
 		return
 	}
 	fmt.Println("Messenger set up")
-	select {}
+	// Sync with alfie until it is gone
+	if err := messenger.Session(wgpstypes.SyncRoleBetty).Wait(); err != nil {
+		fmt.Println("Sync ended:", err)
+	}
 }
@@ -1,23 +1,20 @@
 package wgps
 
 import (
-	"bytes"
-	"encoding/binary"
-	"encoding/gob"
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"log"
-	"reflect"
-	"time"
+	"sync"
 
-	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/data"
-	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/syncutils"
-
-	//"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/encoding"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/decoding"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/encoding"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/pai"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/reconciliation"
 
-	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/handlestore"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 	"github.com/PES-Innovation-Lab/willow-go/types"
@@ -25,55 +22,13 @@ import (
 	"golang.org/x/exp/constraints"
 )
 
-type NewMessengerReturn[
-
-	ReadCapability any,
-	Receiver types.SubspaceId,
-	SyncSignature,
-	ReceiverSecretKey,
-	PsiGroup any,
-	PsiScalar int,
-	SubspaceCapability any,
-	SubspaceReceiver types.SubspaceId,
-	SyncSubspaceSignature,
-	SubspaceSecretKey any,
-	Prefingerprint,
-	Fingerprint string,
-	AuthorisationToken,
-	StaticToken,
-	DynamicToken string,
-	AuthorisationOpts []byte,
-	K constraints.Unsigned,
-
-] struct {
-	NewMessenger *WgpsMessenger[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	]
-	Error error
-}
 type WgpsMessengerOpts[
 	ReadCapability any,
 	Receiver types.SubspaceId,
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -89,15 +44,17 @@ type WgpsMessengerOpts[
 	//Transport *wgpstypes.Transport
 	/** Sets the [`maximum payload size`](https://willowprotocol.org/specs/sync/index.html#peer_max_payload_size) for newWgpsMessenger peer, which is 2 to the power of the given number.
 	 *
-	 * The given power must be a natural number lesser than or equal to 64. */
-	//MaxPayloadSizePower int
+	 * The given power must be a natural number lesser than or equal to 64, and defaults to 64. */
+	MaxPayloadSizePower int
 
 	/** Sets the [`challenge_length`](https://willowprotocol.org/specs/sync/index.html#challenge_length) for the [Willow General Purpose Sync Protocol](https://willowprotocol.org/specs/sync/index.html#sync).*/
-	//ChallengeLength int
+	ChallengeLength int
 	/** Sets the [`challenge_hash_length`](https://willowprotocol.org/specs/sync/index.html#challenge_hash_length) for the [Willow General Purpose Sync Protocol](https://willowprotocol.org/specs/sync/index.html#sync).*/
-	//ChallengeHashLength int
-	/** Sets the [`challeng_hash`](https://willowprotocol.org/specs/sync/index.html#challenge_hash) for the [Willow General Purpose Sync Protocol](https://willowprotocol.org/specs/sync/index.html#sync).*/
-	//ChallengeHash func(bytes []byte) []byte
+	ChallengeHashLength int
+	/** Sets the [`challeng_hash`](https://willowprotocol.org/specs/sync/index.html#challenge_hash) for the [Willow General Purpose Sync Protocol](https://willowprotocol.org/specs/sync/index.html#sync).
+	 *
+	 * The challenge length, challenge hash length and challenge hash default to SHA-256 over 16 byte nonces, and must be set together. */
+	ChallengeHash func(bytes []byte) []byte
 	/** The parameter schemes used to configure the `WgpsMessenger` for sync. */
 
 	Schemes wgpstypes.SyncSchemes[
@@ -119,13 +76,38 @@ type WgpsMessengerOpts[
 		AuthorisationOpts,
 		K,
 	]
-	//Interests map[*wgpstypes.ReadAuthorisation[ReadCapability, SubspaceCapability]][]types.AreaOfInterest
+	/** The read authorisations we sync with, which private area intersection finds the ones the other peer shares with. Requires the Pai scheme. */
+	Interests map[*wgpstypes.ReadAuthorisation[ReadCapability, SubspaceCapability]][]types.AreaOfInterest
 
 	GetStore               wgpstypes.GetStoreFn[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]
 	TransformPayload       func(chunk []byte) []byte
 	ProcessReceivedPayload func(chunk []byte, entryLength uint64) []byte
+	// Number of bytes we buffer for the other peer on each logical channel, defaults to DEFAULT_CHANNEL_CAPACITY
+	ChannelCapacity uint64
+	// Number of handles of each type the other peer may have bound at once, defaults to DEFAULT_MAX_HANDLES
+	MaxHandles uint64
+	// Whether we want the payloads within an area of interest of ours sent along with the entries, all are eager if unset
+	IsEager func(aoi types.AreaOfInterest) bool
+	// Payloads longer than this are never sent along with the entries, defaults to reconciliation.EAGER_PAYLOAD_THRESHOLD
+	EagerPayloadThreshold uint64
+	// The identity we prove to the peers we sync with over QUIC, a new one which is forgotten once the process ends if unset
+	Identity *transport.Identity
+	/*
+		Decides which peers we sync with over QUIC, by the keys they prove to hold. Trusts the peers we connect to on
+		first use if unset, which lets in no peer connecting to us but the ones we connected to before. Give
+		transport.AnyPeer to let in any peer.
+	*/
+	PeerTrust transport.PeerTrust
+	/*
+		How fast every session sends and receives, and how many send payloads at a time, unlimited when unset. Control
+		and reconciliation messages are sent ahead of payloads, whichever limits are set.
+	*/
+	Limits SessionLimits
 }
 
+// Buffer capacity of each logical channel, enough for the largest messages we send
+const DEFAULT_CHANNEL_CAPACITY = 1 << 16
+
 /** Coordinates an open-ended synchronisation session between two peers using the [Willow General Purpose Sync Protocol](https://willowprotocol.org/specs/sync/index.html#sync).
  */
 type WgpsMessenger[
@@ -134,7 +116,7 @@ type WgpsMessenger[
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -147,11 +129,50 @@ type WgpsMessenger[
 	AuthorisationOpts []byte,
 	K constraints.Unsigned,
 ] struct {
-	Closed bool
-	//Interests map[*wgpstypes.ReadAuthorisation[ReadCapability, SubspaceCapability]][]types.AreaOfInterest
-	Transport *transport.QuicTransport
+	Closed    bool
+	Interests map[*wgpstypes.ReadAuthorisation[ReadCapability, SubspaceCapability]][]types.AreaOfInterest
+	// The sessions with the peer we connected to, and with the peer which connected to us, nil until they start
+	InitiatorSession *Session[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]
+	AcceptedSession *Session[
+		ReadCapability,
+		Receiver,
+		SyncSignature,
+		ReceiverSecretKey,
+		PsiGroup,
+		PsiScalar,
+		SubspaceCapability,
+		SubspaceReceiver,
+		SyncSubspaceSignature,
+		SubspaceSecretKey,
+		Prefingerprint,
+		Fingerprint,
+		AuthorisationToken,
+		StaticToken,
+		DynamicToken,
+		AuthorisationOpts,
+		K,
+	]
 
-	/*InitiatorEncoder *encoding.MessageEncoder[
+	// Encode the messages we send to the peer we connected to, and to the peer which connected to us
+	InitiatorEncoder *encoding.MessageEncoder[
 		ReadCapability,
 		Receiver,
 		SyncSignature,
@@ -188,24 +209,43 @@ type WgpsMessenger[
 		DynamicToken,
 		AuthorisationOpts,
 		K,
-	] */
-	// Initiator side
-	InitiatorOutChannelReconciliation GuaranteedQueue
-	InitiatorOutChannelData           GuaranteedQueue
-	InitiatorOutChannelIntersection   GuaranteedQueue
-	InitiatorOutChannelCapability     GuaranteedQueue
-	InitiatorOutChannelAreaOfInterest GuaranteedQueue
-	InitiatorOutChannelPayloadRequest GuaranteedQueue
-	InitiatorOutChannelStaticToken    GuaranteedQueue
+	]
 
-	// Accepted side
-	AcceptedOutChannelReconciliation GuaranteedQueue
-	AcceptedOutChannelData           GuaranteedQueue
-	AcceptedOutChannelIntersection   GuaranteedQueue
-	AcceptedOutChannelCapability     GuaranteedQueue
-	AcceptedOutChannelAreaOfInterest GuaranteedQueue
-	AcceptedOutChannelPayloadRequest GuaranteedQueue
-	AcceptedOutChannelStaticToken    GuaranteedQueue
+	// Reconciliation with the peer we connected to, and with the peer which connected to us
+	InitiatorReconciliation *reconciliation.Engine[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]
+	AcceptedReconciliation  *reconciliation.Engine[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]
+
+	// The messages of either peer arrive on several logical channels at once, but an Engine is not safe for concurrent use
+	initiatorMu sync.Mutex
+	acceptedMu  sync.Mutex
+	// Entries ingested into our store which were not forwarded to the peers yet, signalled on ingestedSignal
+	ingested            []ingestedEntry[AuthorisationToken]
+	ingestedMu          sync.Mutex
+	ingestedSignal      chan struct{}
+	unsubscribeIngested func()
+	// Entries forwarded before we held all of their payload, by its digest, which are forwarded again once we do
+	awaitingPayload     map[types.PayloadDigest][]ingestedEntry[AuthorisationToken]
+	awaitingPayloadMu   sync.Mutex
+	unsubscribePayloads func()
+	// Payloads a session ended in the middle of, which the next session able to read them resumes
+	interrupted   []data.PayloadRequest
+	interruptedMu sync.Mutex
+	// Broadcast whenever the other peer binds a handle or replies to a fragment, which messages on other channels may be waiting for
+	initiatorHandlesBound *sync.Cond
+	acceptedHandlesBound  *sync.Cond
+	// The messages we send on every logical channel but the control channel wait here for guarantees of the other peer
+	InitiatorOutChannels map[wgpstypes.Channel]*GuaranteedQueue
+	AcceptedOutChannels  map[wgpstypes.Channel]*GuaranteedQueue
+
+	// The room we guarantee the other peer on every logical channel but the control channel
+	InitiatorInBuffers map[wgpstypes.Channel]*ReceiveBuffer
+	AcceptedInBuffers  map[wgpstypes.Channel]*ReceiveBuffer
+	ChannelCapacity    uint64
+
+	// The resource handles bound with the peer we connected to, and with the peer which connected to us
+	InitiatorHandles *SessionHandles[ReadCapability, PsiGroup, StaticToken]
+	AcceptedHandles  *SessionHandles[ReadCapability, PsiGroup, StaticToken]
+	MaxHandles       uint64
 
 	// Initiator side
 	InitiatorInChannelReconciliation chan wgpstypes.ReconciliationChannelMsg
@@ -228,12 +268,14 @@ type WgpsMessenger[
 	AcceptedInChannelNone           chan wgpstypes.SyncMessage
 
 	// Commitment scheme
-	//MaxPayloadSizePower int
-	//ChallengeHash            func(bytes []byte) []byte
-	//Nonce                    []byte
-	//OurChallenge             []byte //Supposed to be async, need to see how newWgpsMessenger will affect it
-	//TheirChallenge           []byte //Supposed to be async, need to see how newWgpsMessenger will affect it
-	Schemes wgpstypes.SyncSchemes[
+	MaxPayloadSizePower int
+	ChallengeLength     int
+	ChallengeHashLength int
+	ChallengeHash       func(bytes []byte) []byte
+	// The nonces and challenges of the session with the peer we connected to, and with the peer which connected to us
+	InitiatorCommitment *Commitment
+	AcceptedCommitment  *Commitment
+	Schemes             wgpstypes.SyncSchemes[
 		ReadCapability,
 		Receiver,
 		SyncSignature,
@@ -252,50 +294,37 @@ type WgpsMessenger[
 		AuthorisationOpts,
 		K,
 	]
-	// Private area intersection
-	//HandleIntersectionOurs   handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
-	//HandleIntersectionTheirs handlestore.HandleStore[wgpstypes.Intersection[PsiGroup]]
-	//PaiFinder                pai.PaiFinder[ReadCapability, PsiGroup, PsiScalar, SubspaceCapability, K]
-
-	//Setup
-	//HandleCapsOurs   handlestore.HandleStore[ReadCapability]
-	//HandleCapsTheirs handlestore.HandleStore[ReadCapability]
-
-	HandlesAoisOurs   handlestore.HandleStore[types.AreaOfInterest]
-	HandlesAoisTheirs handlestore.HandleStore[types.AreaOfInterest]
-
-	HandlesStaticTokenOurs   handlestore.HandleStore[StaticToken]
-	HandlesStaticTokenTheirs handlestore.HandleStore[StaticToken]
+	// Private area intersection with the peer we connected to, and with the peer which connected to us
+	InitiatorPaiFinder *pai.PaiFinder[ReadCapability, PsiGroup, SubspaceCapability, PsiScalar, K]
+	AcceptedPaiFinder  *pai.PaiFinder[ReadCapability, PsiGroup, SubspaceCapability, PsiScalar, K]
+	// The read capabilities the other peer proved to hold, which cover every entry we send it
+	InitiatorCapFinder *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
+	AcceptedCapFinder  *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
+	// Find the read capabilities we bound, with which we request payloads
+	InitiatorCapFinderOurs *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
+	AcceptedCapFinderOurs  *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
+	// The pairs of areas of interest both peers bound which intersect, each of which is reconciled
+	InitiatorAoiFinder *reconciliation.AoiIntersectionFinder
+	AcceptedAoiFinder  *reconciliation.AoiIntersectionFinder
+	// Answer the payload requests of the peer we connected to, and of the peer which connected to us
+	InitiatorDataSender   *data.DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]
+	AcceptedDataSender    *data.DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]
+	IsEager               func(aoi types.AreaOfInterest) bool
+	EagerPayloadThreshold uint64
+	TransformPayload      func(chunk []byte) []byte
+	Identity              *transport.Identity
+	PeerTrust             transport.PeerTrust
+	Limits                SessionLimits
 
 	//Reconciliation
-	YourRangeCounter int
 	// GetStore         wgpstypes.GetStoreFn[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]
 	Store store.Store[Prefingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken]
-	// ReconcilerMap    reconciliation.ReconcilerMap[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken] //TODO: has to be changed to ReconcilerMap
-	//AoiIntersectionFinder     reconciliation.AoiIntersectionFinder
-	//Announcer                 reconciliation.Announcer
-	CurrentlyReceivingEntries struct {
-		Namespace types.NamespaceId
-		Range     types.Range3d
-		Remaining uint64
-		//IsReceiving bool
-	}
-	ReconciliationPayloadIngester data.PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts]
 
 	//Data
-	//CapFinder               CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
-	CurrentlySentEntry      types.Entry
-	CurrentlyReceivedEntry  types.Entry
-	CurrentlyReceivedOffset uint64
-
-	HandlesPayloadRequestsOurs   handlestore.HandleStore[data.PayloadRequest]
-	HandlesPayloadRequestsTheirs handlestore.HandleStore[data.PayloadRequest]
-
-	DataSender data.DataSender[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]
-
-	ReconcilerMap         reconciliation.ReconcilerMap[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken]
-	AoiIntersectionFinder reconciliation.AoiIntersectionFinder
-	DataPayloadIngester   data.PayloadIngester[Prefingerprint, Fingerprint, AuthorisationToken, AuthorisationOpts]
+	// Receive the replies to the payload requests we sent the peer we connected to, and the peer which connected to us
+	InitiatorDataPayloadIngester *data.PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]
+	AcceptedDataPayloadIngester  *data.PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]
+	ProcessReceivedPayload       func(chunk []byte, entryLength uint64) []byte
 }
 
 func NewWgpsMessenger[
@@ -304,7 +333,7 @@ func NewWgpsMessenger[
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
@@ -336,27 +365,27 @@ func NewWgpsMessenger[
 		AuthorisationOpts,
 		K,
 	],
-	newMessengerChan chan NewMessengerReturn[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	], addr string, // ONLY FOR TESTING!!!!
+	addr string,
 	Store store.Store[Prefingerprint, Fingerprint, K, AuthorisationOpts, AuthorisationToken],
-) {
+) (*WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+], error) {
 	var newWgpsMessenger WgpsMessenger[
 		ReadCapability,
 		Receiver,
@@ -376,87 +405,45 @@ func NewWgpsMessenger[
 		AuthorisationOpts,
 		K,
 	]
+	if Store.Listeners == nil {
+		return nil, ErrStoreWithoutListeners
+	}
+	// The sessions of the messenger share its copy of the store, which they take turns ingesting into
+	if Store.IngestionMutexLock == nil {
+		return nil, ErrStoreWithoutLock
+	}
 	var err error
 	newWgpsMessenger.Schemes = opts.Schemes
+	newWgpsMessenger.Interests = opts.Interests
 
 	newWgpsMessenger.Store = Store
 
-	newWgpsMessenger.InitiatorOutChannelReconciliation = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-
-	newWgpsMessenger.InitiatorOutChannelData = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
+	newWgpsMessenger.ChannelCapacity = opts.ChannelCapacity
+	if newWgpsMessenger.ChannelCapacity == 0 {
+		newWgpsMessenger.ChannelCapacity = DEFAULT_CHANNEL_CAPACITY
 	}
-
-	newWgpsMessenger.InitiatorOutChannelIntersection = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-
-	newWgpsMessenger.InitiatorOutChannelCapability = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-
-	newWgpsMessenger.InitiatorOutChannelAreaOfInterest = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-
-	newWgpsMessenger.InitiatorOutChannelPayloadRequest = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-	newWgpsMessenger.InitiatorOutChannelStaticToken = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
+	newWgpsMessenger.MaxHandles = opts.MaxHandles
+	if newWgpsMessenger.MaxHandles == 0 {
+		newWgpsMessenger.MaxHandles = DEFAULT_MAX_HANDLES
 	}
 
-	newWgpsMessenger.AcceptedOutChannelReconciliation = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
+	newWgpsMessenger.MaxPayloadSizePower = opts.MaxPayloadSizePower
+	if newWgpsMessenger.MaxPayloadSizePower == 0 {
+		newWgpsMessenger.MaxPayloadSizePower = DEFAULT_MAX_PAYLOAD_SIZE_POWER
 	}
-	newWgpsMessenger.AcceptedOutChannelData = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
+	newWgpsMessenger.ChallengeLength = opts.ChallengeLength
+	newWgpsMessenger.ChallengeHashLength = opts.ChallengeHashLength
+	newWgpsMessenger.ChallengeHash = opts.ChallengeHash
+	if newWgpsMessenger.ChallengeHash == nil {
+		newWgpsMessenger.ChallengeLength = DEFAULT_CHALLENGE_LENGTH
+		newWgpsMessenger.ChallengeHashLength = DEFAULT_CHALLENGE_HASH_LENGTH
+		newWgpsMessenger.ChallengeHash = defaultChallengeHash
 	}
-	newWgpsMessenger.AcceptedOutChannelIntersection = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
+	if newWgpsMessenger.MaxPayloadSizePower > 64 || newWgpsMessenger.ChallengeLength <= 0 || newWgpsMessenger.ChallengeHashLength <= 0 {
+		err = fmt.Errorf("invalid commitment scheme options")
 	}
-	newWgpsMessenger.AcceptedOutChannelCapability = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-	newWgpsMessenger.AcceptedOutChannelAreaOfInterest = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-
-	newWgpsMessenger.AcceptedOutChannelPayloadRequest = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
-	}
-	newWgpsMessenger.AcceptedOutChannelStaticToken = GuaranteedQueue{
-		Queue:         make(chan []byte, 32),
-		ReceivedBytes: make([]byte, 1),
-		OutGoingBytes: make([]byte, 1),
+	if len(newWgpsMessenger.Interests) > 0 && newWgpsMessenger.Schemes.Pai.GetScalar == nil {
+		err = fmt.Errorf("interests can only be synced with a PaiScheme")
 	}
 
 	newWgpsMessenger.InitiatorInChannelData = make(chan wgpstypes.DataChannelMsg, 32)
@@ -477,2131 +464,1561 @@ func NewWgpsMessenger[
 	newWgpsMessenger.AcceptedInChannelStaticToken = make(chan wgpstypes.StaticTokenChannelMsg, 32)
 	newWgpsMessenger.AcceptedInChannelAreaOfInterest = make(chan wgpstypes.AreaOfInterestChannelMsg, 32)
 
-	/* newWgpsMessenger.AcceptedEncoder = encoding.NewMessageEncoder[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	](newWgpsMessenger.Schemes,
-		struct {
-			reconciliation.ReconcileMsgTrackerOpts
-			//GetIntersectionPrivy  func(handle uint64) wgpstypes.ReadCapPrivy
-			//GetCap                func(handle uint64) ReadCapability
-			GetCurrentlySentEntry func() types.Entry
-		}{
-			reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-			},
-			func() types.Entry {
-
-				return newWgpsMessenger.CurrentlySentEntry
-
-			}},
-	)
-
-	newWgpsMessenger.InitiatorEncoder = encoding.NewMessageEncoder[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	](newWgpsMessenger.Schemes,
-		struct {
-			reconciliation.ReconcileMsgTrackerOpts
-			//GetIntersectionPrivy  func(handle uint64) wgpstypes.ReadCapPrivy
-			//GetCap                func(handle uint64) ReadCapability
-			GetCurrentlySentEntry func() types.Entry
-		}{
-			reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-			},
-			func() types.Entry {
-
-				return newWgpsMessenger.CurrentlySentEntry
-
-			}},
-	) */
-
-	newWgpsMessenger.HandlesStaticTokenOurs = handlestore.HandleStore[StaticToken]{
-		Map: handlestore.NewMap[StaticToken](),
+	newWgpsMessenger.IsEager = opts.IsEager
+	if newWgpsMessenger.IsEager == nil {
+		newWgpsMessenger.IsEager = func(aoi types.AreaOfInterest) bool { return true }
 	}
-
-	newWgpsMessenger.HandlesStaticTokenTheirs = handlestore.HandleStore[StaticToken]{
-		Map: handlestore.NewMap[StaticToken](),
+	newWgpsMessenger.EagerPayloadThreshold = opts.EagerPayloadThreshold
+	newWgpsMessenger.TransformPayload = opts.TransformPayload
+	newWgpsMessenger.ProcessReceivedPayload = opts.ProcessReceivedPayload
+	newWgpsMessenger.Identity = opts.Identity
+	newWgpsMessenger.PeerTrust = opts.PeerTrust
+	newWgpsMessenger.Limits = opts.Limits
+	if err == nil && newWgpsMessenger.Identity == nil {
+		newWgpsMessenger.Identity, err = transport.NewIdentity()
 	}
-	newWgpsMessenger.HandlesAoisOurs = handlestore.HandleStore[types.AreaOfInterest]{
-		Map: handlestore.NewMap[types.AreaOfInterest](),
+	if newWgpsMessenger.PeerTrust == nil {
+		newWgpsMessenger.PeerTrust = &transport.KnownPeers{}
 	}
 
-	newWgpsMessenger.HandlesAoisTheirs = handlestore.HandleStore[types.AreaOfInterest]{
-		Map: handlestore.NewMap[types.AreaOfInterest](),
+	if err != nil {
+		return nil, err
 	}
 
-	newWgpsMessenger.CurrentlySentEntry = utils.DefaultEntry(
-		newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-		newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-		newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-	)
-	newWgpsMessenger.CurrentlyReceivedEntry = utils.DefaultEntry(
-		newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-		newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-		newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-	)
-
-	newWgpsMessenger.HandlesPayloadRequestsOurs = handlestore.HandleStore[data.PayloadRequest]{
-		Map: handlestore.NewMap[data.PayloadRequest](),
+	// Keep the peers up to date with the entries ingested into our store from now on
+	newWgpsMessenger.ingestedSignal = make(chan struct{}, 1)
+	newWgpsMessenger.awaitingPayload = make(map[types.PayloadDigest][]ingestedEntry[AuthorisationToken])
+	newWgpsMessenger.unsubscribeIngested, err = newWgpsMessenger.Store.OnIngestEntry(newWgpsMessenger.queueIngested)
+	if err != nil {
+		return nil, err
 	}
-	newWgpsMessenger.HandlesPayloadRequestsTheirs = handlestore.HandleStore[data.PayloadRequest]{
-		Map: handlestore.NewMap[data.PayloadRequest](),
+	newWgpsMessenger.unsubscribePayloads, err = newWgpsMessenger.Store.OnIngestPayload(newWgpsMessenger.queueAwaitingPayload)
+	if err != nil {
+		return nil, err
 	}
-	newWgpsMessenger.DataSender = data.NewDataSender[
-		Prefingerprint,
-		Fingerprint,
-		K,
-		AuthorisationToken,
-		DynamicToken,
-		AuthorisationOpts,
-	](data.DataSenderOpts[
-		Prefingerprint,
-		Fingerprint,
-		K,
-		AuthorisationToken,
-		DynamicToken,
-		AuthorisationOpts,
-	]{
-		HandlesPayloadRequestsTheirs: newWgpsMessenger.HandlesPayloadRequestsTheirs,
-	})
-
-	// Reconciliation helpers
-
-	newWgpsMessenger.ReconcilerMap = reconciliation.ReconcilerMap[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken]{
-		Map: make(map[uint64]map[uint64]reconciliation.Reconciler[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken]),
+	go newWgpsMessenger.forwardIngested()
+
+	// Sync with whichever peer connects to the address as soon as it does. Without one the sessions are brought by AcceptOver.
+	if addr != "" {
+		tlsConfig, err := newWgpsMessenger.Identity.ServerTLSConfig(newWgpsMessenger.PeerTrust)
+		var acceptedTransport *transport.QuicTransport
+		if err == nil {
+			acceptedTransport, err = transport.ListenQuic(addr, tlsConfig)
+		}
+		if err == nil {
+			err = newWgpsMessenger.AcceptOver(acceptedTransport)
+		}
+		if err != nil {
+			newWgpsMessenger.Close()
+			return nil, err
+		}
 	}
-	newWgpsMessenger.AoiIntersectionFinder = *reconciliation.NewAoiIntersectionFinder(reconciliation.AoiIntersectionFinderOpts{
-		NamespaceScheme: newWgpsMessenger.Schemes.NamespaceScheme,
-		SubspaceScheme:  newWgpsMessenger.Schemes.SubspaceScheme,
-		HandlesOurs:     newWgpsMessenger.HandlesAoisOurs,
-		HandlesTheirs:   newWgpsMessenger.HandlesAoisTheirs,
-	})
-	newWgpsMessenger.ReconciliationPayloadIngester = data.NewPayloadIngester[
-		Prefingerprint,
-		Fingerprint,
-		K,
-		AuthorisationToken,
-		AuthorisationOpts,
-	](data.PayloadIngesterOpts[
-		Prefingerprint,
-		Fingerprint,
-		K,
-		AuthorisationToken,
-		AuthorisationOpts,
-	]{
-		GetStore:               opts.GetStore,
-		ProcessReceivedPayload: opts.ProcessReceivedPayload,
-	})
-
-	newWgpsMessenger.DataPayloadIngester = data.NewPayloadIngester[
-		Prefingerprint,
-		Fingerprint,
-		K,
-		AuthorisationToken,
-		AuthorisationOpts,
-	](data.PayloadIngesterOpts[
-		Prefingerprint,
-		Fingerprint,
-		K,
-		AuthorisationToken,
-		AuthorisationOpts,
-	]{
-		GetStore:               opts.GetStore,
-		ProcessReceivedPayload: opts.ProcessReceivedPayload,
-	})
 
-	newWgpsMessenger.Transport, err = transport.NewQuicTransport(addr)
-	fmt.Println("Listening Now!!")
-	if err != nil {
+	return &newWgpsMessenger, nil
+}
 
-		newMessengerChan <- NewMessengerReturn[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		]{
-			NewMessenger: &newWgpsMessenger,
-			Error:        err,
-		}
-		return
+// An entry ingested into our store, with the authorisation token it was ingested with
+type ingestedEntry[AuthorisationToken string] struct {
+	Entry         types.Entry
+	Authorisation AuthorisationToken
+	// The role towards the only peer the entry is forwarded to, it is forwarded to both peers if unset
+	Role wgpstypes.SyncRole
+}
 
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Initiate(addr string) error {
+	initiatorTransport, err := w.dial(addr)
+	if err != nil {
+		return err
 	}
+	return w.InitiateOver(initiatorTransport)
+}
 
-	/* go syncutils.AsyncReceive[encoding.EncodedSyncMessage](newWgpsMessenger.InitiatorEncoder.MessageChannel, func(msg encoding.EncodedSyncMessage) error {
-		switch msg.Channel {
-		case wgpstypes.ReconciliationChannel:
-			newWgpsMessenger.InitiatorOutChannelReconciliation.Push(msg.Message)
-		case wgpstypes.DataChannel:
-			newWgpsMessenger.InitiatorOutChannelData.Push(msg.Message)
-		case wgpstypes.IntersectionChannel:
-			newWgpsMessenger.InitiatorOutChannelIntersection.Push(msg.Message)
-		case wgpstypes.CapabilityChannel:
-			newWgpsMessenger.InitiatorOutChannelCapability.Push(msg.Message)
-		case wgpstypes.AreaOfInterestChannel:
-			newWgpsMessenger.InitiatorOutChannelAreaOfInterest.Push(msg.Message)
-		case wgpstypes.StaticTokenChannel:
-			newWgpsMessenger.InitiatorOutChannelStaticToken.Push(msg.Message)
-		case wgpstypes.PayloadRequestChannel:
-			newWgpsMessenger.InitiatorOutChannelPayloadRequest.Push(msg.Message)
-		default:
-			newWgpsMessenger.Transport.Send(msg.Message, wgpstypes.ControlChannel, wgpstypes.SyncRoleAlfie)
-		}
-		return nil
-	}, nil)
-
-	go syncutils.AsyncReceive[encoding.EncodedSyncMessage](newWgpsMessenger.AcceptedEncoder.MessageChannel, func(msg encoding.EncodedSyncMessage) error {
-		switch msg.Channel {
-		case wgpstypes.ReconciliationChannel:
-			newWgpsMessenger.AcceptedOutChannelReconciliation.Push(msg.Message)
-		case wgpstypes.DataChannel:
-			newWgpsMessenger.AcceptedOutChannelData.Push(msg.Message)
-		case wgpstypes.IntersectionChannel:
-			newWgpsMessenger.AcceptedOutChannelIntersection.Push(msg.Message)
-		case wgpstypes.CapabilityChannel:
-			newWgpsMessenger.AcceptedOutChannelCapability.Push(msg.Message)
-		case wgpstypes.AreaOfInterestChannel:
-			newWgpsMessenger.AcceptedOutChannelAreaOfInterest.Push(msg.Message)
-		case wgpstypes.StaticTokenChannel:
-			newWgpsMessenger.AcceptedOutChannelStaticToken.Push(msg.Message)
-		case wgpstypes.PayloadRequestChannel:
-			newWgpsMessenger.AcceptedOutChannelPayloadRequest.Push(msg.Message)
-		default:
-			newWgpsMessenger.Transport.Send(msg.Message, wgpstypes.ControlChannel, wgpstypes.SyncRoleBetty)
-
-		}
-		return nil
-	}, nil) */
-	/*
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelData.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.DataChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelReconciliation.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelPayloadRequest.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.PayloadRequestChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelStaticToken.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.StaticTokenChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelCapability.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.CapabilityChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelIntersection.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.IntersectionChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.AcceptedOutChannelAreaOfInterest.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.AreaOfInterestChannel, wgpstypes.SyncRoleBetty)
-			return err
-		}, nil)
-
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelData.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelReconciliation.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelPayloadRequest.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.PayloadRequestChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelStaticToken.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.StaticTokenChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelCapability.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.CapabilityChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelIntersection.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.IntersectionChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil)
-		go syncutils.AsyncReceive[[]byte](newWgpsMessenger.InitiatorOutChannelAreaOfInterest.Queue, func(value []byte) error {
-			err := newWgpsMessenger.Transport.Send(value, wgpstypes.AreaOfInterestChannel, wgpstypes.SyncRoleAlfie)
-			return err
-		}, nil) */
-
-	// initiatorControlChannelListener := make(chan []byte, 32)
-	initiatorReconciliationChannelListener := make(chan []byte, 32)
-	initiatorDataChannelListener := make(chan []byte, 32)
-	//initiatorIntersectionChannelListener := make(chan []byte, 32)
-	//initiatorCapabilityChannelListener := make(chan []byte, 32)
-	//initiatorAreaOfInterestChannelListener := make(chan []byte, 32)
-	//initiatorPayloadRequestChannelListener := make(chan []byte, 32)
-	//initiatorStaticTokenChannelListener := make(chan []byte, 32)
-	//acceptedControlChannelListener := make(chan []byte, 32)
-	acceptedReconciliationChannelListener := make(chan []byte, 32)
-	acceptedDataChannelListener := make(chan []byte, 32)
-	//acceptedIntersectionChannelListener := make(chan []byte, 32)
-	//acceptedCapabilityChannelListener := make(chan []byte, 32)
-	//acceptedAreaOfInterestChannelListener := make(chan []byte, 32)
-	//acceptedPayloadRequestChannelListener := make(chan []byte, 32)
-	//acceptedStaticTokenChannelListener := make(chan []byte, 32)
-
-	/*go syncutils.AsyncReceive[[]byte](initiatorReconciliationChannelListener, func(msg []byte) error {
-
-	}, nil) */
-
-	/*go syncutils.AsyncReceive[[]byte](acceptedReconciliationChannelListener, func(msg []byte) error {
-		fmt.Println(string(msg))
-		return nil
-
-	}, nil) */
-
-	/*go syncutils.AsyncReceive[[]byte](initiatorDataChannelListener, func(msg []byte) error {
-
-	}, nil) */
-
-	go syncutils.AsyncReceive[[]byte](acceptedDataChannelListener, func(msg []byte) error {
-		fmt.Println(string(msg))
-		return nil
-	}, nil)
-
-	go syncutils.AsyncReceive[[]byte](initiatorDataChannelListener, func(msg []byte) error {
-		// ADD LOGIC FOR DATA AS ALFIE
-		decodedEntry, payload := DecodeEntryPayload(msg)
-		newWgpsMessenger.HandleDataInitiator(decodedEntry, payload)
-		return nil
-	}, nil)
-
-	go syncutils.AsyncReceive[[]byte](acceptedReconciliationChannelListener, func(msg []byte) error {
-		// ADD LOGIC FOR RECONCILIATION AS BETTY
-		extendedFingerprint, range3d := DecodeSummary(msg)
-		newWgpsMessenger.HandleMsgReconciliationAcceptor(range3d, Fingerprint(extendedFingerprint.FingerPrint))
-		return nil
-	}, nil)
-
-	go syncutils.AsyncReceive[[]byte](initiatorReconciliationChannelListener, func(msg []byte) error {
-		// ADD LOGIC FOR RECONCILIATION AS ALFIE
-		decodedValue := DecodeRange(msg)
-		newWgpsMessenger.HandleMsgReconciliationInitiator(decodedValue)
-		return nil
-	}, nil)
-
-	go newWgpsMessenger.Transport.Recv(initiatorDataChannelListener, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-	go newWgpsMessenger.Transport.Recv(initiatorReconciliationChannelListener, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-
-	go newWgpsMessenger.Transport.Recv(acceptedDataChannelListener, wgpstypes.DataChannel, wgpstypes.SyncRoleBetty)
-	go newWgpsMessenger.Transport.Recv(acceptedReconciliationChannelListener, wgpstypes.DataChannel, wgpstypes.SyncRoleBetty)
-
-	/* if newWgpsMessenger.Transport != nil {
-		go newWgpsMessenger.Transport.Recv(initiatorControlChannelListener, wgpstypes.ControlChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorReconciliationChannelListener, wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorDataChannelListener, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorIntersectionChannelListener, wgpstypes.IntersectionChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorCapabilityChannelListener, wgpstypes.CapabilityChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorAreaOfInterestChannelListener, wgpstypes.AreaOfInterestChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorPayloadRequestChannelListener, wgpstypes.PayloadRequestChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(initiatorStaticTokenChannelListener, wgpstypes.StaticTokenChannel, wgpstypes.SyncRoleAlfie)
-		go newWgpsMessenger.Transport.Recv(acceptedControlChannelListener, wgpstypes.ControlChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedReconciliationChannelListener, wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedDataChannelListener, wgpstypes.DataChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedIntersectionChannelListener, wgpstypes.IntersectionChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedCapabilityChannelListener, wgpstypes.CapabilityChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedAreaOfInterestChannelListener, wgpstypes.AreaOfInterestChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedPayloadRequestChannelListener, wgpstypes.PayloadRequestChannel, wgpstypes.SyncRoleBetty)
-		go newWgpsMessenger.Transport.Recv(acceptedStaticTokenChannelListener, wgpstypes.StaticTokenChannel, wgpstypes.SyncRoleBetty)
-	} */
-	/*
-		decodedInitiatorControlChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorReconciliationChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorDataChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorIntersectionChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorCapabilityChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorAreaOfInterestChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorPayloadRequestChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedInitiatorStaticTokenChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedControlChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedReconciliationChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedDataChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedIntersectionChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedCapabilityChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedAreaOfInterestChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedPayloadRequestChannelListener := make(chan wgpstypes.SyncMessage, 32)
-		decodedAcceptedStaticTokenChannelListener := make(chan wgpstypes.SyncMessage, 32)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorControlChannelListener, decodedInitiatorControlChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorReconciliationChannelListener, decodedInitiatorReconciliationChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorDataChannelListener, decodedInitiatorDataChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorIntersectionChannelListener, decodedInitiatorIntersectionChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorCapabilityChannelListener, decodedInitiatorCapabilityChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorAreaOfInterestChannelListener, decodedInitiatorAreaOfInterestChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorPayloadRequestChannelListener, decodedInitiatorPayloadRequestChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			initiatorStaticTokenChannelListener, decodedInitiatorStaticTokenChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedControlChannelListener, decodedAcceptedControlChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedDataChannelListener, decodedAcceptedDataChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedReconciliationChannelListener, decodedAcceptedReconciliationChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedIntersectionChannelListener, decodedAcceptedIntersectionChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedPayloadRequestChannelListener, decodedAcceptedPayloadRequestChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedAreaOfInterestChannelListener, decodedAcceptedAreaOfInterestChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
-
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedStaticTokenChannelListener, decodedAcceptedStaticTokenChannelListener)
-
-		go decoding.DecodeMessages[
-			ReadCapability,
-			Receiver,
-			SyncSignature,
-			ReceiverSecretKey,
-			PsiGroup,
-			PsiScalar,
-			SubspaceCapability,
-			SubspaceReceiver,
-			SyncSubspaceSignature,
-			SubspaceSecretKey,
-			Prefingerprint,
-			Fingerprint,
-			AuthorisationToken,
-			StaticToken,
-			DynamicToken,
-			AuthorisationOpts,
-			K,
-		](decoding.DecodeMessageOpts[ReadCapability, Receiver, SyncSignature, ReceiverSecretKey, PsiGroup, PsiScalar, SubspaceCapability, SubspaceReceiver, SyncSubspaceSignature, SubspaceSecretKey, Prefingerprint, Fingerprint, AuthorisationToken, StaticToken, DynamicToken, AuthorisationOpts, K]{
-			Schemes: newWgpsMessenger.Schemes,
-			Reconcile: reconciliation.ReconcileMsgTrackerOpts{
-				DefaultNamespaceId:   newWgpsMessenger.Schemes.NamespaceScheme.DefaultNamespaceId,
-				DefaultSubspaceId:    newWgpsMessenger.Schemes.SubspaceScheme.MinimalSubspaceId,
-				DefaultPayloadDigest: newWgpsMessenger.Schemes.Payload.DefaultPayloadDigest,
-				HandleToNamespaceId: func(handle uint64) types.NamespaceId {
-					return newWgpsMessenger.AoiIntersectionFinder.HandleToNamespaceId(handle, false)
-				},
-				AoiHandlesToRange3d: func(senderAoiHandle uint64, receiverAoiHandle uint64) types.Range3d {
-					reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(senderAoiHandle, receiverAoiHandle)
-					return <-reconciler.Ranges
-				},
-			},
-			AoiHandlesToArea: func(senderHandle uint64, receiverHandle uint64) types.Area {
-				senderAoi, _ := newWgpsMessenger.HandlesAoisTheirs.Get(senderHandle)
-				receiverAoi, _ := newWgpsMessenger.HandlesAoisOurs.Get(receiverHandle)
-
-				intersectionArea := utils.IntersectArea(newWgpsMessenger.Schemes.SubspaceScheme.Order, senderAoi.Area, receiverAoi.Area)
-
-				return *intersectionArea
-			},
-			GetCurrentlyReceivedEntry: func() types.Entry {
-				return newWgpsMessenger.CurrentlyReceivedEntry
-			},
-			AoiHandlesToNamespace: func(senderHandle uint64, receiverHandle uint64) types.NamespaceId {
+// Connects to the peer listening on an address over QUIC, which has to prove it holds a key PeerTrust trusts
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) dial(addr string) (wgpstypes.Transport, error) {
+	// The peer is known by the address we dial
+	tlsConfig, err := w.Identity.ClientTLSConfig(addr, w.PeerTrust)
+	if err != nil {
+		return nil, err
+	}
+	initiatorTransport, err := transport.DialQuic(addr, tlsConfig)
+	if transport.IsAuthFailure(err) {
+		return nil, AuthFailureError{Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return initiatorTransport, nil
+}
 
-				reconciler, _ := newWgpsMessenger.ReconcilerMap.GetReconciler(
-					receiverHandle, senderHandle,
-				)
-				return reconciler.Store.NameSpaceId
-			},
-		},
-			acceptedCapabilityChannelListener, decodedAcceptedCapabilityChannelListener)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorControlChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
+// Syncs with the peer at the other end of a transport we opened, which need not run over QUIC
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) InitiateOver(initiatorTransport wgpstypes.Transport) error {
+	return w.NewSession(wgpstypes.SyncRoleAlfie, initiatorTransport).Start(context.Background())
+}
 
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
+// Syncs with the peer at the other end of a transport it opened, for messengers which do not listen on an address
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) AcceptOver(acceptedTransport wgpstypes.Transport) error {
+	return w.NewSession(wgpstypes.SyncRoleBetty, acceptedTransport).Start(context.Background())
+}
 
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
+// The latest session in the given role, which may have ended already, and nil if there was none
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Session(role wgpstypes.SyncRole) *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+] {
+	if wgpstypes.IsAlfie(role) {
+		w.initiatorMu.Lock()
+		defer w.initiatorMu.Unlock()
+		return w.InitiatorSession
+	}
+	w.acceptedMu.Lock()
+	defer w.acceptedMu.Unlock()
+	return w.AcceptedSession
+}
 
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
+/*
+Returns the key the peer we sync with in the given role proved to hold while connecting, which is the identity of the
+peer the session is with. There is none before the session starts, or if the transport does not authenticate peers.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) PeerKey(role wgpstypes.SyncRole) (ed25519.PublicKey, bool) {
+	session := w.Session(role)
+	if session == nil {
+		return nil, false
+	}
+	authenticated, ok := session.Transport.(wgpstypes.AuthenticatedTransport)
+	if !ok {
+		return nil, false
+	}
+	peerKey := authenticated.PeerKey()
+	return peerKey, peerKey != nil
+}
 
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) Close() error {
+	w.ingestedMu.Lock()
+	if !w.Closed && w.ingestedSignal != nil {
+		w.unsubscribeIngested()
+		w.unsubscribePayloads()
+		close(w.ingestedSignal)
+	}
+	w.Closed = true
+	w.ingestedMu.Unlock()
+	var err error
+	for _, role := range []wgpstypes.SyncRole{wgpstypes.SyncRoleAlfie, wgpstypes.SyncRoleBetty} {
+		session := w.Session(role)
+		if session == nil {
+			continue
+		}
+		if closeErr := session.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
 
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
+// Listens to the entries ingested into our store, which is called by whoever ingests them and so must not block
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) queueIngested(entry types.Entry, authorisation AuthorisationToken) {
+	w.queueForwarding(ingestedEntry[AuthorisationToken]{Entry: entry, Authorisation: authorisation})
+}
 
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+// Listens to the payloads completed in our store, and forwards the entries which were waiting for them again
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) queueAwaitingPayload(digest types.PayloadDigest) {
+	w.awaitingPayloadMu.Lock()
+	awaiting := w.awaitingPayload[digest]
+	delete(w.awaitingPayload, digest)
+	w.awaitingPayloadMu.Unlock()
+	for _, ingested := range awaiting {
+		w.queueForwarding(ingested)
+	}
+}
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) queueForwarding(ingested ingestedEntry[AuthorisationToken]) {
+	w.ingestedMu.Lock()
+	defer w.ingestedMu.Unlock()
+	if w.Closed {
+		return
+	}
+	w.ingested = append(w.ingested, ingested)
+	select {
+	case w.ingestedSignal <- struct{}{}:
+	default:
+	}
+}
 
-			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorDataChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
+/*
+Forwards the entries ingested into our store to both peers until the messenger is closed. Entries are ingested while
+handling messages, so they are forwarded from here rather than from the listener, which may hold the lock of a session.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) forwardIngested() {
+	for range w.ingestedSignal {
+		w.ingestedMu.Lock()
+		ingested := w.ingested
+		w.ingested = nil
+		w.ingestedMu.Unlock()
+
+		for _, role := range []wgpstypes.SyncRole{wgpstypes.SyncRoleAlfie, wgpstypes.SyncRoleBetty} {
+			for _, entry := range ingested {
+				if entry.Role != "" && entry.Role != role {
+					continue
 				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
-			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorIntersectionChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
+				// There is nobody to forward to in a role without a session going on
+				if err := w.forwardEntry(role, entry); err != nil && !errors.Is(err, ErrSessionEnded) {
+					log.Printf("could not forward an entry to the peer we have role %v towards: %v", role, err)
 				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorPayloadRequestChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+		}
+	}
+}
 
-			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorCapabilityChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
+/*
+Sends an entry ingested into our store on the data channel to the peer we have the given role towards, if it is of
+interest to that peer in the areas of interest we reconcile with it. Its payload is sent along with it if the peer is
+eager for payloads there, the payload is within EagerPayloadThreshold and we hold all of it.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) forwardEntry(role wgpstypes.SyncRole, ingested ingestedEntry[AuthorisationToken]) error {
+	return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+		engine, dataSender := forRole(role, &w.InitiatorReconciliation, &w.AcceptedReconciliation), forRole(role, &w.InitiatorDataSender, &w.AcceptedDataSender)
+		if engine == nil {
+			return nil, nil
+		}
+		entry := ingested.Entry
+		shared, eager := engine.Shares(entry)
+		if !shared {
+			return nil, nil
+		}
 
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
+		var replies []wgpstypes.SyncMessage
+		staticToken, dynamicToken := w.Schemes.AuthorisationToken.DecomposeAuthToken(ingested.Authorisation)
+		staticTokenHandle, alreadyExisted := engine.GetStaticTokenHandle(staticToken)
+		if !alreadyExisted {
+			replies = append(replies, wgpstypes.MsgSetupBindStaticToken[StaticToken]{
+				Kind: wgpstypes.SetupBindStaticToken,
+				Data: wgpstypes.MsgSetupBindStaticTokenData[StaticToken]{StaticToken: staticToken},
+			})
+		}
 
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
+		// Starting at the end of the payload sends none of it
+		offset := entry.Payload_length
+		if eager && entry.Payload_length <= engine.EagerPayloadThreshold && w.holdsPayloadOrAwaits(role, ingested) {
+			offset = 0
+		}
+		if err := dataSender.QueueEntry(entry, staticTokenHandle, dynamicToken, offset); err != nil {
+			return nil, err
+		}
+		sent, err := dataSender.Messages()
+		return append(replies, sent...), err
+	})
+}
 
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
+/*
+Whether we hold all of the payload of an entry we forward to the peer we have the given role towards. If we do not
+yet, the entry is forwarded to that peer again once we do, as the payload of an entry we received may arrive after it.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) holdsPayloadOrAwaits(role wgpstypes.SyncRole, ingested ingestedEntry[AuthorisationToken]) bool {
+	// Held while checking, so that a payload completed meanwhile finds the entry awaiting it
+	w.awaitingPayloadMu.Lock()
+	defer w.awaitingPayloadMu.Unlock()
+	digest := ingested.Entry.Payload_digest
+	if w.Store.AvailablePayload(digest) == ingested.Entry.Payload_length {
+		return true
+	}
+	ingested.Role = role
+	w.awaitingPayload[digest] = append(w.awaitingPayload[digest], ingested)
+	return false
+}
 
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
+/*
+Starts syncing with the peer we connected to as alfie, or with the peer which connected to us as betty.
+Every logical channel of the transport is decoded into messages, which are handled in the order they arrive on
+their channel, and our replies are encoded and sent on the channels they belong to.
 
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
+Each read capability a peer binds is followed by the areas of interest of its authorisation, and every pair of
+areas of interest of both peers which intersect is reconciled. Only the entries the read capabilities of the other
+peer cover, and which are within the limits of both areas of interest, are sent to it.
 
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+Both peers start with their opening on the control channel, and reveal the nonce they committed to in it once the
+opening of the other peer arrived. Private area intersection then finds the namespaces and areas both peers have read
+access to, for which each of them binds its read capability. A peer whose nonce does not match its commitment, or which refers to handles it
+may not use, or binds more than MaxHandles handles of a type, ends the session.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) startSync(session *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) error {
+	role, sessionTransport := session.Role, session.Transport
+	var capFinder *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]
+	engine := reconciliation.NewEngine(reconciliation.EngineOpts[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken]{
+		Role:                     role,
+		Store:                    &w.Store,
+		AuthorisationTokenScheme: w.Schemes.AuthorisationToken,
+		EagerPayloadThreshold:    w.EagerPayloadThreshold,
+		MayRead: func(entry types.Entry) bool {
+			_, found := capFinder.FindCapHandle(entry)
+			return found
+		},
+	})
+	handles := NewSessionHandles[ReadCapability, PsiGroup](w.MaxHandles, &engine.StaticTokensOurs, &engine.StaticTokensTheirs)
+	capFinderOpts := Options[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K]{Handles: &handles.CapabilityTheirs}
+	capFinderOpts.Schemes.Namespace = w.Schemes.NamespaceScheme
+	capFinderOpts.Schemes.Subspace = w.Schemes.SubspaceScheme
+	capFinderOpts.Schemes.AccessControl = w.Schemes.AccessControl
+	capFinder = NewCapFinder(capFinderOpts)
+	capFinderOpts.Handles = &handles.CapabilityOurs
+	capFinderOurs := NewCapFinder(capFinderOpts)
+	aoiFinder := reconciliation.NewAoiIntersectionFinder(reconciliation.AoiIntersectionFinderOpts{
+		NamespaceScheme: w.Schemes.NamespaceScheme,
+		SubspaceScheme:  w.Schemes.SubspaceScheme,
+		HandlesOurs:     &handles.AreaOfInterestOurs,
+		HandlesTheirs:   &handles.AreaOfInterestTheirs,
+	})
+	dataSender := data.NewDataSender(data.DataSenderOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, DynamicToken, AuthorisationOpts]{
+		HandlesPayloadRequestsTheirs: &handles.PayloadRequestTheirs,
+		Store:                        &w.Store,
+		TransformPayload:             w.TransformPayload,
+	})
+	payloadIngester := data.NewPayloadIngester(data.PayloadIngesterOpts[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts]{
+		HandlesPayloadRequestsOurs: &handles.PayloadRequestOurs,
+		Store:                      &w.Store,
+		ProcessReceivedPayload:     w.ProcessReceivedPayload,
+	})
+	commitment, err := NewCommitment(role, w.ChallengeLength, w.ChallengeHash)
+	if err != nil {
+		return err
+	}
+	// Without a PaiScheme we take no part in private area intersection
+	var finder *pai.PaiFinder[ReadCapability, PsiGroup, SubspaceCapability, PsiScalar, K]
+	if w.Schemes.Pai.GetScalar != nil {
+		finder = pai.NewPaiFinder(pai.PaiFinderOpts[ReadCapability, PsiGroup, SubspaceCapability, PsiScalar, K]{
+			NamespaceScheme:           w.Schemes.NamespaceScheme,
+			PaiScheme:                 w.Schemes.Pai,
+			IntersectionHandlesOurs:   &handles.IntersectionOurs,
+			IntersectionHandlesTheirs: &handles.IntersectionTheirs,
+		})
+	}
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+	trackerOpts := reconciliation.ReconcileMsgTrackerOpts{
+		DefaultNamespaceId:   w.Schemes.NamespaceScheme.DefaultNamespaceId,
+		DefaultSubspaceId:    w.Schemes.SubspaceScheme.MinimalSubspaceId,
+		DefaultPayloadDigest: w.Schemes.Payload.DefaultPayloadDigest,
+		HandleToNamespaceId: func(aoiHandle uint64) types.NamespaceId {
+			return w.Store.NameSpaceId
+		},
+	}
+	// The first range a reconciliation message refers to is encoded relative to the intersection of its areas of interest
+	intersection := func(aoiHandleOurs, aoiHandleTheirs uint64) types.Range3d {
+		reconciler, err := engine.Reconcilers.GetReconciler(aoiHandleOurs, aoiHandleTheirs)
+		if err != nil {
+			return utils.DefaultRange3d(w.Schemes.SubspaceScheme.MinimalSubspaceId)
+		}
+		return reconciler.Range
+	}
 
+	encoderOpts := trackerOpts
+	encoderOpts.AoiHandlesToRange3d = func(senderAoiHandle, receiverAoiHandle uint64) types.Range3d {
+		return intersection(senderAoiHandle, receiverAoiHandle)
+	}
+	encoder := encoding.NewMessageEncoder(w.Schemes, struct {
+		reconciliation.ReconcileMsgTrackerOpts
+		GetIntersectionPrivy  func(handle uint64) (wgpstypes.ReadCapPrivy, error)
+		GetCapabilityArea     func(handle uint64) (types.Area, error)
+		GetCurrentlySentEntry func() types.Entry
+	}{
+		// Without GetCurrentlySentEntry entries are encoded relative to the empty entry, which the peers agree on
+		// whichever logical channel the entries are sent on
+		ReconcileMsgTrackerOpts: encoderOpts,
+		GetIntersectionPrivy: func(handle uint64) (wgpstypes.ReadCapPrivy, error) {
+			if finder == nil {
+				return wgpstypes.ReadCapPrivy{}, fmt.Errorf("private area intersection is not configured")
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorStaticTokenChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
+			return finder.GetIntersectionPrivy(handle, true)
+		},
+		// Messages are encoded while their replies are being sent, so the handles are not locked again
+		GetCapabilityArea: func(handle uint64) (types.Area, error) {
+			capability, found := handles.CapabilityOurs.Get(handle)
+			if !found {
+				return types.Area{}, fmt.Errorf("no read capability of ours is bound to handle %v", handle)
+			}
+			return w.Schemes.AccessControl.GetGrantedArea(capability), nil
+		},
+	})
 
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
+	decoderOpts := trackerOpts
+	decoderOpts.AoiHandlesToRange3d = func(senderAoiHandle, receiverAoiHandle uint64) types.Range3d {
+		return intersection(receiverAoiHandle, senderAoiHandle)
+	}
 
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
+	// Every logical channel but the control channel is subject to guarantees
+	outChannels := make(map[wgpstypes.Channel]*GuaranteedQueue)
+	inBuffers := make(map[wgpstypes.Channel]*ReceiveBuffer)
+	for channel := wgpstypes.ReconciliationChannel; channel <= wgpstypes.StaticTokenChannel; channel++ {
+		outChannels[channel] = NewGuaranteedQueue()
+		inBuffers[channel] = NewReceiveBuffer(w.ChannelCapacity)
+	}
 
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+	// A session takes the place of the last one in its role once that one is over
+	mu := &w.acceptedMu
+	if wgpstypes.IsAlfie(role) {
+		mu = &w.initiatorMu
+	}
+	handlesBound := sync.NewCond(mu)
+	mu.Lock()
+	previous := forRole(role, &w.InitiatorSession, &w.AcceptedSession)
+	if previous != nil && !previous.IsEnded() {
+		mu.Unlock()
+		return fmt.Errorf("already syncing with the peer we have role %v towards", role)
+	}
+	session.engine, session.handles = engine, handles
+	if wgpstypes.IsAlfie(role) {
+		w.InitiatorSession = session
+		w.InitiatorEncoder = encoder
+		w.InitiatorReconciliation = engine
+		w.InitiatorHandles = handles
+		w.InitiatorCommitment = commitment
+		w.InitiatorPaiFinder = finder
+		w.InitiatorCapFinder = capFinder
+		w.InitiatorAoiFinder = aoiFinder
+		w.InitiatorCapFinderOurs = capFinderOurs
+		w.InitiatorDataSender = dataSender
+		w.InitiatorDataPayloadIngester = payloadIngester
+		w.initiatorHandlesBound = handlesBound
+		w.InitiatorOutChannels = outChannels
+		w.InitiatorInBuffers = inBuffers
+	} else {
+		w.AcceptedSession = session
+		w.AcceptedEncoder = encoder
+		w.AcceptedReconciliation = engine
+		w.AcceptedHandles = handles
+		w.AcceptedCommitment = commitment
+		w.AcceptedPaiFinder = finder
+		w.AcceptedCapFinder = capFinder
+		w.AcceptedAoiFinder = aoiFinder
+		w.AcceptedCapFinderOurs = capFinderOurs
+		w.AcceptedDataSender = dataSender
+		w.AcceptedDataPayloadIngester = payloadIngester
+		w.acceptedHandlesBound = handlesBound
+		w.AcceptedOutChannels = outChannels
+		w.AcceptedInBuffers = inBuffers
+	}
+	mu.Unlock()
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+	// Once the session is over nothing more is sent, and whatever waits for the other peer stops waiting
+	session.onEnd(func() {
+		for _, outChannel := range outChannels {
+			outChannel.Close()
+		}
+		go func() {
+			// No more replies are encoded once the session is over, and the ones being encoded are drained below
+			mu.Lock()
+			close(encoder.MessageChannel)
+			handlesBound.Broadcast()
+			w.interrupt(payloadIngester.Abort(ErrSessionEnded))
+			w.interrupt(engine.Abort())
+			mu.Unlock()
+		}()
+	})
 
+	/*
+		A transport fails to send once either peer closed it or it broke down, and receiving tells which of the two it was,
+		so the session is left for the receiving end to end.
+	*/
+	send := func(bytes []byte, channel wgpstypes.Channel) {
+		sessionTransport.Send(bytes, channel)
+	}
+	// Messages are sent as they come out of the scheduler, within the limits of the session, each channel sending its own
+	scheduler := newSendScheduler(w.Limits.PayloadTransfers)
+	uploadLimiter, downloadLimiter := NewRateLimiter(w.Limits.UploadRate), NewRateLimiter(w.Limits.DownloadRate)
+	writes := make(map[wgpstypes.Channel]chan []byte)
+	for _, channel := range sendPriority {
+		write := make(chan []byte, 1)
+		writes[channel] = write
+		go func() {
+			for {
+				select {
+				case bytes := <-write:
+					if !session.IsEnded() {
+						send(bytes, channel)
+						session.sent()
+					}
+					scheduler.Sent(channel)
+				case <-session.Done():
+					return
+				}
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorReconciliationChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
+		}()
+	}
+	go func() {
+		defer scheduler.Stop()
+		for {
+			bytes, channel, ok := scheduler.Next(session.Done())
+			if !ok || !waitForRate(len(bytes), session.Done(), uploadLimiter, w.Limits.GlobalUpload) {
+				return
+			}
+			writes[channel] <- bytes
+		}
+	}()
+
+	// Our opening comes before any message
+	session.queued()
+	scheduler.Push(encoding.EncodeOpening(uint8(w.MaxPayloadSizePower), commitment.Ours()), wgpstypes.ControlChannel)
+	go func() {
+		for msg := range encoder.MessageChannel {
+			if session.IsEnded() {
+				continue
+			}
+			if msg.Channel == wgpstypes.ControlChannel {
+				scheduler.Push(msg.Message, msg.Channel)
+				continue
+			}
+			push := outChannels[msg.Channel].Push
+			if msg.Bulk {
+				push = outChannels[msg.Channel].PushBulk
+			}
+			if err := push(msg.Message); err != nil {
+				session.end(fmt.Errorf("could not send a message on channel %v: %w", msg.Channel, err))
+			}
+		}
+	}()
+	// Messages leave their queues once the other peer guaranteed to have room for them, and wait for their turn to be sent
+	for channel, outChannel := range outChannels {
+		go func() {
+			for {
+				select {
+				case msg := <-outChannel.Queue:
+					scheduler.push(msg, channel)
+				case <-session.Done():
+					return
 				}
+			}
+		}()
+	}
 
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
+	// A message which trips up its handler is as much the fault of the other peer as one which does not decode
+	handle := func(msg wgpstypes.SyncMessage) (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = ProtocolViolationError{Err: fmt.Errorf("handling a message of kind %v failed: %v", msg.GetKind(), recovered)}
+			}
+		}()
+		return w.handleMessage(session, msg)
+	}
 
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+	for channel := wgpstypes.ControlChannel; channel <= wgpstypes.StaticTokenChannel; channel++ {
+		received := make(chan []byte, 32)
+		decoded := make(chan wgpstypes.SyncMessage, 32)
+		// The lengths of the messages in decoded, whose room in the buffer is freed once they are handled
+		lengths := make(chan uint64, 32)
+		inBuffer := inBuffers[channel]
+
+		var acceptMessage func(length uint64) bool
+		if inBuffer != nil {
+			acceptMessage = func(length uint64) bool {
+				accept, startedDropping := inBuffer.Receive(length)
+				if startedDropping {
+					err := w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+						return []wgpstypes.SyncMessage{wgpstypes.MsgControlAnnounceDropping{
+							Kind: wgpstypes.ControlAnnounceDropping,
+							Data: wgpstypes.ControlAnnounceDroppingData{Channel: channel},
+						}}, nil
+					})
+					if err != nil && !session.IsEnded() {
+						log.Printf("could not announce dropping messages on channel %v: %v", channel, err)
+					}
+				}
+				if accept {
+					lengths <- length
+				}
+				return accept
+			}
+		}
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+		// The opening of the other peer comes before any message on the control channel, we reveal our nonce once it arrived
+		var receiveOpening func(maxPayloadSizePower uint8, commitment []byte)
+		if channel == wgpstypes.ControlChannel {
+			receiveOpening = func(maxPayloadSizePower uint8, theirCommitment []byte) {
+				err := w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+					return []wgpstypes.SyncMessage{commitment.ReceiveOpening(maxPayloadSizePower, theirCommitment)}, nil
+				})
+				if err != nil && !session.IsEnded() {
+					log.Printf("could not reveal our nonce: %v", err)
+				}
+			}
+		}
 
+		go func() {
+			defer close(received)
+			for {
+				bytes, err := sessionTransport.Recv(channel)
+				if err != nil {
+					session.lost(err)
+					return
+				}
+				// Bulk data waits for the bytes of the other channels, which are received right away
+				if channel != wgpstypes.DataChannel {
+					takeRate(len(bytes), downloadLimiter, w.Limits.GlobalDownload)
+				} else if !waitForRate(len(bytes), session.Done(), downloadLimiter, w.Limits.GlobalDownload) {
+					return
+				}
+				received <- bytes
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedInitiatorAreaOfInterestChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
+		}()
+		go func() {
+			err := decoding.DecodeMessages(decoding.DecodeMessageOpts[
+				ReadCapability,
+				Receiver,
+				SyncSignature,
+				ReceiverSecretKey,
+				PsiGroup,
+				PsiScalar,
+				SubspaceCapability,
+				SubspaceReceiver,
+				SyncSubspaceSignature,
+				SubspaceSecretKey,
+				Prefingerprint,
+				Fingerprint,
+				AuthorisationToken,
+				StaticToken,
+				DynamicToken,
+				AuthorisationOpts,
+				K,
+			]{
+				Reconcile:           decoderOpts,
+				Schemes:             w.Schemes,
+				AcceptMessage:       acceptMessage,
+				ChallengeLength:     w.ChallengeLength,
+				ChallengeHashLength: w.ChallengeHashLength,
+				ReceiveOpening:      receiveOpening,
+				// Areas of interest may overtake the read capabilities they are bound with, which arrive on another logical channel
+				GetCapabilityArea: func(handle uint64) (types.Area, error) {
+					handlesBound.L.Lock()
+					defer handlesBound.L.Unlock()
+					for !handles.CapabilityTheirs.WasBound(handle) {
+						if session.IsEnded() {
+							return types.Area{}, ErrSessionEnded
+						}
+						handlesBound.Wait()
+					}
+					capability, found := handles.CapabilityTheirs.Get(handle)
+					if !found {
+						return types.Area{}, fmt.Errorf("the other peer bound an area of interest with the freed read capability %v", handle)
+					}
+					return w.Schemes.AccessControl.GetGrantedArea(capability), nil
+				},
+			}, received, decoded)
+			// Bytes which do not decode are the fault of the other peer, unless the session ended in the middle of a message
+			if err != nil && !session.IsEnded() {
+				session.end(ProtocolViolationError{Err: fmt.Errorf("could not decode the messages on channel %v: %w", channel, err)})
+			}
+		}()
+		go func() {
+			// Messages which arrive after the session ended are drained without being handled
+			for msg := range decoded {
+				if !session.IsEnded() {
+					// A message which can not be handled leaves the session in a state the other peer does not know of
+					if err := handle(msg); err != nil {
+						session.end(fmt.Errorf("could not handle a message of kind %v: %w", msg.GetKind(), err))
+					}
 				}
+				if inBuffer == nil {
+					continue
+				}
+				inBuffer.Handled(<-lengths)
+				if session.IsEnded() {
+					continue
+				}
+				err := w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+					return issueGuarantees(inBuffers, channel), nil
+				})
+				if err != nil && !session.IsEnded() {
+					log.Printf("could not issue guarantees for channel %v: %v", channel, err)
+				}
+			}
+		}()
+	}
 
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
+	return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+		var replies []wgpstypes.SyncMessage
+		for channel := range inBuffers {
+			replies = append(replies, issueGuarantees(inBuffers, channel)...)
+		}
+		// Bind the fragments of our read authorisations, to find the ones the other peer shares
+		if finder != nil {
+			for authorisation := range w.Interests {
+				replies = append(replies, finder.SubmitAuthorisation(authorisation)...)
+			}
+		}
+		return replies, nil
+	})
+}
 
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
+// The guarantee to issue for a logical channel, if its buffer has enough room again
+func issueGuarantees(inBuffers map[wgpstypes.Channel]*ReceiveBuffer, channel wgpstypes.Channel) []wgpstypes.SyncMessage {
+	amount := inBuffers[channel].TopUp()
+	if amount == 0 {
+		return nil
+	}
+	return []wgpstypes.SyncMessage{wgpstypes.MsgControlIssueGuarantee{
+		Kind: wgpstypes.ControlIssueGuarantee,
+		Data: wgpstypes.ControlIssueGuaranteeData{Amount: amount, Channel: channel},
+	}}
+}
 
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
+// Shrinks the buffer we keep for the peer we have the given role towards on a logical channel, and pleads it to give up the guarantees beyond it
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) ShrinkChannelCapacity(role wgpstypes.SyncRole, channel wgpstypes.Channel, capacity uint64) error {
+	mu := &w.acceptedMu
+	if wgpstypes.IsAlfie(role) {
+		mu = &w.initiatorMu
+	}
+	mu.Lock()
+	inBuffer, found := forRole(role, &w.InitiatorInBuffers, &w.AcceptedInBuffers)[channel]
+	mu.Unlock()
+	if !found {
+		return fmt.Errorf("channel %v is not subject to guarantees", channel)
+	}
+	target, plead := inBuffer.Shrink(capacity)
+	if !plead {
+		return nil
+	}
+	return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+		return []wgpstypes.SyncMessage{wgpstypes.MsgControlPlead{
+			Kind: wgpstypes.ControlPlead,
+			Data: wgpstypes.ControlPleadData{Target: target, Channel: channel},
+		}}, nil
+	})
+}
 
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
+/*
+Frees a handle bound in the session with the peer we have the given role towards, either one we bound or one the
+other peer bound, and tells the other peer about it. Neither peer may use the handle afterwards, so it must only be
+freed once no message we send refers to it anymore.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) FreeHandle(role wgpstypes.SyncRole, handleType wgpstypes.HandleType, handle uint64, ours bool) error {
+	return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+		store, err := forRole(role, &w.InitiatorHandles, &w.AcceptedHandles).Store(handleType, ours)
+		if err != nil {
+			return nil, err
+		}
+		err = store.Free(handle)
+		if err != nil {
+			return nil, err
+		}
+		return []wgpstypes.SyncMessage{wgpstypes.MsgControlFree{
+			Kind: wgpstypes.ControlFree,
+			Data: wgpstypes.MsgControlFreeData{Handle: handle, Mine: ours, HandleType: handleType},
+		}}, nil
+	})
+}
 
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+/*
+Requests the payload of an entry we hold from an offset on, from the peer we have the given role towards. The request
+is made with a read capability we bound in the session which grants reading the entry. The returned channel reports
+the progress of receiving the payload, which is stored as it was received once the reply is complete, and is closed
+after reporting that the request was answered.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) RequestPayload(role wgpstypes.SyncRole, entry types.Entry, offset uint64) (<-chan data.PayloadProgress, error) {
+	if offset >= entry.Payload_length {
+		return nil, fmt.Errorf("offset %v is beyond the payload of length %v", offset, entry.Payload_length)
+	}
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+	var progress <-chan data.PayloadProgress
+	err := w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+		handles, capFinderOurs := forRole(role, &w.InitiatorHandles, &w.AcceptedHandles), forRole(role, &w.InitiatorCapFinderOurs, &w.AcceptedCapFinderOurs)
+		payloadIngester := forRole(role, &w.InitiatorDataPayloadIngester, &w.AcceptedDataPayloadIngester)
+		capability, found := capFinderOurs.FindCapHandle(entry)
+		if !found {
+			return nil, fmt.Errorf("we bound no read capability which grants reading the entry")
+		}
+		handle := handles.PayloadRequestOurs.Bind(data.PayloadRequest{Offset: offset, Entry: entry})
+		progress = payloadIngester.Expect(handle)
+		return []wgpstypes.SyncMessage{wgpstypes.MsgDataBindPayloadRequest{
+			Kind: wgpstypes.DataBindPayloadRequest,
+			Data: wgpstypes.MsgDataBindPayloadRequestData{Entry: entry, Offset: offset, Capability: capability},
+		}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
 
+// Keeps the payloads a session ended in the middle of, for the next session to resume
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) interrupt(requests []data.PayloadRequest) {
+	w.interruptedMu.Lock()
+	defer w.interruptedMu.Unlock()
+	for _, request := range requests {
+		known := false
+		for _, interrupted := range w.interrupted {
+			if interrupted.Entry.Payload_digest == request.Entry.Payload_digest {
+				known = true
+				break
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedControlChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
+		}
+		if !known {
+			w.interrupted = append(w.interrupted, request)
+		}
+	}
+}
 
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
+/*
+Requests the payloads an earlier session ended in the middle of which a read capability we bound grants reading, from
+as much of them as we stored. Returns the messages binding the requests, whose replies the payload ingester receives.
+*/
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) resumePayloads(
+	handles *SessionHandles[ReadCapability, PsiGroup, StaticToken],
+	capFinderOurs *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K],
+	payloadIngester *data.PayloadIngester[Prefingerprint, Fingerprint, K, AuthorisationToken, AuthorisationOpts],
+) []wgpstypes.SyncMessage {
+	w.interruptedMu.Lock()
+	defer w.interruptedMu.Unlock()
+	var requests []wgpstypes.SyncMessage
+	remaining := w.interrupted[:0]
+	for _, interrupted := range w.interrupted {
+		entry := interrupted.Entry
+		capability, found := capFinderOurs.FindCapHandle(entry)
+		if !found {
+			remaining = append(remaining, interrupted)
+			continue
+		}
+		// A payload which was completed since needs no resuming
+		if w.Store.AvailablePayload(entry.Payload_digest) == entry.Payload_length {
+			continue
+		}
+		offset := w.Store.PartialPayload(entry.Payload_digest)
+		if offset >= entry.Payload_length {
+			offset = 0
+		}
+		payloadIngester.Expect(handles.PayloadRequestOurs.Bind(data.PayloadRequest{Offset: offset, Entry: entry}))
+		requests = append(requests, wgpstypes.MsgDataBindPayloadRequest{
+			Kind: wgpstypes.DataBindPayloadRequest,
+			Data: wgpstypes.MsgDataBindPayloadRequestData{Entry: entry, Offset: offset, Capability: capability},
+		})
+	}
+	w.interrupted = remaining
+	return requests
+}
 
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+// The type of handle a message received from the other peer binds, if any, and the handles it refers to
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) handleReferences(msg wgpstypes.SyncMessage) (*wgpstypes.HandleType, []handleReference) {
+	bind := func(handleType wgpstypes.HandleType) *wgpstypes.HandleType {
+		return &handleType
+	}
+	switch msg := msg.(type) {
+	case wgpstypes.MsgPaiBindFragment[PsiGroup]:
+		return bind(wgpstypes.IntersectionHandle), nil
+	case wgpstypes.MsgPaiReplyFragment[PsiGroup]:
+		return nil, []handleReference{{wgpstypes.IntersectionHandle, msg.Data.Handle, true}}
+	case wgpstypes.MsgPaiRequestSubspaceCapability:
+		return nil, []handleReference{{wgpstypes.IntersectionHandle, msg.Data.Handle, false}}
+	case wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]:
+		return nil, []handleReference{{wgpstypes.IntersectionHandle, msg.Data.Handle, true}}
+	case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
+		return bind(wgpstypes.CapabilityHandle), []handleReference{{wgpstypes.IntersectionHandle, msg.Data.Handle, false}}
+	case wgpstypes.MsgSetupBindAreaOfInterest:
+		return bind(wgpstypes.AreaOfInterestHandle), []handleReference{{wgpstypes.CapabilityHandle, msg.Data.Authorisation, false}}
+	case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
+		return bind(wgpstypes.StaticTokenHandle), nil
+	case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
+		return nil, []handleReference{
+			{wgpstypes.AreaOfInterestHandle, msg.Data.SenderHandle, false},
+			{wgpstypes.AreaOfInterestHandle, msg.Data.ReceiverHandle, true},
+		}
+	case wgpstypes.MsgReconciliationAnnounceEntries:
+		return nil, []handleReference{
+			{wgpstypes.AreaOfInterestHandle, msg.Data.SenderHandle, false},
+			{wgpstypes.AreaOfInterestHandle, msg.Data.ReceiverHandle, true},
+		}
+	case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
+		return nil, []handleReference{{wgpstypes.StaticTokenHandle, msg.Data.StaticTokenHandle, false}}
+	case wgpstypes.MsgDataSendEntry[DynamicToken]:
+		return nil, []handleReference{{wgpstypes.StaticTokenHandle, msg.Data.StaticTokenHandle, false}}
+	case wgpstypes.MsgDataSetMetadata:
+		return nil, []handleReference{
+			{wgpstypes.AreaOfInterestHandle, msg.Data.SenderHandle, false},
+			{wgpstypes.AreaOfInterestHandle, msg.Data.ReceiverHandle, true},
+		}
+	case wgpstypes.MsgDataBindPayloadRequest:
+		return bind(wgpstypes.PayloadRequestHandle), []handleReference{{wgpstypes.CapabilityHandle, msg.Data.Capability, false}}
+	case wgpstypes.MsgDataReplyPayload:
+		return nil, []handleReference{{wgpstypes.PayloadRequestHandle, msg.Data.Handle, true}}
+	default:
+		return nil, nil
+	}
+}
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+// Handles a message received from the peer we have the given role towards
+func (w *WgpsMessenger[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+]) handleMessage(session *Session[
+	ReadCapability,
+	Receiver,
+	SyncSignature,
+	ReceiverSecretKey,
+	PsiGroup,
+	PsiScalar,
+	SubspaceCapability,
+	SubspaceReceiver,
+	SyncSubspaceSignature,
+	SubspaceSecretKey,
+	Prefingerprint,
+	Fingerprint,
+	AuthorisationToken,
+	StaticToken,
+	DynamicToken,
+	AuthorisationOpts,
+	K,
+], msg wgpstypes.SyncMessage) error {
+	role := session.Role
+	mu := &w.acceptedMu
+	if wgpstypes.IsAlfie(role) {
+		mu = &w.initiatorMu
+	}
+	// A session which ended may have been followed by another one in its role already, whose state this is not
+	mu.Lock()
+	if forRole(role, &w.InitiatorSession, &w.AcceptedSession) != session {
+		mu.Unlock()
+		return ErrSessionEnded
+	}
+	engine, handles := forRole(role, &w.InitiatorReconciliation, &w.AcceptedReconciliation), forRole(role, &w.InitiatorHandles, &w.AcceptedHandles)
+	handlesBound, commitment := forRole(role, &w.initiatorHandlesBound, &w.acceptedHandlesBound), forRole(role, &w.InitiatorCommitment, &w.AcceptedCommitment)
+	outChannels, inBuffers := forRole(role, &w.InitiatorOutChannels, &w.AcceptedOutChannels), forRole(role, &w.InitiatorInBuffers, &w.AcceptedInBuffers)
+	finder, capFinder := forRole(role, &w.InitiatorPaiFinder, &w.AcceptedPaiFinder), forRole(role, &w.InitiatorCapFinder, &w.AcceptedCapFinder)
+	aoiFinder, capFinderOurs := forRole(role, &w.InitiatorAoiFinder, &w.AcceptedAoiFinder), forRole(role, &w.InitiatorCapFinderOurs, &w.AcceptedCapFinderOurs)
+	dataSender, payloadIngester := forRole(role, &w.InitiatorDataSender, &w.AcceptedDataSender), forRole(role, &w.InitiatorDataPayloadIngester, &w.AcceptedDataPayloadIngester)
+	mu.Unlock()
+
+	isPai := false
+	switch msg.GetKind() {
+	case wgpstypes.PaiBindFragment,
+		wgpstypes.PaiReplyFragment,
+		wgpstypes.PaiRequestSubspaceCapability,
+		wgpstypes.PaiReplySubspaceCapability,
+		wgpstypes.SetupBindReadCapability:
+		if finder == nil {
+			return fmt.Errorf("private area intersection is not configured")
+		}
+		// Capabilities are bound with signatures over our challenge, which needs the nonce of the other peer
+		commitment.Challenges()
+		isPai = true
+	}
 
+	binds, references := w.handleReferences(msg)
+	if binds != nil || msg.GetKind() == wgpstypes.PaiReplyFragment {
+		defer func() {
+			handlesBound.L.Lock()
+			handlesBound.Broadcast()
+			handlesBound.L.Unlock()
+		}()
+	}
+	err := func() error {
+		handlesBound.L.Lock()
+		defer handlesBound.L.Unlock()
+		// The handles the message refers to are bound on other logical channels, so they may not have arrived yet
+		for !handles.Arrived(references) {
+			if session.IsEnded() {
+				return ErrSessionEnded
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedDataChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
+			handlesBound.Wait()
+		}
+		if msg.GetKind() == wgpstypes.SetupBindReadCapability {
+			// Neither may the replies to our fragments which complete the intersection the capability is for
+			for finder.AwaitingReplies() {
+				if session.IsEnded() {
+					return ErrSessionEnded
 				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
+				handlesBound.Wait()
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedIntersectionChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
+		}
+		if err := handles.Check(binds, references); err != nil {
+			return ProtocolViolationError{Err: err}
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
 
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+	// Reconciling is done once every pair of areas of interest found so far is, and the replies to our fragments can find no more
+	checkReconciled := func() {
+		if session.reconciledSoFar() && (finder == nil || !finder.AwaitingReplies()) {
+			session.reconcile()
+		}
+	}
 
+	if isPai {
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			replies, err := w.handlePai(engine, handles, commitment, finder, capFinder, capFinderOurs, aoiFinder, msg)
+			if err != nil {
+				return nil, err
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedPayloadRequestChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
+			checkReconciled()
+			// The read capabilities we bound in reply may let us resume payloads an earlier session ended in the middle of
+			return append(replies, w.resumePayloads(handles, capFinderOurs, payloadIngester)...), nil
+		})
+	}
 
+	switch msg.GetKind() {
+	case wgpstypes.SetupBindStaticToken,
+		wgpstypes.ReconciliationSendFingerprint,
+		wgpstypes.ReconciliationAnnounceEntries,
+		wgpstypes.ReconciliationSendEntry,
+		wgpstypes.ReconciliationSendPayload,
+		wgpstypes.ReconciliationTerminatePayload:
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			replies, err := engine.HandleMessage(msg)
+			if err != nil {
+				return nil, err
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedCapabilityChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
+			checkReconciled()
+			return replies, nil
+		})
+	case wgpstypes.SetupBindAreaOfInterest:
+		aoi := msg.(wgpstypes.MsgSetupBindAreaOfInterest).Data
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			capability, _ := handles.CapabilityTheirs.Get(aoi.Authorisation)
+			if !utils.AreaIsIncluded(w.Schemes.SubspaceScheme.Order, aoi.AreaOfInterest.Area, w.Schemes.AccessControl.GetGrantedArea(capability)) {
+				return nil, ProtocolViolationError{Err: fmt.Errorf("the other peer bound an area of interest outside of the read capability it bound it with")}
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedReconciliationChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
+			handle := handles.AreaOfInterestTheirs.Bind(aoi.AreaOfInterest)
+			intersections := aoiFinder.AddAoiHandleToNamespace(handle, w.Schemes.AccessControl.GetGrantedNamespace(capability), false)
+			replies, err := w.reconcileIntersections(engine, handles, intersections)
+			if err != nil {
+				return nil, err
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedStaticTokenChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
+			// An area of interest which intersects none of ours may leave nothing to reconcile
+			checkReconciled()
+			return replies, nil
+		})
+	case wgpstypes.DataSetMetadata:
+		metadata := msg.(wgpstypes.MsgDataSetMetadata).Data
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			return nil, engine.SetEagerness(metadata.ReceiverHandle, metadata.SenderHandle, metadata.IsEager)
+		})
+	case wgpstypes.DataBindPayloadRequest:
+		request := msg.(wgpstypes.MsgDataBindPayloadRequest).Data
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			// The other peer has to prove it may read the entry whose payload it requests
+			capability, _ := handles.CapabilityTheirs.Get(request.Capability)
+			if !w.Schemes.NamespaceScheme.IsEqual(request.Entry.Namespace_id, w.Schemes.AccessControl.GetGrantedNamespace(capability)) ||
+				!utils.IsIncludedArea(w.Schemes.SubspaceScheme.Order, w.Schemes.AccessControl.GetGrantedArea(capability), utils.EntryPosition(request.Entry)) {
+				return nil, ProtocolViolationError{Err: fmt.Errorf("the other peer requested a payload its read capability does not grant")}
 			}
-			return nil
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](decodedAcceptedAreaOfInterestChannelListener, func(msg wgpstypes.SyncMessage) error {
-			switch msg := msg.(type) {
-			case wgpstypes.MsgDataSendEntry[DynamicToken]:
-				newWgpsMessenger.CurrentlyReceivedEntry = msg.Data.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = msg.Data.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgDataReplyPayload:
-				request, found := newWgpsMessenger.HandlesPayloadRequestsOurs.Get(msg.Data.Handle)
-				if !found {
-					return fmt.Errorf("No Payload handler")
-				}
-
-				newWgpsMessenger.CurrentlyReceivedEntry = request.Entry
-				newWgpsMessenger.CurrentlyReceivedOffset = request.Offset
-				newWgpsMessenger.InitiatorInChannelData <- msg
-			case wgpstypes.MsgPaiBindFragment[PsiGroup]:
-				newWgpsMessenger.InitiatorInChannelIntersection <- msg
-
-			case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
-				newWgpsMessenger.InitiatorInChannelCapability <- msg
-
-			case wgpstypes.MsgSetupBindAreaOfInterest:
-				newWgpsMessenger.InitiatorInChannelAreaOfInterest <- msg
-
-			case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-				newWgpsMessenger.InitiatorInChannelStaticToken <- msg
-
-			case wgpstypes.MsgReconciliationSendFingerprint[Fingerprint]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationAnnounceEntries:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendEntry[DynamicToken]:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationSendPayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgReconciliationTerminatePayload:
-				newWgpsMessenger.InitiatorInChannelReconciliation <- msg
-			case wgpstypes.MsgDataSendPayload:
-				newWgpsMessenger.InitiatorInChannelData <- msg
-
-			case wgpstypes.MsgDataBindPayloadRequest:
-				newWgpsMessenger.InitiatorInChannelPayloadRequest <- msg
-
-			default:
-				newWgpsMessenger.InitiatorInChannelNone <- msg
-
+			handle := handles.PayloadRequestTheirs.Bind(data.PayloadRequest{Entry: request.Entry, Offset: request.Offset})
+			if err := dataSender.QueuePayloadRequest(handle); err != nil {
+				return nil, err
 			}
+			return dataSender.Messages()
+		})
+	case wgpstypes.DataSendEntry:
+		sent := msg.(wgpstypes.MsgDataSendEntry[DynamicToken]).Data
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			wantsPayload, err := engine.ReceiveEntry(sent.Entry, sent.StaticTokenHandle, sent.DynamicToken)
+			if err != nil {
+				return nil, err
+			}
+			if wantsPayload {
+				payloadIngester.TargetEntry(sent.Entry, sent.Offset)
+			} else {
+				// The payload of an entry we hold a newer one of is dropped
+				payloadIngester.Terminate()
+			}
+			return payloadIngester.Messages(), nil
+		})
+	case wgpstypes.DataReplyPayload:
+		handle := msg.(wgpstypes.MsgDataReplyPayload).Data.Handle
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			err := payloadIngester.Target(handle)
+			return payloadIngester.Messages(), err
+		})
+	case wgpstypes.DataSendPayload:
+		chunk := msg.(wgpstypes.MsgDataSendPayload).Data.Bytes
+		session.transferred(0, uint64(len(chunk)))
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			payloadIngester.Push(chunk)
+			return payloadIngester.Messages(), nil
+		})
+	case wgpstypes.CommitmentReveal:
+		err := commitment.Reveal(msg.(wgpstypes.MsgCommitmentReveal).Data.Nonce)
+		if err != nil {
+			return ProtocolViolationError{Err: err}
+		}
+		return nil
+	case wgpstypes.ControlIssueGuarantee:
+		data := msg.(wgpstypes.MsgControlIssueGuarantee).Data
+		outChannel, found := outChannels[data.Channel]
+		if !found {
+			return fmt.Errorf("received guarantees for channel %v, which is not subject to guarantees", data.Channel)
+		}
+		if err := outChannel.AddGuarantees(data.Amount); err != nil {
+			return fmt.Errorf("could not send a message on channel %v: %w", data.Channel, err)
+		}
+		return nil
+	case wgpstypes.ControlPlead:
+		data := msg.(wgpstypes.MsgControlPlead).Data
+		outChannel, found := outChannels[data.Channel]
+		if !found {
+			return fmt.Errorf("received a plead for channel %v, which is not subject to guarantees", data.Channel)
+		}
+		amount := outChannel.Plead(data.Target)
+		if amount == 0 {
 			return nil
-		}, nil)
-
-		// Handle received messages
-
-		go syncutils.AsyncReceive[wgpstypes.ReconciliationChannelMsg](newWgpsMessenger.InitiatorInChannelReconciliation, func(msg wgpstypes.ReconciliationChannelMsg) error {
-			//newWgpsMessenger.HandleMsgReconciliation(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.StaticTokenChannelMsg](newWgpsMessenger.InitiatorInChannelStaticToken, func(msg wgpstypes.StaticTokenChannelMsg) error {
-			//newWgpsMessenger.HandleMsgStaticToken(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.DataChannelMsg](newWgpsMessenger.InitiatorInChannelData, func(msg wgpstypes.DataChannelMsg) error {
-			newWgpsMessenger.HandleMsgData(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.IntersectionChannelMsg](newWgpsMessenger.InitiatorInChannelIntersection, func(msg wgpstypes.IntersectionChannelMsg) error {
-			//newWgpsMessenger.HandleMsgIntersection(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.PayloadRequestChannelMsg](newWgpsMessenger.InitiatorInChannelPayloadRequest, func(msg wgpstypes.PayloadRequestChannelMsg) error {
-			newWgpsMessenger.HandleMsgPayloadRequest(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.CapabilityChannelMsg](newWgpsMessenger.InitiatorInChannelCapability, func(msg wgpstypes.CapabilityChannelMsg) error {
-			//newWgpsMessenger.HandleMsgCapability(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.AreaOfInterestChannelMsg](newWgpsMessenger.InitiatorInChannelAreaOfInterest, func(msg wgpstypes.AreaOfInterestChannelMsg) error {
-			//newWgpsMessenger.HandleMsgAreaOfInterest(msg)
-			return nil
-
-		}, nil)
-
-		go syncutils.AsyncReceive[wgpstypes.SyncMessage](newWgpsMessenger.InitiatorInChannelNone, func(msg wgpstypes.SyncMessage) error {
-			//newWgpsMessenger.HandleMsg(msg)
-			return nil
-
-		}, nil) */
-
-	newMessengerChan <- NewMessengerReturn[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	]{
-		NewMessenger: &newWgpsMessenger,
-		Error:        nil,
+		}
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			return []wgpstypes.SyncMessage{wgpstypes.MsgControlAbsolve{
+				Kind: wgpstypes.ControlAbsolve,
+				Data: wgpstypes.ControlAbsolveData{Amount: amount, Channel: data.Channel},
+			}}, nil
+		})
+	case wgpstypes.ControlAbsolve:
+		data := msg.(wgpstypes.MsgControlAbsolve).Data
+		inBuffer, found := inBuffers[data.Channel]
+		if !found {
+			return fmt.Errorf("received an absolution for channel %v, which is not subject to guarantees", data.Channel)
+		}
+		inBuffer.Absolve(data.Amount)
+		return nil
+	case wgpstypes.ControlAnnounceDropping:
+		/*
+			We never send more than we were guaranteed, so a peer dropping our messages broke its guarantees. The dropped
+			messages are not sent again, the session ends instead of apologising.
+		*/
+		data := msg.(wgpstypes.MsgControlAnnounceDropping).Data
+		return ProtocolViolationError{Err: fmt.Errorf("dropped messages on channel %v which it guaranteed to have room for", data.Channel)}
+	case wgpstypes.ControlApologise:
+		data := msg.(wgpstypes.MsgControlApologise).Data
+		inBuffer, found := inBuffers[data.Channel]
+		if !found {
+			return fmt.Errorf("received an apology for channel %v, which is not subject to guarantees", data.Channel)
+		}
+		inBuffer.Apologise()
+		return nil
+	case wgpstypes.ControlFree:
+		data := msg.(wgpstypes.MsgControlFree).Data
+		// The handles they call theirs are the ones they bound
+		return w.reply(role, func() ([]wgpstypes.SyncMessage, error) {
+			store, err := handles.Store(data.HandleType, !data.Mine)
+			if err != nil {
+				return nil, ProtocolViolationError{Err: err}
+			}
+			err = store.Free(data.Handle)
+			if err != nil {
+				return nil, ProtocolViolationError{Err: fmt.Errorf("the other peer freed a handle of type %v: %w", data.HandleType, err)}
+			}
+			return nil, nil
+		})
+	default:
+		return fmt.Errorf("handling messages of kind %v is not supported yet", msg.GetKind())
 	}
-	fmt.Println("Everything looks good!")
-	select {}
 }
 
+/*
+Handles a message of private area intersection, returning the messages to send in reply. A subspace or read
+capability the other peer sends must be valid and signed over its challenge, and a read capability must be within
+the intersection it is bound for. Every intersection found binds the read capability of ours it was found for,
+followed by the areas of interest of its authorisation within the area it grants.
+*/
 func (w *WgpsMessenger[
 	ReadCapability,
 	Receiver,
@@ -2620,107 +2037,111 @@ func (w *WgpsMessenger[
 	DynamicToken,
 	AuthorisationOpts,
 	K,
-]) Initiate(addr string) error {
+]) handlePai(
+	engine *reconciliation.Engine[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken],
+	handles *SessionHandles[ReadCapability, PsiGroup, StaticToken],
+	commitment *Commitment,
+	finder *pai.PaiFinder[ReadCapability, PsiGroup, SubspaceCapability, PsiScalar, K],
+	capFinder, capFinderOurs *CapFinder[ReadCapability, SyncSignature, Receiver, ReceiverSecretKey, K],
+	aoiFinder *reconciliation.AoiIntersectionFinder,
+	msg wgpstypes.SyncMessage,
+) ([]wgpstypes.SyncMessage, error) {
+	var replies []wgpstypes.SyncMessage
+	var intersections []pai.Intersection[ReadCapability, SubspaceCapability]
+	var err error
 
-	err := w.Transport.Initiate(addr)
-	time.Sleep(time.Second * 1)
-	return err
+	switch msg := msg.(type) {
+	case wgpstypes.MsgPaiBindFragment[PsiGroup]:
+		replies, intersections, err = finder.ReceivedBind(msg.Data.GroupMember, msg.Data.IsSecondary)
+	case wgpstypes.MsgPaiReplyFragment[PsiGroup]:
+		replies, intersections, err = finder.ReceivedReply(msg.Data.Handle, msg.Data.GroupMember)
+	case wgpstypes.MsgPaiRequestSubspaceCapability:
+		var reply *pai.SubspaceCapReply[SubspaceCapability]
+		reply, err = finder.ReceivedSubspaceCapRequest(msg.Data.Handle)
+		if reply != nil {
+			receiver := w.Schemes.SubspaceCap.GetReceiver(reply.SubspaceReadCapability)
+			replies = append(replies, wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]{
+				Kind: wgpstypes.PaiReplySubspaceCapability,
+				Data: wgpstypes.MsgPaiReplySubspaceCapabilityData[SubspaceCapability, SyncSubspaceSignature]{
+					Handle:     reply.Handle,
+					Capability: reply.SubspaceReadCapability,
+					Signature:  SignChallenge(commitment, w.Schemes.SubspaceCap.Signatures, receiver, w.Schemes.SubspaceCap.GetSecretKey(receiver)),
+				},
+			})
+		}
+	case wgpstypes.MsgPaiReplySubspaceCapability[SubspaceCapability, SyncSubspaceSignature]:
+		if !w.Schemes.SubspaceCap.IsValidCap(msg.Data.Capability) {
+			return nil, AuthFailureError{Err: fmt.Errorf("the other peer sent an invalid subspace capability")}
+		}
+		if !VerifyChallenge(commitment, w.Schemes.SubspaceCap.Signatures, w.Schemes.SubspaceCap.GetReceiver(msg.Data.Capability), msg.Data.Signature) {
+			return nil, AuthFailureError{Err: fmt.Errorf("the other peer sent a subspace capability without a signature over its challenge")}
+		}
+		intersections, err = finder.ReceivedVerifiedSubspaceCapReply(msg.Data.Handle, w.Schemes.SubspaceCap.GetNamespace(msg.Data.Capability))
+	case wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]:
+		// A read capability must be for an intersection, which we may have to reply to with ours
+		privy, err := finder.GetIntersectionPrivy(msg.Data.Handle, false)
+		if err != nil {
+			return nil, ProtocolViolationError{Err: fmt.Errorf("the other peer bound a read capability: %w", err)}
+		}
+		capability := msg.Data.Capability
+		if !w.Schemes.AccessControl.IsValidCap(capability) {
+			return nil, AuthFailureError{Err: fmt.Errorf("the other peer bound an invalid read capability")}
+		}
+		if !w.Schemes.NamespaceScheme.IsEqual(w.Schemes.AccessControl.GetGrantedNamespace(capability), privy.Namespace) ||
+			!utils.AreaIsIncluded(w.Schemes.SubspaceScheme.Order, w.Schemes.AccessControl.GetGrantedArea(capability), privy.Outer) {
+			return nil, ProtocolViolationError{Err: fmt.Errorf("the other peer bound a read capability outside of the intersection it was bound for")}
+		}
+		if !VerifyChallenge(commitment, w.Schemes.AccessControl.Signatures, w.Schemes.AccessControl.GetReceiver(capability), msg.Data.Signature) {
+			return nil, AuthFailureError{Err: fmt.Errorf("the other peer bound a read capability without a signature over its challenge")}
+		}
+		capFinder.AddCap(handles.CapabilityTheirs.Bind(capability))
+		intersections, err = finder.ReceivedReadCapForIntersection(msg.Data.Handle)
+	}
+	if err != nil {
+		return nil, ProtocolViolationError{Err: err}
+	}
 
+	for _, intersection := range intersections {
+		capability := intersection.ReadAuthorisation.Capability
+		receiver := w.Schemes.AccessControl.GetReceiver(capability)
+		capHandle := handles.CapabilityOurs.Bind(capability)
+		capFinderOurs.AddCap(capHandle)
+		replies = append(replies, wgpstypes.MsgSetupBindReadCapability[ReadCapability, SyncSignature]{
+			Kind: wgpstypes.SetupBindReadCapability,
+			Data: wgpstypes.MsgSetupBindReadCapabilityData[ReadCapability, SyncSignature]{
+				Capability: capability,
+				Handle:     intersection.Handle,
+				Signature:  SignChallenge(commitment, w.Schemes.AccessControl.Signatures, receiver, w.Schemes.AccessControl.GetSecretKey(receiver)),
+			},
+		})
+
+		granted := w.Schemes.AccessControl.GetGrantedArea(capability)
+		for _, aoi := range w.Interests[intersection.ReadAuthorisation] {
+			// We may only be interested in what we may read
+			area := utils.IntersectArea(w.Schemes.SubspaceScheme.Order, aoi.Area, granted)
+			if area == nil {
+				continue
+			}
+			aoi.Area = *area
+			aoiHandle := handles.AreaOfInterestOurs.Bind(aoi)
+			replies = append(replies, wgpstypes.MsgSetupBindAreaOfInterest{
+				Kind: wgpstypes.SetupBindAreaOfInterest,
+				Data: wgpstypes.MsgSetupBindAreaOfInterestData{
+					AreaOfInterest: aoi,
+					Authorisation:  capHandle,
+				},
+			})
+			initial, err := w.reconcileIntersections(engine, handles, aoiFinder.AddAoiHandleToNamespace(aoiHandle, intersection.NamespaceId, true))
+			if err != nil {
+				return nil, err
+			}
+			replies = append(replies, initial...)
+		}
+	}
+	return replies, nil
 }
 
-// func (w *WgpsMessenger[
-// 	ReadCapability,
-// 	Receiver,
-// 	SyncSignature,
-// 	ReceiverSecretKey,
-// 	PsiGroup,
-// 	PsiScalar,
-// 	SubspaceCapability,
-// 	SubspaceReceiver,
-// 	SyncSubspaceSignature,
-// 	SubspaceSecretKey,
-// 	Prefingerprint,
-// 	Fingerprint,
-// 	AuthorisationToken,
-// 	StaticToken,
-// 	DynamicToken,
-// 	AuthorisationOpts,
-// 	K,
-// ]) HandleMsgData(msg wgpstypes.DataChannelMsg) error {
-// 	switch msg := msg.(type) {
-// 	case wgpstypes.MsgDataSendEntry[DynamicToken]:
-// 		staticToken, found := w.HandlesStaticTokenTheirs.Get(msg.Data.StaticTokenHandle)
-// 		if !found {
-// 			return fmt.Errorf("static token not found")
-// 		}
-// 		authToken := w.Schemes.AuthorisationToken.RecomposeAuthToken(staticToken, msg.Data.DynamicToken)
-// 		_, err := w.Store.IngestEntry(msg.Data.Entry, authToken)
-// 		if err != nil {
-// 			return fmt.Errorf("could not ingest entry")
-// 		}
-// 		w.DataPayloadIngester.Target(msg.Data.Entry, false)
-// 	}
-// 	return nil
-// }
-
-// func (w *WgpsMessenger[
-// 	ReadCapability,
-// 	Receiver,
-// 	SyncSignature,
-// 	ReceiverSecretKey,
-// 	PsiGroup,
-// 	PsiScalar,
-// 	SubspaceCapability,
-// 	SubspaceReceiver,
-// 	SyncSubspaceSignature,
-// 	SubspaceSecretKey,
-// 	Prefingerprint,
-// 	Fingerprint,
-// 	AuthorisationToken,
-// 	StaticToken,
-// 	DynamicToken,
-// 	AuthorisationOpts,
-// 	K,
-// ]) HandleMsgPayloadRequest(
-// 	msg wgpstypes.PayloadRequestChannelMsg) {
-
-// 	switch msg := msg.(type) {
-// 	case wgpstypes.MsgDataBindPayloadRequest:
-// 		handle := w.HandlesPayloadRequestsTheirs.Bind(data.PayloadRequest{
-// 			Offset: msg.Data.Offset,
-// 			Entry:  msg.Data.Entry,
-// 		})
-// 		w.DataSender.QueuePayloadRequest(handle)
-// 	}
-
-// }
-
-// func (w *WgpsMessenger[
-// 	ReadCapability,
-// 	Receiver,
-// 	SyncSignature,
-// 	ReceiverSecretKey,
-// 	PsiGroup,
-// 	PsiScalar,
-// 	SubspaceCapability,
-// 	SubspaceReceiver,
-// 	SyncSubspaceSignature,
-// 	SubspaceSecretKey,
-// 	Prefingerprint,
-// 	Fingerprint,
-// 	AuthorisationToken,
-// 	StaticToken,
-// 	DynamicToken,
-// 	AuthorisationOpts,
-// 	K,
-// ]) HandleMsgStaticToken(
-// 	msg wgpstypes.StaticTokenChannelMsg) {
-// 	switch msg := msg.(type) {
-// 	case wgpstypes.MsgSetupBindStaticToken[StaticToken]:
-// 		w.HandlesStaticTokenTheirs.Bind(msg.Data.StaticToken)
-// 	}
-// }
-
+// Starts reconciling pairs of intersecting areas of interest, of which alfie sends the first fingerprint
 func (w *WgpsMessenger[
 	ReadCapability,
 	Receiver,
@@ -2739,211 +2160,44 @@ func (w *WgpsMessenger[
 	DynamicToken,
 	AuthorisationOpts,
 	K,
-]) Close() error {
-	w.Closed = true
-	err := w.Transport.Close()
-	return err
-}
-
-func (
-	w *WgpsMessenger[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	]) HandleMsgReconciliationAcceptor(
-	rangeSummary types.Range3d,
-	summary Fingerprint,
-) {
-
-	left, right, response := w.Respond(rangeSummary, summary)
-
-	if response.WantResponse {
-		extendedEntries, err := w.Store.EntryDriver.Query(response.Range)
+]) reconcileIntersections(
+	engine *reconciliation.Engine[K, Prefingerprint, Fingerprint, AuthorisationOpts, AuthorisationToken, StaticToken, DynamicToken],
+	handles *SessionHandles[ReadCapability, PsiGroup, StaticToken],
+	intersections []reconciliation.AoiIntersection,
+) ([]wgpstypes.SyncMessage, error) {
+	var replies []wgpstypes.SyncMessage
+	for _, intersection := range intersections {
+		aoiOurs, _ := handles.AreaOfInterestOurs.Get(intersection.Ours)
+		aoiTheirs, _ := handles.AreaOfInterestTheirs.Get(intersection.Theirs)
+		initial, err := engine.AddAoiPair(intersection.Ours, intersection.Theirs, aoiOurs, aoiTheirs)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		for _, extendedEntry := range extendedEntries {
-			payload, _ := w.Store.GetPayload(types.Position3d{
-				Path:     extendedEntry.Entry.Path,
-				Subspace: extendedEntry.Entry.Subspace_id,
-				Time:     extendedEntry.Entry.Timestamp,
+		// The other peer takes us to be eager unless we tell it otherwise
+		if !w.IsEager(aoiOurs) {
+			replies = append(replies, wgpstypes.MsgDataSetMetadata{
+				Kind: wgpstypes.DataSetMetadata,
+				Data: wgpstypes.MsgDataSetMetadataData{
+					IsEager:        false,
+					SenderHandle:   intersection.Ours,
+					ReceiverHandle: intersection.Theirs,
+				},
 			})
-			encodedEntryPayload := EncodeEntryPayload(extendedEntry.Entry, payload)
-			var finalEncoded []byte
-			binary.BigEndian.PutUint64(finalEncoded, uint64(len(encodedEntryPayload)))
-			finalEncoded = append(finalEncoded, encodedEntryPayload...)
-			w.Transport.Send(finalEncoded, wgpstypes.DataChannel, wgpstypes.SyncRoleBetty)
-		}
-	} else {
-		if reflect.DeepEqual(left, struct {
-			Range       types.Range3d
-			FingerPrint Fingerprint
-		}{}) && reflect.DeepEqual(right, struct {
-			Range       types.Range3d
-			FingerPrint Fingerprint
-		}{}) {
-			return
 		}
-		var leftEncodedExtendedRange []byte
-		binary.BigEndian.PutUint64(leftEncodedExtendedRange, uint64(len(EncodeRange(left.Range))))
-		leftEncodedExtendedRange = append(leftEncodedExtendedRange, EncodeRange(left.Range)...)
-		w.Transport.Send(leftEncodedExtendedRange, wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleBetty)
-
-		var rightEncodedExtendedRange []byte
-		binary.BigEndian.PutUint64(rightEncodedExtendedRange, uint64(len(EncodeRange(right.Range))))
-		rightEncodedExtendedRange = append(rightEncodedExtendedRange, EncodeRange(right.Range)...)
-		w.Transport.Send(rightEncodedExtendedRange, wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleBetty)
+		replies = append(replies, initial...)
 	}
+	return replies, nil
 }
 
-func (
-	w *WgpsMessenger[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	]) HandleMsgReconciliationInitiator(
-	value types.Range3d,
-) {
-	summaryValue := w.Store.EntryDriver.Storage.Summarise(value)
-	w.Transport.Send(EncodeSummary(summaryValue, value), wgpstypes.ReconciliationChannel, wgpstypes.SyncRoleAlfie)
-}
-
-func (
-	w *WgpsMessenger[
-		ReadCapability,
-		Receiver,
-		SyncSignature,
-		ReceiverSecretKey,
-		PsiGroup,
-		PsiScalar,
-		SubspaceCapability,
-		SubspaceReceiver,
-		SyncSubspaceSignature,
-		SubspaceSecretKey,
-		Prefingerprint,
-		Fingerprint,
-		AuthorisationToken,
-		StaticToken,
-		DynamicToken,
-		AuthorisationOpts,
-		K,
-	]) HandleDataInitiator(
-	entry types.Entry,
-	payload []byte,
-) {
-	var entryInput datamodeltypes.EntryInput
-	entryInput.Path = entry.Path
-	entryInput.Subspace = entry.Subspace_id
-	entryInput.Timestamp = entry.Timestamp
-	entryInput.Payload = payload
-	w.Store.Set(entryInput, []byte(entry.Subspace_id))
-}
-
-func EncodeRange[Fingerprint string](Range types.Range3d) []byte {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	encoder.Encode(Range)
-	return buffer.Bytes()
-}
-
-func DecodeRange[Fingerprint string](value []byte) types.Range3d {
-	var decoded types.Range3d
-	buffer := bytes.NewBuffer(value)
-	decoder := gob.NewDecoder(buffer)
-	decoder.Decode(&decoded)
-	return decoded
-}
-
-func EncodeSummary[Fingerprint string](value struct {
-	FingerPrint string
-	Size        uint64
-}, ourRange types.Range3d) []byte {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	encoder.Encode(struct {
-		value struct {
-			FingerPrint string
-			Size        uint64
-		}
-		ourRange types.Range3d
-	}{
-		value, ourRange,
-	})
-	return buffer.Bytes()
-}
-func DecodeSummary[Fingerprint string](value []byte) (struct {
-	FingerPrint string
-	Size        uint64
-}, types.Range3d) {
-	var decoded struct {
-		value struct {
-			FingerPrint string
-			Size        uint64
-		}
-		ourRange types.Range3d
-	}
-	buffer := bytes.NewBuffer(value)
-	decoder := gob.NewDecoder(buffer)
-	decoder.Decode(&decoded)
-	return struct {
-		FingerPrint string
-		Size        uint64
-	}{
-		decoded.value.FingerPrint, decoded.value.Size,
-	}, decoded.ourRange
-}
-
-func EncodeEntryPayload(entry types.Entry, payload []byte) []byte {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-
-	encoder.Encode(struct {
-		Entry   types.Entry
-		Payload []byte
-	}{
-		entry, payload,
-	})
-	return buffer.Bytes()
-}
-func DecodeEntryPayload(value []byte) (entry types.Entry, payload []byte) {
-	var decoded struct {
-		Entry   types.Entry
-		Payload []byte
-	}
-	buffer := bytes.NewBuffer(value)
-	decoder := gob.NewDecoder(buffer)
-	decoder.Decode(&decoded)
-	return decoded.Entry, decoded.Payload
+// The field of the messenger for the given role, of which only the one of that role is read
+func forRole[T any](role wgpstypes.SyncRole, initiator, accepted *T) T {
+	if wgpstypes.IsAlfie(role) {
+		return *initiator
+	}
+	return *accepted
 }
 
+// Runs a step of the reconciliation with the peer we have the given role towards, and sends the messages it returns
 func (w *WgpsMessenger[
 	ReadCapability,
 	Receiver,
@@ -2962,76 +2216,34 @@ func (w *WgpsMessenger[
 	DynamicToken,
 	AuthorisationOpts,
 	K,
-]) Respond(
-	yourRange types.Range3d,
-	fingerprint Fingerprint,
-
-) (struct {
-	Range       types.Range3d
-	FingerPrint Fingerprint
-}, struct {
-	Range       types.Range3d
-	FingerPrint Fingerprint
-}, struct {
-	WantResponse bool
-	Range        types.Range3d
-}) {
-	// TODO Implement Summarise function in store
-	ourFingerprint := w.Store.EntryDriver.Storage.Summarise(yourRange)
-	size := ourFingerprint.Size
-	fingerprintOursFinal := w.Schemes.Fingerprint.FingerPrintFinalise(Prefingerprint(ourFingerprint.FingerPrint))
-	if w.Schemes.Fingerprint.IsEqual(fingerprint, fingerprintOursFinal) {
-		return struct {
-				Range       types.Range3d
-				FingerPrint Fingerprint
-			}{}, struct {
-				Range       types.Range3d
-				FingerPrint Fingerprint
-			}{}, struct {
-				WantResponse bool
-				Range        types.Range3d
-			}{
-				WantResponse: false,
-				Range:        types.Range3d{},
-			}
-	} else if size <= 8 {
-		return struct {
-				Range       types.Range3d
-				FingerPrint Fingerprint
-			}{}, struct {
-				Range       types.Range3d
-				FingerPrint Fingerprint
-			}{}, struct {
-				WantResponse bool
-				Range        types.Range3d
-			}{
-				WantResponse: true,
-				Range:        yourRange,
-			}
-	} else {
-		// TODO: Implement Store Split Range
-		left, right := w.Store.EntryDriver.Storage.SplitRange(yourRange, int(size))
-		fingerprintLeftFinal := w.Schemes.Fingerprint.FingerPrintFinalise(Prefingerprint(w.Store.EntryDriver.Storage.Summarise(left).FingerPrint)) //Most readable code in Willow-Go
-		fingerprintRightFinal := w.Schemes.Fingerprint.FingerPrintFinalise(Prefingerprint(w.Store.EntryDriver.Storage.Summarise(right).FingerPrint))
-
-		return struct {
-				Range       types.Range3d
-				FingerPrint Fingerprint
-			}{
-				Range:       left,
-				FingerPrint: fingerprintLeftFinal,
-			}, struct {
-				Range       types.Range3d
-				FingerPrint Fingerprint
-			}{
-				Range:       right,
-				FingerPrint: fingerprintRightFinal,
-			}, struct {
-				WantResponse bool
-				Range        types.Range3d
-			}{
-				WantResponse: false,
-				Range:        types.Range3d{},
-			}
+]) reply(role wgpstypes.SyncRole, step func() ([]wgpstypes.SyncMessage, error)) error {
+	mu := &w.acceptedMu
+	if wgpstypes.IsAlfie(role) {
+		mu = &w.initiatorMu
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// Only the fields of the role whose lock we hold may be read
+	session, encoder := forRole(role, &w.InitiatorSession, &w.AcceptedSession), forRole(role, &w.InitiatorEncoder, &w.AcceptedEncoder)
+	if session == nil || session.IsEnded() {
+		return ErrSessionEnded
+	}
+
+	replies, err := step()
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		session.queued()
+		err = encoder.Encode(reply)
+		if err != nil {
+			session.sent()
+			return err
+		}
+		if chunk, isChunk := reply.(wgpstypes.MsgDataSendPayload); isChunk {
+			session.transferred(chunk.Data.Amount, 0)
+		}
 	}
+	session.settle()
+	return nil
 }
@@ -0,0 +1,284 @@
+package wgps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pinagoladastore "github.com/PES-Innovation-Lab/willow-go/PinaGoladaStore"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/pai"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
+	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
+	"github.com/PES-Innovation-Lab/willow-go/types"
+	"github.com/PES-Innovation-Lab/willow-go/utils"
+)
+
+type testMessenger = WgpsMessenger[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]
+
+type testStore = store.Store[string, string, uint, []byte, string]
+
+// Sets up a messenger which does not listen on any address, over an in-memory store holding the given payloads by path
+func newTestMessenger(t *testing.T, payloads map[string]string) (*testMessenger, *testStore) {
+	willowStore := pinagoladastore.InitMemoryStorage(types.NamespaceId("myspace"), t.TempDir())
+	pinagoladastore.InitKDTree(willowStore)
+	for path, payload := range payloads {
+		_, err := willowStore.Set(datamodeltypes.EntryInput{
+			Subspace: types.SubspaceId("myspace"),
+			Path:     types.Path{[]byte(path)},
+			Payload:  []byte(payload),
+		}, []byte("myspace"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+		Schemes: wgpstypes.SyncSchemes[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+			NamespaceScheme:    pinagoladastore.TestNameSpaceScheme,
+			SubspaceScheme:     pinagoladastore.TestSubspaceScheme,
+			Payload:            pinagoladastore.TestPayloadScheme,
+			Fingerprint:        pinagoladastore.TestFingerprintScheme,
+			PathParams:         pinagoladastore.TestPathParams,
+			AuthorisationToken: pinagoladastore.TestAuthorisationTokenScheme,
+			AccessControl:      pinagoladastore.TestAccessControlScheme,
+			SubspaceCap:        pinagoladastore.TestSubspaceCapScheme,
+			Pai:                pinagoladastore.TestPaiScheme,
+		},
+		Interests: map[*wgpstypes.ReadAuthorisation[string, string]][]types.AreaOfInterest{
+			{Capability: "myspace"}: {{Area: utils.FullArea()}},
+		},
+	}
+	messenger, err := NewWgpsMessenger(opts, "", *willowStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { messenger.Close() })
+	return messenger, willowStore
+}
+
+// Returns the payloads of the entries a store holds, by path
+func storedPayloads(t *testing.T, willowStore *testStore) map[string]string {
+	entries, err := willowStore.EntryDriver.Query(utils.DefaultRange3d(types.SubspaceId("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := map[string]string{}
+	for _, entry := range entries {
+		payload, err := willowStore.PayloadDriver.Get(entry.Entry.Payload_digest)
+		if err != nil {
+			payloads[string(entry.Entry.Path[0])] = ""
+			continue
+		}
+		payloads[string(entry.Entry.Path[0])] = string(payload.Bytes())
+	}
+	return payloads
+}
+
+// Syncs two messengers over the two ends of a transport, and checks they end up holding each other's entries
+func assertReconciles(t *testing.T, alfieTransport, bettyTransport wgpstypes.Transport) {
+	alfieMessenger, alfieStore := newTestMessenger(t, map[string]string{"entry1": "payload1", "entry2": "payload2"})
+	bettyMessenger, bettyStore := newTestMessenger(t, map[string]string{"entry3": "payload3", "entry4": "payload4"})
+
+	if err := bettyMessenger.AcceptOver(bettyTransport); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieMessenger.InitiateOver(alfieTransport); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"entry1": "payload1", "entry2": "payload2", "entry3": "payload3", "entry4": "payload4"}
+	// Stores are not safe to query while they ingest, so only the payloads are looked for until the peers are done
+	var digests []types.PayloadDigest
+	for _, payload := range expected {
+		digests = append(digests, <-pinagoladastore.TestPayloadScheme.FromBytes([]byte(payload)))
+	}
+	holdsAll := func(willowStore *testStore) bool {
+		for _, digest := range digests {
+			if willowStore.AvailablePayload(digest) == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if holdsAll(alfieStore) && holdsAll(bettyStore) {
+			break
+		}
+	}
+	alfieMessenger.Close()
+	bettyMessenger.Close()
+
+	alfieHolds, bettyHolds := storedPayloads(t, alfieStore), storedPayloads(t, bettyStore)
+	if fmt.Sprint(alfieHolds) != fmt.Sprint(expected) || fmt.Sprint(bettyHolds) != fmt.Sprint(expected) {
+		t.Errorf("expected both peers to hold %v, alfie holds %v and betty holds %v", expected, alfieHolds, bettyHolds)
+	}
+}
+
+func TestMessengersReconcileOverMemoryTransport(t *testing.T) {
+	networks := map[string]transport.MemoryTransportOpts{
+		"perfect network": {},
+		"slow reordering network": {
+			Latency:   time.Millisecond,
+			Bandwidth: 1 << 20,
+			Reorder:   3 * time.Millisecond,
+			Seed:      7,
+		},
+	}
+	for name, network := range networks {
+		t.Run(name, func(t *testing.T) {
+			alfieTransport, bettyTransport := transport.NewMemoryTransportPair(network)
+			assertReconciles(t, alfieTransport, bettyTransport)
+		})
+	}
+}
+
+func TestMessengersReconcileOverUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "willow.sock")
+	bettyTransport, err := transport.ListenStream("unix", socket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alfieTransport, err := transport.DialStream("unix", socket, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertReconciles(t, alfieTransport, bettyTransport)
+}
+
+func TestMessengersKnowWhoTheySyncWith(t *testing.T) {
+	alfieIdentity, err := transport.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bettyIdentity, err := transport.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig, err := bettyIdentity.ServerTLSConfig(transport.PinnedPeers{alfieIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig, err := alfieIdentity.ClientTLSConfig("localhost:4247", transport.PinnedPeers{bettyIdentity.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bettyTransport, err := transport.ListenQuic("localhost:4247", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alfieTransport, err := transport.DialQuic("localhost:4247", clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alfieMessenger, _ := newTestMessenger(t, nil)
+	defer alfieMessenger.Close()
+	bettyMessenger, _ := newTestMessenger(t, nil)
+	defer bettyMessenger.Close()
+	if _, found := alfieMessenger.PeerKey(wgpstypes.SyncRoleAlfie); found {
+		t.Error("expected no peer key before the session starts")
+	}
+	if err := bettyMessenger.AcceptOver(bettyTransport); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieMessenger.InitiateOver(alfieTransport); err != nil {
+		t.Fatal(err)
+	}
+
+	if peerKey, found := alfieMessenger.PeerKey(wgpstypes.SyncRoleAlfie); !found || !peerKey.Equal(bettyIdentity.PublicKey()) {
+		t.Errorf("expected alfie to sync with the key of betty, got %v", peerKey)
+	}
+	eventually(t, "betty to know the key of alfie", func() bool {
+		peerKey, found := bettyMessenger.PeerKey(wgpstypes.SyncRoleBetty)
+		return found && peerKey.Equal(alfieIdentity.PublicKey())
+	})
+}
+
+func TestMessengersShareTheListenersOfTheirStore(t *testing.T) {
+	messenger, willowStore := newTestMessenger(t, nil)
+	withoutListeners := *willowStore
+	withoutListeners.Listeners = nil
+	opts := WgpsMessengerOpts[string, types.SubspaceId, string, string, pai.X25519Group, pai.X25519Scalar, string, types.SubspaceId, string, string, string, string, string, string, string, []byte, uint]{
+		Schemes:   messenger.Schemes,
+		Interests: messenger.Interests,
+	}
+	if _, err := NewWgpsMessenger(opts, "", withoutListeners); !errors.Is(err, ErrStoreWithoutListeners) {
+		t.Errorf("expected a store without listeners to be refused, got %v", err)
+	}
+
+	// What is ingested into the store the messenger was given reaches the copy it holds
+	ingested := make(chan types.Entry, 1)
+	unsubscribe, err := messenger.Store.OnIngestEntry(func(entry types.Entry, authorisation string) {
+		ingested <- entry
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+	if _, err := willowStore.Set(datamodeltypes.EntryInput{
+		Subspace: types.SubspaceId("myspace"),
+		Path:     types.Path{[]byte("later")},
+		Payload:  []byte("ingested later"),
+	}, []byte("myspace")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case entry := <-ingested:
+		if string(entry.Path[0]) != "later" {
+			t.Errorf("expected the messenger to hear of the entry at later, heard of %s", entry.Path[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the messenger to hear of the entry ingested into the store it was given")
+	}
+}
+
+func TestMessengersForwardPayloadsWhichArriveAfterTheirEntry(t *testing.T) {
+	alfieMessenger, alfieStore := newTestMessenger(t, nil)
+	bettyMessenger, bettyStore := newTestMessenger(t, nil)
+	alfieTransport, bettyTransport := transport.NewMemoryTransportPair(transport.MemoryTransportOpts{})
+	bettySession := bettyMessenger.NewSession(wgpstypes.SyncRoleBetty, bettyTransport)
+	if err := bettySession.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := alfieMessenger.NewSession(wgpstypes.SyncRoleAlfie, alfieTransport).Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitForReconciled(t, bettySession)
+
+	// Alfie ingests an entry before its payload, as he does when another peer sends him both
+	payload := []byte("arrives later")
+	entry := types.Entry{
+		Namespace_id:   types.NamespaceId("myspace"),
+		Subspace_id:    types.SubspaceId("myspace"),
+		Path:           types.Path{[]byte("later")},
+		Timestamp:      uint64(time.Now().UnixMicro()),
+		Payload_length: uint64(len(payload)),
+		Payload_digest: <-pinagoladastore.TestPayloadScheme.FromBytes(payload),
+	}
+	authorisation, err := alfieStore.Schemes.AuthorisationScheme.Authorise(entry, []byte("myspace"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alfieStore.IngestEntry(entry, authorisation); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "betty to receive the entry", func() bool {
+		var err error
+		bettyStore.ReadEntries(func() {
+			_, err = bettyStore.EntryDriver.Get(entry.Subspace_id, entry.Path)
+		})
+		return err == nil
+	})
+	if _, err := alfieStore.IngestPayload(utils.EntryPosition(entry), payload, false, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "betty to receive the payload", func() bool {
+		return bettyStore.AvailablePayload(entry.Payload_digest) == entry.Payload_length
+	})
+}
@@ -0,0 +1,17 @@
+package wgpstypes
+
+/*
+ProtocolViolationError is an error caused by the other peer violating the protocol, such as by referring to a handle
+it may not use or by sending bytes which do not decode. The session can not continue after it.
+*/
+type ProtocolViolationError struct {
+	Err error
+}
+
+func (e ProtocolViolationError) Error() string {
+	return "protocol violation: " + e.Err.Error()
+}
+
+func (e ProtocolViolationError) Unwrap() error {
+	return e.Err
+}
@@ -55,7 +55,7 @@ type FragmentKit interface {
 func (FragmentKitComplete) IsFragmentKit()  {}
 func (FragmentKitSelective) IsFragmentKit() {}
 
-type PaiScheme[ReadCapability, PsiGroup any, PsiScalar int, K constraints.Unsigned] struct {
+type PaiScheme[ReadCapability, PsiGroup any, PsiScalar any, K constraints.Unsigned] struct {
 	FragmentToGroup     func(Fragment) PsiGroup
 	GetScalar           func() PsiScalar
 	ScalarMult          func(group PsiGroup, scalar PsiScalar) PsiGroup
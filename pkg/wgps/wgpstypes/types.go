@@ -1,6 +1,9 @@
 package wgpstypes
 
 import (
+	"crypto/ed25519"
+	"net"
+
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/datamodeltypes"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/data_model/store"
 	"github.com/PES-Innovation-Lab/willow-go/types"
@@ -42,14 +45,32 @@ type ReadAuthorisation[ReadCapability, SubspaceReadCapability any] struct {
 
 //will need to check if the type is any or something else
 
-// Transport defines the interface for communication channels
+/*
+Transport carries the logical channels of a single WGPS session between us and one other peer. The messenger only
+depends on this interface, whichever peer opened the connection and whatever it runs over.
+*/
 type Transport interface {
-	Send(data []byte, channel Channel) error // Use byte slice instead of Uint8Array
-	Recv(channel Channel) ([]byte, error)    // Returns a receive channel and potential error (PLEASE CHECK IF THIS IS RIGHT)
+	Send(data []byte, channel Channel) error // Sends bytes on a logical channel, in order with the bytes sent on it before
+	Recv(channel Channel) ([]byte, error)    // Blocks until bytes arrive on a logical channel, errors once no more bytes will arrive on it
 	Close() error
 	IsClosed() bool
 }
 
+// Listener hands out a Transport for every peer which connects to us, along with the address the peer connected from
+type Listener interface {
+	Accept() (Transport, net.Addr, error) // Blocks until the next peer connects, errors once the listener is closed
+	Close() error
+}
+
+/*
+AuthenticatedTransport is a Transport whose peer proved it holds an Ed25519 key while the connection was set up. The
+key is nil when the peer did not authenticate, such as over a plain TCP connection.
+*/
+type AuthenticatedTransport interface {
+	Transport
+	PeerKey() ed25519.PublicKey
+}
+
 type HandleType int
 
 const (
@@ -570,7 +591,7 @@ type SyncSchemes[ReadCapability any,
 	SyncSignature,
 	ReceiverSecretKey,
 	PsiGroup any,
-	PsiScalar int,
+	PsiScalar any,
 	SubspaceCapability any,
 	SubspaceReceiver types.SubspaceId,
 	SyncSubspaceSignature,
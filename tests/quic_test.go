@@ -1,71 +1,72 @@
 package tests
 
 import (
-	"fmt"
 	"reflect"
 	"testing"
-	"time"
 
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/transport"
 	"github.com/PES-Innovation-Lab/willow-go/pkg/wgps/wgpstypes"
 )
 
 func TestQuicTransportSendAndReceive(t *testing.T) {
-	var alfieTransport *transport.QuicTransport
-	//var bettyTransport *transport.QuicTransport
-	var err error
-	// Create a new QuicTransport
-	/*go func() {
-		bettyTransport, err = transport.NewQuicTransport(wgpstypes.SyncRoleBetty, "localhost:4242")
-		if err != nil {
-			t.Fatal(err)
-		}
-		fmt.Println("Betty has been set up!")
-	}() */
-	//time.Sleep(time.Second * 10)
-	// Create a new QuicTransport
-	alfieTransport, err = transport.NewQuicTransport("localhost:4241")
+	// Betty listens, alfie connects to her
+	alfieIdentity, err := transport.NewIdentity()
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println("Alfie has been set up!")
-	err = alfieTransport.Initiate("localhost:4242")
+	bettyIdentity, err := transport.NewIdentity()
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// Send a message from Alfie to Betty
-	err = alfieTransport.Send([]byte{1, 2, 3, 4}, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
+	serverConfig, err := bettyIdentity.ServerTLSConfig(transport.PinnedPeers{alfieIdentity.PublicKey()})
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println("Alfie has sent a message!")
-
-	err = alfieTransport.Send([]byte{5, 6}, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
+	clientConfig, err := alfieIdentity.ClientTLSConfig("localhost:4243", transport.PinnedPeers{bettyIdentity.PublicKey()})
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println("Alfie has sent a message!")
-
-	err = alfieTransport.Send([]byte{7}, wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
+	bettyTransport, err := transport.ListenQuic("localhost:4243", serverConfig)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println("Alfie has sent a message!")
+	alfieTransport, err := transport.DialQuic("localhost:4243", clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alfieTransport.Close()
+	defer bettyTransport.Close()
 
-	var alfieMessage []byte
+	// Both ends look alike to whoever uses them
+	var alfie, betty wgpstypes.Transport = alfieTransport, bettyTransport
 
-	// Receive a message from Alfie to Betty
-	time.Sleep(time.Second * 10)
-	alfieMessage, err = alfieTransport.Recv(wgpstypes.DataChannel, wgpstypes.SyncRoleAlfie)
-	if err != nil {
+	for _, message := range [][]byte{{1, 2, 3, 4}, {5, 6}, {7}} {
+		if err := alfie.Send(message, wgpstypes.DataChannel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := betty.Send([]byte{255, 254}, wgpstypes.ReconciliationChannel); err != nil {
 		t.Fatal(err)
 	}
 
-	//fmt.Println(string(bettyMessage))
-	fmt.Println(alfieMessage)
-	if !reflect.DeepEqual(alfieMessage, []byte{255, 254, 253, 252, 251, 250, 249}) {
-		t.Errorf("Did not receive correct message. Wanted %v, got %v", []byte{255, 254, 253, 252, 251, 250, 249}, alfieMessage)
+	// Messages on a logical channel may arrive in other chunks than they were sent in, but in the same order
+	var received []byte
+	for len(received) < 7 {
+		bytes, err := betty.Recv(wgpstypes.DataChannel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, bytes...)
+	}
+	if !reflect.DeepEqual(received, []byte{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("Did not receive correct message. Wanted %v, got %v", []byte{1, 2, 3, 4, 5, 6, 7}, received)
 	}
 
+	received, err = alfie.Recv(wgpstypes.ReconciliationChannel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(received, []byte{255, 254}) {
+		t.Errorf("Did not receive correct message. Wanted %v, got %v", []byte{255, 254}, received)
+	}
 }
@@ -20,7 +20,7 @@ func TestNewWgpsMessenger(t *testing.T) {
 		SyncSignature,
 		ReceiverSecretKey,
 		PsiGroup any,
-		PsiScalar int,
+		PsiScalar any,
 		SubspaceCapability any,
 		SubspaceReceiver types.SubspaceId,
 		SyncSubspaceSignature,
@@ -140,11 +140,15 @@ func IntersectArea(orderSubspace types.TotalOrder[types.SubspaceId], a, b types.
 		return nil
 	}
 
+	// The intersection is restricted to a subspace if either of the areas is
+	intersection := types.Area{Subspace_id: a.Subspace_id, Any_subspace: a.Any_subspace && b.Any_subspace, Path: a.Path, Times: timeIntersection}
+	if a.Any_subspace {
+		intersection.Subspace_id = b.Subspace_id
+	}
 	if isPrefixA {
-		return &types.Area{Subspace_id: a.Subspace_id, Path: b.Path, Times: timeIntersection} // we put b.Path here, as a.Path is it's prefix, which means that there's no use of putting a.Path
+		intersection.Path = b.Path // we put b.Path here, as a.Path is it's prefix, which means that there's no use of putting a.Path
 	}
-
-	return &types.Area{Subspace_id: a.Subspace_id, Path: a.Path, Times: timeIntersection}
+	return &intersection
 }
 
 /** Convert an `Area` to a `Range3d`. */
@@ -444,10 +448,12 @@ func DecodeStreamAreaInArea[Params constraints.Unsigned](
 	hasOpenEnd := (flags & 0x40) == 0x40
 	addStartDiff := (flags & 0x20) == 0x20
 	addEndDiff := (flags & 0x10) == 0x10
-	startDiffWidth := math.Pow(2, float64((0x3 & flags >> 2)))
+	startDiffWidth := math.Pow(2, float64(0x3&(flags>>2)))
 	endDiffWidth := math.Pow(2, float64((0x3 & flags)))
 	var subSpaceId types.SubspaceId
 	var timeReturnStart uint64
+	// The subspace is only left out when it is the one of the outer area, which includes it being any subspace
+	anySubspace := !includeInnerSybspaceId && outer.Any_subspace
 
 	bytes.Prune(1)
 
@@ -481,7 +487,7 @@ func DecodeStreamAreaInArea[Params constraints.Unsigned](
 				End:     0,
 				OpenEnd: true,
 			},
-			Any_subspace: false,
+			Any_subspace: anySubspace,
 		}, nil
 	}
 	accumulatedBytes = bytes.NextAbsolute(int(startDiffWidth))
@@ -513,11 +519,15 @@ func DecodeStreamAreaInArea[Params constraints.Unsigned](
 	} else {
 		timeReturnStart = outer.Times.Start - startDiff
 	}
+	outerEnd := outer.Times.End
+	if outer.Times.OpenEnd {
+		outerEnd = REALLY_BIG_INT
+	}
 	var timeReturnEnd uint64
 	if addEndDiff {
 		timeReturnEnd = timeReturnStart + endDif
 	} else {
-		timeReturnEnd = outer.Times.End - endDif
+		timeReturnEnd = outerEnd - endDif
 	}
 
 	return types.Area{
@@ -528,7 +538,7 @@ func DecodeStreamAreaInArea[Params constraints.Unsigned](
 			End:     timeReturnEnd,
 			OpenEnd: false,
 		},
-		Any_subspace: false,
+		Any_subspace: anySubspace,
 	}, nil
 }
 
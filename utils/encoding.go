@@ -47,7 +47,7 @@ func EncodeIntMax32[T constraints.Unsigned](num, max T) []byte {
 	case 2:
 		binary.BigEndian.PutUint16(bytes, uint16(num))
 	case 3:
-		bytes[0] = byte(num >> 16)
+		bytes[0] = byte(uint32(num) >> 16)
 		binary.BigEndian.PutUint16(bytes[1:], uint16(num))
 	case 4:
 		binary.BigEndian.PutUint32(bytes, uint32(num))
@@ -141,3 +141,63 @@ func DecodeIntMax64(encoded []byte) (uint64, error) {
 		return 0, errors.New("invalid byte slice length")
 	}
 }
+
+/*
+LengthPrefixedEncoding is a self delimiting encoding for byte strings of any length, which makes it usable
+for decoding from a stream. A header byte holds the compact width of the length, followed by the length
+itself and then the bytes.
+*/
+func LengthPrefixedEncoding[T ~string | ~[]byte]() EncodingScheme[T] {
+	widths := map[int]byte{1: 0x0, 2: 0x1, 4: 0x2, 8: 0x3}
+
+	return EncodingScheme[T]{
+		Encode: func(value T) []byte {
+			length := uint64(len(value))
+			encoded := []byte{widths[GetWidthMax64Int(length)]}
+			encoded = append(encoded, EncodeIntMax64(length)...)
+			return append(encoded, []byte(value)...)
+		},
+		Decode: func(encoded []byte) (T, error) {
+			if len(encoded) < 1 {
+				return T(""), errors.New("not enough bytes to decode the length")
+			}
+			width := 1 << (encoded[0] & 0x3)
+			if len(encoded) < 1+width {
+				return T(""), errors.New("not enough bytes to decode the length")
+			}
+			length, _ := DecodeIntMax64(encoded[1 : 1+width])
+			if uint64(len(encoded)-1-width) < length {
+				return T(""), errors.New("not enough bytes to decode the value")
+			}
+			return T(encoded[1+width : 1+width+int(length)]), nil
+		},
+		EncodedLength: func(value T) uint64 {
+			length := uint64(len(value))
+			return 1 + uint64(GetWidthMax64Int(length)) + length
+		},
+		DecodeStream: func(bytes *GrowingBytes) chan T {
+			ch := make(chan T, 1)
+			go func() {
+				defer close(ch)
+				header := bytes.NextAbsolute(1)
+				if len(header) < 1 {
+					return
+				}
+				width := 1 << (header[0] & 0x3)
+				accumulated := bytes.NextAbsolute(1 + width)
+				if len(accumulated) < 1+width {
+					return
+				}
+				length, _ := DecodeIntMax64(accumulated[1 : 1+width])
+				accumulated = bytes.NextAbsolute(1 + width + int(length))
+				if len(accumulated) < 1+width+int(length) {
+					return
+				}
+				value := T(append([]byte{}, accumulated[1+width:1+width+int(length)]...))
+				bytes.Prune(1 + width + int(length))
+				ch <- value
+			}()
+			return ch
+		},
+	}
+}
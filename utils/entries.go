@@ -153,7 +153,6 @@ func EncodeEntryRelativeEntry[ValueType constraints.Unsigned](
 	} else {
 		addOrSubtractTimeDiff = 0x0
 	}
-	fmt.Println(addOrSubtractTimeDiff)
 	// 2-bit integer n such that 2^n gives compact_width(time_diff)
 	compactWidthTimeDiffFlag := CompactWidthEndMasks[GetWidthMax64Int(timeDiff)] << 2
 	// 2-bit integer n such that 2^n gives compact_width(e.payload_length)
@@ -336,14 +335,8 @@ func EncodeEntryRelativeRange3d[ValueType constraints.Unsigned](
 	var encodedPath []byte
 
 	if !outer.PathRange.OpenEnd {
-		commonPrefixStart, err := CommonPrefix(entry.Path, outer.PathRange.Start)
-		if err != nil {
-			return []byte{}, err
-		}
-		commonPrefixEnd, err := CommonPrefix(entry.Path, outer.PathRange.End)
-		if err != nil {
-			return []byte{}, err
-		}
+		commonPrefixStart, _ := CommonPrefix(entry.Path, outer.PathRange.Start)
+		commonPrefixEnd, _ := CommonPrefix(entry.Path, outer.PathRange.End)
 
 		if len(commonPrefixStart) >= len(commonPrefixEnd) {
 			encodePathRelativeToStartFlag = 0x40
@@ -4,60 +4,39 @@ import (
 	"sync"
 )
 
-// Defer processing of bytestrings until a certain chunk size (length, in bytes) has been reached.
-// Put the array of bytes into the resolver
-type DeferredUntilLength struct {
-	resolver chan []byte
-	length   int
-}
-
 // GrowingBytes objects allows us to process bytestreams in a nonblocking fashion with buffered channels and
 // also provdes us with useful helper functions.
-
+// Once the incoming channel is closed, requests which can no longer be fulfilled return whatever bytes are left.
 type GrowingBytes struct {
-	Incoming               chan []byte
-	HasUnfulfilledRequests chan struct{}
-	DeferredUntilLength    *DeferredUntilLength
-	Array                  []byte
-	Mu                     sync.Mutex
+	Incoming chan []byte
+	Array    []byte
+	Closed   bool
+	// Total number of bytes pruned so far
+	Pruned int
+	Mu     sync.Mutex
+	grown  *sync.Cond
 }
 
 // Construct a new new Growing Bytes instance and return a pointer to it
 func NewGrowingBytes(incoming chan []byte) *GrowingBytes {
 	gb := &GrowingBytes{
-		Incoming:               incoming,
-		Array:                  []byte{},
-		HasUnfulfilledRequests: make(chan struct{}, 1),
+		Incoming: incoming,
+		Array:    []byte{},
 	}
+	gb.grown = sync.NewCond(&gb.Mu)
+
 	// Non blocking goroutine to take in byte chunks, synchronize and append to array buffer.
 	go func() {
-		defer close(gb.HasUnfulfilledRequests)
-		for {
-			select {
-			case <-gb.HasUnfulfilledRequests:
-
-			case chunk, ok := <-gb.Incoming:
-
-				if !ok {
-					return
-				}
-
-				gb.Mu.Lock()
-
-				gb.Array = append(gb.Array, chunk...)
-				gb.Mu.Unlock()
-
-				if gb.DeferredUntilLength != nil && len(gb.Array) >= gb.DeferredUntilLength.length {
-					gb.DeferredUntilLength.resolver <- gb.Array
-					gb.DeferredUntilLength = nil
-					gb.HasUnfulfilledRequests <- struct{}{}
-
-				}
-
-			}
-
+		for chunk := range gb.Incoming {
+			gb.Mu.Lock()
+			gb.Array = append(gb.Array, chunk...)
+			gb.Mu.Unlock()
+			gb.grown.Broadcast()
 		}
-
+		gb.Mu.Lock()
+		gb.Closed = true
+		gb.Mu.Unlock()
+		gb.grown.Broadcast()
 	}()
 	return gb
 
@@ -65,35 +44,28 @@ func NewGrowingBytes(incoming chan []byte) *GrowingBytes {
 
 // NextRelative pulls bytes until the accumulated bytestring has grown by the given amount
 func (gb *GrowingBytes) NextRelative(length int) []byte {
+	gb.Mu.Lock()
 	target := len(gb.Array) + length
+	gb.Mu.Unlock()
 	return gb.NextAbsolute(target)
 }
 
 // NextAbsolute pulls bytes until the accumulated bytestring has grown to the given size
 func (gb *GrowingBytes) NextAbsolute(length int) []byte {
-
-	if len(gb.Array) >= length {
-		return gb.Array
-	}
-
 	gb.Mu.Lock()
+	defer gb.Mu.Unlock()
 
-	// If there's already a deferred request for the same length, return the resolved result
-	if gb.DeferredUntilLength != nil && gb.DeferredUntilLength.length == length {
-		resolver := gb.DeferredUntilLength.resolver
-		return <-resolver
+	for len(gb.Array) < length && !gb.Closed {
+		gb.grown.Wait()
 	}
+	return gb.Array
+}
 
-	resolver := make(chan []byte, 10)
-	gb.DeferredUntilLength = &DeferredUntilLength{
-		length:   length,
-		resolver: resolver,
-	}
-
-	gb.HasUnfulfilledRequests <- struct{}{}
-	gb.Mu.Unlock()
-
-	return <-resolver
+// Whether the incoming channel was closed, after which no more bytes arrive
+func (gb *GrowingBytes) IsClosed() bool {
+	gb.Mu.Lock()
+	defer gb.Mu.Unlock()
+	return gb.Closed
 }
 
 // Prune the array by the given byte length
@@ -101,8 +73,10 @@ func (gb *GrowingBytes) Prune(length int) {
 	gb.Mu.Lock()
 
 	if length >= len(gb.Array) {
+		gb.Pruned += len(gb.Array)
 		gb.Array = []byte{}
 	} else {
+		gb.Pruned += length
 		gb.Array = gb.Array[length:]
 	}
 	gb.Mu.Unlock()
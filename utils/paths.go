@@ -143,10 +143,8 @@ func EncodePathLength[T constraints.Unsigned](pathParams types.PathParams[T], pa
 }
 
 func EncodeRelativePath[T constraints.Unsigned](pathParams types.PathParams[T], toEncode types.Path, reference types.Path) []byte {
-	longestPrefix, err := CommonPrefix(toEncode, reference)
-	if err != nil {
-		log.Fatalf("error in calculating common paths: %s", err)
-	}
+	// Paths without any common prefix are encoded relative to the empty prefix
+	longestPrefix, _ := CommonPrefix(toEncode, reference)
 	longestPrefixLength := len(longestPrefix)
 	prefixLengthBytes := EncodeIntMax32(T(longestPrefixLength), pathParams.MaxComponentCount)
 	suffix := toEncode[longestPrefixLength:]
@@ -173,9 +171,10 @@ func DecodePathStream[T constraints.Unsigned](pathParams types.PathParams[T], by
 		lengthBytes := accumulatedBytes[0:componentLengthWidth]
 		componentLength, _ := DecodeIntMax32(lengthBytes, pathParams.MaxComponentLength)
 
-		newAccumulatedBytes := bytes.NextAbsolute(componentLengthWidth + componentLengthWidth)
+		newAccumulatedBytes := bytes.NextAbsolute(componentLengthWidth + int(componentLength))
 
-		pathComponent := newAccumulatedBytes[componentLengthWidth : componentLengthWidth+int(componentLength)]
+		// Copy the component, the accumulated bytes are reused once pruned
+		pathComponent := append([]byte{}, newAccumulatedBytes[componentLengthWidth:componentLengthWidth+int(componentLength)]...)
 
 		path = append(path, pathComponent)
 
@@ -195,7 +194,7 @@ func DecodeRelativePath[T constraints.Unsigned](
 		log.Fatalf("error: %s", err)
 	}
 
-	prefix := refernce[0:prefixLength]
+	prefix := append(types.Path{}, refernce[0:prefixLength]...)
 
 	_, suffix, err := DecodePath(pathParams, encRelPath[prefixLengthWidth:])
 	if err != nil {
@@ -214,24 +213,22 @@ func DecodeRelPathStream[T constraints.Unsigned](
 	accumulatedBytes := bytes.NextAbsolute(prefixLengthWidth)
 
 	prefixLength, _ := DecodeIntMax32(accumulatedBytes[0:prefixLengthWidth], pathParams.MaxComponentCount)
-	prefix := reference[0:prefixLength]
 	bytes.Prune(prefixLengthWidth)
 
 	suffix := DecodePathStream(pathParams, bytes)
 
-	return append(prefix, suffix...)
+	// Appending to the reference itself would overwrite the components following the prefix
+	path := make(types.Path, 0, int(prefixLength)+len(suffix))
+	path = append(path, reference[0:prefixLength]...)
+	return append(path, suffix...)
 }
 
 func EncodePathRelativeLength[T constraints.Unsigned](pathParams types.PathParams[T], primary types.Path, refernce types.Path) int {
-	longestPrefix, err := CommonPrefix(primary, refernce)
-	if err != nil {
-		log.Fatalf("Error: %s", err)
-	}
+	longestPrefix, _ := CommonPrefix(primary, refernce)
 	longestPrefixLength := len(longestPrefix)
 	prefixLengthLength := GetWidthMax32Int(pathParams.MaxComponentCount)
 	suffix := primary[longestPrefixLength:]
-	suffixLength := len(suffix)
-	return prefixLengthLength + suffixLength
+	return prefixLengthLength + int(EncodePathLength(pathParams, suffix))
 }
 
 // PathDistance calculates the distance between two paths
@@ -206,6 +206,16 @@ func AbsDiffuint64(a uint64, b uint64) uint64 {
 	}
 }
 
+/*
+Encodes a Range3d relative to a reference Range3d. Every bound is expressed through whichever bound of the
+reference it is closest to, open ends of the reference are never referred to.
+
+The first byte holds how the subspace start (bits 0-1) and end (bits 2-3) are encoded, whether the path start
+is relative to the start of the reference (bit 4), whether the path end is open (bit 5) or relative to the
+start of the reference (bit 6) and whether the time range is open (bit 7). The second byte holds, for the start
+(bits 8-11) and end (bits 12-15) of the time range, whether it is relative to the start of the reference,
+whether the difference is added and the compact width of the difference.
+*/
 func EncodeRange3dRelative[T constraints.Unsigned](opts struct {
 	OrderSubspace    types.TotalOrder[types.SubspaceId]
 	EncodeSubspaceId func(subspace types.SubspaceId) []byte
@@ -214,164 +224,121 @@ func EncodeRange3dRelative[T constraints.Unsigned](opts struct {
 	r types.Range3d,
 	ref types.Range3d,
 ) []byte {
-	start_to_start := AbsDiffuint64(r.TimeRange.Start, ref.TimeRange.Start)
+	var encoding1, encoding2 byte
 
-	if ref.TimeRange.OpenEnd {
-		ref.TimeRange.End = math.MaxUint64
-		if r.TimeRange.OpenEnd {
-			r.TimeRange.End = math.MaxUint64
-		}
-	}
-
-	start_to_end := AbsDiffuint64(r.TimeRange.Start, ref.TimeRange.End)
-	end_to_start := AbsDiffuint64(r.TimeRange.End, ref.TimeRange.Start)
-	end_to_end := AbsDiffuint64(r.TimeRange.End, ref.TimeRange.End)
-	start_time_diff := min(start_to_start, start_to_end)
-	end_time_diff := min(end_to_start, end_to_end)
-
-	var encoding1 byte = 0x00
-	var encoding2 byte = 0x00
-
-	var subspaceIdEncodingStart []byte
-	var subspaceIdEncodingEnd []byte
+	var subspaceStartEncoded, subspaceEndEncoded []byte
+	var pathStartEncoded, pathEndEncoded []byte
+	var timeStartEncoded, timeEndEncoded []byte
 
-	var pathEncodingStart []byte
-	var pathEncodingEnd []byte
-
-	// Encode byte 1
-
-	// encoding bits 0, 1
-	if IsEqualRangeValue(opts.OrderSubspace, r.SubspaceRange, true, ref.SubspaceRange, true) {
-		encoding1 = encoding1 | 0x40
-	} else if IsEqualRangeValue(opts.OrderSubspace, r.SubspaceRange, true, ref.SubspaceRange, false) {
-		encoding1 = encoding1 | 0x80
+	// Subspace start, bits 0 and 1
+	if opts.OrderSubspace(r.SubspaceRange.Start, ref.SubspaceRange.Start) == 0 {
+		encoding1 |= 0x40
+	} else if !ref.SubspaceRange.OpenEnd && opts.OrderSubspace(r.SubspaceRange.Start, ref.SubspaceRange.End) == 0 {
+		encoding1 |= 0x80
 	} else {
-		encoding1 = encoding1 | 0xC0
-		subspaceIdEncodingStart = opts.EncodeSubspaceId(r.SubspaceRange.Start)
+		encoding1 |= 0xc0
+		subspaceStartEncoded = opts.EncodeSubspaceId(r.SubspaceRange.Start)
 	}
 
-	// eoncoding bits at 2, 3
-	if r.SubspaceRange.OpenEnd {
-		// do nothing
-	} else if IsEqualRangeValue(opts.OrderSubspace, r.SubspaceRange, false, ref.SubspaceRange, true) {
-		encoding1 = encoding2 | 0x10
-	} else if IsEqualRangeValue(opts.OrderSubspace, r.SubspaceRange, false, ref.SubspaceRange, false) {
-		encoding1 = encoding1 | 0x20
-	} else {
-		encoding1 = encoding1 | 0x30
-		subspaceIdEncodingEnd = opts.EncodeSubspaceId(r.SubspaceRange.End)
+	// Subspace end, bits 2 and 3, left at zero when open
+	if !r.SubspaceRange.OpenEnd {
+		if opts.OrderSubspace(r.SubspaceRange.End, ref.SubspaceRange.Start) == 0 {
+			encoding1 |= 0x10
+		} else if !ref.SubspaceRange.OpenEnd && opts.OrderSubspace(r.SubspaceRange.End, ref.SubspaceRange.End) == 0 {
+			encoding1 |= 0x20
+		} else {
+			encoding1 |= 0x30
+			subspaceEndEncoded = opts.EncodeSubspaceId(r.SubspaceRange.End)
+		}
 	}
 
-	// encoding bit 4
-	prefixStartStart, _ := CommonPrefix(r.PathRange.Start, ref.PathRange.Start)
-	prefixStartEnd, _ := CommonPrefix(r.PathRange.Start, ref.PathRange.End)
-
-	if len(prefixStartStart) >= len(prefixStartEnd) {
-		encoding1 = encoding1 | 0x08
-		pathEncodingStart = EncodeRelativePath(opts.PathScheme, r.PathRange.Start, ref.PathRange.Start)
+	// Path start, bit 4
+	if pathCloserToStart(r.PathRange.Start, ref.PathRange) {
+		encoding1 |= 0x08
+		pathStartEncoded = EncodeRelativePath(opts.PathScheme, r.PathRange.Start, ref.PathRange.Start)
 	} else {
-		pathEncodingStart = EncodeRelativePath(opts.PathScheme, r.PathRange.Start, ref.PathRange.End)
+		pathStartEncoded = EncodeRelativePath(opts.PathScheme, r.PathRange.Start, ref.PathRange.End)
 	}
 
-	// encoding bit 5
+	// Path end, bits 5 and 6
 	if r.PathRange.OpenEnd {
-		encoding1 = encoding1 | 0x04
-	}
-
-	// encoding bit 6
-	prefixEndStart, _ := CommonPrefix(r.PathRange.End, ref.PathRange.Start)
-	prefixEndEnd, _ := CommonPrefix(r.PathRange.End, ref.PathRange.End)
-	if len(prefixEndStart) >= len(prefixEndEnd) {
-		encoding1 = encoding1 | 0x02
-		pathEncodingEnd = EncodeRelativePath(opts.PathScheme, r.PathRange.End, ref.PathRange.Start)
+		encoding1 |= 0x04
+	} else if pathCloserToStart(r.PathRange.End, ref.PathRange) {
+		encoding1 |= 0x02
+		pathEndEncoded = EncodeRelativePath(opts.PathScheme, r.PathRange.End, ref.PathRange.Start)
 	} else {
-		pathEncodingEnd = EncodeRelativePath(opts.PathScheme, r.PathRange.End, ref.PathRange.End)
-	}
-	if r.PathRange.OpenEnd {
-		encoding1 = encoding1 & 0xFD
+		pathEndEncoded = EncodeRelativePath(opts.PathScheme, r.PathRange.End, ref.PathRange.End)
 	}
 
-	// encoding big 7
+	// Time end open, bit 7
 	if r.TimeRange.OpenEnd {
-		encoding1 = encoding1 | 0x01
+		encoding1 |= 0x01
 	}
 
-	// encoding byte 2
-
-	// encoding bit 8 (0)
-	if start_to_start <= start_to_end {
-		encoding2 = encoding2 | 0x80
+	// Time start, bits 8 to 11
+	relativeToStart, add, diff := timeDifference(r.TimeRange.Start, ref.TimeRange)
+	if relativeToStart {
+		encoding2 |= 0x80
 	}
+	if add {
+		encoding2 |= 0x40
+	}
+	encoding2 |= byte(CompactWidthEndMasks[GetWidthMax64Int(diff)] << 4)
+	timeStartEncoded = EncodeIntMax64(diff)
 
-	// encoding bit 9 (1)
-	if (encoding2 & 0x80) == 0x80 {
-		if r.TimeRange.Start >= ref.TimeRange.Start {
-			encoding2 = encoding2 | 0x40
+	// Time end, bits 12 to 15, left at zero when open
+	if !r.TimeRange.OpenEnd {
+		relativeToStart, add, diff := timeDifference(r.TimeRange.End, ref.TimeRange)
+		if relativeToStart {
+			encoding2 |= 0x08
 		}
-	} else {
-		if r.TimeRange.Start >= ref.TimeRange.End {
-			encoding2 = encoding2 | 0x40
+		if add {
+			encoding2 |= 0x04
 		}
+		encoding2 |= byte(CompactWidthEndMasks[GetWidthMax64Int(diff)])
+		timeEndEncoded = EncodeIntMax64(diff)
 	}
 
-	// encoding bit 10, 11 (2,3)
-	_compactWidth := GetWidthMax64Int(start_time_diff)
-
-	switch _compactWidth {
-	case 2:
-		encoding2 = encoding2 | 0x10
-	case 4:
-		encoding2 = encoding2 | 0x20
-	case 8:
-		encoding2 = encoding2 | 0x30
-	}
+	return concat(
+		[]byte{encoding1, encoding2},
+		subspaceStartEncoded,
+		subspaceEndEncoded,
+		pathStartEncoded,
+		pathEndEncoded,
+		timeStartEncoded,
+		timeEndEncoded,
+	)
+}
 
-	// encoding bit 12 (4)
-	if end_to_start <= end_to_end {
-		encoding2 = encoding2 | 0x08
-	}
-	// encoding bit 13 (5)
-	if (encoding2 & 0x08) == 0x08 {
-		if r.TimeRange.End >= ref.TimeRange.Start {
-			encoding2 = encoding2 | 0x04
-		}
-	} else {
-		if r.TimeRange.End >= ref.TimeRange.End {
-			encoding2 = encoding2 | 0x04
-		}
+// Whether a path shares at least as long a prefix with the start of a range as with its (closed) end
+func pathCloserToStart(path types.Path, ref types.Range[types.Path]) bool {
+	if ref.OpenEnd {
+		return true
 	}
+	prefixStart, _ := CommonPrefix(path, ref.Start)
+	prefixEnd, _ := CommonPrefix(path, ref.End)
+	return len(prefixStart) >= len(prefixEnd)
+}
 
-	// encoding bit 14, 15 (6,7)
-	switch GetWidthMax64Int(end_time_diff) {
-	case 2:
-		encoding2 = encoding2 | 0x01
-	case 4:
-		encoding2 = encoding2 | 0x02
-	case 8:
-		encoding2 = encoding2 | 0x03
+// Expresses a timestamp through the closest (closed) bound of a time range
+func timeDifference(time uint64, ref types.Range[uint64]) (relativeToStart bool, add bool, diff uint64) {
+	reference := ref.Start
+	relativeToStart = true
+	if !ref.OpenEnd && AbsDiffuint64(time, ref.End) < AbsDiffuint64(time, ref.Start) {
+		reference = ref.End
+		relativeToStart = false
 	}
+	return relativeToStart, time >= reference, AbsDiffuint64(time, reference)
+}
 
-	var end_time_diff_encoding []byte
-	if !r.TimeRange.OpenEnd && ref.TimeRange.OpenEnd {
-		end_time_diff_encoding = EncodeIntMax64(end_time_diff)
-	} else {
-		end_time_diff_encoding = EncodeIntMax64(end_to_start)
+func applyTimeDifference(reference uint64, add bool, diff uint64) uint64 {
+	if add {
+		return reference + diff
 	}
-	// remaining encoding information ->
-	start_time_diff_encoding := EncodeIntMax64(start_time_diff)
-
-	EncodedArray := concat(
-		[]byte{encoding1, encoding2},
-		subspaceIdEncodingStart,
-		subspaceIdEncodingEnd,
-		pathEncodingStart,
-		pathEncodingEnd,
-		start_time_diff_encoding,
-		end_time_diff_encoding,
-	)
-	return EncodedArray
+	return reference - diff
 }
 
+// Decodes a Range3d encoded with EncodeRange3dRelative from GrowingBytes, consuming exactly the encoded bytes.
 func DecodeStreamRange3dRelative[K constraints.Unsigned](
 	DecodeStreamSubspaceId func(bytes *GrowingBytes) chan types.SubspaceId,
 	pathScheme types.PathParams[K],
@@ -379,193 +346,97 @@ func DecodeStreamRange3dRelative[K constraints.Unsigned](
 	ref types.Range3d,
 ) (types.Range3d, error) {
 	accumulatedBytes := bytes.NextAbsolute(2)
-	firstByte, secondByte := accumulatedBytes[0], accumulatedBytes[1]
-
-	var isSubspaceStartEncoded string
-
-	switch true {
-	case (firstByte & 0xc0) == 0xc0:
-		isSubspaceStartEncoded = "yes"
-	case (firstByte & 0x80) == 0x80:
-		isSubspaceStartEncoded = "ref_end"
-	case (firstByte & 0x40) == 0x40:
-		isSubspaceStartEncoded = "ref_start"
-	default:
-		isSubspaceStartEncoded = "invalid"
+	if len(accumulatedBytes) < 2 {
+		return types.Range3d{}, fmt.Errorf("not enough bytes to decode a range")
 	}
-	if isSubspaceStartEncoded == "invalid" {
-		return types.Range3d{}, fmt.Errorf("invalid subspace")
-	}
-
-	var isSubspaceEndEncoded string
-
-	switch true {
-	case (firstByte & 0x30) == 0x30:
-		isSubspaceStartEncoded = "yes"
-	case (firstByte & 0x20) == 0x20:
-		isSubspaceStartEncoded = "ref_end"
-	case (firstByte & 0x10) == 0x10:
-		isSubspaceStartEncoded = "ref_start"
-	default:
-		isSubspaceStartEncoded = "open"
-	}
-
-	isPathStartRelativeToRefStart := (firstByte & 0x8) == 0x8
-
-	isRangePathEndOpen := (firstByte & 0x4) == 0x40
-
-	isPathEndEncodedRelToRefStart := (firstByte & 0x2) == 0x2
-
-	isTimeEndOpen := (firstByte & 0x1) == 0x1
-
-	encodeTimeStartRelToRefTimeStart := (secondByte & 0x80) == 0x80
-
-	addStartTimeDiff := (secondByte & 0x40) == 0x40
-
-	compactWidthStartTimeDiff := math.Pow(2, float64((secondByte&0x30)>>4))
-
-	encodeTimeEndRelToRefStart := (secondByte & 0x8) == 0x8
-
-	addEndTimeDiff := (secondByte & 0x4) == 0x4
-
-	compactWidthEndTimeDiff := math.Pow(2, float64(secondByte&0x3))
-
+	firstByte, secondByte := accumulatedBytes[0], accumulatedBytes[1]
 	bytes.Prune(2)
 
-	var subspaceStart types.SubspaceId
+	var decoded types.Range3d
 
-	switch isSubspaceStartEncoded {
-	case "ref_start":
-		subspaceStart = ref.SubspaceRange.Start
-	case "ref_end":
-		if !ref.SubspaceRange.OpenEnd {
-			subspaceStart = ref.SubspaceRange.End
-		} else {
-			return types.Range3d{}, fmt.Errorf("start value cannot be open ended")
+	switch firstByte & 0xc0 {
+	case 0x40:
+		decoded.SubspaceRange.Start = ref.SubspaceRange.Start
+	case 0x80:
+		if ref.SubspaceRange.OpenEnd {
+			return types.Range3d{}, fmt.Errorf("the subspace start cannot be encoded relative to an open end")
 		}
-	case "yes":
-		subspaceStart = <-DecodeStreamSubspaceId(bytes)
+		decoded.SubspaceRange.Start = ref.SubspaceRange.End
+	case 0xc0:
+		decoded.SubspaceRange.Start = <-DecodeStreamSubspaceId(bytes)
+	default:
+		return types.Range3d{}, fmt.Errorf("invalid subspace start encoding")
 	}
 
-	var subspaceEnd types.SubspaceId
-	var subspaceOpenEnd bool
-
-	switch isSubspaceEndEncoded {
-	case "open":
-		subspaceEnd = subspaceStart
-		subspaceOpenEnd = true
-	case "ref_start":
-		subspaceEnd = ref.SubspaceRange.Start
-		subspaceOpenEnd = false
-	case "ref_end":
-		subspaceEnd = ref.SubspaceRange.End
-		subspaceOpenEnd = false
-	case "yes":
-		subspaceEnd = <-DecodeStreamSubspaceId(bytes)
-		subspaceOpenEnd = false
+	switch firstByte & 0x30 {
+	case 0x00:
+		decoded.SubspaceRange.OpenEnd = true
+	case 0x10:
+		decoded.SubspaceRange.End = ref.SubspaceRange.Start
+	case 0x20:
+		if ref.SubspaceRange.OpenEnd {
+			return types.Range3d{}, fmt.Errorf("the subspace end cannot be encoded relative to an open end")
+		}
+		decoded.SubspaceRange.End = ref.SubspaceRange.End
+	case 0x30:
+		decoded.SubspaceRange.End = <-DecodeStreamSubspaceId(bytes)
 	}
 
-	var pathStart types.Path
-
-	if isPathStartRelativeToRefStart {
-		pathStart = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.Start)
+	if (firstByte & 0x08) == 0x08 {
+		decoded.PathRange.Start = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.Start)
 	} else {
 		if ref.PathRange.OpenEnd {
 			return types.Range3d{}, fmt.Errorf("the start of a path range cannot be encoded relative to an open end")
 		}
-		pathStart = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.End)
+		decoded.PathRange.Start = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.End)
 	}
 
-	var pathEnd types.Path
-	var pathOpenEnd bool
-
-	if isRangePathEndOpen {
-		pathEnd = types.Path{}
-		pathOpenEnd = true
-	} else if isPathEndEncodedRelToRefStart {
-		pathEnd = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.Start)
+	if (firstByte & 0x04) == 0x04 {
+		decoded.PathRange.OpenEnd = true
+	} else if (firstByte & 0x02) == 0x02 {
+		decoded.PathRange.End = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.Start)
 	} else {
 		if ref.PathRange.OpenEnd {
 			return types.Range3d{}, fmt.Errorf("the end of a path range cannot be encoded relative to an open end")
 		}
-		pathEnd = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.End)
-	}
-	accumulatedBytes = bytes.NextAbsolute(int(compactWidthStartTimeDiff))
-
-	startTimeDiff, err := DecodeIntMax64(accumulatedBytes[0:int(compactWidthStartTimeDiff)])
-	if err != nil {
-		return types.Range3d{}, fmt.Errorf("could not decode startTime")
+		decoded.PathRange.End = DecodeRelPathStream(pathScheme, bytes, ref.PathRange.End)
 	}
 
-	bytes.Prune(int(compactWidthStartTimeDiff))
-
-	var timeStart uint64
+	decodeTime := func(relativeToStart, add bool, width int) (uint64, error) {
+		accumulatedBytes := bytes.NextAbsolute(width)
+		if len(accumulatedBytes) < width {
+			return 0, fmt.Errorf("not enough bytes to decode a time difference")
+		}
+		diff, err := DecodeIntMax64(accumulatedBytes[:width])
+		if err != nil {
+			return 0, err
+		}
+		bytes.Prune(width)
 
-	if encodeTimeStartRelToRefTimeStart {
-		if addStartTimeDiff {
-			timeStart = ref.TimeRange.Start + uint64(startTimeDiff)
-		} else {
-			timeStart = ref.TimeRange.Start - uint64(startTimeDiff)
+		if relativeToStart {
+			return applyTimeDifference(ref.TimeRange.Start, add, diff), nil
 		}
-	} else {
 		if ref.TimeRange.OpenEnd {
-			return types.Range3d{}, fmt.Errorf("the start of a time cannot be open ended")
-		}
-		if addStartTimeDiff {
-			timeStart = ref.TimeRange.End + uint64(startTimeDiff)
-		} else {
-			timeStart = ref.TimeRange.End - uint64(startTimeDiff)
+			return 0, fmt.Errorf("a time cannot be encoded relative to an open end")
 		}
+		return applyTimeDifference(ref.TimeRange.End, add, diff), nil
 	}
-	var timeEnd uint64
-	var timeOpenEnd bool
 
-	if isTimeEndOpen {
-		timeOpenEnd = true
-		timeEnd = timeStart
+	var err error
+	decoded.TimeRange.Start, err = decodeTime((secondByte&0x80) == 0x80, (secondByte&0x40) == 0x40, 1<<((secondByte&0x30)>>4))
+	if err != nil {
+		return types.Range3d{}, err
+	}
+	if (firstByte & 0x01) == 0x01 {
+		decoded.TimeRange.OpenEnd = true
 	} else {
-		accumulatedBytes = bytes.NextAbsolute(int(compactWidthEndTimeDiff))
-
-		endTimeDiff, err := DecodeIntMax64(accumulatedBytes[0:int(compactWidthEndTimeDiff)])
+		decoded.TimeRange.End, err = decodeTime((secondByte&0x08) == 0x08, (secondByte&0x04) == 0x04, 1<<(secondByte&0x03))
 		if err != nil {
-			return types.Range3d{}, fmt.Errorf("could not decode end time difference")
-		}
-
-		if encodeTimeEndRelToRefStart {
-			if addEndTimeDiff {
-				timeEnd = ref.TimeRange.End + uint64(endTimeDiff)
-			} else {
-				timeEnd = ref.TimeRange.End - uint64(endTimeDiff)
-			}
-		} else {
-			if ref.TimeRange.OpenEnd {
-				return types.Range3d{}, fmt.Errorf("end of timerange cannot be encoded relative to open end")
-			}
-			if addEndTimeDiff {
-				timeEnd = ref.TimeRange.End + uint64(endTimeDiff)
-			} else {
-				timeEnd = ref.TimeRange.End - uint64(endTimeDiff)
-			}
+			return types.Range3d{}, err
 		}
 	}
-	return types.Range3d{
-		SubspaceRange: types.Range[types.SubspaceId]{
-			Start:   subspaceStart,
-			End:     subspaceEnd,
-			OpenEnd: subspaceOpenEnd,
-		},
-		PathRange: types.Range[types.Path]{
-			Start:   pathStart,
-			End:     pathEnd,
-			OpenEnd: pathOpenEnd,
-		},
-		TimeRange: types.Range[uint64]{
-			Start:   timeStart,
-			End:     timeEnd,
-			OpenEnd: timeOpenEnd,
-		},
-	}, nil
 
+	return decoded, nil
 }
 
 /** Decode an {@linkcode Range3d} relative to another `Range3d` from {@linkcode GrowingBytes}. */